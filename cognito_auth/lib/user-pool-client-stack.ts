@@ -21,7 +21,8 @@ export class UserPoolClientStack extends cdk.Stack {
 		const client = new cognito.UserPoolClient(this, "ServerClient", {
 			userPool: props.userPool,
 			authFlows: {
-				userSrp: true // ???
+				userSrp: true, // ???
+				userPassword: true, // server-side login with InitiateAuth
 			},
 		});
 
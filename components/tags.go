@@ -0,0 +1,37 @@
+package components
+
+import (
+	"context"
+	"github.com/a-h/templ"
+	"htmxtodo/gen/htmxtodo_dev/public/model"
+	"io"
+	"strconv"
+)
+
+// TagChip shows a tag in its color. It is written by hand because templ does not allow
+// expressions in style attributes; tag colors are validated #rrggbb when tags are saved,
+// so they are safe to use there.
+func TagChip(tag model.Tag) templ.Component {
+	return templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+		style := "background-color: " + tag.Color + "; color: " + tagTextColor(tag.Color) + ";"
+		_, err := io.WriteString(w, `<span class="tag" style="`+templ.EscapeString(style)+`">`+
+			templ.EscapeString(tag.Name)+`</span>`)
+		return err
+	})
+}
+
+// tagTextColor is dark or light, whichever reads better on a tag's color.
+func tagTextColor(color string) string {
+	if len(color) != 7 {
+		return "#ffffff"
+	}
+
+	r, _ := strconv.ParseUint(color[1:3], 16, 8)
+	g, _ := strconv.ParseUint(color[3:5], 16, 8)
+	b, _ := strconv.ParseUint(color[5:7], 16, 8)
+	// perceived brightness, as in the W3C's color contrast guidance
+	if (r*299+g*587+b*114)/1000 > 150 {
+		return "#000000"
+	}
+	return "#ffffff"
+}
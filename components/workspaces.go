@@ -0,0 +1,21 @@
+package components
+
+import (
+	"context"
+	"github.com/a-h/templ"
+	"htmxtodo/internal/repo"
+	"htmxtodo/internal/view"
+	"io"
+)
+
+// WorkspaceSwitcher lets the user switch to any of their workspaces. It is written by hand
+// so that the workspaces are only loaded for pages that show it.
+func WorkspaceSwitcher(active *repo.UserWorkspace) templ.Component {
+	return templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+		workspaces, err := view.Workspaces(ctx)
+		if err != nil {
+			return err
+		}
+		return workspaceSwitcher(active, workspaces).Render(ctx, w)
+	})
+}
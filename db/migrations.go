@@ -0,0 +1,8 @@
+package db
+
+import "embed"
+
+// Migrations are the dbmate migrations, for tests that build a schema of their own.
+//
+//go:embed migrations/*.sql
+var Migrations embed.FS
@@ -0,0 +1,31 @@
+-- migrate:up
+CREATE TABLE app_user
+(
+	id                  BIGSERIAL PRIMARY KEY,
+	email               VARCHAR(255) NOT NULL,
+	role                VARCHAR(32)  NOT NULL DEFAULT 'user',
+	disabled_at         TIMESTAMPTZ,
+	sessions_revoked_at TIMESTAMPTZ,
+	created_at          TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+	updated_at          TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+
+	UNIQUE (email),
+	CHECK (role IN ('user', 'admin'))
+);
+
+CREATE TABLE audit_event
+(
+	id          BIGSERIAL PRIMARY KEY,
+	actor_id    BIGINT REFERENCES app_user (id),
+	action      VARCHAR(64) NOT NULL,
+	entity_type VARCHAR(64) NOT NULL,
+	entity_id   BIGINT      NOT NULL,
+	details     JSONB,
+	created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX audit_event_created_at_idx ON audit_event (created_at);
+
+-- migrate:down
+DROP TABLE audit_event;
+DROP TABLE app_user;
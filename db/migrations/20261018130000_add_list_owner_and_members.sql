@@ -0,0 +1,53 @@
+-- migrate:up
+ALTER TABLE list ADD COLUMN owner_id BIGINT REFERENCES app_user (id);
+
+-- Lists created before ownership existed go to the first user, or to a placeholder user if
+-- there is none yet. An admin can impersonate it to transfer the lists to their owners.
+INSERT INTO app_user (email)
+SELECT 'legacy-lists@htmxtodo.invalid'
+WHERE EXISTS (SELECT FROM list)
+  AND NOT EXISTS (SELECT FROM app_user);
+
+UPDATE list
+SET owner_id = (SELECT MIN(id) FROM app_user)
+WHERE owner_id IS NULL;
+
+ALTER TABLE list ALTER COLUMN owner_id SET NOT NULL;
+ALTER TABLE list DROP CONSTRAINT list_name_key;
+ALTER TABLE list ADD CONSTRAINT list_owner_id_name_key UNIQUE (owner_id, name);
+
+CREATE TABLE list_member
+(
+	list_id    BIGINT      NOT NULL REFERENCES list (id) ON DELETE CASCADE,
+	user_id    BIGINT      NOT NULL REFERENCES app_user (id) ON DELETE CASCADE,
+	permission VARCHAR(32) NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+	PRIMARY KEY (list_id, user_id),
+	CHECK (permission IN ('viewer', 'editor'))
+);
+
+CREATE INDEX list_member_user_id_idx ON list_member (user_id);
+
+-- Invitations for emails without an account yet, accepted on their first login.
+CREATE TABLE list_invitation
+(
+	id            BIGSERIAL PRIMARY KEY,
+	list_id       BIGINT       NOT NULL REFERENCES list (id) ON DELETE CASCADE,
+	email         VARCHAR(255) NOT NULL,
+	permission    VARCHAR(32)  NOT NULL,
+	invited_by_id BIGINT       NOT NULL REFERENCES app_user (id),
+	created_at    TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+
+	UNIQUE (list_id, email),
+	CHECK (permission IN ('viewer', 'editor'))
+);
+
+CREATE INDEX list_invitation_email_idx ON list_invitation (email);
+
+-- migrate:down
+DROP TABLE list_invitation;
+DROP TABLE list_member;
+ALTER TABLE list DROP CONSTRAINT list_owner_id_name_key;
+ALTER TABLE list ADD CONSTRAINT list_name_key UNIQUE (name);
+ALTER TABLE list DROP COLUMN owner_id;
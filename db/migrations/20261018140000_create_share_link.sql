@@ -0,0 +1,18 @@
+-- migrate:up
+CREATE TABLE share_link
+(
+	id            BIGSERIAL PRIMARY KEY,
+	list_id       BIGINT      NOT NULL REFERENCES list (id) ON DELETE CASCADE,
+	token         VARCHAR(64) NOT NULL,
+	created_by_id BIGINT      NOT NULL REFERENCES app_user (id),
+	expires_at    TIMESTAMPTZ,
+	revoked_at    TIMESTAMPTZ,
+	created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+	UNIQUE (token)
+);
+
+CREATE INDEX share_link_list_id_idx ON share_link (list_id);
+
+-- migrate:down
+DROP TABLE share_link;
@@ -0,0 +1,54 @@
+-- migrate:up
+-- Personal workspaces have the user they belong to in personal_user_id.
+CREATE TABLE workspace
+(
+	id               BIGSERIAL PRIMARY KEY,
+	name             VARCHAR(255) NOT NULL,
+	personal_user_id BIGINT REFERENCES app_user (id) ON DELETE CASCADE,
+	created_at       TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+	updated_at       TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+
+	UNIQUE (personal_user_id)
+);
+
+CREATE TABLE workspace_member
+(
+	workspace_id BIGINT      NOT NULL REFERENCES workspace (id) ON DELETE CASCADE,
+	user_id      BIGINT      NOT NULL REFERENCES app_user (id) ON DELETE CASCADE,
+	role         VARCHAR(32) NOT NULL,
+	created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+	PRIMARY KEY (workspace_id, user_id),
+	CHECK (role IN ('owner', 'admin', 'member'))
+);
+
+CREATE INDEX workspace_member_user_id_idx ON workspace_member (user_id);
+
+-- every existing user gets a personal workspace holding the lists they own, which is every
+-- list, as lists without an owner were given one when ownership was added
+INSERT INTO workspace (name, personal_user_id)
+SELECT 'Personal', id
+FROM app_user;
+
+INSERT INTO workspace_member (workspace_id, user_id, role)
+SELECT id, personal_user_id, 'owner'
+FROM workspace;
+
+ALTER TABLE list ADD COLUMN workspace_id BIGINT REFERENCES workspace (id);
+
+UPDATE list
+SET workspace_id = workspace.id
+FROM workspace
+WHERE workspace.personal_user_id = list.owner_id;
+
+ALTER TABLE list ALTER COLUMN workspace_id SET NOT NULL;
+
+ALTER TABLE list DROP CONSTRAINT list_owner_id_name_key;
+ALTER TABLE list ADD CONSTRAINT list_workspace_id_name_key UNIQUE (workspace_id, name);
+
+-- migrate:down
+ALTER TABLE list DROP CONSTRAINT list_workspace_id_name_key;
+ALTER TABLE list ADD CONSTRAINT list_owner_id_name_key UNIQUE (owner_id, name);
+ALTER TABLE list DROP COLUMN workspace_id;
+DROP TABLE workspace_member;
+DROP TABLE workspace;
@@ -0,0 +1,16 @@
+-- migrate:up
+ALTER TABLE list
+	ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (to_tsvector('english', name)) STORED;
+
+ALTER TABLE item
+	ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (to_tsvector('english', name)) STORED;
+
+CREATE INDEX list_search_vector_idx ON list USING gin (search_vector);
+CREATE INDEX item_search_vector_idx ON item USING gin (search_vector);
+
+-- migrate:down
+DROP INDEX item_search_vector_idx;
+DROP INDEX list_search_vector_idx;
+
+ALTER TABLE item DROP COLUMN search_vector;
+ALTER TABLE list DROP COLUMN search_vector;
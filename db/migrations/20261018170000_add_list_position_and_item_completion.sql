@@ -0,0 +1,23 @@
+-- migrate:up
+ALTER TABLE item ADD COLUMN completed_at TIMESTAMPTZ;
+
+-- lists start out in their custom order by name
+ALTER TABLE list ADD COLUMN position INTEGER NOT NULL DEFAULT 0;
+
+UPDATE list
+SET position = ordered.position
+FROM (SELECT id, row_number() OVER (PARTITION BY workspace_id ORDER BY name, id) AS position FROM list) ordered
+WHERE list.id = ordered.id;
+
+-- keyset pagination indexes, one per sort order
+CREATE INDEX list_workspace_id_created_at_idx ON list (workspace_id, created_at, id);
+CREATE INDEX list_workspace_id_updated_at_idx ON list (workspace_id, updated_at, id);
+CREATE INDEX list_workspace_id_position_idx ON list (workspace_id, position, id);
+
+-- migrate:down
+DROP INDEX list_workspace_id_position_idx;
+DROP INDEX list_workspace_id_updated_at_idx;
+DROP INDEX list_workspace_id_created_at_idx;
+
+ALTER TABLE list DROP COLUMN position;
+ALTER TABLE item DROP COLUMN completed_at;
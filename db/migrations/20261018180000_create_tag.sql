@@ -0,0 +1,38 @@
+-- migrate:up
+-- Tags belong to a workspace, and colors are #rrggbb.
+CREATE TABLE tag
+(
+	id           BIGSERIAL PRIMARY KEY,
+	workspace_id BIGINT      NOT NULL REFERENCES workspace (id) ON DELETE CASCADE,
+	name         VARCHAR(64) NOT NULL,
+	color        VARCHAR(7)  NOT NULL,
+	created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	updated_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+	UNIQUE (workspace_id, name)
+);
+
+CREATE TABLE list_tag
+(
+	list_id BIGINT NOT NULL REFERENCES list (id) ON DELETE CASCADE,
+	tag_id  BIGINT NOT NULL REFERENCES tag (id) ON DELETE CASCADE,
+
+	PRIMARY KEY (list_id, tag_id)
+);
+
+CREATE INDEX list_tag_tag_id_idx ON list_tag (tag_id);
+
+CREATE TABLE item_tag
+(
+	item_id BIGINT NOT NULL REFERENCES item (id) ON DELETE CASCADE,
+	tag_id  BIGINT NOT NULL REFERENCES tag (id) ON DELETE CASCADE,
+
+	PRIMARY KEY (item_id, tag_id)
+);
+
+CREATE INDEX item_tag_tag_id_idx ON item_tag (tag_id);
+
+-- migrate:down
+DROP TABLE item_tag;
+DROP TABLE list_tag;
+DROP TABLE tag;
@@ -0,0 +1,28 @@
+-- migrate:up
+ALTER TABLE list ADD COLUMN deleted_at TIMESTAMPTZ;
+ALTER TABLE item ADD COLUMN deleted_at TIMESTAMPTZ;
+
+-- lists in the trash don't keep their names from being reused
+ALTER TABLE list DROP CONSTRAINT list_workspace_id_name_key;
+CREATE UNIQUE INDEX list_workspace_id_name_key ON list (workspace_id, name) WHERE deleted_at IS NULL;
+
+-- for the trash and the job that empties it
+CREATE INDEX list_deleted_at_idx ON list (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX item_deleted_at_idx ON item (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- purging a list purges its items
+ALTER TABLE item DROP CONSTRAINT item_list_id_fkey;
+ALTER TABLE item ADD CONSTRAINT item_list_id_fkey FOREIGN KEY (list_id) REFERENCES list (id) ON DELETE CASCADE;
+
+-- migrate:down
+ALTER TABLE item DROP CONSTRAINT item_list_id_fkey;
+ALTER TABLE item ADD CONSTRAINT item_list_id_fkey FOREIGN KEY (list_id) REFERENCES list (id);
+
+DROP INDEX item_deleted_at_idx;
+DROP INDEX list_deleted_at_idx;
+
+DROP INDEX list_workspace_id_name_key;
+ALTER TABLE list ADD CONSTRAINT list_workspace_id_name_key UNIQUE (workspace_id, name);
+
+ALTER TABLE item DROP COLUMN deleted_at;
+ALTER TABLE list DROP COLUMN deleted_at;
@@ -0,0 +1,40 @@
+-- migrate:up
+-- what a change was made to, and how it was made, for list activity and the admin audit view
+ALTER TABLE audit_event
+    ADD COLUMN workspace_id BIGINT,
+    ADD COLUMN list_id BIGINT,
+    ADD COLUMN before JSONB,
+    ADD COLUMN after JSONB,
+    ADD COLUMN request_id VARCHAR(64);
+
+-- events outlive the lists and workspaces they describe, so these are not foreign keys
+CREATE INDEX audit_event_list_id_created_at_idx ON audit_event (list_id, created_at) WHERE list_id IS NOT NULL;
+CREATE INDEX audit_event_workspace_id_created_at_idx ON audit_event (workspace_id, created_at) WHERE workspace_id IS NOT NULL;
+CREATE INDEX audit_event_actor_id_idx ON audit_event (actor_id);
+
+CREATE FUNCTION audit_event_append_only() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_event is append-only';
+END;
+$$;
+
+CREATE TRIGGER audit_event_append_only
+    BEFORE UPDATE OR DELETE ON audit_event
+    FOR EACH ROW EXECUTE FUNCTION audit_event_append_only();
+
+-- migrate:down
+DROP TRIGGER audit_event_append_only ON audit_event;
+DROP FUNCTION audit_event_append_only();
+
+DROP INDEX audit_event_actor_id_idx;
+DROP INDEX audit_event_workspace_id_created_at_idx;
+DROP INDEX audit_event_list_id_created_at_idx;
+
+ALTER TABLE audit_event
+    DROP COLUMN request_id,
+    DROP COLUMN after,
+    DROP COLUMN before,
+    DROP COLUMN list_id,
+    DROP COLUMN workspace_id;
@@ -0,0 +1,8 @@
+-- migrate:up
+-- bumped by every update, so that edits made from a stale copy can be detected
+ALTER TABLE list ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE item ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+
+-- migrate:down
+ALTER TABLE item DROP COLUMN version;
+ALTER TABLE list DROP COLUMN version;
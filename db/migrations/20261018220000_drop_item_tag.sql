@@ -0,0 +1,14 @@
+-- migrate:up
+-- Only lists are tagged; nothing ever wrote item tags.
+DROP TABLE item_tag;
+
+-- migrate:down
+CREATE TABLE item_tag
+(
+	item_id BIGINT NOT NULL REFERENCES item (id) ON DELETE CASCADE,
+	tag_id  BIGINT NOT NULL REFERENCES tag (id) ON DELETE CASCADE,
+
+	PRIMARY KEY (item_id, tag_id)
+);
+
+CREATE INDEX item_tag_tag_id_idx ON item_tag (tag_id);
@@ -9,10 +9,97 @@ SET xmloption = content;
 SET client_min_messages = warning;
 SET row_security = off;
 
+--
+-- Name: audit_event_append_only(); Type: FUNCTION; Schema: public; Owner: -
+--
+
+CREATE FUNCTION public.audit_event_append_only() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_event is append-only';
+END;
+$$;
+
+
 SET default_tablespace = '';
 
 SET default_table_access_method = heap;
 
+--
+-- Name: app_user; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.app_user (
+    id bigint NOT NULL,
+    email character varying(255) NOT NULL,
+    role character varying(32) DEFAULT 'user'::character varying NOT NULL,
+    disabled_at timestamp with time zone,
+    sessions_revoked_at timestamp with time zone,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT app_user_role_check CHECK (((role)::text = ANY ((ARRAY['user'::character varying, 'admin'::character varying])::text[])))
+);
+
+
+--
+-- Name: app_user_id_seq; Type: SEQUENCE; Schema: public; Owner: -
+--
+
+CREATE SEQUENCE public.app_user_id_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+--
+-- Name: app_user_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: -
+--
+
+ALTER SEQUENCE public.app_user_id_seq OWNED BY public.app_user.id;
+
+
+--
+-- Name: audit_event; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.audit_event (
+    id bigint NOT NULL,
+    actor_id bigint,
+    action character varying(64) NOT NULL,
+    entity_type character varying(64) NOT NULL,
+    entity_id bigint NOT NULL,
+    details jsonb,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    workspace_id bigint,
+    list_id bigint,
+    before jsonb,
+    after jsonb,
+    request_id character varying(64)
+);
+
+
+--
+-- Name: audit_event_id_seq; Type: SEQUENCE; Schema: public; Owner: -
+--
+
+CREATE SEQUENCE public.audit_event_id_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+--
+-- Name: audit_event_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: -
+--
+
+ALTER SEQUENCE public.audit_event_id_seq OWNED BY public.audit_event.id;
+
+
 --
 -- Name: fiber_storage; Type: TABLE; Schema: public; Owner: -
 --
@@ -34,7 +121,11 @@ CREATE TABLE public.item (
     "position" integer NOT NULL,
     name character varying(255) NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
-    updated_at timestamp with time zone DEFAULT now() NOT NULL
+    updated_at timestamp with time zone DEFAULT now() NOT NULL,
+    search_vector tsvector GENERATED ALWAYS AS (to_tsvector('english'::regconfig, (name)::text)) STORED,
+    completed_at timestamp with time zone,
+    deleted_at timestamp with time zone,
+    version integer DEFAULT 1 NOT NULL
 );
 
 
@@ -65,7 +156,13 @@ CREATE TABLE public.list (
     id bigint NOT NULL,
     name character varying(255) NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
-    updated_at timestamp with time zone DEFAULT now() NOT NULL
+    updated_at timestamp with time zone DEFAULT now() NOT NULL,
+    owner_id bigint NOT NULL,
+    workspace_id bigint NOT NULL,
+    search_vector tsvector GENERATED ALWAYS AS (to_tsvector('english'::regconfig, (name)::text)) STORED,
+    "position" integer DEFAULT 0 NOT NULL,
+    deleted_at timestamp with time zone,
+    version integer DEFAULT 1 NOT NULL
 );
 
 
@@ -88,6 +185,63 @@ CREATE SEQUENCE public.list_id_seq
 ALTER SEQUENCE public.list_id_seq OWNED BY public.list.id;
 
 
+--
+-- Name: list_invitation; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.list_invitation (
+    id bigint NOT NULL,
+    list_id bigint NOT NULL,
+    email character varying(255) NOT NULL,
+    permission character varying(32) NOT NULL,
+    invited_by_id bigint NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT list_invitation_permission_check CHECK (((permission)::text = ANY ((ARRAY['viewer'::character varying, 'editor'::character varying])::text[])))
+);
+
+
+--
+-- Name: list_invitation_id_seq; Type: SEQUENCE; Schema: public; Owner: -
+--
+
+CREATE SEQUENCE public.list_invitation_id_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+--
+-- Name: list_invitation_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: -
+--
+
+ALTER SEQUENCE public.list_invitation_id_seq OWNED BY public.list_invitation.id;
+
+
+--
+-- Name: list_member; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.list_member (
+    list_id bigint NOT NULL,
+    user_id bigint NOT NULL,
+    permission character varying(32) NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT list_member_permission_check CHECK (((permission)::text = ANY ((ARRAY['viewer'::character varying, 'editor'::character varying])::text[])))
+);
+
+
+--
+-- Name: list_tag; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.list_tag (
+    list_id bigint NOT NULL,
+    tag_id bigint NOT NULL
+);
+
+
 --
 -- Name: schema_migrations; Type: TABLE; Schema: public; Owner: -
 --
@@ -97,6 +251,132 @@ CREATE TABLE public.schema_migrations (
 );
 
 
+--
+-- Name: share_link; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.share_link (
+    id bigint NOT NULL,
+    list_id bigint NOT NULL,
+    token character varying(64) NOT NULL,
+    created_by_id bigint NOT NULL,
+    expires_at timestamp with time zone,
+    revoked_at timestamp with time zone,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+--
+-- Name: share_link_id_seq; Type: SEQUENCE; Schema: public; Owner: -
+--
+
+CREATE SEQUENCE public.share_link_id_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+--
+-- Name: share_link_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: -
+--
+
+ALTER SEQUENCE public.share_link_id_seq OWNED BY public.share_link.id;
+
+
+--
+-- Name: tag; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.tag (
+    id bigint NOT NULL,
+    workspace_id bigint NOT NULL,
+    name character varying(64) NOT NULL,
+    color character varying(7) NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+--
+-- Name: tag_id_seq; Type: SEQUENCE; Schema: public; Owner: -
+--
+
+CREATE SEQUENCE public.tag_id_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+--
+-- Name: tag_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: -
+--
+
+ALTER SEQUENCE public.tag_id_seq OWNED BY public.tag.id;
+
+
+--
+-- Name: workspace; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.workspace (
+    id bigint NOT NULL,
+    name character varying(255) NOT NULL,
+    personal_user_id bigint,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+--
+-- Name: workspace_id_seq; Type: SEQUENCE; Schema: public; Owner: -
+--
+
+CREATE SEQUENCE public.workspace_id_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+--
+-- Name: workspace_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: -
+--
+
+ALTER SEQUENCE public.workspace_id_seq OWNED BY public.workspace.id;
+
+
+--
+-- Name: workspace_member; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.workspace_member (
+    workspace_id bigint NOT NULL,
+    user_id bigint NOT NULL,
+    role character varying(32) NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT workspace_member_role_check CHECK (((role)::text = ANY ((ARRAY['owner'::character varying, 'admin'::character varying, 'member'::character varying])::text[])))
+);
+
+
+--
+-- Name: app_user id; Type: DEFAULT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.app_user ALTER COLUMN id SET DEFAULT nextval('public.app_user_id_seq'::regclass);
+
+
+--
+-- Name: audit_event id; Type: DEFAULT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.audit_event ALTER COLUMN id SET DEFAULT nextval('public.audit_event_id_seq'::regclass);
+
+
 --
 -- Name: item id; Type: DEFAULT; Schema: public; Owner: -
 --
@@ -111,6 +391,58 @@ ALTER TABLE ONLY public.item ALTER COLUMN id SET DEFAULT nextval('public.item_id
 ALTER TABLE ONLY public.list ALTER COLUMN id SET DEFAULT nextval('public.list_id_seq'::regclass);
 
 
+--
+-- Name: list_invitation id; Type: DEFAULT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.list_invitation ALTER COLUMN id SET DEFAULT nextval('public.list_invitation_id_seq'::regclass);
+
+
+--
+-- Name: share_link id; Type: DEFAULT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.share_link ALTER COLUMN id SET DEFAULT nextval('public.share_link_id_seq'::regclass);
+
+
+--
+-- Name: tag id; Type: DEFAULT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.tag ALTER COLUMN id SET DEFAULT nextval('public.tag_id_seq'::regclass);
+
+
+--
+-- Name: workspace id; Type: DEFAULT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.workspace ALTER COLUMN id SET DEFAULT nextval('public.workspace_id_seq'::regclass);
+
+
+--
+-- Name: app_user app_user_email_key; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.app_user
+    ADD CONSTRAINT app_user_email_key UNIQUE (email);
+
+
+--
+-- Name: app_user app_user_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.app_user
+    ADD CONSTRAINT app_user_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: audit_event audit_event_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.audit_event
+    ADD CONSTRAINT audit_event_pkey PRIMARY KEY (id);
+
+
 --
 -- Name: fiber_storage fiber_storage_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -128,11 +460,35 @@ ALTER TABLE ONLY public.item
 
 
 --
--- Name: list list_name_key; Type: CONSTRAINT; Schema: public; Owner: -
+-- Name: list_invitation list_invitation_list_id_email_key; Type: CONSTRAINT; Schema: public; Owner: -
 --
 
-ALTER TABLE ONLY public.list
-    ADD CONSTRAINT list_name_key UNIQUE (name);
+ALTER TABLE ONLY public.list_invitation
+    ADD CONSTRAINT list_invitation_list_id_email_key UNIQUE (list_id, email);
+
+
+--
+-- Name: list_invitation list_invitation_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.list_invitation
+    ADD CONSTRAINT list_invitation_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: list_member list_member_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.list_member
+    ADD CONSTRAINT list_member_pkey PRIMARY KEY (list_id, user_id);
+
+
+--
+-- Name: list_tag list_tag_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.list_tag
+    ADD CONSTRAINT list_tag_pkey PRIMARY KEY (list_id, tag_id);
 
 
 --
@@ -151,6 +507,90 @@ ALTER TABLE ONLY public.schema_migrations
     ADD CONSTRAINT schema_migrations_pkey PRIMARY KEY (version);
 
 
+--
+-- Name: share_link share_link_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.share_link
+    ADD CONSTRAINT share_link_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: share_link share_link_token_key; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.share_link
+    ADD CONSTRAINT share_link_token_key UNIQUE (token);
+
+
+--
+-- Name: tag tag_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.tag
+    ADD CONSTRAINT tag_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: tag tag_workspace_id_name_key; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.tag
+    ADD CONSTRAINT tag_workspace_id_name_key UNIQUE (workspace_id, name);
+
+
+--
+-- Name: workspace workspace_personal_user_id_key; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.workspace
+    ADD CONSTRAINT workspace_personal_user_id_key UNIQUE (personal_user_id);
+
+
+--
+-- Name: workspace workspace_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.workspace
+    ADD CONSTRAINT workspace_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: workspace_member workspace_member_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.workspace_member
+    ADD CONSTRAINT workspace_member_pkey PRIMARY KEY (workspace_id, user_id);
+
+
+--
+-- Name: audit_event_actor_id_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX audit_event_actor_id_idx ON public.audit_event USING btree (actor_id);
+
+
+--
+-- Name: audit_event_created_at_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX audit_event_created_at_idx ON public.audit_event USING btree (created_at);
+
+
+--
+-- Name: audit_event_list_id_created_at_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX audit_event_list_id_created_at_idx ON public.audit_event USING btree (list_id, created_at) WHERE (list_id IS NOT NULL);
+
+
+--
+-- Name: audit_event_workspace_id_created_at_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX audit_event_workspace_id_created_at_idx ON public.audit_event USING btree (workspace_id, created_at) WHERE (workspace_id IS NOT NULL);
+
+
 --
 -- Name: e; Type: INDEX; Schema: public; Owner: -
 --
@@ -165,12 +605,230 @@ CREATE INDEX e ON public.fiber_storage USING btree (e);
 CREATE INDEX items_list_id_position_idx ON public.item USING btree (list_id, "position");
 
 
+--
+-- Name: item_deleted_at_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX item_deleted_at_idx ON public.item USING btree (deleted_at) WHERE (deleted_at IS NOT NULL);
+
+
+--
+-- Name: item_search_vector_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX item_search_vector_idx ON public.item USING gin (search_vector);
+
+
+--
+-- Name: list_deleted_at_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX list_deleted_at_idx ON public.list USING btree (deleted_at) WHERE (deleted_at IS NOT NULL);
+
+
+--
+-- Name: list_invitation_email_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX list_invitation_email_idx ON public.list_invitation USING btree (email);
+
+
+--
+-- Name: list_member_user_id_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX list_member_user_id_idx ON public.list_member USING btree (user_id);
+
+
+--
+-- Name: list_search_vector_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX list_search_vector_idx ON public.list USING gin (search_vector);
+
+
+--
+-- Name: list_tag_tag_id_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX list_tag_tag_id_idx ON public.list_tag USING btree (tag_id);
+
+
+--
+-- Name: list_workspace_id_created_at_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX list_workspace_id_created_at_idx ON public.list USING btree (workspace_id, created_at, id);
+
+
+--
+-- Name: list_workspace_id_name_key; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE UNIQUE INDEX list_workspace_id_name_key ON public.list USING btree (workspace_id, name) WHERE (deleted_at IS NULL);
+
+
+--
+-- Name: list_workspace_id_position_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX list_workspace_id_position_idx ON public.list USING btree (workspace_id, "position", id);
+
+
+--
+-- Name: list_workspace_id_updated_at_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX list_workspace_id_updated_at_idx ON public.list USING btree (workspace_id, updated_at, id);
+
+
+--
+-- Name: share_link_list_id_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX share_link_list_id_idx ON public.share_link USING btree (list_id);
+
+
+--
+-- Name: workspace_member_user_id_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX workspace_member_user_id_idx ON public.workspace_member USING btree (user_id);
+
+
+--
+-- Name: audit_event audit_event_append_only; Type: TRIGGER; Schema: public; Owner: -
+--
+
+CREATE TRIGGER audit_event_append_only BEFORE DELETE OR UPDATE ON public.audit_event FOR EACH ROW EXECUTE FUNCTION public.audit_event_append_only();
+
+
+--
+-- Name: audit_event audit_event_actor_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.audit_event
+    ADD CONSTRAINT audit_event_actor_id_fkey FOREIGN KEY (actor_id) REFERENCES public.app_user(id);
+
+
 --
 -- Name: item item_list_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
 --
 
 ALTER TABLE ONLY public.item
-    ADD CONSTRAINT item_list_id_fkey FOREIGN KEY (list_id) REFERENCES public.list(id);
+    ADD CONSTRAINT item_list_id_fkey FOREIGN KEY (list_id) REFERENCES public.list(id) ON DELETE CASCADE;
+
+
+--
+-- Name: list_invitation list_invitation_invited_by_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.list_invitation
+    ADD CONSTRAINT list_invitation_invited_by_id_fkey FOREIGN KEY (invited_by_id) REFERENCES public.app_user(id);
+
+
+--
+-- Name: list_invitation list_invitation_list_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.list_invitation
+    ADD CONSTRAINT list_invitation_list_id_fkey FOREIGN KEY (list_id) REFERENCES public.list(id) ON DELETE CASCADE;
+
+
+--
+-- Name: list_member list_member_list_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.list_member
+    ADD CONSTRAINT list_member_list_id_fkey FOREIGN KEY (list_id) REFERENCES public.list(id) ON DELETE CASCADE;
+
+
+--
+-- Name: list_member list_member_user_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.list_member
+    ADD CONSTRAINT list_member_user_id_fkey FOREIGN KEY (user_id) REFERENCES public.app_user(id) ON DELETE CASCADE;
+
+
+--
+-- Name: list_tag list_tag_list_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.list_tag
+    ADD CONSTRAINT list_tag_list_id_fkey FOREIGN KEY (list_id) REFERENCES public.list(id) ON DELETE CASCADE;
+
+
+--
+-- Name: list_tag list_tag_tag_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.list_tag
+    ADD CONSTRAINT list_tag_tag_id_fkey FOREIGN KEY (tag_id) REFERENCES public.tag(id) ON DELETE CASCADE;
+
+
+--
+-- Name: list list_owner_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.list
+    ADD CONSTRAINT list_owner_id_fkey FOREIGN KEY (owner_id) REFERENCES public.app_user(id);
+
+
+--
+-- Name: list list_workspace_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.list
+    ADD CONSTRAINT list_workspace_id_fkey FOREIGN KEY (workspace_id) REFERENCES public.workspace(id);
+
+
+--
+-- Name: share_link share_link_created_by_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.share_link
+    ADD CONSTRAINT share_link_created_by_id_fkey FOREIGN KEY (created_by_id) REFERENCES public.app_user(id);
+
+
+--
+-- Name: share_link share_link_list_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.share_link
+    ADD CONSTRAINT share_link_list_id_fkey FOREIGN KEY (list_id) REFERENCES public.list(id) ON DELETE CASCADE;
+
+
+--
+-- Name: tag tag_workspace_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.tag
+    ADD CONSTRAINT tag_workspace_id_fkey FOREIGN KEY (workspace_id) REFERENCES public.workspace(id) ON DELETE CASCADE;
+
+
+--
+-- Name: workspace_member workspace_member_user_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.workspace_member
+    ADD CONSTRAINT workspace_member_user_id_fkey FOREIGN KEY (user_id) REFERENCES public.app_user(id) ON DELETE CASCADE;
+
+
+--
+-- Name: workspace_member workspace_member_workspace_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.workspace_member
+    ADD CONSTRAINT workspace_member_workspace_id_fkey FOREIGN KEY (workspace_id) REFERENCES public.workspace(id) ON DELETE CASCADE;
+
+
+--
+-- Name: workspace workspace_personal_user_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.workspace
+    ADD CONSTRAINT workspace_personal_user_id_fkey FOREIGN KEY (personal_user_id) REFERENCES public.app_user(id) ON DELETE CASCADE;
 
 
 --
@@ -184,4 +842,15 @@ ALTER TABLE ONLY public.item
 
 INSERT INTO public.schema_migrations (version) VALUES
     ('20230810152727'),
-    ('20230810153011');
+    ('20230810153011'),
+    ('20261018120000'),
+    ('20261018130000'),
+    ('20261018140000'),
+    ('20261018150000'),
+    ('20261018160000'),
+    ('20261018170000'),
+    ('20261018180000'),
+    ('20261018190000'),
+    ('20261018200000'),
+    ('20261018210000'),
+    ('20261018220000');
@@ -12,10 +12,14 @@ import (
 )
 
 type Item struct {
-	ID        int64 `sql:"primary_key"`
-	ListID    int64
-	Position  int32
-	Name      string
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID           int64 `sql:"primary_key"`
+	ListID       int64
+	Position     int32
+	Name         string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	SearchVector *string
+	CompletedAt  *time.Time
+	DeletedAt    *time.Time
+	Version      int32
 }
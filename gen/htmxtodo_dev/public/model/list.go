@@ -12,8 +12,14 @@ import (
 )
 
 type List struct {
-	ID        int64 `sql:"primary_key"`
-	Name      string
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID           int64 `sql:"primary_key"`
+	Name         string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	OwnerID      int64
+	WorkspaceID  int64
+	SearchVector *string
+	Position     int32
+	DeletedAt    *time.Time
+	Version      int32
 }
@@ -17,12 +17,16 @@ type itemTable struct {
 	postgres.Table
 
 	// Columns
-	ID        postgres.ColumnInteger
-	ListID    postgres.ColumnInteger
-	Position  postgres.ColumnInteger
-	Name      postgres.ColumnString
-	CreatedAt postgres.ColumnTimestampz
-	UpdatedAt postgres.ColumnTimestampz
+	ID           postgres.ColumnInteger
+	ListID       postgres.ColumnInteger
+	Position     postgres.ColumnInteger
+	Name         postgres.ColumnString
+	CreatedAt    postgres.ColumnTimestampz
+	UpdatedAt    postgres.ColumnTimestampz
+	SearchVector postgres.ColumnString
+	CompletedAt  postgres.ColumnTimestampz
+	DeletedAt    postgres.ColumnTimestampz
+	Version      postgres.ColumnInteger
 
 	AllColumns     postgres.ColumnList
 	MutableColumns postgres.ColumnList
@@ -63,26 +67,34 @@ func newItemTable(schemaName, tableName, alias string) *ItemTable {
 
 func newItemTableImpl(schemaName, tableName, alias string) itemTable {
 	var (
-		IDColumn        = postgres.IntegerColumn("id")
-		ListIDColumn    = postgres.IntegerColumn("list_id")
-		PositionColumn  = postgres.IntegerColumn("position")
-		NameColumn      = postgres.StringColumn("name")
-		CreatedAtColumn = postgres.TimestampzColumn("created_at")
-		UpdatedAtColumn = postgres.TimestampzColumn("updated_at")
-		allColumns      = postgres.ColumnList{IDColumn, ListIDColumn, PositionColumn, NameColumn, CreatedAtColumn, UpdatedAtColumn}
-		mutableColumns  = postgres.ColumnList{ListIDColumn, PositionColumn, NameColumn, CreatedAtColumn, UpdatedAtColumn}
+		IDColumn           = postgres.IntegerColumn("id")
+		ListIDColumn       = postgres.IntegerColumn("list_id")
+		PositionColumn     = postgres.IntegerColumn("position")
+		NameColumn         = postgres.StringColumn("name")
+		CreatedAtColumn    = postgres.TimestampzColumn("created_at")
+		UpdatedAtColumn    = postgres.TimestampzColumn("updated_at")
+		SearchVectorColumn = postgres.StringColumn("search_vector")
+		CompletedAtColumn  = postgres.TimestampzColumn("completed_at")
+		DeletedAtColumn    = postgres.TimestampzColumn("deleted_at")
+		VersionColumn      = postgres.IntegerColumn("version")
+		allColumns         = postgres.ColumnList{IDColumn, ListIDColumn, PositionColumn, NameColumn, CreatedAtColumn, UpdatedAtColumn, SearchVectorColumn, CompletedAtColumn, DeletedAtColumn, VersionColumn}
+		mutableColumns     = postgres.ColumnList{ListIDColumn, PositionColumn, NameColumn, CreatedAtColumn, UpdatedAtColumn, SearchVectorColumn, CompletedAtColumn, DeletedAtColumn, VersionColumn}
 	)
 
 	return itemTable{
 		Table: postgres.NewTable(schemaName, tableName, alias, allColumns...),
 
 		//Columns
-		ID:        IDColumn,
-		ListID:    ListIDColumn,
-		Position:  PositionColumn,
-		Name:      NameColumn,
-		CreatedAt: CreatedAtColumn,
-		UpdatedAt: UpdatedAtColumn,
+		ID:           IDColumn,
+		ListID:       ListIDColumn,
+		Position:     PositionColumn,
+		Name:         NameColumn,
+		CreatedAt:    CreatedAtColumn,
+		UpdatedAt:    UpdatedAtColumn,
+		SearchVector: SearchVectorColumn,
+		CompletedAt:  CompletedAtColumn,
+		DeletedAt:    DeletedAtColumn,
+		Version:      VersionColumn,
 
 		AllColumns:     allColumns,
 		MutableColumns: mutableColumns,
@@ -17,10 +17,16 @@ type listTable struct {
 	postgres.Table
 
 	// Columns
-	ID        postgres.ColumnInteger
-	Name      postgres.ColumnString
-	CreatedAt postgres.ColumnTimestampz
-	UpdatedAt postgres.ColumnTimestampz
+	ID           postgres.ColumnInteger
+	Name         postgres.ColumnString
+	CreatedAt    postgres.ColumnTimestampz
+	UpdatedAt    postgres.ColumnTimestampz
+	OwnerID      postgres.ColumnInteger
+	WorkspaceID  postgres.ColumnInteger
+	SearchVector postgres.ColumnString
+	Position     postgres.ColumnInteger
+	DeletedAt    postgres.ColumnTimestampz
+	Version      postgres.ColumnInteger
 
 	AllColumns     postgres.ColumnList
 	MutableColumns postgres.ColumnList
@@ -61,22 +67,34 @@ func newListTable(schemaName, tableName, alias string) *ListTable {
 
 func newListTableImpl(schemaName, tableName, alias string) listTable {
 	var (
-		IDColumn        = postgres.IntegerColumn("id")
-		NameColumn      = postgres.StringColumn("name")
-		CreatedAtColumn = postgres.TimestampzColumn("created_at")
-		UpdatedAtColumn = postgres.TimestampzColumn("updated_at")
-		allColumns      = postgres.ColumnList{IDColumn, NameColumn, CreatedAtColumn, UpdatedAtColumn}
-		mutableColumns  = postgres.ColumnList{NameColumn, CreatedAtColumn, UpdatedAtColumn}
+		IDColumn           = postgres.IntegerColumn("id")
+		NameColumn         = postgres.StringColumn("name")
+		CreatedAtColumn    = postgres.TimestampzColumn("created_at")
+		UpdatedAtColumn    = postgres.TimestampzColumn("updated_at")
+		OwnerIDColumn      = postgres.IntegerColumn("owner_id")
+		WorkspaceIDColumn  = postgres.IntegerColumn("workspace_id")
+		SearchVectorColumn = postgres.StringColumn("search_vector")
+		PositionColumn     = postgres.IntegerColumn("position")
+		DeletedAtColumn    = postgres.TimestampzColumn("deleted_at")
+		VersionColumn      = postgres.IntegerColumn("version")
+		allColumns         = postgres.ColumnList{IDColumn, NameColumn, CreatedAtColumn, UpdatedAtColumn, OwnerIDColumn, WorkspaceIDColumn, SearchVectorColumn, PositionColumn, DeletedAtColumn, VersionColumn}
+		mutableColumns     = postgres.ColumnList{NameColumn, CreatedAtColumn, UpdatedAtColumn, OwnerIDColumn, WorkspaceIDColumn, SearchVectorColumn, PositionColumn, DeletedAtColumn, VersionColumn}
 	)
 
 	return listTable{
 		Table: postgres.NewTable(schemaName, tableName, alias, allColumns...),
 
 		//Columns
-		ID:        IDColumn,
-		Name:      NameColumn,
-		CreatedAt: CreatedAtColumn,
-		UpdatedAt: UpdatedAtColumn,
+		ID:           IDColumn,
+		Name:         NameColumn,
+		CreatedAt:    CreatedAtColumn,
+		UpdatedAt:    UpdatedAtColumn,
+		OwnerID:      OwnerIDColumn,
+		WorkspaceID:  WorkspaceIDColumn,
+		SearchVector: SearchVectorColumn,
+		Position:     PositionColumn,
+		DeletedAt:    DeletedAtColumn,
+		Version:      VersionColumn,
 
 		AllColumns:     allColumns,
 		MutableColumns: mutableColumns,
@@ -10,6 +10,15 @@ package table
 // UseSchema sets a new schema name for all generated table SQL builder types. It is recommended to invoke
 // this method only once at the beginning of the program.
 func UseSchema(schema string) {
+	AppUser = AppUser.FromSchema(schema)
+	AuditEvent = AuditEvent.FromSchema(schema)
 	Item = Item.FromSchema(schema)
 	List = List.FromSchema(schema)
+	ListInvitation = ListInvitation.FromSchema(schema)
+	ListMember = ListMember.FromSchema(schema)
+	ListTag = ListTag.FromSchema(schema)
+	ShareLink = ShareLink.FromSchema(schema)
+	Tag = Tag.FromSchema(schema)
+	Workspace = Workspace.FromSchema(schema)
+	WorkspaceMember = WorkspaceMember.FromSchema(schema)
 }
@@ -0,0 +1,259 @@
+package app
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cognito "github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/session"
+	"htmxtodo/gen/htmxtodo_dev/public/model"
+	"htmxtodo/internal/constants"
+	"htmxtodo/internal/flash"
+	"htmxtodo/internal/repo"
+	"htmxtodo/internal/view"
+	adminviews "htmxtodo/views/admin"
+	"strings"
+)
+
+// recentAuditEvents is how many audit events the admin dashboard shows, and auditPageSize
+// how many the audit log shows.
+const (
+	recentAuditEvents = 20
+	auditPageSize     = 200
+)
+
+type AdminHandlers struct {
+	renderer          *view.Renderer
+	repo              repo.Repository
+	sessionStore      *session.Store
+	sessionPolicy     *SessionPolicy
+	cognitoClient     CognitoClient
+	cognitoUserPoolId string
+}
+
+func (a *AdminHandlers) Dashboard(c *fiber.Ctx) error {
+	stats, err := a.repo.GetStats(c.UserContext())
+	if err != nil {
+		return err
+	}
+
+	events, err := a.repo.FilterAuditEvents(c.UserContext(), repo.AuditQuery{Limit: recentAuditEvents})
+	if err != nil {
+		return err
+	}
+
+	return a.renderer.RenderComponent(c, 200, adminviews.Dashboard(stats, events))
+}
+
+// Audit searches the whole audit log.
+func (a *AdminHandlers) Audit(c *fiber.Ctx) error {
+	var filters adminviews.AuditFilters
+	if err := c.QueryParser(&filters); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	query := repo.AuditQuery{
+		ActorEmail: filters.Actor,
+		Action:     strings.TrimSpace(filters.Action),
+		EntityType: filters.Entity,
+		ListID:     filters.ListID,
+		Limit:      auditPageSize,
+	}
+	var err error
+	if query.From, err = parseDate(filters.From, false); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	if query.To, err = parseDate(filters.To, true); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	events, err := a.repo.FilterAuditEvents(c.UserContext(), query)
+	if err != nil {
+		return err
+	}
+
+	// the filter form only replaces the table
+	if c.Get("HX-Target") == adminviews.AuditTableId {
+		return a.renderer.RenderComponent(c, 200, adminviews.AuditTable(events))
+	}
+
+	return a.renderer.RenderComponent(c, 200, adminviews.Audit(events, filters))
+}
+
+func (a *AdminHandlers) Users(c *fiber.Ctx) error {
+	query := c.Query("q")
+
+	users, err := a.repo.FilterUsers(c.UserContext(), query)
+	if err != nil {
+		return err
+	}
+
+	// the search box only replaces the table
+	if c.Get("HX-Target") == adminviews.UsersTableId {
+		return a.renderer.RenderComponent(c, 200, adminviews.UsersTable(users))
+	}
+
+	return a.renderer.RenderComponent(c, 200, adminviews.Users(users, query))
+}
+
+func (a *AdminHandlers) Disable(c *fiber.Ctx) error {
+	return a.setDisabled(c, true)
+}
+
+func (a *AdminHandlers) Enable(c *fiber.Ctx) error {
+	return a.setDisabled(c, false)
+}
+
+func (a *AdminHandlers) setDisabled(c *fiber.Ctx, disabled bool) error {
+	actor, target, err := a.targetUser(c)
+	if err != nil {
+		return err
+	}
+
+	action, message := "user.enable", "Enabled "+target.Email+"."
+	if disabled {
+		action, message = "user.disable", "Disabled "+target.Email+"."
+	}
+
+	var user model.AppUser
+	err = a.repo.WithTx(c.UserContext(), func(r repo.Repository) error {
+		var err error
+		if user, err = r.UpdateUserDisabled(c.UserContext(), target.ID, disabled); err != nil {
+			return err
+		}
+		return audit(c.UserContext(), r, actor, action, user)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = flash.Add(c, a.sessionStore, flash.Success, message); err != nil {
+		return err
+	}
+
+	return a.renderer.RenderComponent(c, 200, adminviews.UserRow(user))
+}
+
+// ResetPassword makes the user choose a new password through Cognito's forgotten password
+// flow, and ends their existing sessions.
+func (a *AdminHandlers) ResetPassword(c *fiber.Ctx) error {
+	actor, target, err := a.targetUser(c)
+	if err != nil {
+		return err
+	}
+
+	_, err = a.cognitoClient.AdminResetUserPassword(c.UserContext(), &cognito.AdminResetUserPasswordInput{
+		UserPoolId: aws.String(a.cognitoUserPoolId),
+		Username:   aws.String(target.Email),
+	})
+	if err != nil {
+		return err
+	}
+
+	var user model.AppUser
+	err = a.repo.WithTx(c.UserContext(), func(r repo.Repository) error {
+		var err error
+		if user, err = r.RevokeUserSessions(c.UserContext(), target.ID); err != nil {
+			return err
+		}
+		return audit(c.UserContext(), r, actor, "user.reset_password", user)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = flash.Add(c, a.sessionStore, flash.Success, "Password reset for "+user.Email+"."); err != nil {
+		return err
+	}
+
+	return a.renderer.RenderComponent(c, 200, adminviews.UserRow(user))
+}
+
+// Impersonate logs the admin in as another user until they stop impersonating or log out.
+// Other admins cannot be impersonated, so impersonation never grants more than it hides.
+func (a *AdminHandlers) Impersonate(c *fiber.Ctx) error {
+	actor, target, err := a.targetUser(c)
+	if err != nil {
+		return err
+	}
+
+	if target.Role == repo.RoleAdmin || target.DisabledAt != nil {
+		return fiber.NewError(fiber.StatusUnprocessableEntity, "only enabled, non-admin users can be impersonated")
+	}
+
+	if err = audit(c.UserContext(), a.repo, actor, "user.impersonate", target); err != nil {
+		return err
+	}
+
+	// queued before the session ID changes, as later lookups still use the request's old ID
+	if err = flash.Add(c, a.sessionStore, flash.Warning, "You are now impersonating "+target.Email+"."); err != nil {
+		return err
+	}
+
+	sess, err := a.sessionStore.Get(c)
+	if err != nil {
+		panic(err)
+	}
+
+	if err = a.sessionPolicy.Impersonate(c, sess, actor.ID, target.ID); err != nil {
+		panic(err)
+	}
+
+	c.Set("HX-Location", "/app/lists")
+	return c.Redirect("/app/lists", fiber.StatusFound)
+}
+
+// StopImpersonating returns an impersonating admin to their own account. It is routed
+// outside the admin area, since the current user is whoever is being impersonated.
+func (a *AdminHandlers) StopImpersonating(c *fiber.Ctx) error {
+	user, _ := c.Locals(constants.CurrentUserContextKey).(*model.AppUser)
+	impersonator, _ := c.Locals(constants.ImpersonatorContextKey).(*model.AppUser)
+	if impersonator == nil {
+		return c.Redirect("/app/lists", fiber.StatusFound)
+	}
+
+	sess, err := a.sessionStore.Get(c)
+	if err != nil {
+		panic(err)
+	}
+
+	if _, err = a.sessionPolicy.StopImpersonating(c, sess); err != nil {
+		panic(err)
+	}
+
+	if err = audit(c.UserContext(), a.repo, impersonator, "user.stop_impersonating", *user); err != nil {
+		return err
+	}
+
+	c.Set("HX-Location", "/admin/users")
+	return c.Redirect("/admin/users", fiber.StatusFound)
+}
+
+// targetUser returns the current admin and the user named by the :id route parameter.
+// Admins cannot act on themselves, so they cannot lock themselves out.
+func (a *AdminHandlers) targetUser(c *fiber.Ctx) (*model.AppUser, model.AppUser, error) {
+	actor := currentUser(c)
+
+	var params struct {
+		ID int64 `params:"id"`
+	}
+	if err := c.ParamsParser(&params); err != nil {
+		return nil, model.AppUser{}, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	if params.ID == actor.ID {
+		return nil, model.AppUser{}, fiber.NewError(fiber.StatusUnprocessableEntity, "admins cannot change their own account")
+	}
+
+	target, err := a.repo.GetUserById(c.UserContext(), params.ID)
+	if err != nil {
+		return nil, target, err
+	}
+
+	return actor, target, nil
+}
+
+func audit(ctx context.Context, r repo.Repository, actor *model.AppUser, action string, target model.AppUser) error {
+	_, err := r.CreateAuditEvent(ctx, repo.UserAuditEvent(actor, action, target))
+	return err
+}
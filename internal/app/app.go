@@ -2,73 +2,122 @@ package app
 
 import (
 	"context"
+	"database/sql"
 	"errors"
+	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
-	_ "github.com/aws/aws-sdk-go-v2/aws"
-	awsconfig "github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
 	cognito "github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
 	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
 	"github.com/gofiber/fiber/v2"
 	fiberlog "github.com/gofiber/fiber/v2/log"
 	"github.com/gofiber/fiber/v2/middleware/compress"
 	"github.com/gofiber/fiber/v2/middleware/csrf"
+	expvarmw "github.com/gofiber/fiber/v2/middleware/expvar"
 	"github.com/gofiber/fiber/v2/middleware/favicon"
 	"github.com/gofiber/fiber/v2/middleware/filesystem"
 	"github.com/gofiber/fiber/v2/middleware/helmet"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
 	"github.com/gofiber/fiber/v2/middleware/session"
-	"github.com/gofiber/storage/postgres/v3"
 	_ "github.com/lib/pq"
 	"htmxtodo/gen/htmxtodo_dev/public/model"
+	"htmxtodo/internal/challenge"
 	"htmxtodo/internal/config"
 	"htmxtodo/internal/constants"
+	"htmxtodo/internal/flash"
+	"htmxtodo/internal/ratelimit"
 	"htmxtodo/internal/repo"
+	"htmxtodo/internal/secrets"
+	"htmxtodo/internal/sessionstore"
 	"htmxtodo/internal/view"
 	errorviews "htmxtodo/views/errors"
 	listviews "htmxtodo/views/lists"
 	loginviews "htmxtodo/views/login"
+	trashviews "htmxtodo/views/trash"
+	"strconv"
 	"strings"
 	"time"
 )
 
 func New(cfg *config.Config) *fiber.App {
-	fiberlog.Info("Starting app with environment: ", cfg.Env)
+	cognitoClient, err := newCognitoClient(context.TODO(), cfg.CognitoEndpoint)
+	if err != nil {
+		fiberlog.Fatal(err)
+	}
 
-	app := fiber.New(fiber.Config{
-		AppName:      "HtmxTodo 0.1.0",
-		ErrorHandler: errorHandler,
-	})
+	return newApp(cfg, cognitoClient)
+}
 
-	postgresStorage := postgres.New(postgres.Config{
-		ConnectionURI: cfg.Secrets.DatabaseUrl(),
-	})
+// newApp builds the app around a Cognito client, which tests replace with a fake.
+func newApp(cfg *config.Config, cognitoClient CognitoClient) *fiber.App {
+	fiberlog.Info("Starting app with environment: ", cfg.Env)
+
+	sessionStorage, err := newStorage(cfg, cfg.SessionBackend, "")
+	if err != nil {
+		fiberlog.Fatal(err)
+	}
+	rateLimitStorage, err := newStorage(cfg, cfg.RateLimitBackend, rateLimitRedisKeyPrefix)
+	if err != nil {
+		fiberlog.Fatal(err)
+	}
 
+	// Expiration only controls how long idle sessions linger in storage; lifetimes and
+	// cookie expiry are enforced by the SessionPolicy.
 	sessionStore := session.New(session.Config{
-		Expiration:     24 * time.Hour * 30,
-		KeyLookup:      "cookie:htmxtodo_session_id",
+		Expiration:     cfg.SessionIdleTimeout,
+		KeyLookup:      "cookie:" + constants.SessionCookieName,
 		CookieSecure:   cfg.CookieSecure,
 		CookieHTTPOnly: true,
-		Storage:        postgresStorage,
+		Storage:        sessionStorage,
 	})
+	sessionStore.RegisterType([]flash.Message{})
+	sessionPolicy := NewSessionPolicy(sessionStore, cfg.Repo, cfg.SessionIdleTimeout, cfg.SessionAbsoluteTimeout, cfg.ReplicaStickiness)
 
-	renderer := &view.Renderer{SessionStore: sessionStore}
+	renderer := &view.Renderer{
+		SessionStore: sessionStore,
+		Features:     cfg.Features,
+	}
+
+	app := fiber.New(fiber.Config{
+		AppName:      "HtmxTodo 0.1.0",
+		ErrorHandler: newErrorHandler(renderer),
+		// c.IP is the client's, not the proxy's, so that rate limits are per client
+		ProxyHeader:             cfg.ProxyHeader,
+		EnableTrustedProxyCheck: cfg.ProxyHeader != "",
+		TrustedProxies:          cfg.TrustedProxies,
+		EnableIPValidation:      cfg.ProxyHeader != "",
+	})
 
+	app.Use(requestid.New(requestid.Config{
+		ContextKey: constants.RequestIdContextKey,
+	}))
 	app.Use(logger.New(logger.Config{
 		DisableColors: cfg.DisableLogColors,
 	}))
 	app.Use(recover.New(recover.Config{
 		EnableStackTrace: cfg.EnableStackTrace,
 	}))
+	app.Use(StatementTimeout(cfg.StatementTimeout))
 	app.Use(compress.New())
-	app.Use(helmet.New())
+	helmetConfig := helmet.Config{}
+	if hostedCaptcha(cfg) {
+		// the CAPTCHA's script and frames come from its provider, without the headers
+		// require-corp asks for
+		helmetConfig.CrossOriginEmbedderPolicy = "unsafe-none"
+	}
+	app.Use(helmet.New(helmetConfig))
 	app.Use(favicon.New())
 	app.Use("/static", filesystem.New(filesystem.Config{
 		Root:       cfg.StaticFS,
 		PathPrefix: "static",
 	}))
 
+	// the cookie backend has to unpack the session before anything reads it
+	if cookieStorage, ok := sessionStorage.(*sessionstore.CookieStorage); ok {
+		app.Use(cookieStorage.Handler)
+	}
+
 	// Combine two CSRF extractors: use form field as default
 	// so forms work without JS, with header as fallback.
 	csrfFromForm := csrf.CsrfFromForm(constants.CsrfInputName)
@@ -99,63 +148,192 @@ func New(cfg *config.Config) *fiber.App {
 		CookieName: "htmxtodo_csrf",
 	}))
 
-	awsCfg, err := awsconfig.LoadDefaultConfig(context.TODO())
+	registrationChallenge, err := newRegistrationChallenge(cfg, sessionStore)
 	if err != nil {
 		fiberlog.Fatal(err)
 	}
 
-	cognitoClient := cognito.NewFromConfig(awsCfg)
+	limits := cfg.RateLimits
+	limiters := rateLimiters{
+		storage:      rateLimitStorage,
+		limitReached: tooManyRequests(renderer),
+	}
 
 	login := LoginHandlers{
 		renderer:        renderer,
+		repo:            cfg.Repo,
 		sessionStore:    sessionStore,
+		sessionPolicy:   sessionPolicy,
 		cognitoClient:   cognitoClient,
 		cognitoClientId: cfg.Secrets.CognitoClientId(),
+		lockout:         ratelimit.NewLockout(rateLimitStorage, limits.LockoutThreshold, limits.LockoutBase, limits.LockoutMax),
+		challenge:       registrationChallenge,
+		emailDomains:    cfg.Registration.EmailDomains,
+	}
+
+	sharedLists := SharedListsHandlers{
+		renderer: renderer,
+		repo:     cfg.Repo,
+	}
+
+	admin := AdminHandlers{
+		renderer:          renderer,
+		repo:              cfg.Repo,
+		sessionStore:      sessionStore,
+		sessionPolicy:     sessionPolicy,
+		cognitoClient:     cognitoClient,
+		cognitoUserPoolId: cfg.Secrets.CognitoUserPoolId(),
 	}
 
 	lists := ListsHandlers{
+		renderer:       renderer,
+		repo:           cfg.Repo,
+		sessionStore:   sessionStore,
+		trashRetention: cfg.TrashRetention,
+	}
+
+	tags := TagHandlers{
+		renderer:     renderer,
+		repo:         cfg.Repo,
+		sessionStore: sessionStore,
+	}
+
+	workspaces := WorkspaceHandlers{
 		renderer:     renderer,
 		repo:         cfg.Repo,
 		sessionStore: sessionStore,
 	}
 
-	// check logged-in status on all routes
-	app.Use(SetLoggedIn(sessionStore))
+	// check logged-in status and session lifetime on all routes
+	app.Use(sessionPolicy.Handler)
+	app.Use(flash.Middleware(sessionStore))
 
 	app.Get("/", func(c *fiber.Ctx) error {
 		return c.Redirect("/login", fiber.StatusFound)
 	})
 
-	internal := app.Group("/app", RequireLoggedIn)
+	// public links work whether or not anyone is logged in
+	app.Get("/s/:token", sharedLists.Show)
+
+	internal := app.Group("/app", RequireLoggedIn,
+		limiters.limit("app-ip", limits.MutationIp, writesOnly(clientIp)),
+		limiters.limit("app-account", limits.MutationAccount, writesOnly(currentUserId)),
+		workspaces.ActiveWorkspace)
 
 	internal.Get("/lists", lists.Index)
 	internal.Post("/lists", lists.Create)
 	internal.Get("/lists/:id/edit", lists.Edit)
 	internal.Patch("/lists/:id", lists.Update)
 	internal.Delete("/lists/:id", lists.Delete)
+	internal.Post("/lists/:id/restore", lists.Restore)
+	internal.Get("/lists/:id/sharing", lists.Sharing)
+	internal.Post("/lists/:id/members", lists.Share)
+	internal.Delete("/lists/:id/members/:userId", lists.RevokeMember)
+	internal.Delete("/lists/:id/invitations/:invitationId", lists.RevokeInvitation)
+	internal.Post("/lists/:id/owner", lists.TransferOwnership)
+	internal.Post("/lists/:id/share-link", lists.CreateShareLink)
+	internal.Delete("/lists/:id/share-link", lists.RevokeShareLink)
+	internal.Put("/lists/:id/tags", lists.SetTags)
+	internal.Get("/lists/:id/activity", lists.Activity)
+	internal.Get("/trash", lists.Trash)
+	internal.Delete("/trash/:id", lists.Purge)
+	internal.Get("/tags", tags.Index)
+	internal.Post("/tags", tags.Create)
+	internal.Patch("/tags/:id", tags.Update)
+	internal.Post("/tags/:id/merge", tags.Merge)
+	internal.Delete("/tags/:id", tags.Delete)
+	internal.Get("/workspaces/new", workspaces.New)
+	internal.Post("/workspaces", workspaces.Create)
+	internal.Post("/workspaces/switch", workspaces.Switch)
+	internal.Get("/workspace", workspaces.Settings)
+	internal.Post("/workspace/members", workspaces.AddMember)
+	internal.Delete("/workspace/members/:userId", workspaces.RemoveMember)
 	internal.Post("/logout", login.Logout)
+	internal.Post("/impersonation/stop", admin.StopImpersonating)
+
+	adminArea := app.Group("/admin", RequireLoggedIn, RequireRole(repo.RoleAdmin))
+
+	adminArea.Get("/", admin.Dashboard)
+	adminArea.Get("/users", admin.Users)
+	adminArea.Get("/audit", admin.Audit)
+	adminArea.Post("/users/:id/disable", admin.Disable)
+	adminArea.Post("/users/:id/enable", admin.Enable)
+	adminArea.Post("/users/:id/reset-password", admin.ResetPassword)
+	adminArea.Post("/users/:id/impersonate", admin.Impersonate)
+	app.Get("/debug/vars", RequireLoggedIn, RequireRole(repo.RoleAdmin), expvarmw.New())
 
 	external := app.Group("", RedirectInternalIfLoggedIn)
 
 	external.Get("/login", login.LoginForm)
-	external.Post("/login", login.SubmitLogin)
+	external.Post("/login",
+		limiters.limit("login-ip", limits.LoginIp, clientIp),
+		limiters.limit("login-account", limits.LoginAccount, formEmail),
+		login.SubmitLogin)
 
 	external.Get("/register", login.Register)
-	external.Post("/register", login.SubmitRegistration)
+	external.Post("/register",
+		limiters.limit("register-ip", limits.RegisterIp, clientIp),
+		limiters.limit("register-account", limits.RegisterAccount, formEmail),
+		login.SubmitRegistration)
 
 	return app
 }
 
+// rateLimitRedisKeyPrefix keeps rate limits apart from sessions in a shared Redis.
+const rateLimitRedisKeyPrefix = "htmxtodo:ratelimit:"
+
+// newStorage connects to a sessionstore backend, for sessions or rate limits. The postgres
+// backend shares the app's pool, which follows rotated credentials itself; the redis
+// backend is reconnected when its secret is rotated. The cookie backend is left alone, as
+// a new secret would log everybody out.
+func newStorage(cfg *config.Config, backend string, redisKeyPrefix string) (fiber.Storage, error) {
+	storageConfig := func() sessionstore.Config {
+		return sessionstore.Config{
+			Backend:        backend,
+			DB:             cfg.DB,
+			RedisUrl:       cfg.Secrets.RedisUrl(),
+			RedisKeyPrefix: redisKeyPrefix,
+			Secret:         cfg.Secrets.SessionSecret(),
+			CookieName:     constants.SessionCookieName,
+			CookieSecure:   cfg.CookieSecure,
+		}
+	}
+
+	storage, err := sessionstore.New(storageConfig())
+	if err != nil || backend != sessionstore.BackendRedis {
+		return storage, err
+	}
+
+	reloadable := sessionstore.NewReloadableStorage(storage)
+	cfg.Secrets.OnChange(secrets.RedisUrlName, func() {
+		storage, err := sessionstore.New(storageConfig())
+		if err == nil {
+			err = reloadable.Swap(storage)
+		}
+		if err != nil {
+			fiberlog.Error("failed to reconnect to redis: ", err)
+			return
+		}
+		fiberlog.Info("reconnected to redis with rotated credentials")
+	})
+	return reloadable, nil
+}
+
 type LoginHandlers struct {
 	renderer        *view.Renderer
+	repo            repo.Repository
 	sessionStore    *session.Store
-	cognitoClient   *cognitoidentityprovider.Client
+	sessionPolicy   *SessionPolicy
+	cognitoClient   CognitoClient
 	cognitoClientId string
+	lockout         *ratelimit.Lockout
+	challenge       challenge.Challenge
+	emailDomains    challenge.EmailDomains
 }
 
 func (l *LoginHandlers) LoginForm(c *fiber.Ctx) error {
 	form := loginviews.LoginForm{}
-	return l.renderer.RenderComponent(c, 200, loginviews.Login(form))
+	return l.renderer.RenderComponent(c, 200, loginviews.Login(form, ""))
 }
 
 func (l *LoginHandlers) SubmitLogin(c *fiber.Ctx) error {
@@ -166,19 +344,77 @@ func (l *LoginHandlers) SubmitLogin(c *fiber.Ctx) error {
 		return err
 	}
 
-	fiberlog.Debug("form:", form)
+	form.Email = strings.ToLower(strings.TrimSpace(form.Email))
+	password := form.Password
+	form.Password = "" // never render the password back
 
-	sess, err := l.sessionStore.Get(c)
+	// locked out per account and IP, so that failing to log in as someone else cannot lock
+	// them out; the per account rate limit slows down attempts from many IPs
+	lockoutKey := form.Email + " " + c.IP()
+	lockedFor, err := l.lockout.Locked(lockoutKey)
 	if err != nil {
-		panic(err)
+		return err
+	}
+	if lockedFor > 0 {
+		return l.lockedOut(c, form, lockedFor)
 	}
 
-	if err = sess.Reset(); err != nil {
-		panic(err)
+	authOutput, err := l.cognitoClient.InitiateAuth(c.UserContext(), &cognito.InitiateAuthInput{
+		AuthFlow: types.AuthFlowTypeUserPasswordAuth,
+		ClientId: aws.String(l.cognitoClientId),
+		AuthParameters: map[string]string{
+			"USERNAME": form.Email,
+			"PASSWORD": password,
+		},
+	})
+	if err != nil {
+		if isBadCredentials(err) {
+			lockedFor, lockoutErr := l.lockout.Fail(lockoutKey)
+			if lockoutErr != nil {
+				return lockoutErr
+			}
+			if lockedFor > 0 {
+				fiberlog.Warn("locked out ", form.Email, " from ", c.IP(), " for ", lockedFor)
+				return l.lockedOut(c, form, lockedFor)
+			}
+		}
+		return l.renderer.RenderComponent(c, fiber.StatusUnprocessableEntity,
+			loginviews.Login(form, loginErrorMessage(err)))
+	}
+	if err = l.lockout.Succeed(lockoutKey); err != nil {
+		return err
+	}
+	if authOutput.ChallengeName != "" {
+		fiberlog.Warn("unsupported login challenge: ", authOutput.ChallengeName)
+		return l.renderer.RenderComponent(c, fiber.StatusUnprocessableEntity,
+			loginviews.Login(form, "Your account requires a login step that is not supported yet."))
 	}
-	sess.Set(constants.LoggedInSessionKey, "true")
-	err = sess.Save()
+
+	var user model.AppUser
+	err = l.repo.WithTx(c.UserContext(), func(r repo.Repository) error {
+		var err error
+		if user, err = r.FindOrCreateUser(c.UserContext(), form.Email); err != nil {
+			return err
+		}
+		if user.DisabledAt != nil {
+			return nil
+		}
+		return r.AcceptListInvitations(c.UserContext(), user)
+	})
 	if err != nil {
+		return err
+	}
+	if user.DisabledAt != nil {
+		return l.renderer.RenderComponent(c, fiber.StatusUnprocessableEntity,
+			loginviews.Login(form, "This account has been disabled."))
+	}
+
+	sess, err := l.sessionStore.Get(c)
+	if err != nil {
+		panic(err)
+	}
+
+	if err = l.sessionPolicy.LogIn(c, sess, user.ID, form.RememberMe); err != nil {
 		panic(err)
 	}
 
@@ -186,23 +422,36 @@ func (l *LoginHandlers) SubmitLogin(c *fiber.Ctx) error {
 	return c.Redirect("/app/lists", fiber.StatusFound)
 }
 
+// lockedOut re-renders the login form saying how long to wait before trying again.
+func (l *LoginHandlers) lockedOut(c *fiber.Ctx, form loginviews.LoginForm, lockedFor time.Duration) error {
+	setRetryAfter(c, lockedFor)
+	return l.renderer.RenderComponent(c, fiber.StatusTooManyRequests,
+		loginviews.Login(form, errorviews.TooManyRequestsMessage(lockedFor)))
+}
+
 func (l *LoginHandlers) Logout(c *fiber.Ctx) error {
 	sess, err := l.sessionStore.Get(c)
 	if err != nil {
 		panic(err)
 	}
-	err = sess.Reset()
+	err = l.sessionPolicy.LogOut(c, sess)
 	if err != nil {
 		panic(err)
 	}
 
+	// saved with the message, so that the browser is given the new ID; getting the session
+	// again would load the old one
+	flash.Queue(sess, flash.Info, "You have been logged out.")
+	if err = sess.Save(); err != nil {
+		return err
+	}
+
 	c.Set("HX-Location", "/login")
 	return c.Redirect("/login", fiber.StatusFound)
 }
 
 func (l *LoginHandlers) Register(c *fiber.Ctx) error {
-	form := loginviews.RegistrationForm{}
-	return l.renderer.RenderComponent(c, 200, loginviews.Register(form, ""))
+	return l.renderRegistration(c, 200, loginviews.RegistrationForm{}, "")
 }
 
 func (l *LoginHandlers) SubmitRegistration(c *fiber.Ctx) error {
@@ -213,13 +462,23 @@ func (l *LoginHandlers) SubmitRegistration(c *fiber.Ctx) error {
 		return err
 	}
 
-	fiberlog.Debug("form:", form)
+	form.Email = strings.ToLower(strings.TrimSpace(form.Email))
+
+	if err = l.challenge.Verify(c); err == nil {
+		err = l.emailDomains.Check(form.Email)
+	}
+	var rejection *challenge.Rejection
+	if errors.As(err, &rejection) {
+		return l.rejectRegistration(c, form, rejection)
+	}
+	if err != nil {
+		return err
+	}
 
 	// validation:
 
 	if form.Password != form.PasswordConfirmation {
-		return l.renderer.RenderComponent(c, fiber.StatusUnprocessableEntity,
-			loginviews.Register(form, "passwords do not match"))
+		return l.renderRegistration(c, fiber.StatusUnprocessableEntity, form, "passwords do not match")
 	}
 
 	signUpInput := &cognito.SignUpInput{
@@ -240,23 +499,82 @@ func (l *LoginHandlers) SubmitRegistration(c *fiber.Ctx) error {
 		ValidationData: nil,
 	}
 
-	_, err = l.cognitoClient.SignUp(c.Context(), signUpInput)
+	_, err = l.cognitoClient.SignUp(c.UserContext(), signUpInput)
 	if err != nil {
-		return l.renderer.RenderComponent(c, fiber.StatusUnprocessableEntity, loginviews.Register(form, err.Error()))
+		return l.renderRegistration(c, fiber.StatusUnprocessableEntity, form, err.Error())
+	}
+
+	if err = flash.Add(c, l.sessionStore, flash.Success,
+		"Registration successful! Please confirm your email address, then log in."); err != nil {
+		return err
 	}
 
 	c.Set("HX-Location", "/login")
 	return c.Redirect("/login", fiber.StatusFound)
 }
 
+// listActivityEntries is how much of a list's activity its card shows.
+const listActivityEntries = 20
+
 type ListsHandlers struct {
-	renderer     *view.Renderer
-	repo         repo.Repository
-	sessionStore *session.Store
+	renderer       *view.Renderer
+	repo           repo.Repository
+	sessionStore   *session.Store
+	trashRetention time.Duration
 }
 
 func (l *ListsHandlers) Index(c *fiber.Ctx) error {
-	results, err := l.repo.FilterLists(c.Context())
+	user := currentUser(c)
+	workspace := activeWorkspace(c)
+
+	var filters listviews.Filters
+	if err := c.QueryParser(&filters); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	filters.Search = strings.TrimSpace(filters.Search)
+	filters.Name = strings.TrimSpace(filters.Name)
+
+	query, err := listQuery(workspace.ID, filters)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	page, err := l.repo.FilterLists(c.UserContext(), query)
+	if errors.Is(err, repo.ErrInvalidCursor) {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		return err
+	}
+	results := page.Results
+
+	tags, err := l.repo.FilterTags(c.UserContext(), workspace.ID)
+	if err != nil {
+		return err
+	}
+
+	shared, err := l.repo.FilterSharedLists(c.UserContext(), workspace.ID, user.ID)
+	if err != nil {
+		return err
+	}
+
+	links, err := l.repo.FilterActiveShareLinks(c.UserContext(), workspace.ID)
+	if err != nil {
+		return err
+	}
+	linksByList := make(map[int64]model.ShareLink, len(links))
+	for _, link := range links {
+		linksByList[link.ListID] = link
+	}
+
+	listIds := make([]int64, 0, len(results)+len(shared))
+	for _, result := range results {
+		listIds = append(listIds, result.ID)
+	}
+	for _, result := range shared {
+		listIds = append(listIds, result.ID)
+	}
+	listTags, err := l.repo.FilterListTags(c.UserContext(), listIds)
 	if err != nil {
 		return err
 	}
@@ -265,31 +583,101 @@ func (l *ListsHandlers) Index(c *fiber.Ctx) error {
 	for i, result := range results {
 		cards[i] = listviews.CardProps{
 			EditingName: false,
-			List:        result,
+			List:        result.List,
+			Permission:  repo.WorkspaceListPermission(workspace.Role, result.List, user.ID),
+			Snippet:     result.Snippet,
+			Tags:        listTags[result.ID],
+			TagOptions:  tagModels(tags),
+		}
+		if link, ok := linksByList[result.ID]; ok && cards[i].IsOwner() {
+			cards[i] = withShareLink(c, cards[i], link)
+		}
+	}
+
+	// the filter form only replaces the workspace's cards, and infinite scroll only appends
+	// the next page
+	switch c.Get("HX-Target") {
+	case listviews.ListsId:
+		return l.renderer.RenderComponent(c, 200, listviews.Cards(cards, filters, page.Next))
+	case listviews.MoreListsId:
+		return l.renderer.RenderComponent(c, 200, listviews.CardsPage(cards, filters, page.Next))
+	}
+
+	sharedCards := make([]listviews.CardProps, len(shared))
+	for i, result := range shared {
+		sharedCards[i] = listviews.CardProps{
+			EditingName: false,
+			List:        result.List,
+			Permission:  result.Permission,
+			SharedBy:    result.OwnerEmail,
+			Tags:        listTags[result.ID],
 		}
 	}
 	newList := model.List{}
 
-	return l.renderer.RenderComponent(c, 200, listviews.Index(cards, newList))
+	return l.renderer.RenderComponent(c, 200, listviews.Index(cards, page.Next, sharedCards, newList, filters, tags))
 }
 
-func (l *ListsHandlers) Edit(c *fiber.Ctx) error {
-	var params struct {
-		ID int64 `params:"id"`
+// listQuery converts the lists page's filters to a repository query.
+func listQuery(workspaceId int64, filters listviews.Filters) (repo.ListQuery, error) {
+	query := repo.ListQuery{
+		WorkspaceID: workspaceId,
+		Search:      filters.Search,
+		Filter: repo.ListFilter{
+			NameContains:       filters.Name,
+			HasIncompleteItems: filters.Incomplete,
+			TagID:              filters.Tag,
+		},
+		Sort:  filters.Sort,
+		After: filters.After,
 	}
-	if err := c.ParamsParser(&params); err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+
+	dates := []struct {
+		value     string
+		dest      **time.Time
+		inclusive bool
+	}{
+		{filters.CreatedFrom, &query.Filter.CreatedFrom, false},
+		{filters.CreatedTo, &query.Filter.CreatedTo, true},
+		{filters.UpdatedFrom, &query.Filter.UpdatedFrom, false},
+		{filters.UpdatedTo, &query.Filter.UpdatedTo, true},
 	}
+	for _, date := range dates {
+		t, err := parseDate(date.value, date.inclusive)
+		if err != nil {
+			return query, err
+		}
+		*date.dest = t
+	}
+
+	return query, nil
+}
+
+// parseDate parses a date from a filter form, which may be empty. Ranges exclude their end,
+// so an inclusive end date is the start of the next day.
+func parseDate(value string, inclusive bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return nil, err
+	}
+	if inclusive {
+		t = t.AddDate(0, 0, 1)
+	}
+
+	return &t, nil
+}
 
-	result, err := l.repo.GetListById(c.Context(), params.ID)
+func (l *ListsHandlers) Edit(c *fiber.Ctx) error {
+	list, permission, err := l.authorizeList(c, repo.PermissionEditor)
 	if err != nil {
 		return err
 	}
 
-	return l.renderer.RenderComponent(c, 200, listviews.Card(listviews.CardProps{
-		EditingName: true,
-		List:        result,
-	}))
+	return l.renderCard(c, list, permission, true)
 }
 
 type CreateListRequest struct {
@@ -311,7 +699,21 @@ func (l *ListsHandlers) Create(c *fiber.Ctx) error {
 		}, "name is required"))
 	}
 
-	result, err := l.repo.CreateList(c.Context(), req.Name)
+	result, err := l.repo.CreateList(c.UserContext(), activeWorkspace(c).ID, currentUser(c).ID, req.Name)
+	if errors.Is(err, repo.ErrListNameTaken) {
+		return l.renderer.RenderComponent(c, fiber.StatusUnprocessableEntity, listviews.CreateFailure(model.List{
+			Name: req.Name,
+		}, "a list with that name already exists"))
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = flash.Add(c, l.sessionStore, flash.Success, "List created."); err != nil {
+		return err
+	}
+
+	tagOptions, err := l.tagOptions(c, result)
 	if err != nil {
 		return err
 	}
@@ -319,18 +721,21 @@ func (l *ListsHandlers) Create(c *fiber.Ctx) error {
 	return l.renderer.RenderComponent(c, 200, listviews.CreateSuccess(listviews.CardProps{
 		EditingName: false,
 		List:        result,
+		Permission:  repo.PermissionOwner,
+		TagOptions:  tagOptions,
 	}))
 }
 
 type UpdateListRequest struct {
 	Name string `json:"name" form:"name"`
+	// Version is the version of the list the edit was made from. API clients may send it
+	// in an If-Match header instead.
+	Version int32 `json:"version" form:"version"`
 }
 
 func (l *ListsHandlers) Update(c *fiber.Ctx) error {
-	var params struct {
-		ID int64 `params:"id"`
-	}
-	if err := c.ParamsParser(&params); err != nil {
+	list, permission, err := l.authorizeList(c, repo.PermissionEditor)
+	if err != nil {
 		return err
 	}
 
@@ -344,29 +749,213 @@ func (l *ListsHandlers) Update(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusBadRequest, "name is required")
 	}
 
-	list, err := l.repo.UpdateListById(c.Context(), params.ID, req.Name)
+	version, err := expectedVersion(c, req.Version)
 	if err != nil {
 		return err
 	}
+	if version == 0 {
+		return fiber.NewError(fiber.StatusPreconditionRequired, "the version being edited is required")
+	}
 
-	return l.renderer.RenderComponent(c, 200, listviews.Card(listviews.CardProps{
-		EditingName: false,
-		List:        list,
-	}))
+	list, err = l.repo.UpdateListById(c.UserContext(), list.ID, req.Name, version)
+	if errors.Is(err, repo.ErrVersionConflict) {
+		// show both names, and let the user save theirs over the current one
+		card, err := l.card(c, list, permission, true)
+		if err != nil {
+			return err
+		}
+		card.ConflictName = req.Name
+
+		c.Set(fiber.HeaderETag, listETag(list))
+		return l.renderer.RenderComponent(c, fiber.StatusConflict, listviews.Card(card))
+	}
+	if errors.Is(err, repo.ErrListNameTaken) {
+		return fiber.NewError(fiber.StatusUnprocessableEntity, "a list with that name already exists")
+	}
+	if err != nil {
+		return err
+	}
+
+	return l.renderCard(c, list, permission, false)
 }
 
 func (l *ListsHandlers) Delete(c *fiber.Ctx) error {
+	list, _, err := l.authorizeList(c, repo.PermissionOwner)
+	if err != nil {
+		return err
+	}
+
+	err = l.repo.DeleteListById(c.UserContext(), list.ID)
+	if err != nil {
+		return err
+	}
+
+	if err = flash.AddUndo(c, l.sessionStore, flash.Info, "List moved to trash.", trashviews.RestoreUrl(list)); err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// renderCard renders a list's card, including its public link if the current user owns it.
+func (l *ListsHandlers) renderCard(c *fiber.Ctx, list model.List, permission string, editingName bool) error {
+	card, err := l.card(c, list, permission, editingName)
+	if err != nil {
+		return err
+	}
+
+	c.Set(fiber.HeaderETag, listETag(list))
+	return l.renderer.RenderComponent(c, 200, listviews.Card(card))
+}
+
+// card returns the props for a list's card.
+func (l *ListsHandlers) card(c *fiber.Ctx, list model.List, permission string, editingName bool) (listviews.CardProps, error) {
+	listTags, err := l.repo.FilterListTags(c.UserContext(), []int64{list.ID})
+	if err != nil {
+		return listviews.CardProps{}, err
+	}
+
+	card := listviews.CardProps{
+		EditingName: editingName,
+		List:        list,
+		Permission:  permission,
+		Tags:        listTags[list.ID],
+	}
+
+	if repo.PermissionAllows(permission, repo.PermissionEditor) {
+		if card.TagOptions, err = l.tagOptions(c, list); err != nil {
+			return card, err
+		}
+	}
+
+	if permission == repo.PermissionOwner {
+		link, err := l.repo.GetActiveShareLink(c.UserContext(), list.ID)
+		if err == nil {
+			card = withShareLink(c, card, link)
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return card, err
+		}
+	}
+
+	return card, nil
+}
+
+// listETag identifies the version of a list, for If-Match.
+func listETag(list model.List) string {
+	return fmt.Sprintf(`"%d"`, list.Version)
+}
+
+// expectedVersion returns the version of a list an update was made from: the If-Match
+// header's for API clients, or the edit form's. It returns 0 if there is neither.
+func expectedVersion(c *fiber.Ctx, formVersion int32) (int32, error) {
+	match := c.Get(fiber.HeaderIfMatch)
+	if match == "" {
+		return formVersion, nil
+	}
+
+	version, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(match, "W/"), `"`), 10, 32)
+	if err != nil {
+		return 0, fiber.NewError(fiber.StatusBadRequest, "If-Match must be an ETag from this list")
+	}
+	return int32(version), nil
+}
+
+// tagOptions returns the tags that can be put on a list, those in its workspace. Tags belong
+// to the workspace, so people the list is shared with from outside it get none.
+func (l *ListsHandlers) tagOptions(c *fiber.Ctx, list model.List) ([]model.Tag, error) {
+	member, err := l.inListWorkspace(c, list)
+	if err != nil || !member {
+		return nil, err
+	}
+
+	tags, err := l.repo.FilterTags(c.UserContext(), list.WorkspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	return tagModels(tags), nil
+}
+
+type SetListTagsRequest struct {
+	TagIDs []int64 `form:"tag_id"`
+}
+
+// SetTags replaces the tags on a list with those checked in its tag picker.
+func (l *ListsHandlers) SetTags(c *fiber.Ctx) error {
+	list, permission, err := l.authorizeList(c, repo.PermissionEditor)
+	if err != nil {
+		return err
+	}
+
+	member, err := l.inListWorkspace(c, list)
+	if err != nil {
+		return err
+	}
+	if !member {
+		return fiber.ErrForbidden
+	}
+
+	var req SetListTagsRequest
+	if err = c.BodyParser(&req); err != nil {
+		return err
+	}
+
+	if err = l.repo.SetListTags(c.UserContext(), list.ID, req.TagIDs); err != nil {
+		return err
+	}
+
+	return l.renderCard(c, list, permission, false)
+}
+
+// Activity shows the latest activity on a list to anyone who can see it. Only owners see
+// who it was shared with.
+func (l *ListsHandlers) Activity(c *fiber.Ctx) error {
+	list, permission, err := l.authorizeList(c, repo.PermissionViewer)
+	if err != nil {
+		return err
+	}
+
+	entries, err := l.repo.FilterAuditEvents(c.UserContext(), repo.AuditQuery{ListID: list.ID, Limit: listActivityEntries})
+	if err != nil {
+		return err
+	}
+
+	showMembers := repo.PermissionAllows(permission, repo.PermissionOwner)
+	return l.renderer.RenderComponent(c, 200, listviews.Activity(entries, showMembers))
+}
+
+// inListWorkspace reports whether the current user is a member of a list's workspace.
+func (l *ListsHandlers) inListWorkspace(c *fiber.Ctx, list model.List) (bool, error) {
+	if list.WorkspaceID == activeWorkspace(c).ID {
+		return true, nil
+	}
+
+	role, err := l.repo.GetWorkspaceRole(c.UserContext(), list.WorkspaceID, currentUser(c).ID)
+	return role != "", err
+}
+
+// authorizeList returns the list named by the :id route parameter and the current user's
+// permission on it, if that allows what they need. Lists they cannot see at all are not
+// found, so that list IDs do not reveal which lists exist.
+func (l *ListsHandlers) authorizeList(c *fiber.Ctx, need string) (model.List, string, error) {
 	var params struct {
 		ID int64 `params:"id"`
 	}
 	if err := c.ParamsParser(&params); err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		return model.List{}, "", fiber.NewError(fiber.StatusBadRequest, err.Error())
 	}
 
-	err := l.repo.DeleteListById(c.Context(), params.ID)
+	permission, err := l.repo.GetListPermission(c.UserContext(), activeWorkspace(c).ID, params.ID, currentUser(c).ID)
 	if err != nil {
-		return err
+		return model.List{}, "", err
+	}
+	if permission == "" {
+		return model.List{}, "", fiber.ErrNotFound
+	}
+	if !repo.PermissionAllows(permission, need) {
+		return model.List{}, "", fiber.ErrForbidden
 	}
 
-	return c.SendStatus(fiber.StatusNoContent)
+	list, err := l.repo.GetListById(c.UserContext(), params.ID)
+	return list, permission, err
 }
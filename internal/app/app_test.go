@@ -1,45 +1,551 @@
 package app
 
 import (
-	"database/sql"
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cognito "github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
 	"github.com/gofiber/fiber/v2"
-	"github.com/joho/godotenv"
+	"htmxtodo/gen/htmxtodo_dev/public/model"
 	"htmxtodo/internal/config"
-	"log"
+	"htmxtodo/internal/constants"
+	"htmxtodo/internal/fakecognito"
+	"htmxtodo/internal/htmxtest"
+	"htmxtodo/internal/repo"
+	"io"
+	"net/http"
 	"net/http/httptest"
-	"os"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 )
 
-var testApp *fiber.App
+// The handler tests run the whole app against the in-memory repository and a fakecognito
+// server, so they need neither a database nor AWS.
 
-func TestMain(m *testing.M) {
-	err := godotenv.Load("../../.env.test")
+const (
+	testPassword   = "correct horse battery staple"
+	csrfCookieName = "htmxtodo_csrf"
+)
+
+// testApp is the app with fresh, empty state.
+type testApp struct {
+	t       *testing.T
+	app     *fiber.App
+	repo    *repo.MemoryRepository
+	cognito *fakecognito.Server
+	// cognitoClient calls the fake like the app does, for the steps that have no page yet.
+	cognitoClient *cognito.Client
+}
+
+// newTestApp starts the app with the test config, as changed by configure.
+func newTestApp(t *testing.T, configure ...func(cfg *config.Config)) *testApp {
+	r := repo.NewMemory()
+	fake := fakecognito.New()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	cfg := config.NewTestConfig(r)
+	cfg.StaticFS = http.Dir("../../static")
+	cfg.CognitoEndpoint = server.URL
+	for _, fn := range configure {
+		fn(cfg)
+	}
+
+	cognitoClient, err := newCognitoClient(context.Background(), server.URL)
 	if err != nil {
-		log.Fatalf("Error loading .env.test file: %s", err.Error())
+		t.Fatal(err)
 	}
 
-	db, err := sql.Open("postgres", os.Getenv("DATABASE_URL"))
+	return &testApp{t: t, app: New(cfg), repo: r, cognito: fake, cognitoClient: cognitoClient}
+}
+
+// client is a browser: it keeps the cookies it is given, and sends the CSRF token with
+// every request like the htmx config does.
+type client struct {
+	a       *testApp
+	cookies map[string]string
+	// csrfToken is the token in the forms of the last page that had one.
+	csrfToken string
+}
+
+func (a *testApp) client() *client {
+	return &client{a: a, cookies: map[string]string{}}
+}
+
+// response is a response with its body read.
+type response struct {
+	*http.Response
+	body string
+}
+
+func (r response) page(t *testing.T) *htmxtest.Page {
+	return htmxtest.New(t, r.Response, r.body)
+}
+
+func (c *client) get(path string) response {
+	return c.do("GET", path, nil)
+}
+
+func (c *client) do(method string, path string, form url.Values) response {
+	c.a.t.Helper()
+	return c.doWith(method, path, form, nil)
+}
+
+// htmx makes a request like htmx does for an element that is not boosted.
+func (c *client) htmx(method string, path string, form url.Values) response {
+	c.a.t.Helper()
+	return c.doWith(method, path, form, http.Header{"Hx-Request": {"true"}})
+}
+
+func (c *client) doWith(method string, path string, form url.Values, header http.Header) response {
+	c.a.t.Helper()
+
+	// forms carry the token of the page they are on, and other requests send it in a
+	// header like the htmx config does
+	var body io.Reader
+	if form != nil {
+		if !form.Has(constants.CsrfInputName) && c.csrfToken != "" {
+			form = cloneValues(form)
+			form.Set(constants.CsrfInputName, c.csrfToken)
+		}
+		body = strings.NewReader(form.Encode())
+	}
+	req := httptest.NewRequest(method, path, body)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if form != nil {
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationForm)
+	} else if token, ok := c.cookies[csrfCookieName]; ok {
+		req.Header.Set("X-CSRF-Token", token)
+	}
+	for name, value := range c.cookies {
+		req.AddCookie(&http.Cookie{Name: name, Value: value})
+	}
+
+	resp, err := c.a.app.Test(req, -1)
 	if err != nil {
-		log.Fatalf("failed to open db: %v", err)
+		c.a.t.Fatal(err)
 	}
-	defer func(db *sql.DB) {
-		err := db.Close()
-		if err != nil {
-			log.Fatalf("failed to close db: %+v", err)
+	defer resp.Body.Close()
+
+	for _, cookie := range resp.Cookies() {
+		if cookie.MaxAge < 0 || cookie.Value == "" || (!cookie.Expires.IsZero() && cookie.Expires.Before(time.Now())) {
+			delete(c.cookies, cookie.Name)
+		} else {
+			c.cookies[cookie.Name] = cookie.Value
 		}
-	}(db)
+	}
 
-	cfg := config.NewTestConfig(db)
-	testApp = New(cfg)
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		c.a.t.Fatal(err)
+	}
+	r := response{Response: resp, body: string(b)}
 
-	os.Exit(m.Run())
+	if strings.Contains(r.body, constants.CsrfInputName) {
+		if inputs := r.page(c.a.t).Find(`input[name="` + constants.CsrfInputName + `"]`); len(inputs) > 0 {
+			c.csrfToken = inputs[0].Attr("value")
+		}
+	}
+	return r
+}
+
+func cloneValues(values url.Values) url.Values {
+	clone := url.Values{}
+	for key, value := range values {
+		clone[key] = append([]string(nil), value...)
+	}
+	return clone
+}
+
+// login logs a new client in as a user, creating the user if needed.
+func (a *testApp) login(email string) (*client, model.AppUser) {
+	a.t.Helper()
+	a.cognito.AddUser(email, testPassword)
+	c := a.client()
+
+	c.get("/login")
+	resp := c.do("POST", "/login", url.Values{"email": {email}, "password": {testPassword}})
+	expectRedirect(a.t, resp, "/app/lists")
+
+	// logging in starts a new session, which needs a new CSRF token
+	expectStatus(a.t, c.get("/app/lists"), fiber.StatusOK)
+
+	user, err := a.repo.GetUserByEmail(context.Background(), email)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return c, user
+}
+
+// loginAdmin logs a new client in as a new admin.
+func (a *testApp) loginAdmin(email string) (*client, model.AppUser) {
+	a.t.Helper()
+	user, err := a.repo.FindOrCreateUser(context.Background(), email)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	if _, err = a.repo.UpdateUserRole(context.Background(), user.ID, repo.RoleAdmin); err != nil {
+		a.t.Fatal(err)
+	}
+	return a.login(email)
+}
+
+func expectStatus(t *testing.T, resp response, status int) {
+	t.Helper()
+	if resp.StatusCode != status {
+		t.Fatalf("expected %d, got %d: %s", status, resp.StatusCode, resp.body)
+	}
+}
+
+func expectRedirect(t *testing.T, resp response, location string) {
+	t.Helper()
+	if resp.StatusCode != fiber.StatusFound || resp.Header.Get(fiber.HeaderLocation) != location {
+		t.Fatalf("expected redirect to %s, got %d to %q: %s", location, resp.StatusCode, resp.Header.Get(fiber.HeaderLocation), resp.body)
+	}
+}
+
+func expectBody(t *testing.T, resp response, contains string) {
+	t.Helper()
+	if !strings.Contains(resp.body, contains) {
+		t.Fatalf("expected the response to contain %q, got %s", contains, resp.body)
+	}
 }
 
 func TestLogin(t *testing.T) {
-	req := httptest.NewRequest("GET", "/login", nil)
-	resp, _ := testApp.Test(req)
-	if resp.StatusCode != fiber.StatusOK {
-		t.Fatal("response was not 200, was ", resp.Status)
+	a := newTestApp(t)
+	expectStatus(t, a.client().get("/login"), fiber.StatusOK)
+}
+
+func TestLoginFormHasCsrfToken(t *testing.T) {
+	a := newTestApp(t)
+	c := a.client()
+	resp := c.get("/login")
+
+	csrfCookie := c.cookies[csrfCookieName]
+	if csrfCookie == "" {
+		t.Fatal("no CSRF cookie was set")
+	}
+
+	page := resp.page(t)
+	if token := page.CSRFToken(); token != csrfCookie {
+		t.Fatalf("expected the form's token to be the cookie's %q, got %q", csrfCookie, token)
+	}
+	page.ExpectAttr("#login-form", "action", "/login")
+	page.Expect("#login-form").ExpectField("email", "")
+}
+
+func TestRootRedirectsToLogin(t *testing.T) {
+	a := newTestApp(t)
+	expectRedirect(t, a.client().get("/"), "/login")
+}
+
+func TestAdminRequiresLogin(t *testing.T) {
+	a := newTestApp(t)
+	expectRedirect(t, a.client().get("/admin/users"), "/login")
+}
+
+func TestInternalRoutesRequireLogin(t *testing.T) {
+	a := newTestApp(t)
+	c := a.client()
+	c.get("/login")
+
+	routes := []struct{ method, path string }{
+		{"GET", "/app/lists"},
+		{"POST", "/app/lists"},
+		{"GET", "/app/lists/1/edit"},
+		{"PATCH", "/app/lists/1"},
+		{"DELETE", "/app/lists/1"},
+		{"POST", "/app/lists/1/restore"},
+		{"GET", "/app/lists/1/sharing"},
+		{"POST", "/app/lists/1/members"},
+		{"DELETE", "/app/lists/1/members/2"},
+		{"DELETE", "/app/lists/1/invitations/2"},
+		{"POST", "/app/lists/1/owner"},
+		{"POST", "/app/lists/1/share-link"},
+		{"DELETE", "/app/lists/1/share-link"},
+		{"PUT", "/app/lists/1/tags"},
+		{"GET", "/app/lists/1/activity"},
+		{"GET", "/app/trash"},
+		{"DELETE", "/app/trash/1"},
+		{"GET", "/app/tags"},
+		{"POST", "/app/tags"},
+		{"PATCH", "/app/tags/1"},
+		{"POST", "/app/tags/1/merge"},
+		{"DELETE", "/app/tags/1"},
+		{"GET", "/app/workspaces/new"},
+		{"POST", "/app/workspaces"},
+		{"POST", "/app/workspaces/switch"},
+		{"GET", "/app/workspace"},
+		{"POST", "/app/workspace/members"},
+		{"DELETE", "/app/workspace/members/1"},
+		{"POST", "/app/logout"},
+		{"POST", "/app/impersonation/stop"},
+		{"GET", "/admin/"},
+		{"GET", "/admin/audit"},
+		{"POST", "/admin/users/1/disable"},
+		{"POST", "/admin/users/1/enable"},
+		{"POST", "/admin/users/1/reset-password"},
+		{"POST", "/admin/users/1/impersonate"},
 	}
+
+	for _, route := range routes {
+		t.Run(route.method+" "+route.path, func(t *testing.T) {
+			expectRedirect(t, c.do(route.method, route.path, url.Values{}), "/login")
+		})
+	}
+}
+
+func TestCsrfTokenIsRequired(t *testing.T) {
+	a := newTestApp(t)
+	c, user := a.login("csrf@example.com")
+
+	token := c.cookies[csrfCookieName]
+	delete(c.cookies, csrfCookieName)
+	expectStatus(t, c.do("POST", "/app/lists", url.Values{"name": {"Forged"}}), fiber.StatusForbidden)
+
+	c.cookies[csrfCookieName] = "not-the-token"
+	expectStatus(t, c.do("POST", "/app/lists", url.Values{"name": {"Forged"}}), fiber.StatusForbidden)
+
+	// the token in the form must match the cookie, too
+	c.cookies[csrfCookieName] = token
+	form := url.Values{"name": {"Forged"}, "_csrf": {"not-the-token"}}
+	expectStatus(t, c.do("POST", "/app/lists", form), fiber.StatusForbidden)
+
+	workspaces, err := a.repo.FilterWorkspaces(context.Background(), user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := a.repo.FilterLists(context.Background(), repo.ListQuery{WorkspaceID: workspaces[0].ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Results) != 0 {
+		t.Fatalf("expected no lists to be created, got %+v", page.Results)
+	}
+
+	form.Set("_csrf", token)
+	expectStatus(t, c.do("POST", "/app/lists", form), fiber.StatusOK)
+}
+
+func TestSubmitLogin(t *testing.T) {
+	a := newTestApp(t)
+	c := a.client()
+	c.get("/login")
+
+	// unknown emails and wrong passwords get the same message
+	resp := c.do("POST", "/login", url.Values{"email": {"user@example.com"}, "password": {testPassword}})
+	expectStatus(t, resp, fiber.StatusUnprocessableEntity)
+	expectBody(t, resp, "Incorrect email or password.")
+
+	a.cognito.AddUser("user@example.com", testPassword)
+	resp = c.do("POST", "/login", url.Values{"email": {"user@example.com"}, "password": {"wrong"}})
+	expectStatus(t, resp, fiber.StatusUnprocessableEntity)
+	expectBody(t, resp, "Incorrect email or password.")
+
+	a.cognito.AddUser("challenged@example.com", testPassword)
+	a.cognito.RequireNewPassword("challenged@example.com")
+	resp = c.do("POST", "/login", url.Values{"email": {"challenged@example.com"}, "password": {testPassword}})
+	expectStatus(t, resp, fiber.StatusUnprocessableEntity)
+	expectBody(t, resp, "not supported yet")
+
+	// emails are normalized, so this is the same user as above
+	resp = c.do("POST", "/login", url.Values{"email": {" User@Example.com "}, "password": {testPassword}})
+	expectRedirect(t, resp, "/app/lists")
+	resp.page(t).ExpectLocation("/app/lists")
+	expectStatus(t, c.get("/app/lists"), fiber.StatusOK)
+
+	// logged in users are sent to their lists
+	expectRedirect(t, c.get("/login"), "/app/lists")
+	expectRedirect(t, c.get("/register"), "/app/lists")
+}
+
+func TestDisabledUsersCannotLogIn(t *testing.T) {
+	a := newTestApp(t)
+	user, err := a.repo.FindOrCreateUser(context.Background(), "disabled@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = a.repo.UpdateUserDisabled(context.Background(), user.ID, true); err != nil {
+		t.Fatal(err)
+	}
+
+	a.cognito.AddUser(user.Email, testPassword)
+	c := a.client()
+	c.get("/login")
+	resp := c.do("POST", "/login", url.Values{"email": {user.Email}, "password": {testPassword}})
+	expectStatus(t, resp, fiber.StatusUnprocessableEntity)
+	expectBody(t, resp, "This account has been disabled.")
+}
+
+func TestLoginAcceptsInvitations(t *testing.T) {
+	a := newTestApp(t)
+	owner, _ := a.login("owner@example.com")
+	list := createList(t, a, owner, "Invited")
+
+	expectRedirect(t, owner.do("POST", listPath(list, "/members"), url.Values{
+		"email":      {"invitee@example.com"},
+		"permission": {repo.PermissionEditor},
+	}), listPath(list, "/sharing"))
+
+	invitee, _ := a.login("invitee@example.com")
+	expectStatus(t, invitee.get(listPath(list, "/edit")), fiber.StatusOK)
+}
+
+func TestLogout(t *testing.T) {
+	a := newTestApp(t)
+	c, _ := a.login("logout@example.com")
+
+	before := c.cookies[constants.SessionCookieName]
+
+	expectRedirect(t, c.do("POST", "/app/logout", nil), "/login")
+	after := c.cookies[constants.SessionCookieName]
+	if after == "" || after == before {
+		t.Fatalf("expected a new session ID after logging out, had %q, got %q", before, after)
+	}
+	expectBody(t, c.get("/login"), "You have been logged out.")
+	expectRedirect(t, c.get("/app/lists"), "/login")
+
+	// the ID from before logging out is no use to anyone who captured it
+	captured := a.client()
+	captured.cookies[constants.SessionCookieName] = before
+	expectRedirect(t, captured.get("/app/lists"), "/login")
+}
+
+func TestRegister(t *testing.T) {
+	a := newTestApp(t)
+	c := a.client()
+	expectStatus(t, c.get("/register"), fiber.StatusOK)
+
+	resp := c.do("POST", "/register", url.Values{
+		"email":                 {"new@example.com"},
+		"password":              {testPassword},
+		"password_confirmation": {"something else"},
+	})
+	expectStatus(t, resp, fiber.StatusUnprocessableEntity)
+	expectBody(t, resp, "passwords do not match")
+
+	resp = c.do("POST", "/register", url.Values{
+		"email":                 {"new@example.com"},
+		"password":              {"weak"},
+		"password_confirmation": {"weak"},
+	})
+	expectStatus(t, resp, fiber.StatusUnprocessableEntity)
+	expectBody(t, resp, "Password not long enough")
+
+	form := url.Values{
+		"email":                 {" New@Example.com"},
+		"password":              {testPassword},
+		"password_confirmation": {testPassword},
+	}
+	expectRedirect(t, c.do("POST", "/register", form), "/login")
+	expectStatus(t, c.do("POST", "/register", form), fiber.StatusUnprocessableEntity)
+
+	login := url.Values{"email": {"new@example.com"}, "password": {testPassword}}
+	resp = c.do("POST", "/login", login)
+	expectStatus(t, resp, fiber.StatusUnprocessableEntity)
+	expectBody(t, resp, "Please confirm your email address")
+
+	code := a.cognito.LastCode("new@example.com")
+	if code == "" {
+		t.Fatal("expected a confirmation code to be sent to new@example.com")
+	}
+	_, err := a.cognitoClient.ConfirmSignUp(context.Background(), &cognito.ConfirmSignUpInput{
+		ClientId:         aws.String("client"),
+		Username:         aws.String("new@example.com"),
+		ConfirmationCode: aws.String(code),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectRedirect(t, c.do("POST", "/login", login), "/app/lists")
+}
+
+func TestAdmin(t *testing.T) {
+	a := newTestApp(t)
+	userClient, user := a.login("user@example.com")
+	admin, adminUser := a.loginAdmin("admin@example.com")
+
+	nonAdmin, _ := a.login("other@example.com")
+	expectStatus(t, nonAdmin.get("/admin/users"), fiber.StatusForbidden)
+	expectStatus(t, nonAdmin.do("POST", userPath(user, "/disable"), nil), fiber.StatusForbidden)
+
+	expectStatus(t, admin.get("/admin/"), fiber.StatusOK)
+	resp := admin.get("/admin/users?q=user@")
+	expectStatus(t, resp, fiber.StatusOK)
+	expectBody(t, resp, user.Email)
+	expectStatus(t, admin.get("/admin/audit"), fiber.StatusOK)
+	resp = admin.get("/admin/audit?from=yesterday")
+	expectStatus(t, resp, fiber.StatusBadRequest)
+	resp.page(t).ExpectText("h1", "400 - ")
+
+	// admins cannot lock themselves out
+	expectStatus(t, admin.do("POST", userPath(adminUser, "/disable"), nil), fiber.StatusUnprocessableEntity)
+	expectStatus(t, admin.do("POST", "/admin/users/0/disable", nil), fiber.StatusNotFound)
+
+	expectStatus(t, admin.do("POST", userPath(user, "/disable"), nil), fiber.StatusOK)
+	if got, _ := a.repo.GetUserById(context.Background(), user.ID); got.DisabledAt == nil {
+		t.Fatal("expected the user to be disabled")
+	}
+	// and logged out of the session they had
+	expectRedirect(t, userClient.get("/app/lists"), "/login")
+	expectStatus(t, admin.do("POST", userPath(user, "/impersonate"), nil), fiber.StatusUnprocessableEntity)
+	expectStatus(t, admin.do("POST", userPath(user, "/enable"), nil), fiber.StatusOK)
+
+	expectStatus(t, admin.do("POST", userPath(user, "/reset-password"), nil), fiber.StatusOK)
+	if a.cognito.LastCode(user.Email) == "" {
+		t.Fatalf("expected a reset code to be sent to %s", user.Email)
+	}
+	c := a.client()
+	c.get("/login")
+	expectBody(t, c.do("POST", "/login", url.Values{"email": {user.Email}, "password": {testPassword}}), "Your password must be reset")
+
+	entries, err := a.repo.FilterAuditEvents(context.Background(), repo.AuditQuery{EntityType: repo.EntityUser})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actions []string
+	for _, entry := range entries {
+		actions = append(actions, entry.Action)
+	}
+	if fmt.Sprint(actions) != "[user.reset_password user.enable user.disable]" {
+		t.Fatalf("expected the admin's actions to be audited, got %v", actions)
+	}
+}
+
+func TestImpersonation(t *testing.T) {
+	a := newTestApp(t)
+	user, userModel := a.login("user@example.com")
+	admin, _ := a.loginAdmin("admin@example.com")
+	createList(t, a, user, "Private to the user")
+
+	// each switch gives the session a new ID
+	adminSession := admin.cookies[constants.SessionCookieName]
+	expectRedirect(t, admin.do("POST", userPath(userModel, "/impersonate"), nil), "/app/lists")
+	impersonatingSession := admin.cookies[constants.SessionCookieName]
+	if impersonatingSession == adminSession {
+		t.Fatal("expected a new session ID when impersonating")
+	}
+	resp := admin.get("/app/lists")
+	expectStatus(t, resp, fiber.StatusOK)
+	expectBody(t, resp, "Private to the user")
+
+	// the admin area is closed while impersonating a user
+	expectStatus(t, admin.get("/admin/users"), fiber.StatusForbidden)
+
+	expectRedirect(t, admin.do("POST", "/app/impersonation/stop", nil), "/admin/users")
+	if session := admin.cookies[constants.SessionCookieName]; session == impersonatingSession || session == adminSession {
+		t.Fatal("expected a new session ID when impersonation stops")
+	}
+	expectStatus(t, admin.get("/admin/users"), fiber.StatusOK)
+
+	// stopping when not impersonating anyone does nothing
+	expectRedirect(t, user.do("POST", "/app/impersonation/stop", nil), "/app/lists")
+}
+
+func userPath(user model.AppUser, suffix string) string {
+	return fmt.Sprintf("/admin/users/%d%s", user.ID, suffix)
 }
@@ -0,0 +1,84 @@
+package app
+
+import (
+	"context"
+	"errors"
+	cognito "github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+	fiberlog "github.com/gofiber/fiber/v2/log"
+	"htmxtodo/internal/config"
+	"htmxtodo/internal/fakecognito"
+	"net"
+	"net/http"
+)
+
+// CognitoClient is the subset of the Cognito API the app uses, satisfied by *cognito.Client.
+type CognitoClient interface {
+	SignUp(ctx context.Context, params *cognito.SignUpInput, optFns ...func(*cognito.Options)) (*cognito.SignUpOutput, error)
+	InitiateAuth(ctx context.Context, params *cognito.InitiateAuthInput, optFns ...func(*cognito.Options)) (*cognito.InitiateAuthOutput, error)
+	AdminResetUserPassword(ctx context.Context, params *cognito.AdminResetUserPasswordInput, optFns ...func(*cognito.Options)) (*cognito.AdminResetUserPasswordOutput, error)
+}
+
+// newCognitoClient returns a client for AWS Cognito, or for the Cognito API at endpoint if
+// it is set.
+func newCognitoClient(ctx context.Context, endpoint string) (*cognito.Client, error) {
+	if endpoint == config.CognitoEndpointFake {
+		var err error
+		if endpoint, err = startFakeCognito(); err != nil {
+			return nil, err
+		}
+	}
+
+	awsCfg, err := config.AWSConfig(ctx, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return cognito.NewFromConfig(awsCfg), nil
+}
+
+// startFakeCognito serves a fakecognito server on a local port. New users are confirmed
+// straight away, and the codes it sends are logged.
+func startFakeCognito() (string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+
+	fake := fakecognito.New()
+	fake.AutoConfirm = true
+	fake.OnCode = func(username string, code string) {
+		fiberlog.Infof("fake Cognito sent code %s to %s", code, username)
+	}
+	go func() {
+		fiberlog.Error("fake Cognito stopped: ", http.Serve(listener, fake))
+	}()
+
+	fiberlog.Warn("using a fake Cognito at ", listener.Addr())
+	return "http://" + listener.Addr().String(), nil
+}
+
+// isBadCredentials reports whether a login failed because of a wrong email or password.
+func isBadCredentials(err error) bool {
+	var notAuthorized *types.NotAuthorizedException
+	var userNotFound *types.UserNotFoundException
+	return errors.As(err, &notAuthorized) || errors.As(err, &userNotFound)
+}
+
+// loginErrorMessage turns a failed InitiateAuth into a message safe to show on the login
+// form. It does not reveal whether the email belongs to an account.
+func loginErrorMessage(err error) string {
+	var notConfirmed *types.UserNotConfirmedException
+	var resetRequired *types.PasswordResetRequiredException
+
+	switch {
+	case isBadCredentials(err):
+		return "Incorrect email or password."
+	case errors.As(err, &notConfirmed):
+		return "Please confirm your email address before logging in."
+	case errors.As(err, &resetRequired):
+		return "Your password must be reset before you can log in."
+	default:
+		fiberlog.Error("login failed: ", err)
+		return "Login failed, please try again later."
+	}
+}
@@ -5,13 +5,20 @@ import (
 	"errors"
 	"github.com/gofiber/fiber/v2"
 	fiberlog "github.com/gofiber/fiber/v2/log"
+	"github.com/gofiber/fiber/v2/utils"
 	"htmxtodo/internal/view"
 	errorviews "htmxtodo/views/errors"
 	"net/http"
 	"strings"
 )
 
-func errorHandler(c *fiber.Ctx, err error) error {
+func newErrorHandler(renderer *view.Renderer) fiber.ErrorHandler {
+	return func(c *fiber.Ctx, err error) error {
+		return errorHandler(renderer, c, err)
+	}
+}
+
+func errorHandler(renderer *view.Renderer, c *fiber.Ctx, err error) error {
 	// Status code defaults to 500
 	code := http.StatusInternalServerError
 	msg := err.Error()
@@ -34,10 +41,23 @@ func errorHandler(c *fiber.Ctx, err error) error {
 
 	// Render a template for 404 errors
 	if code == http.StatusNotFound {
-		return view.RenderComponent(c, code, errorviews.Error404())
+		return renderer.RenderComponent(c, code, errorviews.Error404())
+	}
+
+	if code == http.StatusForbidden {
+		return renderer.RenderComponent(c, code, errorviews.Error403())
+	}
+
+	// Log server errors, and render a default template that does not give their details away
+	if code >= http.StatusInternalServerError {
+		fiberlog.Error(msg)
+		return renderer.RenderComponent(c, code, errorviews.GenericError(code, utils.StatusMessage(code)))
 	}
 
-	// Log 500 errors and also render a default template
-	fiberlog.Error(msg)
-	return view.RenderComponent(c, code, errorviews.Error500())
+	// Other client errors were returned on purpose, with a message for the user
+	fiberlog.Info(code, " ", c.Method(), " ", c.Path(), ": ", msg)
+	if e == nil {
+		msg = utils.StatusMessage(code)
+	}
+	return renderer.RenderComponent(c, code, errorviews.GenericError(code, msg))
 }
@@ -0,0 +1,440 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"htmxtodo/gen/htmxtodo_dev/public/model"
+	"htmxtodo/internal/repo"
+	listviews "htmxtodo/views/lists"
+	trashviews "htmxtodo/views/trash"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// createList creates a list through the app, returning it as stored.
+func createList(t *testing.T, a *testApp, c *client, name string) model.List {
+	t.Helper()
+	expectStatus(t, c.do("POST", "/app/lists", url.Values{"name": {name}}), fiber.StatusOK)
+
+	// the newest list is the one just created
+	events, err := a.repo.FilterAuditEvents(context.Background(), repo.AuditQuery{Action: "list.create", Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) == 0 {
+		t.Fatalf("list %s was not created", name)
+	}
+
+	list, err := a.repo.GetListById(context.Background(), events[0].EntityID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return list
+}
+
+func listPath(list model.List, suffix string) string {
+	return fmt.Sprintf("/app/lists/%d%s", list.ID, suffix)
+}
+
+func TestCreateList(t *testing.T) {
+	a := newTestApp(t)
+	c, _ := a.login("lists@example.com")
+
+	resp := c.do("POST", "/app/lists", url.Values{"name": {"  "}})
+	expectStatus(t, resp, fiber.StatusUnprocessableEntity)
+	resp.page(t).ExpectOOB("create-list-form").ExpectText(".is-danger", "name is required")
+
+	resp = c.do("POST", "/app/lists", url.Values{"name": {"Groceries"}})
+	expectStatus(t, resp, fiber.StatusOK)
+	page := resp.page(t)
+	page.ExpectText(".list-card", "Groceries")
+	page.ExpectHx(".list-card", "swap", "outerHTML")
+
+	// the form is reset for the next list
+	page.ExpectOOB("create-list-form").ExpectField("name", "")
+
+	resp = c.do("POST", "/app/lists", url.Values{"name": {"Groceries"}})
+	expectStatus(t, resp, fiber.StatusUnprocessableEntity)
+	resp.page(t).ExpectOOB("create-list-form").ExpectText(".is-danger", "a list with that name already exists")
+
+	resp = c.get("/app/lists")
+	expectStatus(t, resp, fiber.StatusOK)
+	resp.page(t).ExpectCount(".list-card", 1)
+}
+
+func TestListIndexFilters(t *testing.T) {
+	a := newTestApp(t)
+	c, _ := a.login("filters@example.com")
+	createList(t, a, c, "Groceries")
+	createList(t, a, c, "Chores")
+
+	resp := c.get("/app/lists?q=grocer")
+	expectStatus(t, resp, fiber.StatusOK)
+	expectBody(t, resp, "Groceries")
+
+	expectStatus(t, c.get("/app/lists?sort=custom&name=o&incomplete=true"), fiber.StatusOK)
+	expectStatus(t, c.get("/app/lists?created_from=last+week"), fiber.StatusBadRequest)
+	expectStatus(t, c.get("/app/lists?after=not-a-cursor"), fiber.StatusBadRequest)
+}
+
+func TestListSearch(t *testing.T) {
+	a := newTestApp(t)
+	c, _ := a.login("search@example.com")
+	party := createList(t, a, c, "Party")
+	if _, err := a.repo.CreateItem(context.Background(), party.ID, "Buy apples"); err != nil {
+		t.Fatal(err)
+	}
+	createList(t, a, c, "Chores")
+
+	// active search only replaces the cards, highlighting why each list matched
+	resp := c.doWith("GET", "/app/lists?q=apples", nil, http.Header{"Hx-Request": {"true"}, "Hx-Target": {listviews.ListsId}})
+	expectStatus(t, resp, fiber.StatusOK)
+	page := resp.page(t)
+	page.ExpectNone("#create-list-form")
+	page.ExpectCount(".list-card", 1)
+	page.ExpectText(".list-card mark", "apples")
+
+	// searches can be linked to
+	resp = c.get("/app/lists?q=chores")
+	expectStatus(t, resp, fiber.StatusOK)
+	page = resp.page(t)
+	page.ExpectCount(".list-card", 1)
+	page.ExpectAttr(`input[name="q"]`, "value", "chores")
+}
+
+func TestEditAndUpdateList(t *testing.T) {
+	a := newTestApp(t)
+	c, _ := a.login("edit@example.com")
+	list := createList(t, a, c, "Draft")
+	createList(t, a, c, "Taken")
+
+	resp := c.get(listPath(list, "/edit"))
+	expectStatus(t, resp, fiber.StatusOK)
+	if resp.Header.Get(fiber.HeaderETag) != `"1"` {
+		t.Fatalf("expected ETag \"1\", got %s", resp.Header.Get(fiber.HeaderETag))
+	}
+
+	version := strconv.Itoa(int(list.Version))
+	expectStatus(t, c.do("PATCH", listPath(list, ""), url.Values{"name": {""}, "version": {version}}), fiber.StatusBadRequest)
+	expectStatus(t, c.do("PATCH", listPath(list, ""), url.Values{"name": {"Final"}}), fiber.StatusPreconditionRequired)
+	expectStatus(t, c.do("PATCH", listPath(list, ""), url.Values{"name": {"Taken"}, "version": {version}}), fiber.StatusUnprocessableEntity)
+
+	resp = c.do("PATCH", listPath(list, ""), url.Values{"name": {"Final"}, "version": {version}})
+	expectStatus(t, resp, fiber.StatusOK)
+	expectBody(t, resp, "Final")
+
+	// an edit of the old version conflicts, showing both names
+	resp = c.do("PATCH", listPath(list, ""), url.Values{"name": {"Mine"}, "version": {version}})
+	expectStatus(t, resp, fiber.StatusConflict)
+	expectBody(t, resp, "Final")
+	expectBody(t, resp, "Mine")
+
+	// API clients send the version in If-Match, which wins over the form
+	ifMatch := func(etag string) http.Header {
+		return http.Header{fiber.HeaderIfMatch: {etag}}
+	}
+	current := url.Values{"name": {"Mine"}, "version": {"2"}}
+	expectStatus(t, c.doWith("PATCH", listPath(list, ""), current, ifMatch(`"nope"`)), fiber.StatusBadRequest)
+	expectStatus(t, c.doWith("PATCH", listPath(list, ""), current, ifMatch(`"1"`)), fiber.StatusConflict)
+	resp = c.doWith("PATCH", listPath(list, ""), url.Values{"name": {"Mine"}}, ifMatch(`W/"2"`))
+	expectStatus(t, resp, fiber.StatusOK)
+	expectBody(t, resp, "Mine")
+	resp = c.get(listPath(list, "/edit"))
+	if resp.Header.Get(fiber.HeaderETag) != `"3"` {
+		t.Fatalf("expected ETag \"3\", got %s", resp.Header.Get(fiber.HeaderETag))
+	}
+
+	expectStatus(t, c.get(listPath(list, "/activity")), fiber.StatusOK)
+	expectStatus(t, c.get("/app/lists/999999/edit"), fiber.StatusNotFound)
+	expectStatus(t, c.get("/app/lists/nope/edit"), fiber.StatusNotFound)
+}
+
+func TestOtherUsersListsAreNotFound(t *testing.T) {
+	a := newTestApp(t)
+	owner, _ := a.login("owner@example.com")
+	list := createList(t, a, owner, "Private")
+
+	other, _ := a.login("other@example.com")
+	expectStatus(t, other.get(listPath(list, "/edit")), fiber.StatusNotFound)
+	expectStatus(t, other.do("PATCH", listPath(list, ""), url.Values{"name": {"Mine"}, "version": {"1"}}), fiber.StatusNotFound)
+	expectStatus(t, other.do("DELETE", listPath(list, ""), nil), fiber.StatusNotFound)
+	expectStatus(t, other.get(listPath(list, "/sharing")), fiber.StatusNotFound)
+
+	// viewers can see a list, but not change it
+	expectRedirect(t, owner.do("POST", listPath(list, "/members"), url.Values{
+		"email":      {"other@example.com"},
+		"permission": {repo.PermissionViewer},
+	}), listPath(list, "/sharing"))
+	expectStatus(t, other.get(listPath(list, "/edit")), fiber.StatusForbidden)
+	expectStatus(t, other.do("DELETE", listPath(list, ""), nil), fiber.StatusForbidden)
+}
+
+func TestListActivity(t *testing.T) {
+	a := newTestApp(t)
+	owner, _ := a.login("owner@example.com")
+	list := createList(t, a, owner, "Watched")
+	expectStatus(t, owner.do("PATCH", listPath(list, ""), url.Values{"name": {"Renamed"}, "version": {"1"}}), fiber.StatusOK)
+
+	viewer, _ := a.login("viewer@example.com")
+	expectRedirect(t, owner.do("POST", listPath(list, "/members"), url.Values{
+		"email":      {"viewer@example.com"},
+		"permission": {repo.PermissionViewer},
+	}), listPath(list, "/sharing"))
+
+	resp := owner.htmx("GET", listPath(list, "/activity"), nil)
+	expectStatus(t, resp, fiber.StatusOK)
+	page := resp.page(t)
+	page.ExpectCount(".activity li", 3)
+	expectBody(t, resp, "viewer@example.com (viewer)")
+	expectBody(t, resp, "“Watched” → “Renamed”")
+
+	// viewers see what happened, but not who else the list is shared with
+	resp = viewer.htmx("GET", listPath(list, "/activity"), nil)
+	expectStatus(t, resp, fiber.StatusOK)
+	page = resp.page(t)
+	page.ExpectCount(".activity li", 3)
+	expectBody(t, resp, "shared the list")
+	expectBody(t, resp, "“Watched” → “Renamed”")
+	if strings.Contains(resp.body, "viewer@example.com") {
+		t.Fatalf("expected member emails to be left out, got %s", resp.body)
+	}
+
+	other, _ := a.login("other@example.com")
+	expectStatus(t, other.get(listPath(list, "/activity")), fiber.StatusNotFound)
+}
+
+func TestDeleteRestoreAndPurgeList(t *testing.T) {
+	a := newTestApp(t)
+	c, _ := a.login("trash@example.com")
+	list := createList(t, a, c, "Old")
+
+	expectStatus(t, c.do("POST", listPath(list, "/restore"), nil), fiber.StatusNotFound)
+	resp := c.htmx("DELETE", listPath(list, ""), nil)
+	expectStatus(t, resp, fiber.StatusNoContent)
+	resp.page(t).ExpectHeader("HX-Trigger", fmt.Sprintf(
+		`{"flash":{"messages":[{"level":"info","text":"List moved to trash.","undo":"%s"}]}}`, trashviews.RestoreUrl(list)))
+	expectStatus(t, c.get(listPath(list, "/edit")), fiber.StatusNotFound)
+
+	resp = c.get("/app/trash")
+	expectStatus(t, resp, fiber.StatusOK)
+	expectBody(t, resp, "Old")
+
+	// a new list has taken the name
+	createList(t, a, c, "Old")
+	expectStatus(t, c.do("POST", listPath(list, "/restore"), nil), fiber.StatusUnprocessableEntity)
+
+	expectStatus(t, c.do("DELETE", fmt.Sprintf("/app/trash/%d", list.ID), nil), fiber.StatusNoContent)
+	expectStatus(t, c.do("DELETE", fmt.Sprintf("/app/trash/%d", list.ID), nil), fiber.StatusNotFound)
+
+	other := createList(t, a, c, "Other")
+	expectStatus(t, c.do("DELETE", listPath(other, ""), nil), fiber.StatusNoContent)
+	resp = c.do("POST", listPath(other, "/restore"), nil)
+	expectStatus(t, resp, fiber.StatusOK)
+	expectBody(t, resp, "Other")
+	expectStatus(t, c.get(listPath(other, "/edit")), fiber.StatusOK)
+
+	// nobody outside the workspace can see, restore or purge what is in its trash
+	expectStatus(t, c.do("DELETE", listPath(other, ""), nil), fiber.StatusNoContent)
+	stranger, _ := a.login("stranger@example.com")
+	resp = stranger.get("/app/trash")
+	expectStatus(t, resp, fiber.StatusOK)
+	if strings.Contains(resp.body, "Other") {
+		t.Fatal("expected the list to be missing from someone else's trash")
+	}
+	expectStatus(t, stranger.do("POST", listPath(other, "/restore"), nil), fiber.StatusNotFound)
+	expectStatus(t, stranger.do("DELETE", fmt.Sprintf("/app/trash/%d", other.ID), nil), fiber.StatusNotFound)
+	if _, err := a.repo.GetDeletedList(context.Background(), other.ID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestShareList(t *testing.T) {
+	a := newTestApp(t)
+	owner, _ := a.login("owner@example.com")
+	friend, friendUser := a.login("friend@example.com")
+	list := createList(t, a, owner, "Shared")
+
+	expectStatus(t, owner.get(listPath(list, "/sharing")), fiber.StatusOK)
+
+	invalid := []url.Values{
+		{"email": {"not an email"}, "permission": {repo.PermissionViewer}},
+		{"email": {"friend@example.com"}, "permission": {repo.PermissionOwner}},
+		{"email": {"owner@example.com"}, "permission": {repo.PermissionEditor}},
+	}
+	for _, form := range invalid {
+		expectStatus(t, owner.do("POST", listPath(list, "/members"), form), fiber.StatusUnprocessableEntity)
+	}
+
+	expectRedirect(t, owner.do("POST", listPath(list, "/members"), url.Values{
+		"email":      {"Friend@Example.com"},
+		"permission": {repo.PermissionEditor},
+	}), listPath(list, "/sharing"))
+
+	resp := friend.get("/app/lists")
+	expectStatus(t, resp, fiber.StatusOK)
+	expectBody(t, resp, "Shared")
+	version := strconv.Itoa(int(list.Version))
+	expectStatus(t, friend.do("PATCH", listPath(list, ""), url.Values{"name": {"Ours"}, "version": {version}}), fiber.StatusOK)
+
+	expectStatus(t, owner.do("DELETE", listPath(list, fmt.Sprintf("/members/%d", friendUser.ID)), nil), fiber.StatusNoContent)
+	expectStatus(t, friend.get(listPath(list, "/edit")), fiber.StatusNotFound)
+
+	// unknown emails are invited
+	expectRedirect(t, owner.do("POST", listPath(list, "/members"), url.Values{
+		"email":      {"stranger@example.com"},
+		"permission": {repo.PermissionViewer},
+	}), listPath(list, "/sharing"))
+	invitations, err := a.repo.FilterListInvitations(context.Background(), list.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(invitations) != 1 {
+		t.Fatalf("expected one invitation, got %+v", invitations)
+	}
+	expectStatus(t, owner.do("DELETE", listPath(list, fmt.Sprintf("/invitations/%d", invitations[0].ID)), nil), fiber.StatusNoContent)
+	if invitations, _ = a.repo.FilterListInvitations(context.Background(), list.ID); len(invitations) != 0 {
+		t.Fatalf("expected the invitation to be deleted, got %+v", invitations)
+	}
+	expectStatus(t, owner.do("DELETE", listPath(list, "/members/nobody"), nil), fiber.StatusBadRequest)
+}
+
+func TestListViewers(t *testing.T) {
+	a := newTestApp(t)
+	owner, _ := a.login("owner@example.com")
+	viewer, _ := a.login("viewer@example.com")
+	list := createList(t, a, owner, "Read only")
+
+	expectRedirect(t, owner.do("POST", listPath(list, "/members"), url.Values{
+		"email":      {"viewer@example.com"},
+		"permission": {repo.PermissionViewer},
+	}), listPath(list, "/sharing"))
+
+	resp := viewer.get("/app/lists")
+	expectStatus(t, resp, fiber.StatusOK)
+	expectBody(t, resp, "Read only")
+
+	version := strconv.Itoa(int(list.Version))
+	expectStatus(t, viewer.get(listPath(list, "/edit")), fiber.StatusForbidden)
+	expectStatus(t, viewer.do("PATCH", listPath(list, ""), url.Values{"name": {"Mine"}, "version": {version}}), fiber.StatusForbidden)
+	expectStatus(t, viewer.do("DELETE", listPath(list, ""), nil), fiber.StatusForbidden)
+	expectStatus(t, viewer.get(listPath(list, "/sharing")), fiber.StatusForbidden)
+	expectStatus(t, viewer.do("POST", listPath(list, "/members"), url.Values{
+		"email":      {"someone@example.com"},
+		"permission": {repo.PermissionEditor},
+	}), fiber.StatusForbidden)
+
+	if got, _ := a.repo.GetListById(context.Background(), list.ID); got.Name != "Read only" {
+		t.Fatalf("expected the list to be unchanged, got %q", got.Name)
+	}
+}
+
+func TestTransferListOwnership(t *testing.T) {
+	a := newTestApp(t)
+	owner, _ := a.login("owner@example.com")
+	friend, friendUser := a.login("friend@example.com")
+	list := createList(t, a, owner, "Handed over")
+
+	form := url.Values{"user_id": {strconv.FormatInt(friendUser.ID, 10)}}
+	expectStatus(t, owner.do("POST", listPath(list, "/owner"), form), fiber.StatusUnprocessableEntity)
+
+	expectRedirect(t, owner.do("POST", listPath(list, "/members"), url.Values{
+		"email":      {friendUser.Email},
+		"permission": {repo.PermissionEditor},
+	}), listPath(list, "/sharing"))
+	expectRedirect(t, owner.do("POST", listPath(list, "/owner"), form), "/app/lists")
+
+	expectStatus(t, friend.get(listPath(list, "/sharing")), fiber.StatusOK)
+	// the old owner is left as an editor
+	expectStatus(t, owner.get(listPath(list, "/sharing")), fiber.StatusForbidden)
+	expectStatus(t, owner.get(listPath(list, "/edit")), fiber.StatusOK)
+}
+
+func TestShareLinks(t *testing.T) {
+	a := newTestApp(t)
+	owner, _ := a.login("owner@example.com")
+	list := createList(t, a, owner, "Public")
+
+	expectStatus(t, owner.do("POST", listPath(list, "/share-link"), url.Values{"expires_in_days": {"2"}}), fiber.StatusBadRequest)
+	resp := owner.do("POST", listPath(list, "/share-link"), url.Values{"expires_in_days": {"7"}})
+	expectStatus(t, resp, fiber.StatusOK)
+
+	link, err := a.repo.GetActiveShareLink(context.Background(), list.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectBody(t, resp, "/s/"+link.Token)
+
+	// anyone with the link can see the list, logged in or not
+	resp = a.client().get("/s/" + link.Token)
+	expectStatus(t, resp, fiber.StatusOK)
+	expectBody(t, resp, "Public")
+	stranger, _ := a.login("stranger@example.com")
+	expectBody(t, stranger.get("/s/"+link.Token), "Public")
+	expectStatus(t, a.client().get("/s/not-a-token"), fiber.StatusNotFound)
+
+	// but only the owner can share it
+	expectRedirect(t, owner.do("POST", listPath(list, "/members"), url.Values{
+		"email":      {"stranger@example.com"},
+		"permission": {repo.PermissionEditor},
+	}), listPath(list, "/sharing"))
+	expectStatus(t, stranger.do("POST", listPath(list, "/share-link"), nil), fiber.StatusForbidden)
+	expectStatus(t, stranger.do("DELETE", listPath(list, "/share-link"), nil), fiber.StatusForbidden)
+
+	expectStatus(t, owner.do("DELETE", listPath(list, "/share-link"), nil), fiber.StatusOK)
+	expectStatus(t, a.client().get("/s/"+link.Token), fiber.StatusNotFound)
+
+	// links stop working once they expire
+	expired := time.Now().Add(-time.Minute)
+	link, err = a.repo.CreateShareLink(context.Background(), list.ID, list.OwnerID, &expired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, a.client().get("/s/"+link.Token), fiber.StatusNotFound)
+}
+
+func TestSetListTags(t *testing.T) {
+	a := newTestApp(t)
+	c, user := a.login("tagger@example.com")
+	list := createList(t, a, c, "Tagged")
+
+	tag, err := a.repo.CreateTag(context.Background(), list.WorkspaceID, "urgent", "#ff3860")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp := c.do("PUT", listPath(list, "/tags"), url.Values{"tag_id": {strconv.FormatInt(tag.ID, 10)}})
+	expectStatus(t, resp, fiber.StatusOK)
+	expectBody(t, resp, "urgent")
+
+	tags, err := a.repo.FilterListTags(context.Background(), []int64{list.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags[list.ID]) != 1 {
+		t.Fatalf("expected %s's list to have one tag, got %+v", user.Email, tags)
+	}
+	resp = c.get("/app/lists")
+	expectStatus(t, resp, fiber.StatusOK)
+	resp.page(t).ExpectCount(".list-card .tag-picker", 1)
+
+	// editors from outside the workspace see the tags, but cannot use the workspace's others
+	editor, _ := a.login("editor@example.com")
+	expectRedirect(t, c.do("POST", listPath(list, "/members"), url.Values{
+		"email":      {"editor@example.com"},
+		"permission": {repo.PermissionEditor},
+	}), listPath(list, "/sharing"))
+	resp = editor.get("/app/lists")
+	expectStatus(t, resp, fiber.StatusOK)
+	page := resp.page(t)
+	page.ExpectText(".list-card .tag", "urgent")
+	page.ExpectNone(".list-card .tag-picker")
+	expectStatus(t, editor.do("PUT", listPath(list, "/tags"), url.Values{}), fiber.StatusForbidden)
+	if tags, _ = a.repo.FilterListTags(context.Background(), []int64{list.ID}); len(tags[list.ID]) != 1 {
+		t.Fatalf("expected the tag to stay on the list, got %+v", tags)
+	}
+}
@@ -1,21 +1,28 @@
 package app
 
 import (
+	"context"
 	"github.com/gofiber/fiber/v2"
 	fiberlog "github.com/gofiber/fiber/v2/log"
-	"github.com/gofiber/fiber/v2/middleware/session"
+	"htmxtodo/gen/htmxtodo_dev/public/model"
 	"htmxtodo/internal/constants"
+	"htmxtodo/internal/repo"
+	"time"
 )
 
-func SetLoggedIn(sessionStore *session.Store) fiber.Handler {
+// StatementTimeout gives each request a context that is cancelled after timeout, or never
+// if it is 0, which handlers pass to the database so that a slow query cannot hold a
+// connection indefinitely. Handlers get it with c.UserContext(). It is derived from
+// c.Context() so that locals, such as the current user, can still be looked up in it.
+func StatementTimeout(timeout time.Duration) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		sess, err := sessionStore.Get(c)
-		if err != nil {
-			panic(err)
+		var ctx context.Context = c.Context()
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
 		}
-
-		loggedIn := sess.Get(constants.LoggedInSessionKey) == "true"
-		c.Locals(constants.LoggedInSessionKey, loggedIn)
+		c.SetUserContext(ctx)
 
 		return c.Next()
 	}
@@ -33,6 +40,17 @@ func RequireLoggedIn(c *fiber.Ctx) error {
 	return c.Next()
 }
 
+// currentUser returns the logged-in user. It must only be used behind RequireLoggedIn.
+func currentUser(c *fiber.Ctx) *model.AppUser {
+	return c.Locals(constants.CurrentUserContextKey).(*model.AppUser)
+}
+
+// activeWorkspace returns the workspace the current user is working in. It must only be
+// used behind WorkspaceHandlers.ActiveWorkspace.
+func activeWorkspace(c *fiber.Ctx) *repo.UserWorkspace {
+	return c.Locals(constants.WorkspaceContextKey).(*repo.UserWorkspace)
+}
+
 func RedirectInternalIfLoggedIn(c *fiber.Ctx) error {
 	loggedIn := c.Locals(constants.LoggedInSessionKey).(bool)
 	if loggedIn {
@@ -42,3 +60,16 @@ func RedirectInternalIfLoggedIn(c *fiber.Ctx) error {
 
 	return c.Next()
 }
+
+// RequireRole responds 403 Forbidden unless the current user has role. It must come after
+// RequireLoggedIn.
+func RequireRole(role string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, _ := c.Locals(constants.CurrentUserContextKey).(*model.AppUser)
+		if user == nil || !repo.HasRole(*user, role) {
+			return fiber.ErrForbidden
+		}
+
+		return c.Next()
+	}
+}
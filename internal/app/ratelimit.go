@@ -0,0 +1,75 @@
+package app
+
+import (
+	"github.com/gofiber/fiber/v2"
+	fiberlog "github.com/gofiber/fiber/v2/log"
+	"htmxtodo/internal/flash"
+	"htmxtodo/internal/ratelimit"
+	"htmxtodo/internal/view"
+	errorviews "htmxtodo/views/errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tooManyRequests tells the client to slow down, with a page, or for htmx requests that
+// are not boosted, a notification. Retry-After must already be set.
+func tooManyRequests(renderer *view.Renderer) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		seconds, _ := strconv.Atoi(string(c.Response().Header.Peek(fiber.HeaderRetryAfter)))
+		retryAfter := time.Duration(seconds) * time.Second
+		fiberlog.Warn("rate limited ", c.Method(), " ", c.Path(), " from ", c.IP())
+
+		if !flash.IsFullPage(c) && c.Get("HX-Boosted") != "true" {
+			if err := flash.Now(c, flash.Error, errorviews.TooManyRequestsMessage(retryAfter)); err != nil {
+				return err
+			}
+			return c.SendStatus(fiber.StatusTooManyRequests)
+		}
+
+		return renderer.RenderComponent(c, fiber.StatusTooManyRequests, errorviews.Error429(retryAfter))
+	}
+}
+
+// setRetryAfter sets the Retry-After header to d, rounded up to whole seconds.
+func setRetryAfter(c *fiber.Ctx, d time.Duration) {
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(d.Seconds()))))
+}
+
+// rateLimiters builds the rate limiting middleware, which all count in storage.
+type rateLimiters struct {
+	storage      fiber.Storage
+	limitReached fiber.Handler
+}
+
+func (r rateLimiters) limit(name string, rate ratelimit.Rate, key func(c *fiber.Ctx) string) fiber.Handler {
+	return ratelimit.Limit(r.storage, name, rate, key, r.limitReached)
+}
+
+// clientIp keys a limit by the client's IP, as a trusted proxy reports it if there is one.
+func clientIp(c *fiber.Ctx) string {
+	return c.IP()
+}
+
+// formEmail keys a limit by the email address submitted, as the account being logged in
+// to or registered.
+func formEmail(c *fiber.Ctx) string {
+	return strings.ToLower(strings.TrimSpace(c.FormValue("email")))
+}
+
+// currentUserId keys a limit by the logged-in user. It must only be used behind
+// RequireLoggedIn.
+func currentUserId(c *fiber.Ctx) string {
+	return strconv.FormatInt(currentUser(c).ID, 10)
+}
+
+// writesOnly limits only requests that may change data.
+func writesOnly(key func(c *fiber.Ctx) string) func(c *fiber.Ctx) string {
+	return func(c *fiber.Ctx) string {
+		if !isWrite(c) {
+			return ""
+		}
+		return key(c)
+	}
+}
@@ -0,0 +1,122 @@
+package app
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"htmxtodo/internal/config"
+	"htmxtodo/internal/ratelimit"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func expectRetryAfter(t *testing.T, resp response, max time.Duration) {
+	t.Helper()
+	expectStatus(t, resp, fiber.StatusTooManyRequests)
+	seconds, err := strconv.Atoi(resp.Header.Get(fiber.HeaderRetryAfter))
+	if err != nil || seconds < 1 || time.Duration(seconds)*time.Second > max {
+		t.Fatalf("expected Retry-After of at most %s, got %q", max, resp.Header.Get(fiber.HeaderRetryAfter))
+	}
+}
+
+func TestMutationRateLimit(t *testing.T) {
+	a := newTestApp(t, func(cfg *config.Config) {
+		cfg.RateLimits.MutationAccount = ratelimit.Rate{Max: 2, Per: time.Minute}
+	})
+	c, _ := a.login("busy@example.com")
+
+	for _, name := range []string{"One", "Two"} {
+		expectStatus(t, c.htmx("POST", "/app/lists", url.Values{"name": {name}}), fiber.StatusOK)
+	}
+
+	// htmx is told with a notification, as the response is not swapped in
+	resp := c.htmx("POST", "/app/lists", url.Values{"name": {"Three"}})
+	expectRetryAfter(t, resp, time.Minute)
+	resp.page(t).ExpectHeader("HX-Trigger", `{"flash":{"messages":[{"level":"error","text":"Too many attempts. Please try again in a minute."}]}}`)
+
+	resp = c.do("POST", "/app/lists", url.Values{"name": {"Three"}})
+	expectRetryAfter(t, resp, time.Minute)
+	resp.page(t).ExpectText("#retry-after", "Too many attempts.")
+
+	// reading is not limited, and other users have limits of their own
+	expectStatus(t, c.get("/app/lists"), fiber.StatusOK)
+	other, _ := a.login("other@example.com")
+	expectStatus(t, other.htmx("POST", "/app/lists", url.Values{"name": {"One"}}), fiber.StatusOK)
+}
+
+func TestLoginRateLimit(t *testing.T) {
+	a := newTestApp(t, func(cfg *config.Config) {
+		cfg.RateLimits.LoginAccount = ratelimit.Rate{Max: 1, Per: time.Minute}
+	})
+	c := a.client()
+	c.get("/login")
+
+	form := url.Values{"email": {"user@example.com"}, "password": {"wrong"}}
+	expectStatus(t, c.do("POST", "/login", form), fiber.StatusUnprocessableEntity)
+	expectRetryAfter(t, c.do("POST", "/login", form), time.Minute)
+
+	// the account is normalized like the login form does
+	form.Set("email", " USER@example.com")
+	expectRetryAfter(t, c.do("POST", "/login", form), time.Minute)
+
+	form.Set("email", "someone-else@example.com")
+	expectStatus(t, c.do("POST", "/login", form), fiber.StatusUnprocessableEntity)
+}
+
+func TestLoginLockout(t *testing.T) {
+	a := newTestApp(t, func(cfg *config.Config) {
+		cfg.RateLimits.LockoutThreshold = 2
+		cfg.RateLimits.LockoutBase = time.Minute
+		cfg.RateLimits.LockoutMax = time.Hour
+	})
+	a.cognito.AddUser("user@example.com", testPassword)
+	c := a.client()
+	c.get("/login")
+
+	wrong := url.Values{"email": {"user@example.com"}, "password": {"wrong"}}
+	expectStatus(t, c.do("POST", "/login", wrong), fiber.StatusUnprocessableEntity)
+
+	resp := c.do("POST", "/login", wrong)
+	expectRetryAfter(t, resp, time.Minute)
+	resp.page(t).ExpectText(".is-danger", "Too many attempts. Please try again in a minute.")
+	resp.page(t).ExpectField("email", "user@example.com")
+
+	// even the right password is refused until the lockout ends
+	right := url.Values{"email": {"user@example.com"}, "password": {testPassword}}
+	expectRetryAfter(t, c.do("POST", "/login", right), time.Minute)
+}
+
+func TestRateLimitsBehindProxy(t *testing.T) {
+	// app.Test's requests come from 0.0.0.0, which stands in for the proxy
+	a := newTestApp(t, func(cfg *config.Config) {
+		cfg.ProxyHeader = fiber.HeaderXForwardedFor
+		cfg.TrustedProxies = []string{"0.0.0.0"}
+		cfg.RateLimits.LoginIp = ratelimit.Rate{Max: 1, Per: time.Minute}
+	})
+	c := a.client()
+	c.get("/login")
+
+	from := func(ip string) http.Header {
+		return http.Header{fiber.HeaderXForwardedFor: {ip}}
+	}
+	form := url.Values{"email": {"user@example.com"}, "password": {"wrong"}}
+	expectStatus(t, c.doWith("POST", "/login", form, from("203.0.113.1")), fiber.StatusUnprocessableEntity)
+	expectRetryAfter(t, c.doWith("POST", "/login", form, from("203.0.113.1")), time.Minute)
+
+	// other clients behind the same proxy have limits of their own
+	expectStatus(t, c.doWith("POST", "/login", form, from("203.0.113.2")), fiber.StatusUnprocessableEntity)
+}
+
+func TestRateLimitsIgnoreUntrustedProxyHeaders(t *testing.T) {
+	a := newTestApp(t, func(cfg *config.Config) {
+		cfg.RateLimits.LoginIp = ratelimit.Rate{Max: 1, Per: time.Minute}
+	})
+	c := a.client()
+	c.get("/login")
+
+	// without a trusted proxy, clients cannot dodge the limit by claiming another IP
+	form := url.Values{"email": {"user@example.com"}, "password": {"wrong"}}
+	expectStatus(t, c.doWith("POST", "/login", form, http.Header{fiber.HeaderXForwardedFor: {"203.0.113.1"}}), fiber.StatusUnprocessableEntity)
+	expectRetryAfter(t, c.doWith("POST", "/login", form, http.Header{fiber.HeaderXForwardedFor: {"203.0.113.2"}}), time.Minute)
+}
@@ -0,0 +1,61 @@
+package app
+
+import (
+	"expvar"
+	"github.com/gofiber/fiber/v2"
+	fiberlog "github.com/gofiber/fiber/v2/log"
+	"github.com/gofiber/fiber/v2/middleware/session"
+	"htmxtodo/internal/challenge"
+	"htmxtodo/internal/config"
+	loginviews "htmxtodo/views/login"
+)
+
+// honeypotField is the registration form's honeypot, named like a field bots expect to fill.
+const honeypotField = "website"
+
+// registrationRejections counts the registrations turned away, by reason. Admins can read
+// it, with the other expvar metrics, at /debug/vars.
+var registrationRejections = expvar.NewMap("registration_rejections")
+
+// newRegistrationChallenge builds the challenge the registration form must pass: the
+// honeypot, and the CAPTCHA if one is configured.
+func newRegistrationChallenge(cfg *config.Config, sessionStore *session.Store) (challenge.Challenge, error) {
+	honeypot := challenge.NewHoneypot(sessionStore, honeypotField, cfg.Registration.MinFillTime)
+	if cfg.Registration.Captcha == "" {
+		return honeypot, nil
+	}
+
+	captcha, err := challenge.NewProviderCaptcha(cfg.Registration.Captcha, cfg.Registration.CaptchaSiteKey, cfg.Secrets.CaptchaSecret())
+	if err != nil {
+		return nil, err
+	}
+	return challenge.All(honeypot, captcha), nil
+}
+
+// hostedCaptcha reports whether the registration CAPTCHA is loaded from its provider.
+func hostedCaptcha(cfg *config.Config) bool {
+	return cfg.Registration.Captcha != "" && cfg.Registration.Captcha != challenge.ProviderFake
+}
+
+// renderRegistration shows the registration form, with its challenge.
+func (l *LoginHandlers) renderRegistration(c *fiber.Ctx, status int, form loginviews.RegistrationForm, errorMsg string) error {
+	fields, err := l.challenge.Issue(c)
+	if err != nil {
+		return err
+	}
+	return l.renderer.RenderComponent(c, status, loginviews.Register(form, fields, errorMsg))
+}
+
+// rejectRegistration logs and counts a registration that failed a check, and shows the
+// form again. Only a disallowed email domain is explained, as people can do something
+// about it; the rest give bots nothing to go on.
+func (l *LoginHandlers) rejectRegistration(c *fiber.Ctx, form loginviews.RegistrationForm, rejection *challenge.Rejection) error {
+	fiberlog.Warn("registration rejected (", rejection.Reason, ") from ", c.IP())
+	registrationRejections.Add(rejection.Reason, 1)
+
+	msg := "We could not verify your registration. Please try again."
+	if rejection.Reason == challenge.ReasonEmailDomain {
+		msg = "Registration is not open to this email address."
+	}
+	return l.renderRegistration(c, fiber.StatusUnprocessableEntity, form, msg)
+}
@@ -0,0 +1,123 @@
+package app
+
+import (
+	"expvar"
+	"github.com/gofiber/fiber/v2"
+	"htmxtodo/internal/challenge"
+	"htmxtodo/internal/config"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func registrationForm(email string) url.Values {
+	return url.Values{
+		"email":                 {email},
+		"password":              {testPassword},
+		"password_confirmation": {testPassword},
+	}
+}
+
+func rejections(reason string) int64 {
+	if n, ok := registrationRejections.Get(reason).(*expvar.Int); ok {
+		return n.Value()
+	}
+	return 0
+}
+
+// expectRejected checks that a registration was rejected for reason, and counted.
+func expectRejected(t *testing.T, reason string, message string, register func() response) {
+	t.Helper()
+	before := rejections(reason)
+	resp := register()
+	expectStatus(t, resp, fiber.StatusUnprocessableEntity)
+	resp.page(t).ExpectText("#registration-form .is-danger", message)
+	if after := rejections(reason); after != before+1 {
+		t.Fatalf("expected a %s rejection to be counted, went from %d to %d", reason, before, after)
+	}
+}
+
+func TestRegistrationHoneypot(t *testing.T) {
+	const minFillTime = 200 * time.Millisecond
+	a := newTestApp(t, func(cfg *config.Config) {
+		cfg.Registration.MinFillTime = minFillTime
+	})
+	c := a.client()
+	const message = "We could not verify your registration. Please try again."
+
+	// a form posted without being shown first
+	c.get("/login")
+	expectRejected(t, challenge.ReasonNotIssued, message, func() response {
+		return c.do("POST", "/register", registrationForm("bot@example.com"))
+	})
+
+	expectRejected(t, challenge.ReasonTooFast, message, func() response {
+		return c.do("POST", "/register", registrationForm("bot@example.com"))
+	})
+
+	time.Sleep(minFillTime)
+	form := registrationForm("bot@example.com")
+	form.Set(honeypotField, "https://spam.example.com")
+	expectRejected(t, challenge.ReasonHoneypot, message, func() response {
+		return c.do("POST", "/register", form)
+	})
+
+	// showing the form again starts the wait over
+	expectRejected(t, challenge.ReasonTooFast, message, func() response {
+		return c.do("POST", "/register", registrationForm("bot@example.com"))
+	})
+	time.Sleep(minFillTime)
+	expectRedirect(t, c.do("POST", "/register", registrationForm("person@example.com")), "/login")
+
+	// each showing of the form allows one submission
+	expectRejected(t, challenge.ReasonNotIssued, message, func() response {
+		return c.do("POST", "/register", registrationForm("bot@example.com"))
+	})
+}
+
+func TestRegistrationCaptcha(t *testing.T) {
+	a := newTestApp(t, func(cfg *config.Config) {
+		cfg.Registration.Captcha = challenge.ProviderFake
+	})
+	c := a.client()
+	expectBody(t, c.get("/register"), "I am not a robot")
+
+	expectRejected(t, challenge.ReasonCaptcha, "We could not verify your registration.", func() response {
+		return c.do("POST", "/register", registrationForm("bot@example.com"))
+	})
+
+	form := registrationForm("bot@example.com")
+	form.Set("captcha_response", "guess")
+	expectRejected(t, challenge.ReasonCaptcha, "We could not verify your registration.", func() response {
+		return c.do("POST", "/register", form)
+	})
+
+	form = registrationForm("person@example.com")
+	form.Set("captcha_response", challenge.FakeResponse)
+	expectRedirect(t, c.do("POST", "/register", form), "/login")
+}
+
+func TestRegistrationEmailDomains(t *testing.T) {
+	a := newTestApp(t, func(cfg *config.Config) {
+		cfg.Registration.EmailDomains = challenge.EmailDomains{
+			Allow: []string{"example.com"},
+			Deny:  []string{"contractors.example.com"},
+		}
+	})
+	c := a.client()
+	c.get("/register")
+
+	for _, email := range []string{"someone@example.org", "someone@contractors.example.com"} {
+		expectRejected(t, challenge.ReasonEmailDomain, "Registration is not open to this email address.", func() response {
+			return c.do("POST", "/register", registrationForm(email))
+		})
+	}
+
+	expectRedirect(t, c.do("POST", "/register", registrationForm("someone@staff.example.com")), "/login")
+
+	// admins can see the counts
+	admin, _ := a.loginAdmin("admin@example.com")
+	resp := admin.get("/debug/vars")
+	expectStatus(t, resp, fiber.StatusOK)
+	expectBody(t, resp, `"registration_rejections": {`)
+}
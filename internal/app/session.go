@@ -0,0 +1,280 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	fiberlog "github.com/gofiber/fiber/v2/log"
+	"github.com/gofiber/fiber/v2/middleware/session"
+	"github.com/valyala/fasthttp"
+	"htmxtodo/gen/htmxtodo_dev/public/model"
+	"htmxtodo/internal/constants"
+	"htmxtodo/internal/repo"
+	"time"
+)
+
+// sessionRenewalInterval limits how often activity is written back to the session store,
+// so a burst of requests does not turn into a burst of storage writes.
+const sessionRenewalInterval = time.Minute
+
+// timeNow is the clock sessions are timed by, which tests move forward.
+var timeNow = time.Now
+
+// rememberUntilLocalsKey holds the time a "remember me" cookie should expire, or the zero
+// time if the session cookie should only last for the browser session.
+const rememberUntilLocalsKey = "session.remember_until"
+
+// SessionPolicy enforces idle and absolute lifetimes for logged-in sessions on top of
+// the fiber session store, and decides whether the session cookie outlives the browser.
+// It also sends a user's reads to the primary database for replicaStickiness after they
+// write, so that they see their own changes even if the read replica is behind.
+type SessionPolicy struct {
+	store             *session.Store
+	repo              repo.Repository
+	idleTimeout       time.Duration
+	absoluteTimeout   time.Duration
+	replicaStickiness time.Duration
+}
+
+func NewSessionPolicy(store *session.Store, r repo.Repository, idleTimeout, absoluteTimeout, replicaStickiness time.Duration) *SessionPolicy {
+	return &SessionPolicy{
+		store:             store,
+		repo:              r,
+		idleTimeout:       idleTimeout,
+		absoluteTimeout:   absoluteTimeout,
+		replicaStickiness: replicaStickiness,
+	}
+}
+
+// Handler expires logged-in sessions that have been idle or alive for too long, or whose
+// user has been disabled or had their sessions revoked, and slides the idle timeout forward
+// on real activity. It sets the logged-in status and current user for later middleware and
+// handlers.
+func (p *SessionPolicy) Handler(c *fiber.Ctx) error {
+	sess, err := p.store.Get(c)
+	if err != nil {
+		panic(err)
+	}
+
+	now := timeNow()
+	loggedIn := sess.Get(constants.LoggedInSessionKey) == "true"
+	var user, impersonator *model.AppUser
+	var rememberUntil time.Time
+
+	// a write and whatever it reads back go to the primary, as do the user's reads for a
+	// while after it, as the replica may not have caught up yet
+	if isWrite(c) || now.Sub(sessionTime(sess, constants.SessionLastWriteKey)) < p.replicaStickiness {
+		c.SetUserContext(repo.WithPrimary(c.UserContext()))
+	}
+
+	if loggedIn {
+		createdAt := sessionTime(sess, constants.SessionCreatedAtKey)
+		lastSeen := sessionTime(sess, constants.SessionLastSeenKey)
+
+		userId, _ := sess.Get(constants.UserIdSessionKey).(int64)
+		user, err = p.loadUser(c.UserContext(), userId, createdAt)
+		if err != nil {
+			return err
+		}
+
+		if impersonatorId, ok := sess.Get(constants.ImpersonatorSessionKey).(int64); ok && user != nil {
+			impersonator, err = p.loadUser(c.UserContext(), impersonatorId, createdAt)
+			if err != nil {
+				return err
+			}
+			if impersonator == nil || impersonator.Role != repo.RoleAdmin {
+				user = nil
+			}
+		}
+
+		if user == nil || now.Sub(lastSeen) > p.idleTimeout || now.Sub(createdAt) > p.absoluteTimeout {
+			fiberlog.Info("session expired, logging out")
+			if err = sess.Reset(); err != nil {
+				panic(err)
+			}
+			loggedIn = false
+			user, impersonator = nil, nil
+		} else {
+			if sess.Get(constants.SessionRememberMeKey) == true {
+				rememberUntil = createdAt.Add(p.absoluteTimeout)
+			}
+
+			save := false
+			if isActivity(c) && now.Sub(lastSeen) >= sessionRenewalInterval {
+				sess.Set(constants.SessionLastSeenKey, now.UnixMilli())
+				save = true
+			}
+			if isWrite(c) && p.replicaStickiness > 0 {
+				sess.Set(constants.SessionLastWriteKey, now.UnixMilli())
+				save = true
+			}
+
+			// the session must not be used after saving
+			if save {
+				if err = sess.Save(); err != nil {
+					panic(err)
+				}
+			}
+		}
+	}
+
+	c.Locals(constants.LoggedInSessionKey, loggedIn)
+	c.Locals(constants.CurrentUserContextKey, user)
+	c.Locals(constants.ImpersonatorContextKey, impersonator)
+	c.Locals(rememberUntilLocalsKey, rememberUntil)
+
+	if err = c.Next(); err != nil {
+		return err
+	}
+
+	p.applyCookieLifetime(c)
+	return nil
+}
+
+// loadUser returns the user a session created at sessionCreatedAt belongs to, or nil if
+// they no longer exist, are disabled, or have had their sessions revoked since.
+func (p *SessionPolicy) loadUser(ctx context.Context, id int64, sessionCreatedAt time.Time) (*model.AppUser, error) {
+	user, err := p.repo.GetUserById(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if user.DisabledAt != nil {
+		return nil, nil
+	}
+	if user.SessionsRevokedAt != nil && sessionCreatedAt.Before(*user.SessionsRevokedAt) {
+		return nil, nil
+	}
+
+	return &user, nil
+}
+
+// LogIn starts an authenticated session for a user. The session ID is regenerated and any
+// data from before login is discarded, so an ID planted by an attacker is never promoted.
+// If rememberMe is false the session cookie is deleted when the browser closes.
+func (p *SessionPolicy) LogIn(c *fiber.Ctx, sess *session.Session, userId int64, rememberMe bool) error {
+	if err := sess.Reset(); err != nil {
+		return err
+	}
+
+	now := timeNow()
+	sess.Set(constants.LoggedInSessionKey, "true")
+	sess.Set(constants.UserIdSessionKey, userId)
+	sess.Set(constants.SessionCreatedAtKey, now.UnixMilli())
+	sess.Set(constants.SessionLastSeenKey, now.UnixMilli())
+	sess.Set(constants.SessionRememberMeKey, rememberMe)
+	// logging in may have created the user
+	sess.Set(constants.SessionLastWriteKey, now.UnixMilli())
+
+	var rememberUntil time.Time
+	if rememberMe {
+		rememberUntil = now.Add(p.absoluteTimeout)
+	}
+	c.Locals(rememberUntilLocalsKey, rememberUntil)
+
+	return sess.Save()
+}
+
+// LogOut discards the session and its data, and gives it a new ID. The caller must save
+// the session, so that the browser is sent the new ID in place of the old one.
+func (p *SessionPolicy) LogOut(c *fiber.Ctx, sess *session.Session) error {
+	c.Locals(constants.LoggedInSessionKey, false)
+	c.Locals(constants.CurrentUserContextKey, nil)
+	c.Locals(constants.ImpersonatorContextKey, nil)
+	c.Locals(rememberUntilLocalsKey, time.Time{})
+
+	return sess.Reset()
+}
+
+// Impersonate switches a logged-in admin's session to act as another user, remembering
+// the admin so that StopImpersonating can switch back.
+func (p *SessionPolicy) Impersonate(c *fiber.Ctx, sess *session.Session, impersonatorId int64, userId int64) error {
+	sess.Set(constants.UserIdSessionKey, userId)
+	sess.Set(constants.ImpersonatorSessionKey, impersonatorId)
+
+	return p.regenerate(c, sess)
+}
+
+// StopImpersonating switches the session back to the impersonating admin, returning
+// their ID, or 0 if the session was not impersonating anyone.
+func (p *SessionPolicy) StopImpersonating(c *fiber.Ctx, sess *session.Session) (int64, error) {
+	impersonatorId, ok := sess.Get(constants.ImpersonatorSessionKey).(int64)
+	if !ok {
+		return 0, nil
+	}
+
+	sess.Set(constants.UserIdSessionKey, impersonatorId)
+	sess.Delete(constants.ImpersonatorSessionKey)
+
+	return impersonatorId, p.regenerate(c, sess)
+}
+
+// regenerate gives the session a new ID while keeping its data, so that an ID leaked
+// before the session switched to acting as someone else stops working.
+func (p *SessionPolicy) regenerate(c *fiber.Ctx, sess *session.Session) error {
+	if err := sess.Regenerate(); err != nil {
+		return err
+	}
+
+	sess.Set(constants.SessionLastSeenKey, timeNow().UnixMilli())
+
+	return sess.Save()
+}
+
+// applyCookieLifetime rewrites the session cookie, if this response sets one, so that it
+// expires with the absolute lifetime of a "remember me" login or else with the browser.
+// Anything that saves the session (including the CSRF middleware) sets the cookie with the
+// store's default expiration, so this has to run after the rest of the chain.
+func (p *SessionPolicy) applyCookieLifetime(c *fiber.Ctx) {
+	cookie := fasthttp.AcquireCookie()
+	defer fasthttp.ReleaseCookie(cookie)
+
+	cookie.SetKey(constants.SessionCookieName)
+	if !c.Response().Header.Cookie(cookie) || cookie.MaxAge() < 0 {
+		// not set, or being deleted
+		return
+	}
+
+	rememberUntil, _ := c.Locals(rememberUntilLocalsKey).(time.Time)
+	if rememberUntil.IsZero() {
+		cookie.SetMaxAge(0)
+		cookie.SetExpire(fasthttp.CookieExpireUnlimited)
+	} else {
+		cookie.SetMaxAge(int(rememberUntil.Sub(timeNow()).Seconds()))
+		cookie.SetExpire(rememberUntil)
+	}
+
+	c.Response().Header.SetCookie(cookie)
+}
+
+// isActivity reports whether a request was made by the user, as opposed to speculatively by
+// the browser, and so should keep an idle session alive.
+func isActivity(c *fiber.Ctx) bool {
+	if c.Method() == fiber.MethodHead {
+		return false
+	}
+	if c.Get("Sec-Purpose") != "" || c.Get("Purpose") == "prefetch" {
+		return false
+	}
+	return true
+}
+
+// isWrite reports whether a request may change data, as only safe methods do not.
+func isWrite(c *fiber.Ctx) bool {
+	switch c.Method() {
+	case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+		return false
+	}
+	return true
+}
+
+func sessionTime(sess *session.Session, key string) time.Time {
+	if ms, ok := sess.Get(key).(int64); ok {
+		return time.UnixMilli(ms)
+	}
+	return time.Time{}
+}
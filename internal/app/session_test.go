@@ -0,0 +1,102 @@
+package app
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"htmxtodo/internal/config"
+	"htmxtodo/internal/constants"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// advanceClock moves the session clock forward by d, until the test ends.
+func advanceClock(t *testing.T, d time.Duration) {
+	previous := timeNow
+	timeNow = func() time.Time {
+		return previous().Add(d)
+	}
+	t.Cleanup(func() {
+		timeNow = previous
+	})
+}
+
+func withSessionTimeouts(idle time.Duration, absolute time.Duration) func(cfg *config.Config) {
+	return func(cfg *config.Config) {
+		cfg.SessionIdleTimeout = idle
+		cfg.SessionAbsoluteTimeout = absolute
+	}
+}
+
+func TestSessionIdleTimeout(t *testing.T) {
+	a := newTestApp(t, withSessionTimeouts(time.Hour, 24*time.Hour))
+	c, _ := a.login("idle@example.com")
+
+	// activity keeps the session alive past the idle timeout
+	for i := 0; i < 3; i++ {
+		advanceClock(t, 50*time.Minute)
+		expectStatus(t, c.get("/app/lists"), fiber.StatusOK)
+	}
+
+	advanceClock(t, time.Hour+time.Minute)
+	expectRedirect(t, c.get("/app/lists"), "/login")
+}
+
+func TestSessionAbsoluteTimeout(t *testing.T) {
+	a := newTestApp(t, withSessionTimeouts(time.Hour, 3*time.Hour))
+	c, _ := a.login("absolute@example.com")
+
+	for i := 0; i < 3; i++ {
+		advanceClock(t, 50*time.Minute)
+		expectStatus(t, c.get("/app/lists"), fiber.StatusOK)
+	}
+
+	// however active the session is, it ends once it is that old
+	advanceClock(t, 50*time.Minute)
+	expectRedirect(t, c.get("/app/lists"), "/login")
+}
+
+func sessionCookie(t *testing.T, resp response) *http.Cookie {
+	t.Helper()
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == constants.SessionCookieName {
+			return cookie
+		}
+	}
+	t.Fatalf("expected the session cookie to be set")
+	return nil
+}
+
+func TestRememberMe(t *testing.T) {
+	a := newTestApp(t, withSessionTimeouts(time.Hour, 30*24*time.Hour))
+	a.cognito.AddUser("remembered@example.com", testPassword)
+	a.cognito.AddUser("forgotten@example.com", testPassword)
+
+	login := func(email string, rememberMe bool) (*client, response) {
+		c := a.client()
+		c.get("/login")
+		form := url.Values{"email": {email}, "password": {testPassword}}
+		if rememberMe {
+			form.Set("remember_me", "true")
+		}
+		resp := c.do("POST", "/login", form)
+		expectRedirect(t, resp, "/app/lists")
+		return c, resp
+	}
+
+	// the cookie lasts as long as the login can
+	remembered, resp := login("remembered@example.com", true)
+	if cookie := sessionCookie(t, resp); cookie.MaxAge < int((30*24*time.Hour - time.Minute).Seconds()) {
+		t.Fatalf("expected the session cookie to last 30 days, got a max age of %ds", cookie.MaxAge)
+	}
+
+	// without remember me, it ends with the browser
+	_, resp = login("forgotten@example.com", false)
+	if cookie := sessionCookie(t, resp); cookie.MaxAge != 0 || !cookie.Expires.IsZero() {
+		t.Fatalf("expected a session cookie, got max age %d and expiry %s", cookie.MaxAge, cookie.Expires)
+	}
+
+	// remembering does not stretch the idle timeout
+	advanceClock(t, time.Hour+time.Minute)
+	expectRedirect(t, remembered.get("/app/lists"), "/login")
+}
@@ -0,0 +1,104 @@
+package app
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"htmxtodo/gen/htmxtodo_dev/public/model"
+	"htmxtodo/internal/flash"
+	"htmxtodo/internal/repo"
+	"htmxtodo/internal/view"
+	listviews "htmxtodo/views/lists"
+	"time"
+)
+
+// shareLinkLifetimes are the expiry choices offered for public links, keyed by form value.
+// The zero duration means the link lasts until it is revoked.
+var shareLinkLifetimes = map[string]time.Duration{
+	"":   0,
+	"1":  24 * time.Hour,
+	"7":  7 * 24 * time.Hour,
+	"30": 30 * 24 * time.Hour,
+}
+
+type CreateShareLinkRequest struct {
+	ExpiresInDays string `form:"expires_in_days"`
+}
+
+func (l *ListsHandlers) CreateShareLink(c *fiber.Ctx) error {
+	list, permission, err := l.authorizeList(c, repo.PermissionOwner)
+	if err != nil {
+		return err
+	}
+
+	var req CreateShareLinkRequest
+	if err = c.BodyParser(&req); err != nil {
+		return err
+	}
+
+	lifetime, ok := shareLinkLifetimes[req.ExpiresInDays]
+	if !ok {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid expiry")
+	}
+
+	var expiresAt *time.Time
+	if lifetime > 0 {
+		t := time.Now().Add(lifetime)
+		expiresAt = &t
+	}
+
+	if _, err = l.repo.CreateShareLink(c.UserContext(), list.ID, currentUser(c).ID, expiresAt); err != nil {
+		return err
+	}
+
+	if err = flash.Add(c, l.sessionStore, flash.Success, "Public link created. Anyone with the link can view this list."); err != nil {
+		return err
+	}
+
+	return l.renderCard(c, list, permission, false)
+}
+
+func (l *ListsHandlers) RevokeShareLink(c *fiber.Ctx) error {
+	list, permission, err := l.authorizeList(c, repo.PermissionOwner)
+	if err != nil {
+		return err
+	}
+
+	if err = l.repo.RevokeShareLinks(c.UserContext(), list.ID); err != nil {
+		return err
+	}
+
+	if err = flash.Add(c, l.sessionStore, flash.Info, "Public link revoked."); err != nil {
+		return err
+	}
+
+	return l.renderCard(c, list, permission, false)
+}
+
+// withShareLink adds a public link to a card, as a full URL so that it can be copied.
+func withShareLink(c *fiber.Ctx, card listviews.CardProps, link model.ShareLink) listviews.CardProps {
+	card.ShareLink = &link
+	card.ShareLinkUrl = c.BaseURL() + listviews.SharedListUrl(link.Token)
+	return card
+}
+
+// SharedListsHandlers serve lists to anyone with a public link.
+type SharedListsHandlers struct {
+	renderer *view.Renderer
+	repo     repo.Repository
+}
+
+func (s *SharedListsHandlers) Show(c *fiber.Ctx) error {
+	// the links are secrets, and the lists private, so keep them out of search engines and
+	// shared caches (helmet already stops them leaking through the Referer header)
+	c.Set("X-Robots-Tag", "noindex, nofollow")
+	c.Set(fiber.HeaderCacheControl, "private, no-store")
+
+	list, err := s.repo.GetListByShareToken(c.UserContext(), c.Params("token"))
+	if err != nil {
+		return err
+	}
+
+	return s.renderer.RenderComponent(c, 200, listviews.Shared(listviews.CardProps{
+		List:     list,
+		ReadOnly: true,
+	}))
+}
@@ -0,0 +1,164 @@
+package app
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"htmxtodo/gen/htmxtodo_dev/public/model"
+	"htmxtodo/internal/flash"
+	"htmxtodo/internal/repo"
+	listviews "htmxtodo/views/lists"
+	"strings"
+)
+
+func (l *ListsHandlers) Sharing(c *fiber.Ctx) error {
+	list, _, err := l.authorizeList(c, repo.PermissionOwner)
+	if err != nil {
+		return err
+	}
+
+	return l.renderSharing(c, fiber.StatusOK, list, listviews.ShareForm{Permission: repo.PermissionViewer}, "")
+}
+
+func (l *ListsHandlers) Share(c *fiber.Ctx) error {
+	list, _, err := l.authorizeList(c, repo.PermissionOwner)
+	if err != nil {
+		return err
+	}
+
+	var form listviews.ShareForm
+	if err = c.BodyParser(&form); err != nil {
+		return err
+	}
+	form.Email = strings.ToLower(strings.TrimSpace(form.Email))
+
+	if !strings.Contains(form.Email, "@") {
+		return l.renderSharing(c, fiber.StatusUnprocessableEntity, list, form, "email is required")
+	}
+	if !repo.IsMemberPermission(form.Permission) {
+		return l.renderSharing(c, fiber.StatusUnprocessableEntity, list, form, "choose viewer or editor")
+	}
+
+	invited, err := l.repo.ShareList(c.UserContext(), list.ID, form.Email, form.Permission, currentUser(c).ID)
+	if errors.Is(err, repo.ErrAlreadyOwner) {
+		return l.renderSharing(c, fiber.StatusUnprocessableEntity, list, form, "that user already owns this list")
+	}
+	if err != nil {
+		return err
+	}
+
+	message := "Shared with " + form.Email + "."
+	if invited {
+		message = "Invited " + form.Email + ". They will get access when they sign up."
+	}
+	if err = flash.Add(c, l.sessionStore, flash.Success, message); err != nil {
+		return err
+	}
+
+	url := listviews.CardProps{List: list}.SharingUrl()
+	c.Set("HX-Location", url)
+	return c.Redirect(url, fiber.StatusFound)
+}
+
+func (l *ListsHandlers) RevokeMember(c *fiber.Ctx) error {
+	list, _, err := l.authorizeList(c, repo.PermissionOwner)
+	if err != nil {
+		return err
+	}
+
+	userId, err := c.ParamsInt("userId")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	if err = l.repo.RevokeListMember(c.UserContext(), list.ID, int64(userId)); err != nil {
+		return err
+	}
+
+	if err = flash.Add(c, l.sessionStore, flash.Info, "Access revoked."); err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (l *ListsHandlers) RevokeInvitation(c *fiber.Ctx) error {
+	list, _, err := l.authorizeList(c, repo.PermissionOwner)
+	if err != nil {
+		return err
+	}
+
+	invitationId, err := c.ParamsInt("invitationId")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	if err = l.repo.DeleteListInvitation(c.UserContext(), list.ID, int64(invitationId)); err != nil {
+		return err
+	}
+
+	if err = flash.Add(c, l.sessionStore, flash.Info, "Invitation revoked."); err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+type TransferOwnershipRequest struct {
+	UserID int64 `form:"user_id"`
+}
+
+func (l *ListsHandlers) TransferOwnership(c *fiber.Ctx) error {
+	list, _, err := l.authorizeList(c, repo.PermissionOwner)
+	if err != nil {
+		return err
+	}
+
+	var req TransferOwnershipRequest
+	if err = c.BodyParser(&req); err != nil {
+		return err
+	}
+
+	_, err = l.repo.TransferListOwnership(c.UserContext(), list.ID, req.UserID)
+	if errors.Is(err, repo.ErrNotMember) || errors.Is(err, repo.ErrAlreadyOwner) {
+		return l.renderSharing(c, fiber.StatusUnprocessableEntity, list, listviews.ShareForm{},
+			"ownership can only be given to someone the list is shared with")
+	}
+	if errors.Is(err, repo.ErrNotWorkspaceMember) {
+		return l.renderSharing(c, fiber.StatusUnprocessableEntity, list, listviews.ShareForm{},
+			"ownership can only be given to a member of the list's workspace")
+	}
+	if errors.Is(err, repo.ErrListNameTaken) {
+		return l.renderSharing(c, fiber.StatusUnprocessableEntity, list, listviews.ShareForm{},
+			"the new owner already has a list with this name")
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = flash.Add(c, l.sessionStore, flash.Success, "Ownership transferred. You can still edit the list."); err != nil {
+		return err
+	}
+
+	c.Set("HX-Location", "/app/lists")
+	return c.Redirect("/app/lists", fiber.StatusFound)
+}
+
+func (l *ListsHandlers) renderSharing(c *fiber.Ctx, status int, list model.List, form listviews.ShareForm, errorMsg string) error {
+	members, err := l.repo.FilterListMembers(c.UserContext(), list.ID)
+	if err != nil {
+		return err
+	}
+
+	invitations, err := l.repo.FilterListInvitations(c.UserContext(), list.ID)
+	if err != nil {
+		return err
+	}
+
+	return l.renderer.RenderComponent(c, status, listviews.Sharing(listviews.SharingProps{
+		List:        list,
+		Members:     members,
+		Invitations: invitations,
+		Form:        form,
+		Error:       errorMsg,
+	}))
+}
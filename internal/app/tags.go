@@ -0,0 +1,192 @@
+package app
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/session"
+	"htmxtodo/gen/htmxtodo_dev/public/model"
+	"htmxtodo/internal/flash"
+	"htmxtodo/internal/repo"
+	"htmxtodo/internal/view"
+	tagviews "htmxtodo/views/tags"
+	"strings"
+)
+
+// maxTagNameLength matches the tag.name column.
+const maxTagNameLength = 64
+
+// TagHandlers manage the active workspace's tags. Any member of a workspace may manage its
+// tags, as they may edit all of its lists anyway.
+type TagHandlers struct {
+	renderer     *view.Renderer
+	repo         repo.Repository
+	sessionStore *session.Store
+}
+
+func (t *TagHandlers) Index(c *fiber.Ctx) error {
+	return t.renderIndex(c, fiber.StatusOK, tagviews.TagForm{Color: tagviews.DefaultColor}, "")
+}
+
+func (t *TagHandlers) Create(c *fiber.Ctx) error {
+	form, errorMsg, err := parseTagForm(c)
+	if err != nil {
+		return err
+	}
+	if errorMsg != "" {
+		return t.renderIndex(c, fiber.StatusUnprocessableEntity, form, errorMsg)
+	}
+
+	_, err = t.repo.CreateTag(c.UserContext(), activeWorkspace(c).ID, form.Name, form.Color)
+	if errors.Is(err, repo.ErrTagNameTaken) {
+		return t.renderIndex(c, fiber.StatusUnprocessableEntity, form, "there is already a tag with that name")
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = flash.Add(c, t.sessionStore, flash.Success, "Tag created."); err != nil {
+		return err
+	}
+
+	c.Set("HX-Location", "/app/tags")
+	return c.Redirect("/app/tags", fiber.StatusFound)
+}
+
+// Update renames and recolors a tag.
+func (t *TagHandlers) Update(c *fiber.Ctx) error {
+	tag, err := t.tag(c)
+	if err != nil {
+		return err
+	}
+
+	form, errorMsg, err := parseTagForm(c)
+	if err != nil {
+		return err
+	}
+	if errorMsg != "" {
+		return fiber.NewError(fiber.StatusUnprocessableEntity, errorMsg)
+	}
+
+	tag, err = t.repo.UpdateTag(c.UserContext(), tag.WorkspaceID, tag.ID, form.Name, form.Color)
+	if errors.Is(err, repo.ErrTagNameTaken) {
+		return fiber.NewError(fiber.StatusUnprocessableEntity, "there is already a tag with that name; merge the tags instead")
+	}
+	if err != nil {
+		return err
+	}
+
+	tags, err := t.repo.FilterTags(c.UserContext(), tag.WorkspaceID)
+	if err != nil {
+		return err
+	}
+	for _, count := range tags {
+		if count.ID == tag.ID {
+			return t.renderer.RenderComponent(c, 200, tagviews.Row(count, tags))
+		}
+	}
+
+	return sql.ErrNoRows
+}
+
+// Merge moves everything tagged with a tag to another tag, and deletes it.
+func (t *TagHandlers) Merge(c *fiber.Ctx) error {
+	tag, err := t.tag(c)
+	if err != nil {
+		return err
+	}
+
+	var form tagviews.MergeForm
+	if err = c.BodyParser(&form); err != nil {
+		return err
+	}
+
+	into, err := t.repo.MergeTags(c.UserContext(), tag.WorkspaceID, tag.ID, form.IntoID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fiber.NewError(fiber.StatusUnprocessableEntity, "choose another tag in this workspace")
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = flash.Add(c, t.sessionStore, flash.Success, "Merged "+tag.Name+" into "+into.Name+"."); err != nil {
+		return err
+	}
+
+	c.Set("HX-Location", "/app/tags")
+	return c.Redirect("/app/tags", fiber.StatusFound)
+}
+
+func (t *TagHandlers) Delete(c *fiber.Ctx) error {
+	tag, err := t.tag(c)
+	if err != nil {
+		return err
+	}
+
+	if err = t.repo.DeleteTag(c.UserContext(), tag.WorkspaceID, tag.ID); err != nil {
+		return err
+	}
+
+	if err = flash.Add(c, t.sessionStore, flash.Info, "Tag deleted."); err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (t *TagHandlers) renderIndex(c *fiber.Ctx, status int, form tagviews.TagForm, errorMsg string) error {
+	tags, err := t.repo.FilterTags(c.UserContext(), activeWorkspace(c).ID)
+	if err != nil {
+		return err
+	}
+
+	return t.renderer.RenderComponent(c, status, tagviews.Index(tagviews.IndexProps{
+		Tags:  tags,
+		Form:  form,
+		Error: errorMsg,
+	}))
+}
+
+// tag returns the tag named by the :id route parameter, if it is in the active workspace.
+func (t *TagHandlers) tag(c *fiber.Ctx) (model.Tag, error) {
+	var params struct {
+		ID int64 `params:"id"`
+	}
+	if err := c.ParamsParser(&params); err != nil {
+		return model.Tag{}, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	return t.repo.GetTag(c.UserContext(), activeWorkspace(c).ID, params.ID)
+}
+
+// parseTagForm parses and normalizes a tag form, returning a message for the user if it is
+// not valid.
+func parseTagForm(c *fiber.Ctx) (tagviews.TagForm, string, error) {
+	var form tagviews.TagForm
+	if err := c.BodyParser(&form); err != nil {
+		return form, "", err
+	}
+
+	form.Name = strings.TrimSpace(form.Name)
+	form.Color = strings.ToLower(strings.TrimSpace(form.Color))
+
+	switch {
+	case form.Name == "":
+		return form, "name is required", nil
+	case len([]rune(form.Name)) > maxTagNameLength:
+		return form, "names can be at most 64 characters", nil
+	case !repo.IsTagColor(form.Color):
+		return form, "choose a color", nil
+	}
+
+	return form, "", nil
+}
+
+// tagModels returns the tags without their counts.
+func tagModels(counts []repo.TagCount) []model.Tag {
+	tags := make([]model.Tag, len(counts))
+	for i, count := range counts {
+		tags[i] = count.Tag
+	}
+	return tags
+}
@@ -0,0 +1,107 @@
+package app
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"htmxtodo/gen/htmxtodo_dev/public/model"
+	"htmxtodo/internal/flash"
+	"htmxtodo/internal/repo"
+	listviews "htmxtodo/views/lists"
+	trashviews "htmxtodo/views/trash"
+)
+
+// Trash shows the lists in the active workspace's trash that the current user may restore.
+func (l *ListsHandlers) Trash(c *fiber.Ctx) error {
+	workspace := activeWorkspace(c)
+	user := currentUser(c)
+
+	deleted, err := l.repo.FilterDeletedLists(c.UserContext(), workspace.ID)
+	if err != nil {
+		return err
+	}
+
+	lists := make([]model.List, 0, len(deleted))
+	for _, list := range deleted {
+		if repo.WorkspaceListPermission(workspace.Role, list, user.ID) == repo.PermissionOwner {
+			lists = append(lists, list)
+		}
+	}
+
+	return l.renderer.RenderComponent(c, 200, trashviews.Index(trashviews.IndexProps{
+		Lists:         lists,
+		RetentionDays: int(l.trashRetention.Hours() / 24),
+	}))
+}
+
+// Restore takes a list out of the trash. On the trash page its row is removed; otherwise,
+// as when undoing a delete, its card is put back with the workspace's lists.
+func (l *ListsHandlers) Restore(c *fiber.Ctx) error {
+	list, err := l.authorizeDeletedList(c)
+	if err != nil {
+		return err
+	}
+
+	list, err = l.repo.RestoreList(c.UserContext(), list.ID)
+	if errors.Is(err, repo.ErrListNameTaken) {
+		return fiber.NewError(fiber.StatusUnprocessableEntity,
+			"another list has been given this name since it was deleted; rename that one first")
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = flash.Add(c, l.sessionStore, flash.Success, "List restored."); err != nil {
+		return err
+	}
+
+	if trashviews.IsRow(c.Get("HX-Target")) {
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+
+	c.Set("HX-Retarget", "#"+listviews.ListsId)
+	c.Set("HX-Reswap", "beforeend")
+	return l.renderCard(c, list, repo.PermissionOwner, false)
+}
+
+// Purge deletes a list in the trash forever.
+func (l *ListsHandlers) Purge(c *fiber.Ctx) error {
+	list, err := l.authorizeDeletedList(c)
+	if err != nil {
+		return err
+	}
+
+	if err = l.repo.PurgeList(c.UserContext(), list.ID); err != nil {
+		return err
+	}
+
+	if err = flash.Add(c, l.sessionStore, flash.Info, "List deleted forever."); err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// authorizeDeletedList returns the list in the trash named by the :id route parameter, if
+// the current user owns it through the active workspace. Lists in the trash cannot be
+// shared, so only their owners and the workspace's owners and admins may see them.
+func (l *ListsHandlers) authorizeDeletedList(c *fiber.Ctx) (model.List, error) {
+	var params struct {
+		ID int64 `params:"id"`
+	}
+	if err := c.ParamsParser(&params); err != nil {
+		return model.List{}, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	list, err := l.repo.GetDeletedList(c.UserContext(), params.ID)
+	if err != nil {
+		return list, err
+	}
+
+	workspace := activeWorkspace(c)
+	if list.WorkspaceID != workspace.ID ||
+		repo.WorkspaceListPermission(workspace.Role, list, currentUser(c).ID) != repo.PermissionOwner {
+		return model.List{}, fiber.ErrNotFound
+	}
+
+	return list, nil
+}
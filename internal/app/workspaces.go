@@ -0,0 +1,233 @@
+package app
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/session"
+	"htmxtodo/internal/constants"
+	"htmxtodo/internal/flash"
+	"htmxtodo/internal/repo"
+	"htmxtodo/internal/view"
+	workspaceviews "htmxtodo/views/workspaces"
+	"strings"
+)
+
+type WorkspaceHandlers struct {
+	renderer     *view.Renderer
+	repo         repo.Repository
+	sessionStore *session.Store
+}
+
+// ActiveWorkspace loads the workspace held in the session, falling back to the user's
+// personal workspace if there is none or they have left it. The workspaces to switch to are
+// left for the page to load, if it shows the switcher. It must come after RequireLoggedIn.
+func (w *WorkspaceHandlers) ActiveWorkspace(c *fiber.Ctx) error {
+	user := currentUser(c)
+
+	sess, err := w.sessionStore.Get(c)
+	if err != nil {
+		panic(err)
+	}
+	workspaceId, _ := sess.Get(constants.WorkspaceIdSessionKey).(int64)
+
+	active, err := w.repo.GetUserWorkspace(c.UserContext(), workspaceId, user.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		personal, err := w.repo.EnsurePersonalWorkspace(c.UserContext(), *user)
+		if err != nil {
+			return err
+		}
+		active = repo.UserWorkspace{Workspace: personal, Role: repo.WorkspaceRoleOwner}
+
+		sess.Set(constants.WorkspaceIdSessionKey, active.ID)
+		if err = sess.Save(); err != nil {
+			panic(err)
+		}
+	} else if err != nil {
+		return err
+	}
+
+	ctx := c.UserContext()
+	c.Locals(constants.WorkspaceContextKey, &active)
+	c.Locals(constants.WorkspacesContextKey, func() ([]repo.UserWorkspace, error) {
+		return w.repo.FilterWorkspaces(ctx, user.ID)
+	})
+
+	return c.Next()
+}
+
+type SwitchWorkspaceRequest struct {
+	WorkspaceID int64 `form:"workspace_id"`
+}
+
+func (w *WorkspaceHandlers) Switch(c *fiber.Ctx) error {
+	var req SwitchWorkspaceRequest
+	if err := c.BodyParser(&req); err != nil {
+		return err
+	}
+
+	role, err := w.repo.GetWorkspaceRole(c.UserContext(), req.WorkspaceID, currentUser(c).ID)
+	if err != nil {
+		return err
+	}
+	if role == "" {
+		return fiber.ErrNotFound
+	}
+
+	if err = w.setActive(c, req.WorkspaceID); err != nil {
+		return err
+	}
+
+	c.Set("HX-Location", "/app/lists")
+	return c.Redirect("/app/lists", fiber.StatusFound)
+}
+
+func (w *WorkspaceHandlers) New(c *fiber.Ctx) error {
+	return w.renderer.RenderComponent(c, 200, workspaceviews.New(workspaceviews.WorkspaceForm{}, ""))
+}
+
+func (w *WorkspaceHandlers) Create(c *fiber.Ctx) error {
+	var form workspaceviews.WorkspaceForm
+	if err := c.BodyParser(&form); err != nil {
+		return err
+	}
+
+	form.Name = strings.TrimSpace(form.Name)
+	if form.Name == "" {
+		return w.renderer.RenderComponent(c, fiber.StatusUnprocessableEntity,
+			workspaceviews.New(form, "name is required"))
+	}
+
+	workspace, err := w.repo.CreateWorkspace(c.UserContext(), form.Name, currentUser(c).ID)
+	if err != nil {
+		return err
+	}
+
+	if err = w.setActive(c, workspace.ID); err != nil {
+		return err
+	}
+
+	if err = flash.Add(c, w.sessionStore, flash.Success, "Workspace created. Add members from the workspace settings."); err != nil {
+		return err
+	}
+
+	c.Set("HX-Location", "/app/lists")
+	return c.Redirect("/app/lists", fiber.StatusFound)
+}
+
+func (w *WorkspaceHandlers) Settings(c *fiber.Ctx) error {
+	return w.renderSettings(c, fiber.StatusOK, workspaceviews.MemberForm{Role: repo.WorkspaceRoleMember}, "")
+}
+
+func (w *WorkspaceHandlers) AddMember(c *fiber.Ctx) error {
+	workspace := activeWorkspace(c)
+	if !workspace.CanManage() {
+		return fiber.ErrForbidden
+	}
+
+	var form workspaceviews.MemberForm
+	if err := c.BodyParser(&form); err != nil {
+		return err
+	}
+	form.Email = strings.ToLower(strings.TrimSpace(form.Email))
+
+	if !repo.IsWorkspaceRole(form.Role) {
+		return w.renderSettings(c, fiber.StatusUnprocessableEntity, form, "choose member or admin")
+	}
+	if !workspace.CanManageRole(form.Role) {
+		return fiber.ErrForbidden
+	}
+
+	// changing an existing member's role takes away the one they had
+	existing, err := w.repo.GetUserByEmail(c.UserContext(), form.Email)
+	if err == nil {
+		role, err := w.repo.GetWorkspaceRole(c.UserContext(), workspace.ID, existing.ID)
+		if err != nil {
+			return err
+		}
+		if role != "" && !workspace.CanManageRole(role) {
+			return fiber.ErrForbidden
+		}
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	user, err := w.repo.AddWorkspaceMember(c.UserContext(), workspace.ID, form.Email, form.Role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return w.renderSettings(c, fiber.StatusUnprocessableEntity, form, "nobody has registered with that email")
+	}
+	if errors.Is(err, repo.ErrPersonalWorkspace) {
+		return w.renderSettings(c, fiber.StatusUnprocessableEntity, form, "personal workspaces cannot have other members")
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = flash.Add(c, w.sessionStore, flash.Success, "Added "+user.Email+" to "+workspace.Name+"."); err != nil {
+		return err
+	}
+
+	c.Set("HX-Location", "/app/workspace")
+	return c.Redirect("/app/workspace", fiber.StatusFound)
+}
+
+func (w *WorkspaceHandlers) RemoveMember(c *fiber.Ctx) error {
+	workspace := activeWorkspace(c)
+
+	userId, err := c.ParamsInt("userId")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	// anyone but the owner may leave, but only managers may remove others, and only the
+	// owner may remove admins
+	if int64(userId) != currentUser(c).ID {
+		role, err := w.repo.GetWorkspaceRole(c.UserContext(), workspace.ID, int64(userId))
+		if err != nil {
+			return err
+		}
+		// removing someone who has left already is checked like removing a member
+		if role == "" {
+			role = repo.WorkspaceRoleMember
+		}
+		if !workspace.CanManageRole(role) {
+			return fiber.ErrForbidden
+		}
+	}
+
+	if err = w.repo.RemoveWorkspaceMember(c.UserContext(), workspace.ID, int64(userId)); err != nil {
+		return err
+	}
+
+	if err = flash.Add(c, w.sessionStore, flash.Info, "Member removed."); err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (w *WorkspaceHandlers) renderSettings(c *fiber.Ctx, status int, form workspaceviews.MemberForm, errorMsg string) error {
+	workspace := activeWorkspace(c)
+
+	members, err := w.repo.FilterWorkspaceMembers(c.UserContext(), workspace.ID)
+	if err != nil {
+		return err
+	}
+
+	return w.renderer.RenderComponent(c, status, workspaceviews.Settings(workspaceviews.SettingsProps{
+		Workspace: *workspace,
+		Members:   members,
+		Form:      form,
+		Error:     errorMsg,
+	}))
+}
+
+func (w *WorkspaceHandlers) setActive(c *fiber.Ctx, workspaceId int64) error {
+	sess, err := w.sessionStore.Get(c)
+	if err != nil {
+		return err
+	}
+
+	sess.Set(constants.WorkspaceIdSessionKey, workspaceId)
+	return sess.Save()
+}
@@ -0,0 +1,191 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"htmxtodo/internal/repo"
+	"net/url"
+	"strconv"
+	"testing"
+)
+
+func TestWorkspaces(t *testing.T) {
+	a := newTestApp(t)
+	c, user := a.login("team@example.com")
+	mc, member := a.login("member@example.com")
+
+	expectStatus(t, c.get("/app/workspaces/new"), fiber.StatusOK)
+	expectStatus(t, c.do("POST", "/app/workspaces", url.Values{"name": {" "}}), fiber.StatusUnprocessableEntity)
+	expectRedirect(t, c.do("POST", "/app/workspaces", url.Values{"name": {"Team"}}), "/app/lists")
+
+	workspaces, err := a.repo.FilterWorkspaces(context.Background(), user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(workspaces) != 2 {
+		t.Fatalf("expected a personal and a team workspace, got %+v", workspaces)
+	}
+	personal, team := workspaces[0], workspaces[1]
+
+	// new lists go in the workspace that was just created
+	list := createList(t, a, c, "Team list")
+	if list.WorkspaceID != team.ID {
+		t.Fatalf("expected the list in workspace %d, got %d", team.ID, list.WorkspaceID)
+	}
+
+	expectStatus(t, c.get("/app/workspace"), fiber.StatusOK)
+	invalid := []url.Values{
+		{"email": {member.Email}, "role": {repo.WorkspaceRoleOwner}},
+		{"email": {"nobody@example.com"}, "role": {repo.WorkspaceRoleMember}},
+	}
+	for _, form := range invalid {
+		expectStatus(t, c.do("POST", "/app/workspace/members", form), fiber.StatusUnprocessableEntity)
+	}
+	form := url.Values{"email": {member.Email}, "role": {repo.WorkspaceRoleMember}}
+	expectRedirect(t, c.do("POST", "/app/workspace/members", form), "/app/workspace")
+
+	role, err := a.repo.GetWorkspaceRole(context.Background(), team.ID, member.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if role != repo.WorkspaceRoleMember {
+		t.Fatalf("expected member, got %q", role)
+	}
+
+	// members switch to the workspace, and own the lists they create in it
+	teamForm := url.Values{"workspace_id": {strconv.FormatInt(team.ID, 10)}}
+	expectRedirect(t, mc.do("POST", "/app/workspaces/switch", teamForm), "/app/lists")
+	resp := mc.get("/app/lists")
+	expectStatus(t, resp, fiber.StatusOK)
+	page := resp.page(t)
+	page.ExpectText("#workspace-switcher .navbar-link", "Team")
+	page.ExpectCount("#workspace-switcher form", 2)
+	expectBody(t, resp, "Team list")
+	memberList := createList(t, a, mc, "Member list")
+	expectStatus(t, mc.get(listPath(memberList, "/sharing")), fiber.StatusOK)
+
+	expectStatus(t, c.do("DELETE", fmt.Sprintf("/app/workspace/members/%d", member.ID), nil), fiber.StatusNoContent)
+
+	// once removed, they are back in their personal workspace, without the lists they created
+	resp = mc.get("/app/lists")
+	expectStatus(t, resp, fiber.StatusOK)
+	page = resp.page(t)
+	page.ExpectText("#workspace-switcher .navbar-link", "Personal")
+	page.ExpectCount("#workspace-switcher form", 1)
+	expectStatus(t, mc.get(listPath(memberList, "/edit")), fiber.StatusNotFound)
+	expectStatus(t, mc.do("DELETE", listPath(memberList, ""), nil), fiber.StatusNotFound)
+	expectStatus(t, c.get(listPath(memberList, "/sharing")), fiber.StatusOK)
+
+	switchForm := url.Values{"workspace_id": {strconv.FormatInt(personal.ID, 10)}}
+	expectRedirect(t, c.do("POST", "/app/workspaces/switch", switchForm), "/app/lists")
+	expectStatus(t, c.do("POST", "/app/workspace/members", form), fiber.StatusUnprocessableEntity)
+
+	// nobody can switch to a workspace they are not in
+	other, _ := a.login("other@example.com")
+	expectStatus(t, other.do("POST", "/app/workspaces/switch", url.Values{"workspace_id": {strconv.FormatInt(team.ID, 10)}}), fiber.StatusNotFound)
+}
+
+func TestTags(t *testing.T) {
+	a := newTestApp(t)
+	c, _ := a.login("tags@example.com")
+
+	expectStatus(t, c.get("/app/tags"), fiber.StatusOK)
+
+	invalid := []url.Values{
+		{"name": {""}, "color": {"#ff3860"}},
+		{"name": {"urgent"}, "color": {"red"}},
+	}
+	for _, form := range invalid {
+		expectStatus(t, c.do("POST", "/app/tags", form), fiber.StatusUnprocessableEntity)
+	}
+
+	expectRedirect(t, c.do("POST", "/app/tags", url.Values{"name": {"urgent"}, "color": {"#FF3860"}}), "/app/tags")
+	expectRedirect(t, c.do("POST", "/app/tags", url.Values{"name": {"important"}, "color": {"#3273dc"}}), "/app/tags")
+	expectStatus(t, c.do("POST", "/app/tags", url.Values{"name": {"urgent"}, "color": {"#3273dc"}}), fiber.StatusUnprocessableEntity)
+
+	list := createList(t, a, c, "Tagged")
+	tags, err := a.repo.FilterTags(context.Background(), list.WorkspaceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	important, urgent := tags[0], tags[1]
+	tagPath := func(id int64, suffix string) string {
+		return fmt.Sprintf("/app/tags/%d%s", id, suffix)
+	}
+
+	expectStatus(t, c.do("PATCH", tagPath(important.ID, ""), url.Values{"name": {"urgent"}, "color": {"#3273dc"}}), fiber.StatusUnprocessableEntity)
+	expectStatus(t, c.do("PATCH", tagPath(important.ID, ""), url.Values{"name": {""}, "color": {"#3273dc"}}), fiber.StatusUnprocessableEntity)
+	resp := c.do("PATCH", tagPath(important.ID, ""), url.Values{"name": {"later"}, "color": {"#3273dc"}})
+	expectStatus(t, resp, fiber.StatusOK)
+	expectBody(t, resp, "later")
+
+	into := func(id int64) url.Values {
+		return url.Values{"into_id": {strconv.FormatInt(id, 10)}}
+	}
+	expectStatus(t, c.do("POST", tagPath(important.ID, "/merge"), into(important.ID)), fiber.StatusUnprocessableEntity)
+	expectRedirect(t, c.do("POST", tagPath(important.ID, "/merge"), into(urgent.ID)), "/app/tags")
+	expectStatus(t, c.do("PATCH", tagPath(important.ID, ""), url.Values{"name": {"gone"}, "color": {"#3273dc"}}), fiber.StatusNotFound)
+
+	expectStatus(t, c.do("DELETE", tagPath(urgent.ID, ""), nil), fiber.StatusNoContent)
+	if tags, _ = a.repo.FilterTags(context.Background(), list.WorkspaceID); len(tags) != 0 {
+		t.Fatalf("expected no tags, got %+v", tags)
+	}
+
+	// tags in other workspaces are not found
+	other, _ := a.login("other@example.com")
+	expectStatus(t, other.do("DELETE", tagPath(urgent.ID, ""), nil), fiber.StatusNotFound)
+}
+
+func TestWorkspaceAdmins(t *testing.T) {
+	a := newTestApp(t)
+	owner, _ := a.login("owner@example.com")
+	admin, _ := a.login("admin@example.com")
+	_, peer := a.login("peer@example.com")
+	_, member := a.login("member@example.com")
+	_, newcomer := a.login("newcomer@example.com")
+
+	expectRedirect(t, owner.do("POST", "/app/workspaces", url.Values{"name": {"Team"}}), "/app/lists")
+	add := func(c *client, email string, role string) response {
+		return c.do("POST", "/app/workspace/members", url.Values{"email": {email}, "role": {role}})
+	}
+	expectRedirect(t, add(owner, "admin@example.com", repo.WorkspaceRoleAdmin), "/app/workspace")
+	expectRedirect(t, add(owner, peer.Email, repo.WorkspaceRoleAdmin), "/app/workspace")
+	expectRedirect(t, add(owner, member.Email, repo.WorkspaceRoleMember), "/app/workspace")
+
+	workspaces, err := a.repo.FilterWorkspaces(context.Background(), peer.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	team := workspaces[1]
+	expectRedirect(t, admin.do("POST", "/app/workspaces/switch", url.Values{"workspace_id": {strconv.FormatInt(team.ID, 10)}}), "/app/lists")
+
+	// admins manage members, but only the owner makes and unmakes admins
+	resp := admin.get("/app/workspace")
+	expectStatus(t, resp, fiber.StatusOK)
+	page := resp.page(t)
+	page.ExpectCount(`#workspace-member-form option`, 1)
+	page.ExpectCount(`#workspace-members button`, 2)
+
+	expectStatus(t, add(admin, newcomer.Email, repo.WorkspaceRoleAdmin), fiber.StatusForbidden)
+	expectStatus(t, add(admin, member.Email, repo.WorkspaceRoleAdmin), fiber.StatusForbidden)
+	expectStatus(t, add(admin, peer.Email, repo.WorkspaceRoleMember), fiber.StatusForbidden)
+	expectStatus(t, admin.do("DELETE", fmt.Sprintf("/app/workspace/members/%d", peer.ID), nil), fiber.StatusForbidden)
+
+	role, err := a.repo.GetWorkspaceRole(context.Background(), team.ID, peer.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if role != repo.WorkspaceRoleAdmin {
+		t.Fatalf("expected the peer to still be an admin, got %q", role)
+	}
+
+	expectRedirect(t, add(admin, newcomer.Email, repo.WorkspaceRoleMember), "/app/workspace")
+	expectStatus(t, admin.do("DELETE", fmt.Sprintf("/app/workspace/members/%d", member.ID), nil), fiber.StatusNoContent)
+
+	expectRedirect(t, add(owner, peer.Email, repo.WorkspaceRoleMember), "/app/workspace")
+	if role, _ = a.repo.GetWorkspaceRole(context.Background(), team.ID, peer.ID); role != repo.WorkspaceRoleMember {
+		t.Fatalf("expected the owner to demote the peer, got %q", role)
+	}
+	expectStatus(t, owner.do("DELETE", fmt.Sprintf("/app/workspace/members/%d", peer.ID), nil), fiber.StatusNoContent)
+}
@@ -0,0 +1,172 @@
+package challenge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// The CAPTCHA providers.
+const (
+	ProviderTurnstile = "turnstile"
+	ProviderHcaptcha  = "hcaptcha"
+	ProviderRecaptcha = "recaptcha"
+	ProviderFake      = "fake"
+)
+
+// Verifier checks the response a CAPTCHA, or a proof-of-work solver, added to a form, from
+// the client at remoteIp.
+type Verifier interface {
+	Verify(ctx context.Context, response string, remoteIp string) (bool, error)
+}
+
+// Widget is a CAPTCHA to show in a form. Script renders it into an element with Class and a
+// data-sitekey of SiteKey, and it adds its response to the form as Field.
+type Widget struct {
+	Script  string
+	Class   string
+	SiteKey string
+	Field   string
+	// FakeResponse, if set, is sent by a plain checkbox instead, as the fake has no script.
+	FakeResponse string
+}
+
+// Captcha rejects forms without a response to widget that verifier accepts.
+type Captcha struct {
+	verifier Verifier
+	widget   Widget
+}
+
+func NewCaptcha(verifier Verifier, widget Widget) *Captcha {
+	return &Captcha{verifier: verifier, widget: widget}
+}
+
+// NewProviderCaptcha returns a Captcha from one of the providers, with the site key and
+// secret it issued.
+func NewProviderCaptcha(provider string, siteKey string, secret string) (*Captcha, error) {
+	if provider == ProviderFake {
+		return NewFakeCaptcha(), nil
+	}
+
+	p, ok := providers[provider]
+	if !ok {
+		return nil, fmt.Errorf("unknown CAPTCHA provider %q", provider)
+	}
+	widget := Widget{Script: p.script, Class: p.class, SiteKey: siteKey, Field: p.field}
+	return NewCaptcha(NewSiteVerify(p.verifyUrl, secret), widget), nil
+}
+
+func (c *Captcha) Issue(*fiber.Ctx) (Fields, error) {
+	widget := c.widget
+	return Fields{Captcha: &widget}, nil
+}
+
+func (c *Captcha) Verify(ctx *fiber.Ctx) error {
+	response := ctx.FormValue(c.widget.Field)
+	if response == "" {
+		return &Rejection{Reason: ReasonCaptcha}
+	}
+
+	ok, err := c.verifier.Verify(ctx.UserContext(), response, ctx.IP())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return &Rejection{Reason: ReasonCaptcha}
+	}
+	return nil
+}
+
+type provider struct {
+	script    string
+	class     string
+	field     string
+	verifyUrl string
+}
+
+var providers = map[string]provider{
+	ProviderTurnstile: {
+		script:    "https://challenges.cloudflare.com/turnstile/v0/api.js",
+		class:     "cf-turnstile",
+		field:     "cf-turnstile-response",
+		verifyUrl: "https://challenges.cloudflare.com/turnstile/v0/siteverify",
+	},
+	ProviderHcaptcha: {
+		script:    "https://js.hcaptcha.com/1/api.js",
+		class:     "h-captcha",
+		field:     "h-captcha-response",
+		verifyUrl: "https://api.hcaptcha.com/siteverify",
+	},
+	ProviderRecaptcha: {
+		script:    "https://www.google.com/recaptcha/api.js",
+		class:     "g-recaptcha",
+		field:     "g-recaptcha-response",
+		verifyUrl: "https://www.google.com/recaptcha/api/siteverify",
+	},
+}
+
+// SiteVerify verifies responses with a siteverify API, which Turnstile, hCaptcha and
+// reCAPTCHA all have.
+type SiteVerify struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+func NewSiteVerify(url string, secret string) *SiteVerify {
+	return &SiteVerify{url: url, secret: secret, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (s *SiteVerify) Verify(ctx context.Context, response string, remoteIp string) (bool, error) {
+	form := url.Values{"secret": {s.secret}, "response": {response}, "remoteip": {remoteIp}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("siteverify: unexpected status %s", resp.Status)
+	}
+
+	var result struct {
+		Success    bool     `json:"success"`
+		ErrorCodes []string `json:"error-codes"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, fmt.Errorf("siteverify: %w", err)
+	}
+
+	// a bad secret is our problem, not the client's
+	for _, code := range result.ErrorCodes {
+		if code == "invalid-input-secret" || code == "missing-input-secret" {
+			return false, fmt.Errorf("siteverify: %s", code)
+		}
+	}
+	return result.Success, nil
+}
+
+// FakeResponse is the response the fake accepts.
+const FakeResponse = "fake-captcha-passed"
+
+// Fake accepts only FakeResponse, for local development and tests.
+type Fake struct{}
+
+func (Fake) Verify(ctx context.Context, response string, remoteIp string) (bool, error) {
+	return response == FakeResponse, nil
+}
+
+// NewFakeCaptcha returns a Captcha that is passed by ticking a checkbox.
+func NewFakeCaptcha() *Captcha {
+	return NewCaptcha(Fake{}, Widget{Field: "captcha_response", FakeResponse: FakeResponse})
+}
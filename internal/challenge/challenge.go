@@ -0,0 +1,78 @@
+// Package challenge tells people from bots on public forms, such as registration. A
+// Challenge adds fields to a form when it is rendered, and checks them when it is
+// submitted. Challenges are combined with All.
+package challenge
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// The reasons a submission is rejected for.
+const (
+	ReasonHoneypot    = "honeypot"
+	ReasonNotIssued   = "not_issued"
+	ReasonTooFast     = "too_fast"
+	ReasonCaptcha     = "captcha"
+	ReasonEmailDomain = "email_domain"
+)
+
+// Rejection is returned for a submission that fails a challenge. Its Reason is for logs
+// and metrics, rather than to be shown, so as not to tell bots what gave them away.
+type Rejection struct {
+	Reason string
+}
+
+func (r *Rejection) Error() string {
+	return "rejected by challenge: " + r.Reason
+}
+
+// Fields are what a form must include for its challenges.
+type Fields struct {
+	// Honeypot names a field to hide from people, which bots fill in.
+	Honeypot string
+	// Captcha is the widget to show, if there is one.
+	Captcha *Widget
+}
+
+type Challenge interface {
+	// Issue starts the challenge for a form about to be rendered, and returns the fields
+	// the form must include. It may be called again for the same form, such as when it is
+	// shown again with an error.
+	Issue(c *fiber.Ctx) (Fields, error)
+	// Verify checks a submitted form. It returns a *Rejection if the form fails the
+	// challenge, or another error if it could not be checked.
+	Verify(c *fiber.Ctx) error
+}
+
+// All combines challenges, which a form must pass every one of.
+func All(challenges ...Challenge) Challenge {
+	return all(challenges)
+}
+
+type all []Challenge
+
+func (a all) Issue(c *fiber.Ctx) (Fields, error) {
+	var fields Fields
+	for _, challenge := range a {
+		f, err := challenge.Issue(c)
+		if err != nil {
+			return Fields{}, err
+		}
+		if f.Honeypot != "" {
+			fields.Honeypot = f.Honeypot
+		}
+		if f.Captcha != nil {
+			fields.Captcha = f.Captcha
+		}
+	}
+	return fields, nil
+}
+
+func (a all) Verify(c *fiber.Ctx) error {
+	for _, challenge := range a {
+		if err := challenge.Verify(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
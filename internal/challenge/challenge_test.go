@@ -0,0 +1,61 @@
+package challenge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEmailDomains(t *testing.T) {
+	domains := EmailDomains{Allow: []string{"example.com", "@Example.org"}, Deny: []string{"spam.example.com"}}
+	for email, allowed := range map[string]bool{
+		"someone@example.com":       true,
+		"Someone@EXAMPLE.org":       true,
+		"someone@team.example.com":  true,
+		"someone@spam.example.com":  false,
+		"someone@badexample.com":    false,
+		"someone@example.com.evil":  false,
+		"someone@elsewhere.example": false,
+		"no-domain":                 false,
+	} {
+		err := domains.Check(email)
+		var rejection *Rejection
+		if allowed && err != nil {
+			t.Errorf("expected %s to be allowed, got %v", email, err)
+		}
+		if !allowed && (!errors.As(err, &rejection) || rejection.Reason != ReasonEmailDomain) {
+			t.Errorf("expected %s to be rejected, got %v", email, err)
+		}
+	}
+
+	if err := (EmailDomains{Deny: []string{"spam.example.com"}}).Check("someone@elsewhere.example"); err != nil {
+		t.Errorf("expected any domain that is not denied to be allowed, got %v", err)
+	}
+}
+
+func TestSiteVerify(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result := map[string]any{"success": r.FormValue("response") == "solved" && r.FormValue("remoteip") == "192.0.2.1"}
+		if r.FormValue("secret") != "secret" {
+			result = map[string]any{"success": false, "error-codes": []string{"invalid-input-secret"}}
+		}
+		_ = json.NewEncoder(w).Encode(result)
+	}))
+	defer server.Close()
+
+	verifier := NewSiteVerify(server.URL, "secret")
+	for response, want := range map[string]bool{"solved": true, "guessed": false} {
+		ok, err := verifier.Verify(context.Background(), response, "192.0.2.1")
+		if err != nil || ok != want {
+			t.Errorf("expected %q to verify as %t, got %t, %v", response, want, ok, err)
+		}
+	}
+
+	// a misconfigured secret is an error, rather than every registration failing quietly
+	if _, err := NewSiteVerify(server.URL, "wrong").Verify(context.Background(), "solved", "192.0.2.1"); err == nil {
+		t.Error("expected an error for an invalid secret")
+	}
+}
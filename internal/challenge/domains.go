@@ -0,0 +1,31 @@
+package challenge
+
+import (
+	"strings"
+)
+
+// EmailDomains restricts the email addresses that may be used. If Allow is not empty, only
+// its domains are allowed, and Deny's domains never are. A domain covers its subdomains.
+type EmailDomains struct {
+	Allow []string
+	Deny  []string
+}
+
+// Check returns a *Rejection if email is not allowed.
+func (d EmailDomains) Check(email string) error {
+	_, domain, _ := strings.Cut(strings.ToLower(strings.TrimSpace(email)), "@")
+	if matchDomain(domain, d.Deny) || (len(d.Allow) > 0 && !matchDomain(domain, d.Allow)) {
+		return &Rejection{Reason: ReasonEmailDomain}
+	}
+	return nil
+}
+
+func matchDomain(domain string, list []string) bool {
+	for _, entry := range list {
+		entry = strings.ToLower(strings.TrimPrefix(entry, "@"))
+		if domain == entry || strings.HasSuffix(domain, "."+entry) {
+			return true
+		}
+	}
+	return false
+}
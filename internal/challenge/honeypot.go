@@ -0,0 +1,66 @@
+package challenge
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/session"
+	"time"
+)
+
+// issuedAtKey holds when a Honeypot was last issued in the session, in Unix milliseconds.
+const issuedAtKey = "challenge.issued_at"
+
+// Honeypot rejects forms that fill in a field hidden from people, or that are submitted
+// sooner than a person could fill them in: less than minFillTime after the form was last
+// rendered. That time is kept in the session, so bots cannot forge it, and a form cannot
+// be submitted without having been rendered. Each rendering allows one submission, so a
+// bot cannot keep submitting with the same session. The time is not checked if
+// minFillTime is 0.
+type Honeypot struct {
+	sessionStore *session.Store
+	field        string
+	minFillTime  time.Duration
+}
+
+func NewHoneypot(sessionStore *session.Store, field string, minFillTime time.Duration) *Honeypot {
+	return &Honeypot{sessionStore: sessionStore, field: field, minFillTime: minFillTime}
+}
+
+func (h *Honeypot) Issue(c *fiber.Ctx) (Fields, error) {
+	fields := Fields{Honeypot: h.field}
+	if h.minFillTime == 0 {
+		return fields, nil
+	}
+
+	sess, err := h.sessionStore.Get(c)
+	if err != nil {
+		return Fields{}, err
+	}
+
+	sess.Set(issuedAtKey, time.Now().UnixMilli())
+	return fields, sess.Save()
+}
+
+func (h *Honeypot) Verify(c *fiber.Ctx) error {
+	if c.FormValue(h.field) != "" {
+		return &Rejection{Reason: ReasonHoneypot}
+	}
+	if h.minFillTime == 0 {
+		return nil
+	}
+
+	sess, err := h.sessionStore.Get(c)
+	if err != nil {
+		return err
+	}
+
+	issuedAt, ok := sess.Get(issuedAtKey).(int64)
+	if !ok {
+		return &Rejection{Reason: ReasonNotIssued}
+	}
+	if time.Since(time.UnixMilli(issuedAt)) < h.minFillTime {
+		return &Rejection{Reason: ReasonTooFast}
+	}
+
+	sess.Delete(issuedAtKey)
+	return sess.Save()
+}
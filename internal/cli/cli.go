@@ -0,0 +1,67 @@
+// Package cli implements the administrative commands run as `htmxtodo <command> [args]`,
+// for bootstrapping and operations that should not need the web UI.
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"htmxtodo/gen/htmxtodo_dev/public/model"
+	"htmxtodo/internal/repo"
+	"io"
+	"strings"
+)
+
+const usage = `usage: htmxtodo [flags] [command]
+
+Without a command, the web server is started. See htmxtodo -h for the flags.
+
+Commands:
+  grant-admin <email>   give a user the admin role, creating the user if needed
+  revoke-admin <email>  return an admin to the user role
+`
+
+var ErrUsage = errors.New("invalid command")
+
+// Run executes the command named by args[0], writing its output to out.
+func Run(ctx context.Context, r repo.Repository, out io.Writer, args []string) error {
+	if len(args) != 2 {
+		fmt.Fprint(out, usage)
+		return ErrUsage
+	}
+
+	switch args[0] {
+	case "grant-admin":
+		return setRole(ctx, r, out, args[1], repo.RoleAdmin)
+	case "revoke-admin":
+		return setRole(ctx, r, out, args[1], repo.RoleUser)
+	default:
+		fmt.Fprint(out, usage)
+		return ErrUsage
+	}
+}
+
+func setRole(ctx context.Context, r repo.Repository, out io.Writer, email string, role string) error {
+	var user model.AppUser
+	err := r.WithTx(ctx, func(r repo.Repository) error {
+		var err error
+		user, err = r.FindOrCreateUser(ctx, strings.ToLower(strings.TrimSpace(email)))
+		if err != nil {
+			return err
+		}
+
+		user, err = r.UpdateUserRole(ctx, user.ID, role)
+		if err != nil {
+			return err
+		}
+
+		_, err = r.CreateAuditEvent(ctx, repo.UserAuditEvent(nil, "user.role", user))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "%s now has the %s role\n", user.Email, user.Role)
+	return nil
+}
@@ -3,52 +3,180 @@ package config
 import (
 	"database/sql"
 	"embed"
+	"htmxtodo/internal/challenge"
 	"htmxtodo/internal/constants"
+	"htmxtodo/internal/ratelimit"
 	"htmxtodo/internal/repo"
 	"htmxtodo/internal/secrets"
+	"htmxtodo/internal/sessionstore"
 	"net/http"
 	"os"
+	"time"
 )
 
+const (
+	DefaultSessionIdleTimeout     = 24 * time.Hour
+	DefaultSessionAbsoluteTimeout = 30 * 24 * time.Hour
+	DefaultTrashRetention         = 30 * 24 * time.Hour
+
+	DefaultDbMaxOpenConns     = 20
+	DefaultDbMaxIdleConns     = 5
+	DefaultDbConnMaxLifetime  = 30 * time.Minute
+	DefaultDbConnMaxIdleTime  = 5 * time.Minute
+	DefaultDbStatementTimeout = 10 * time.Second
+
+	// DefaultDbReplicaStickiness comfortably covers the usual replication lag.
+	DefaultDbReplicaStickiness = 5 * time.Second
+
+	DefaultLoginLockoutThreshold = 5
+	DefaultLoginLockoutBase      = time.Minute
+	DefaultLoginLockoutMax       = time.Hour
+
+	// DefaultRegistrationMinFillTime is quicker than a person fills the form in, even with
+	// a password manager.
+	DefaultRegistrationMinFillTime = 2 * time.Second
+
+	// CognitoEndpointFake runs a fakecognito server inside the app, for local development.
+	CognitoEndpointFake = "fake"
+)
+
+var (
+	DefaultRateLimitLoginIp         = ratelimit.Rate{Max: 20, Per: time.Minute}
+	DefaultRateLimitLoginAccount    = ratelimit.Rate{Max: 10, Per: 10 * time.Minute}
+	DefaultRateLimitRegisterIp      = ratelimit.Rate{Max: 10, Per: time.Hour}
+	DefaultRateLimitRegisterAccount = ratelimit.Rate{Max: 3, Per: time.Hour}
+	DefaultRateLimitMutationIp      = ratelimit.Rate{Max: 600, Per: time.Minute}
+	DefaultRateLimitMutationAccount = ratelimit.Rate{Max: 120, Per: time.Minute}
+)
+
+// RateLimits throttle logins, registrations and changes made under /app, per client IP
+// and per account, and lock an account out from an IP after LockoutThreshold failed logins.
+type RateLimits struct {
+	LoginIp          ratelimit.Rate
+	LoginAccount     ratelimit.Rate
+	RegisterIp       ratelimit.Rate
+	RegisterAccount  ratelimit.Rate
+	MutationIp       ratelimit.Rate
+	MutationAccount  ratelimit.Rate
+	LockoutThreshold int
+	LockoutBase      time.Duration
+	LockoutMax       time.Duration
+}
+
+// Registration guards self sign-up against bots: forms submitted sooner than MinFillTime
+// after they were shown are rejected, as are those that fill in a hidden honeypot field,
+// fail the Captcha, if there is one, or use an email address EmailDomains does not allow.
+// Captcha is a challenge provider, and CaptchaSiteKey the site key it issued.
+type Registration struct {
+	MinFillTime    time.Duration
+	Captcha        string
+	CaptchaSiteKey string
+	EmailDomains   challenge.EmailDomains
+}
+
 // Config is the global config for the app router. Host and Port are needed for absolute URL generation.
+//
+// Behind a reverse proxy, ProxyHeader is the header it puts the client's IP in, which rate
+// limits and login lockouts count by. The header is only believed on requests from
+// TrustedProxies.
+//
+// SessionBackend is one of the sessionstore backends. SessionIdleTimeout logs a user out after
+// that long without activity, and SessionAbsoluteTimeout caps the lifetime of a login no matter
+// how active it is.
+//
+// DB is the app's connection pool, which the postgres session backend shares with Repo.
+// StatementTimeout bounds the context each request passes to the database, if it is set.
+// With a read replica, ReplicaStickiness is how long after a user writes that their reads
+// still go to the primary.
+//
+// RateLimitBackend is where RateLimits are counted: one of the postgres, redis or memory
+// sessionstore backends. Only the first two share counts between instances.
+//
+// Registration holds the checks self sign-ups must pass.
+//
+// TrashRetention is how long deleted lists and items stay in the trash before they are purged.
+//
+// CognitoEndpoint, if set, sends Cognito requests to that URL instead of AWS, such as to a
+// fakecognito server. No AWS credentials are needed then. CognitoEndpointFake starts one.
 type Config struct {
-	Env              string
-	Host             string
-	Port             string
-	Repo             repo.Repository
-	CookieSecure     bool
-	DisableLogColors bool
-	EnableStackTrace bool
-	StaticFS         http.FileSystem
-	Secrets          secrets.Secrets
+	Env                    string
+	Host                   string
+	Port                   string
+	ProxyHeader            string
+	TrustedProxies         []string
+	Repo                   repo.Repository
+	DB                     *sql.DB
+	StatementTimeout       time.Duration
+	ReplicaStickiness      time.Duration
+	CookieSecure           bool
+	DisableLogColors       bool
+	EnableStackTrace       bool
+	StaticFS               http.FileSystem
+	Secrets                secrets.Secrets
+	SessionBackend         string
+	SessionIdleTimeout     time.Duration
+	SessionAbsoluteTimeout time.Duration
+	RateLimitBackend       string
+	RateLimits             RateLimits
+	Registration           Registration
+	TrashRetention         time.Duration
+	Features               map[string]bool
+	CognitoEndpoint        string
 }
 
-func NewConfigFromEnvironment(dbConn *sql.DB, staticFS *embed.FS) *Config {
-	env := os.Getenv("ENV")
+// New builds the Config from loaded settings, and the secrets and databases they led to.
+// replicaConn is nil if there is no read replica.
+func New(settings Settings, s secrets.Secrets, dbConn *sql.DB, replicaConn *sql.DB, staticFS *embed.FS) *Config {
+	features := make(map[string]bool)
+	for _, name := range settings.Features {
+		features[name] = true
+	}
 
 	return &Config{
-		Env:              env,
-		Host:             os.Getenv("HOST"),
-		Port:             os.Getenv("PORT"),
-		Repo:             repo.New(dbConn),
-		CookieSecure:     env == constants.EnvProduction,
-		DisableLogColors: env == constants.EnvProduction,
-		EnableStackTrace: env == constants.EnvDevelopment,
+		Env:              settings.Env,
+		Host:             settings.Host,
+		Port:             settings.Port,
+		ProxyHeader:      settings.ProxyHeader,
+		TrustedProxies:   settings.TrustedProxies,
+		Repo:             repo.New(dbConn, replicaConn),
+		DB:               dbConn,
+		StatementTimeout: settings.DbStatementTimeout,
+		CookieSecure:     settings.Env == constants.EnvProduction,
+		DisableLogColors: settings.Env == constants.EnvProduction,
+		EnableStackTrace: settings.Env == constants.EnvDevelopment,
 		StaticFS:         http.FS(staticFS),
-		Secrets:          secrets.New(),
+		Secrets:          s,
+
+		SessionBackend:         settings.SessionBackend,
+		SessionIdleTimeout:     settings.SessionIdleTimeout,
+		SessionAbsoluteTimeout: settings.SessionAbsoluteTimeout,
+		ReplicaStickiness:      settings.DbReplicaStickiness,
+		RateLimitBackend:       settings.RateLimitBackend,
+		RateLimits:             settings.RateLimits(),
+		Registration:           settings.Registration(),
+		TrashRetention:         settings.TrashRetention,
+		Features:               features,
+		CognitoEndpoint:        settings.CognitoEndpoint,
 	}
 }
 
-func NewTestConfig(dbConn *sql.DB) *Config {
+func NewTestConfig(r repo.Repository) *Config {
 	return &Config{
 		Env:              constants.EnvTest,
 		Host:             os.Getenv("HOST"),
 		Port:             os.Getenv("PORT"),
-		Repo:             repo.New(dbConn),
+		Repo:             r,
 		CookieSecure:     false,
 		DisableLogColors: false,
 		EnableStackTrace: true,
 		StaticFS:         http.Dir("./static"),
-		Secrets:          secrets.New(),
+		Secrets:          secrets.New(secrets.Values{}),
+
+		SessionBackend:         sessionstore.BackendMemory,
+		SessionIdleTimeout:     DefaultSessionIdleTimeout,
+		SessionAbsoluteTimeout: DefaultSessionAbsoluteTimeout,
+		RateLimitBackend:       sessionstore.BackendMemory,
+		TrashRetention:         DefaultTrashRetention,
+		Features:               map[string]bool{},
 	}
 }
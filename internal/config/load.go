@@ -0,0 +1,594 @@
+package config
+
+import (
+	"encoding"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+	"htmxtodo/internal/challenge"
+	"htmxtodo/internal/constants"
+	"htmxtodo/internal/database"
+	"htmxtodo/internal/ratelimit"
+	"htmxtodo/internal/secrets"
+	"htmxtodo/internal/sessionstore"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultEnv  = constants.EnvDevelopment
+	DefaultPort = "3000"
+
+	DefaultSecretsRefresh = 5 * time.Minute
+)
+
+// Settings are the app's settings as they are configured, before they are turned into a
+// Config. Each field's setting tag is its name in config files; as an environment variable
+// it is upper-cased, and as a flag its underscores become dashes. Secrets are never
+// printed.
+type Settings struct {
+	Env                      string         `setting:"env" usage:"one of development, production or test"`
+	Host                     string         `setting:"host" usage:"the host to listen on"`
+	Port                     string         `setting:"port" usage:"the port to listen on"`
+	ProxyHeader              string         `setting:"proxy_header" usage:"a header a reverse proxy sets to the client's IP, such as X-Real-IP, or empty if there is no proxy"`
+	TrustedProxies           []string       `setting:"trusted_proxies" usage:"comma separated IPs or CIDR ranges of the proxies whose proxy_header is believed"`
+	DatabaseUrl              string         `setting:"database_url" secret:"true" usage:"the Postgres connection URL"`
+	DatabaseReplicaUrl       string         `setting:"database_replica_url" secret:"true" usage:"a Postgres read replica's connection URL, for reads that can lag behind"`
+	RedisUrl                 string         `setting:"redis_url" secret:"true" usage:"the Redis URL, for the redis session backend"`
+	SessionSecret            string         `setting:"session_secret" secret:"true" usage:"the encryption key, for the cookie session backend"`
+	SessionBackend           string         `setting:"session_backend" usage:"one of postgres, redis, memory or cookie"`
+	SessionIdleTimeout       time.Duration  `setting:"session_idle_timeout" usage:"how long a login lasts without activity"`
+	SessionAbsoluteTimeout   time.Duration  `setting:"session_absolute_timeout" usage:"how long a login lasts at most"`
+	CognitoClientId          string         `setting:"cognito_client_id" usage:"the Cognito app client ID"`
+	CognitoUserPoolId        string         `setting:"cognito_user_pool_id" usage:"the Cognito user pool ID"`
+	CognitoEndpoint          string         `setting:"cognito_endpoint" usage:"a Cognito API to use instead of AWS, or \"fake\""`
+	TrashRetention           time.Duration  `setting:"trash_retention" usage:"how long deleted lists and items are kept"`
+	Features                 []string       `setting:"features" usage:"comma separated feature flags to enable"`
+	SecretsDir               string         `setting:"secrets_dir" usage:"a directory of secret files named after their settings, such as /run/secrets"`
+	SecretsManagerPrefix     string         `setting:"secrets_manager_prefix" usage:"read secrets from AWS Secrets Manager, named with this prefix"`
+	SsmPrefix                string         `setting:"ssm_prefix" usage:"read secrets from SSM Parameter Store, named with this prefix"`
+	SecretsEndpoint          string         `setting:"secrets_endpoint" usage:"a Secrets Manager and SSM API to use instead of AWS"`
+	SecretsRefresh           time.Duration  `setting:"secrets_refresh" usage:"how often secrets are read again, to pick up rotated ones, or 0 for never"`
+	DbDriver                 string         `setting:"db_driver" usage:"the database driver, pq or pgx"`
+	DbMaxOpenConns           int            `setting:"db_max_open_conns" usage:"the most database connections to open, or 0 for no limit"`
+	DbMaxIdleConns           int            `setting:"db_max_idle_conns" usage:"the most idle database connections to keep"`
+	DbConnMaxLifetime        time.Duration  `setting:"db_conn_max_lifetime" usage:"how long a database connection is used at most, or 0 for no limit"`
+	DbConnMaxIdleTime        time.Duration  `setting:"db_conn_max_idle_time" usage:"how long a database connection is kept idle at most, or 0 for no limit"`
+	DbStatementTimeout       time.Duration  `setting:"db_statement_timeout" usage:"how long a request's database queries may take, or 0 for no limit"`
+	DbReplicaStickiness      time.Duration  `setting:"db_replica_stickiness" usage:"how long a user's reads go to the primary after they write, to see their own changes"`
+	RateLimitBackend         string         `setting:"rate_limit_backend" usage:"where rate limits are counted: postgres, redis or memory"`
+	RateLimitLoginIp         ratelimit.Rate `setting:"rate_limit_login_ip" usage:"login attempts allowed per IP, as max/period or off"`
+	RateLimitLoginAccount    ratelimit.Rate `setting:"rate_limit_login_account" usage:"login attempts allowed per email address, as max/period or off"`
+	RateLimitRegisterIp      ratelimit.Rate `setting:"rate_limit_register_ip" usage:"registrations allowed per IP, as max/period or off"`
+	RateLimitRegisterAccount ratelimit.Rate `setting:"rate_limit_register_account" usage:"registrations allowed per email address, as max/period or off"`
+	RateLimitMutationIp      ratelimit.Rate `setting:"rate_limit_mutation_ip" usage:"changes allowed per IP, as max/period or off"`
+	RateLimitMutationAccount ratelimit.Rate `setting:"rate_limit_mutation_account" usage:"changes allowed per user, as max/period or off"`
+	LoginLockoutThreshold    int            `setting:"login_lockout_threshold" usage:"failed logins from an IP to an account before it is locked out, or 0 for never"`
+	LoginLockoutBase         time.Duration  `setting:"login_lockout_base" usage:"how long the first lockout lasts; each further failure doubles it"`
+	LoginLockoutMax          time.Duration  `setting:"login_lockout_max" usage:"how long a lockout lasts at most"`
+	RegistrationMinFillTime  time.Duration  `setting:"registration_min_fill_time" usage:"how long the registration form takes to fill in at least, or 0 for no limit"`
+	RegistrationCaptcha      string         `setting:"registration_captcha" usage:"a CAPTCHA for registration: turnstile, hcaptcha, recaptcha or fake, or empty for none"`
+	CaptchaSiteKey           string         `setting:"captcha_site_key" usage:"the CAPTCHA provider's site key"`
+	CaptchaSecret            string         `setting:"captcha_secret" secret:"true" usage:"the CAPTCHA provider's secret key"`
+	RegistrationAllowDomains []string       `setting:"registration_allow_domains" usage:"comma separated email domains that may register, or empty for any"`
+	RegistrationDenyDomains  []string       `setting:"registration_deny_domains" usage:"comma separated email domains that may not register"`
+
+	// sources records where each setting came from, by name.
+	sources map[string]string
+}
+
+func DefaultSettings() Settings {
+	return Settings{
+		Env:                      DefaultEnv,
+		Port:                     DefaultPort,
+		SessionBackend:           sessionstore.BackendPostgres,
+		SessionIdleTimeout:       DefaultSessionIdleTimeout,
+		SessionAbsoluteTimeout:   DefaultSessionAbsoluteTimeout,
+		TrashRetention:           DefaultTrashRetention,
+		SecretsRefresh:           DefaultSecretsRefresh,
+		DbDriver:                 database.DriverPq,
+		DbMaxOpenConns:           DefaultDbMaxOpenConns,
+		DbMaxIdleConns:           DefaultDbMaxIdleConns,
+		DbConnMaxLifetime:        DefaultDbConnMaxLifetime,
+		DbConnMaxIdleTime:        DefaultDbConnMaxIdleTime,
+		DbStatementTimeout:       DefaultDbStatementTimeout,
+		DbReplicaStickiness:      DefaultDbReplicaStickiness,
+		RateLimitBackend:         sessionstore.BackendPostgres,
+		RateLimitLoginIp:         DefaultRateLimitLoginIp,
+		RateLimitLoginAccount:    DefaultRateLimitLoginAccount,
+		RateLimitRegisterIp:      DefaultRateLimitRegisterIp,
+		RateLimitRegisterAccount: DefaultRateLimitRegisterAccount,
+		RateLimitMutationIp:      DefaultRateLimitMutationIp,
+		RateLimitMutationAccount: DefaultRateLimitMutationAccount,
+		LoginLockoutThreshold:    DefaultLoginLockoutThreshold,
+		LoginLockoutBase:         DefaultLoginLockoutBase,
+		LoginLockoutMax:          DefaultLoginLockoutMax,
+		RegistrationMinFillTime:  DefaultRegistrationMinFillTime,
+	}
+}
+
+// setting describes a field of Settings.
+type setting struct {
+	index  int
+	name   string
+	usage  string
+	secret bool
+}
+
+func (s setting) env() string {
+	return strings.ToUpper(s.name)
+}
+
+func (s setting) flag() string {
+	return strings.ReplaceAll(s.name, "_", "-")
+}
+
+var settingList = func() []setting {
+	t := reflect.TypeOf(Settings{})
+
+	var list []setting
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if name, ok := field.Tag.Lookup("setting"); ok {
+			list = append(list, setting{
+				index:  i,
+				name:   name,
+				usage:  field.Tag.Get("usage"),
+				secret: field.Tag.Get("secret") == "true",
+			})
+		}
+	}
+	return list
+}()
+
+// Load reads the settings from, in increasing precedence, their defaults, a config file,
+// environment variables and the flags in args. The config file is named by the -config
+// flag or CONFIG_FILE, and may be TOML or YAML. The flags are defined on flags, so that
+// callers can add their own; arguments after them are left in flags.Args().
+//
+// Every problem found is returned at once, so that a broken deployment can be fixed in
+// one go.
+func Load(flags *flag.FlagSet, args []string, getenv func(string) string) (Settings, error) {
+	settings := DefaultSettings()
+	settings.sources = map[string]string{}
+
+	configFile := flags.String("config", "", "a TOML or YAML file of settings (env CONFIG_FILE)")
+	for _, s := range settingList {
+		usage := fmt.Sprintf("%s (env %s)", s.usage, s.env())
+		flags.String(s.flag(), settings.format(s), usage)
+	}
+	if err := flags.Parse(args); err != nil {
+		return settings, err
+	}
+
+	var errs []error
+
+	path := *configFile
+	if path == "" {
+		path = getenv("CONFIG_FILE")
+	}
+	if path != "" {
+		errs = append(errs, settings.loadFile(path)...)
+	}
+
+	for _, s := range settingList {
+		if value := getenv(s.env()); value != "" {
+			errs = append(errs, settings.set(s, value, "env "+s.env()))
+		}
+	}
+
+	flags.Visit(func(f *flag.Flag) {
+		for _, s := range settingList {
+			if s.flag() == f.Name {
+				errs = append(errs, settings.set(s, f.Value.String(), "flag -"+f.Name))
+			}
+		}
+	})
+
+	errs = append(errs, settings.validate()...)
+	return settings, errors.Join(errs...)
+}
+
+// loadFile reads settings from a TOML or YAML file, depending on its extension.
+func (settings *Settings) loadFile(path string) []error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return []error{err}
+	}
+
+	values := map[string]any{}
+	switch ext := filepath.Ext(path); ext {
+	case ".toml":
+		err = toml.Unmarshal(b, &values)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &values)
+	default:
+		err = fmt.Errorf("unknown format %q, expected .toml, .yaml or .yml", ext)
+	}
+	if err != nil {
+		return []error{fmt.Errorf("%s: %w", path, err)}
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		value := values[name]
+		s, ok := lookupSetting(name)
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: unknown setting %s", path, name))
+			continue
+		}
+
+		str, err := fileValue(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s %w", path, name, err))
+			continue
+		}
+		errs = append(errs, settings.set(s, str, path))
+	}
+	return errs
+}
+
+func settingNamed(name string) setting {
+	s, ok := lookupSetting(name)
+	if !ok {
+		panic("no setting named " + name)
+	}
+	return s
+}
+
+func lookupSetting(name string) (setting, bool) {
+	for _, s := range settingList {
+		if s.name == name {
+			return s, true
+		}
+	}
+	return setting{}, false
+}
+
+// fileValue returns a value from a config file in the form environment variables have.
+func fileValue(value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case int, int64, float64, bool:
+		return fmt.Sprint(v), nil
+	case []any:
+		var items []string
+		for _, item := range v {
+			str, err := fileValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, str)
+		}
+		return strings.Join(items, ","), nil
+	default:
+		return "", fmt.Errorf("must be a string, number or list, got %T", value)
+	}
+}
+
+// set parses a setting's value, and records where it came from.
+func (settings *Settings) set(s setting, value string, source string) error {
+	field := reflect.ValueOf(settings).Elem().Field(s.index)
+
+	if u, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		if err := u.UnmarshalText([]byte(value)); err != nil {
+			return fmt.Errorf("%s (%s): %w", s.name, source, err)
+		}
+		settings.sources[s.name] = source
+		return nil
+	}
+
+	switch field.Interface().(type) {
+	case time.Duration:
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("%s (%s): %w", s.name, source, err)
+		}
+		field.SetInt(int64(d))
+	case int:
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("%s (%s): must be a whole number, got %q", s.name, source, value)
+		}
+		field.SetInt(int64(n))
+	case []string:
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		field.SetString(strings.TrimSpace(value))
+	}
+
+	settings.sources[s.name] = source
+	return nil
+}
+
+// format returns a setting's value as it would be set.
+func (settings *Settings) format(s setting) string {
+	switch v := reflect.ValueOf(settings).Elem().Field(s.index).Interface().(type) {
+	case time.Duration:
+		return v.String()
+	case []string:
+		return strings.Join(v, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// describe names a setting in an error, with where its value came from.
+func (settings *Settings) describe(name string) string {
+	if source, ok := settings.sources[name]; ok {
+		return fmt.Sprintf("%s (%s)", name, source)
+	}
+	return name
+}
+
+func (settings *Settings) validate() []error {
+	var errs []error
+	invalid := func(name string, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", settings.describe(name), fmt.Sprintf(format, args...)))
+	}
+
+	switch settings.Env {
+	case constants.EnvDevelopment, constants.EnvProduction, constants.EnvTest:
+	default:
+		invalid("env", "must be development, production or test, got %q", settings.Env)
+	}
+
+	if port, err := strconv.Atoi(settings.Port); err != nil || port < 1 || port > 65535 {
+		invalid("port", "must be a number from 1 to 65535, got %q", settings.Port)
+	}
+
+	if settings.ProxyHeader != "" && len(settings.TrustedProxies) == 0 {
+		invalid("trusted_proxies", "is required with proxy_header, or clients can choose their own IP")
+	}
+	for _, proxy := range settings.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				invalid("trusted_proxies", "must be IPs or CIDR ranges such as 10.0.0.0/8, got %q", proxy)
+			}
+		}
+	}
+
+	if !settings.HasSecretProviders() {
+		errs = append(errs, settings.checkSecrets(func(name string) string {
+			return settings.format(settingNamed(name))
+		})...)
+	}
+
+	switch settings.SessionBackend {
+	case sessionstore.BackendPostgres, sessionstore.BackendMemory, sessionstore.BackendRedis, sessionstore.BackendCookie:
+	default:
+		invalid("session_backend", "must be postgres, redis, memory or cookie, got %q", settings.SessionBackend)
+	}
+
+	durations := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"session_idle_timeout", settings.SessionIdleTimeout},
+		{"session_absolute_timeout", settings.SessionAbsoluteTimeout},
+		{"trash_retention", settings.TrashRetention},
+	}
+	for _, d := range durations {
+		if d.value <= 0 {
+			invalid(d.name, "must be positive, got %s", d.value)
+		}
+	}
+	nonNegative := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"secrets_refresh", settings.SecretsRefresh},
+		{"db_conn_max_lifetime", settings.DbConnMaxLifetime},
+		{"db_conn_max_idle_time", settings.DbConnMaxIdleTime},
+		{"db_statement_timeout", settings.DbStatementTimeout},
+		{"db_replica_stickiness", settings.DbReplicaStickiness},
+		{"registration_min_fill_time", settings.RegistrationMinFillTime},
+	}
+	for _, d := range nonNegative {
+		if d.value < 0 {
+			invalid(d.name, "cannot be negative, got %s", d.value)
+		}
+	}
+
+	switch settings.RateLimitBackend {
+	case sessionstore.BackendPostgres, sessionstore.BackendRedis, sessionstore.BackendMemory:
+	default:
+		invalid("rate_limit_backend", "must be postgres, redis or memory, got %q", settings.RateLimitBackend)
+	}
+	if settings.LoginLockoutThreshold < 0 {
+		invalid("login_lockout_threshold", "cannot be negative, got %d", settings.LoginLockoutThreshold)
+	} else if settings.LoginLockoutThreshold > 0 {
+		if settings.LoginLockoutBase <= 0 {
+			invalid("login_lockout_base", "must be positive, got %s", settings.LoginLockoutBase)
+		}
+		if settings.LoginLockoutMax < settings.LoginLockoutBase {
+			invalid("login_lockout_max", "must be at least login_lockout_base, %s", settings.LoginLockoutBase)
+		}
+	}
+
+	switch settings.DbDriver {
+	case database.DriverPq, database.DriverPgx:
+	default:
+		invalid("db_driver", "must be pq or pgx, got %q", settings.DbDriver)
+	}
+	if settings.DbMaxOpenConns < 0 {
+		invalid("db_max_open_conns", "cannot be negative, got %d", settings.DbMaxOpenConns)
+	}
+	if settings.DbMaxIdleConns < 1 {
+		invalid("db_max_idle_conns", "must be at least 1, got %d", settings.DbMaxIdleConns)
+	} else if settings.DbMaxOpenConns > 0 && settings.DbMaxIdleConns > settings.DbMaxOpenConns {
+		invalid("db_max_idle_conns", "cannot be more than db_max_open_conns, %d", settings.DbMaxOpenConns)
+	}
+	if settings.SessionAbsoluteTimeout < settings.SessionIdleTimeout {
+		invalid("session_absolute_timeout", "must be at least session_idle_timeout, %s", settings.SessionIdleTimeout)
+	}
+
+	switch endpoint := settings.CognitoEndpoint; endpoint {
+	case "":
+	case CognitoEndpointFake:
+		if settings.Env == constants.EnvProduction {
+			invalid("cognito_endpoint", "cannot be fake in production")
+		}
+	default:
+		if !isHttpUrl(endpoint) {
+			invalid("cognito_endpoint", "must be an http or https URL, or fake, got %q", endpoint)
+		}
+	}
+
+	if settings.SecretsEndpoint != "" && !isHttpUrl(settings.SecretsEndpoint) {
+		invalid("secrets_endpoint", "must be an http or https URL, got %q", settings.SecretsEndpoint)
+	}
+
+	switch settings.RegistrationCaptcha {
+	case "":
+	case challenge.ProviderFake:
+		if settings.Env == constants.EnvProduction {
+			invalid("registration_captcha", "cannot be fake in production")
+		}
+	case challenge.ProviderTurnstile, challenge.ProviderHcaptcha, challenge.ProviderRecaptcha:
+		if settings.CaptchaSiteKey == "" {
+			invalid("captcha_site_key", "is required for the %s CAPTCHA", settings.RegistrationCaptcha)
+		}
+	default:
+		invalid("registration_captcha", "must be turnstile, hcaptcha, recaptcha, fake or empty, got %q", settings.RegistrationCaptcha)
+	}
+	domainLists := []struct {
+		name  string
+		value []string
+	}{
+		{"registration_allow_domains", settings.RegistrationAllowDomains},
+		{"registration_deny_domains", settings.RegistrationDenyDomains},
+	}
+	for _, list := range domainLists {
+		for _, domain := range list.value {
+			if strings.ContainsAny(strings.TrimPrefix(domain, "@"), "@/ ") {
+				invalid(list.name, "must be domains such as example.com, got %q", domain)
+			}
+		}
+	}
+
+	return errs
+}
+
+// RateLimits returns the rate limits and login lockout.
+func (settings *Settings) RateLimits() RateLimits {
+	return RateLimits{
+		LoginIp:          settings.RateLimitLoginIp,
+		LoginAccount:     settings.RateLimitLoginAccount,
+		RegisterIp:       settings.RateLimitRegisterIp,
+		RegisterAccount:  settings.RateLimitRegisterAccount,
+		MutationIp:       settings.RateLimitMutationIp,
+		MutationAccount:  settings.RateLimitMutationAccount,
+		LockoutThreshold: settings.LoginLockoutThreshold,
+		LockoutBase:      settings.LoginLockoutBase,
+		LockoutMax:       settings.LoginLockoutMax,
+	}
+}
+
+// Registration returns the checks on registrations.
+func (settings *Settings) Registration() Registration {
+	return Registration{
+		MinFillTime:    settings.RegistrationMinFillTime,
+		Captcha:        settings.RegistrationCaptcha,
+		CaptchaSiteKey: settings.CaptchaSiteKey,
+		EmailDomains: challenge.EmailDomains{
+			Allow: settings.RegistrationAllowDomains,
+			Deny:  settings.RegistrationDenyDomains,
+		},
+	}
+}
+
+// DatabaseConfig returns the connection pool settings.
+func (settings *Settings) DatabaseConfig() database.Config {
+	return database.Config{
+		Driver:          settings.DbDriver,
+		MaxOpenConns:    settings.DbMaxOpenConns,
+		MaxIdleConns:    settings.DbMaxIdleConns,
+		ConnMaxLifetime: settings.DbConnMaxLifetime,
+		ConnMaxIdleTime: settings.DbConnMaxIdleTime,
+	}
+}
+
+func isHttpUrl(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// HasSecretProviders reports whether secrets are read from anywhere but the settings
+// themselves, in which case they can only be checked once they have been read.
+func (settings *Settings) HasSecretProviders() bool {
+	return settings.SecretsDir != "" || settings.SecretsManagerPrefix != "" || settings.SsmPrefix != ""
+}
+
+// checkSecrets checks that the secrets the settings need are set, getting them by name.
+func (settings *Settings) checkSecrets(get func(name string) string) []error {
+	var errs []error
+	required := func(name string, format string, args ...any) {
+		if get(name) == "" {
+			errs = append(errs, fmt.Errorf("%s: %s", settings.describe(name), fmt.Sprintf(format, args...)))
+		}
+	}
+
+	required(secrets.DatabaseUrlName, "is required")
+	if settings.RateLimitBackend == sessionstore.BackendRedis && settings.SessionBackend != sessionstore.BackendRedis {
+		required(secrets.RedisUrlName, "is required for the redis rate limit backend")
+	}
+	switch settings.SessionBackend {
+	case sessionstore.BackendRedis:
+		required(secrets.RedisUrlName, "is required for the redis session backend")
+	case sessionstore.BackendCookie:
+		required(secrets.SessionSecretName, "is required for the cookie session backend")
+	}
+	switch settings.RegistrationCaptcha {
+	case challenge.ProviderTurnstile, challenge.ProviderHcaptcha, challenge.ProviderRecaptcha:
+		required(secrets.CaptchaSecretName, "is required for the %s CAPTCHA", settings.RegistrationCaptcha)
+	}
+	if settings.CognitoEndpoint == "" {
+		required(secrets.CognitoClientIdName, "is required unless cognito_endpoint is set")
+		required(secrets.CognitoUserPoolIdName, "is required unless cognito_endpoint is set")
+	}
+	return errs
+}
+
+// Print writes the settings as a YAML config file, noting where each came from. Secrets
+// are redacted.
+func (settings *Settings) Print(w io.Writer) error {
+	for _, s := range settingList {
+		value := settings.format(s)
+		if s.secret && value != "" {
+			value = "[redacted]"
+		}
+
+		source, ok := settings.sources[s.name]
+		if !ok {
+			source = "default"
+		}
+		if _, err := fmt.Fprintf(w, "%s: %s # %s\n", s.name, strconv.Quote(value), source); err != nil {
+			return err
+		}
+	}
+	return nil
+}
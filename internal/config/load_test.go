@@ -0,0 +1,153 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func load(t *testing.T, args []string, env map[string]string) (Settings, error) {
+	t.Helper()
+	flags := flag.NewFlagSet("htmxtodo", flag.ContinueOnError)
+	return Load(flags, args, func(key string) string {
+		return env[key]
+	})
+}
+
+func writeFile(t *testing.T, name string, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeFile(t, "config.toml", `
+port = 4000
+host = "file"
+database_url = "postgres://file"
+session_idle_timeout = "1h"
+db_max_open_conns = 8
+features = ["a", "b"]
+cognito_endpoint = "fake"
+`)
+	env := map[string]string{
+		"CONFIG_FILE":  path,
+		"HOST":         "env",
+		"DATABASE_URL": "postgres://env",
+	}
+
+	settings, err := load(t, []string{"-database-url", "postgres://flag", "grant-admin", "a@example.com"}, env)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := fmt.Sprint(settings.Port, settings.Host, settings.DatabaseUrl, settings.SessionIdleTimeout, settings.Features, settings.TrashRetention, settings.DbMaxOpenConns)
+	want := fmt.Sprint("4000", "env", "postgres://flag", time.Hour, []string{"a", "b"}, DefaultTrashRetention, 8)
+	if got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+
+	var out bytes.Buffer
+	if err = settings.Print(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		`port: "4000" # ` + path,
+		`host: "env" # env HOST`,
+		`database_url: "[redacted]" # flag -database-url`,
+		`env: "development" # default`,
+	} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("expected %q in:\n%s", line, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "postgres://") {
+		t.Errorf("expected secrets to be redacted:\n%s", out.String())
+	}
+}
+
+func TestLoadYaml(t *testing.T) {
+	path := writeFile(t, "config.yaml", "database_url: postgres://file\ncognito_endpoint: http://localhost:9229\nfeatures: a, b\nrate_limit_login_ip: 5/30s\nrate_limit_register_ip: off\n")
+	settings, err := load(t, []string{"-config", path}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.CognitoEndpoint != "http://localhost:9229" || fmt.Sprint(settings.Features) != "[a b]" ||
+		settings.RateLimitLoginIp.String() != "5/30s" || settings.RateLimitRegisterIp.Enabled() {
+		t.Fatalf("expected the file's settings, got %+v", settings)
+	}
+}
+
+func TestLoadReportsEveryProblem(t *testing.T) {
+	path := writeFile(t, "config.yaml", "colour: blue\nport: {number: 1}\n")
+	env := map[string]string{
+		"ENV":                 "staging",
+		"SESSION_BACKEND":     "redis",
+		"TRASH_RETENTION":     "forever",
+		"DB_DRIVER":           "mysql",
+		"DB_MAX_OPEN_CONNS":   "many",
+		"RATE_LIMIT_LOGIN_IP": "lots",
+		"PROXY_HEADER":        "X-Real-IP",
+
+		"REGISTRATION_CAPTCHA":      "turnstile",
+		"REGISTRATION_DENY_DOMAINS": "example.com, spam@example.org",
+	}
+
+	_, err := load(t, []string{"-config", path, "-session-idle-timeout", "0s"}, env)
+	if err == nil {
+		t.Fatal("expected errors")
+	}
+
+	for _, problem := range []string{
+		"unknown setting colour",
+		"port must be a string, number or list",
+		"env (env ENV): must be development, production or test",
+		"trash_retention (env TRASH_RETENTION): time: invalid duration",
+		"database_url: is required",
+		"redis_url: is required for the redis session backend",
+		"session_idle_timeout (flag -session-idle-timeout): must be positive",
+		"cognito_client_id: is required",
+		"db_driver (env DB_DRIVER): must be pq or pgx",
+		`db_max_open_conns (env DB_MAX_OPEN_CONNS): must be a whole number, got "many"`,
+		`rate_limit_login_ip (env RATE_LIMIT_LOGIN_IP): must be max/period, such as 10/1m, or off, got "lots"`,
+		"trusted_proxies: is required with proxy_header",
+		"captcha_site_key: is required for the turnstile CAPTCHA",
+		"captcha_secret: is required for the turnstile CAPTCHA",
+		`registration_deny_domains (env REGISTRATION_DENY_DOMAINS): must be domains such as example.com, got "spam@example.org"`,
+	} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("expected %q in:\n%v", problem, err)
+		}
+	}
+}
+
+func TestLoadTrustedProxies(t *testing.T) {
+	env := map[string]string{
+		"DATABASE_URL":     "postgres://env",
+		"COGNITO_ENDPOINT": "fake",
+		"PROXY_HEADER":     "X-Real-IP",
+		"TRUSTED_PROXIES":  "10.0.0.1, 172.16.0.0/12, proxy",
+	}
+
+	_, err := load(t, nil, env)
+	if err == nil || !strings.Contains(err.Error(), `trusted_proxies (env TRUSTED_PROXIES): must be IPs or CIDR ranges such as 10.0.0.0/8, got "proxy"`) {
+		t.Fatalf("expected the proxy name to be rejected, got %v", err)
+	}
+
+	env["TRUSTED_PROXIES"] = "10.0.0.1, 172.16.0.0/12"
+	settings, err := load(t, nil, env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.ProxyHeader != "X-Real-IP" || len(settings.TrustedProxies) != 2 {
+		t.Fatalf("expected the proxy settings, got %q and %q", settings.ProxyHeader, settings.TrustedProxies)
+	}
+}
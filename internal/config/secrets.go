@@ -0,0 +1,75 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"htmxtodo/internal/secrets"
+)
+
+// localRegion is the region used with a custom endpoint, which only needs one to be set.
+const localRegion = "us-east-1"
+
+// AWSConfig loads the config for clients of the AWS API at endpoint, or of AWS itself if
+// it is empty. Requests to a custom endpoint are not signed, so need no AWS credentials.
+func AWSConfig(ctx context.Context, endpoint string) (aws.Config, error) {
+	if endpoint == "" {
+		return awsconfig.LoadDefaultConfig(ctx)
+	}
+
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx,
+		awsconfig.WithRegion(localRegion),
+		awsconfig.WithCredentialsProvider(aws.AnonymousCredentials{}))
+	if err != nil {
+		return aws.Config{}, err
+	}
+	awsCfg.BaseEndpoint = aws.String(endpoint)
+	return awsCfg, nil
+}
+
+// NewSecrets reads the secrets from the settings, then from the secret files, Secrets
+// Manager and Parameter Store if they are configured, and checks that the secrets the
+// settings need are set.
+func NewSecrets(ctx context.Context, settings Settings) (*secrets.Store, error) {
+	providers := []secrets.Provider{secrets.Static(settings.secretValues())}
+	if settings.SecretsDir != "" {
+		providers = append(providers, secrets.Dir(settings.SecretsDir))
+	}
+
+	if settings.SecretsManagerPrefix != "" || settings.SsmPrefix != "" {
+		awsCfg, err := AWSConfig(ctx, settings.SecretsEndpoint)
+		if err != nil {
+			return nil, err
+		}
+		if settings.SecretsManagerPrefix != "" {
+			providers = append(providers, secrets.SecretsManager(secretsmanager.NewFromConfig(awsCfg), settings.SecretsManagerPrefix))
+		}
+		if settings.SsmPrefix != "" {
+			providers = append(providers, secrets.ParameterStore(ssm.NewFromConfig(awsCfg), settings.SsmPrefix))
+		}
+	}
+
+	store, err := secrets.Load(ctx, providers...)
+	if err != nil {
+		return nil, err
+	}
+	if errs := settings.checkSecrets(store.Get); len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return store, nil
+}
+
+func (settings *Settings) secretValues() secrets.Values {
+	return secrets.Values{
+		DatabaseUrl:        settings.DatabaseUrl,
+		DatabaseReplicaUrl: settings.DatabaseReplicaUrl,
+		CognitoClientId:    settings.CognitoClientId,
+		CognitoUserPoolId:  settings.CognitoUserPoolId,
+		RedisUrl:           settings.RedisUrl,
+		SessionSecret:      settings.SessionSecret,
+		CaptchaSecret:      settings.CaptchaSecret,
+	}
+}
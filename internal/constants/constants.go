@@ -1,10 +1,23 @@
 package constants
 
 const (
-	EnvDevelopment      = "development"
-	EnvProduction       = "production"
-	EnvTest             = "test"
-	CsrfInputName       = "_csrf"
-	CsrfTokenContextKey = "csrf.token"
-	LoggedInSessionKey  = "auth.logged_in"
+	EnvDevelopment         = "development"
+	EnvProduction          = "production"
+	EnvTest                = "test"
+	CsrfInputName          = "_csrf"
+	CsrfTokenContextKey    = "csrf.token"
+	LoggedInSessionKey     = "auth.logged_in"
+	UserIdSessionKey       = "auth.user_id"
+	CurrentUserContextKey  = "auth.current_user"
+	ImpersonatorSessionKey = "auth.impersonator_id"
+	ImpersonatorContextKey = "auth.impersonator"
+	RequestIdContextKey    = "requestid"
+	SessionCookieName      = "htmxtodo_session_id"
+	SessionCreatedAtKey    = "session.created_at"
+	SessionLastSeenKey     = "session.last_seen"
+	SessionRememberMeKey   = "session.remember_me"
+	SessionLastWriteKey    = "session.last_write"
+	WorkspaceIdSessionKey  = "workspace.id"
+	WorkspaceContextKey    = "workspace.active"
+	WorkspacesContextKey   = "workspace.all"
 )
@@ -0,0 +1,97 @@
+// Package database opens the app's Postgres connection pool.
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/lib/pq"
+	"htmxtodo/internal/secrets"
+	"time"
+)
+
+// The database/sql drivers the pool can use.
+const (
+	DriverPq  = "pq"
+	DriverPgx = "pgx"
+)
+
+// Config sizes the pool. Zero values are database/sql's defaults: no limit on open
+// connections or on how long they are kept, and two idle connections.
+type Config struct {
+	Driver          string
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// Open returns a pool that connects with the URL in the named secret, such as
+// secrets.DatabaseUrlName, as it is at the time, so that rotated credentials are picked up
+// without a restart. Idle connections made with old credentials are closed when the URL
+// changes.
+func Open(s secrets.Secrets, name string, cfg Config) (*sql.DB, error) {
+	c := connector{secrets: s, name: name}
+	switch cfg.Driver {
+	case DriverPq, "":
+		c.connect = connectPq
+		c.driver = &pq.Driver{}
+	case DriverPgx:
+		c.connect = connectPgx
+		c.driver = stdlib.GetDefaultDriver()
+	default:
+		return nil, fmt.Errorf("unknown database driver %q", cfg.Driver)
+	}
+
+	maxIdleConns := cfg.MaxIdleConns
+	if maxIdleConns == 0 {
+		// database/sql's default, which SetMaxIdleConns(0) would not restore
+		maxIdleConns = 2
+	}
+
+	db := sql.OpenDB(c)
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(maxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	s.OnChange(name, func() {
+		db.SetMaxIdleConns(0)
+		db.SetMaxIdleConns(maxIdleConns)
+	})
+	return db, nil
+}
+
+type connector struct {
+	secrets secrets.Secrets
+	name    string
+	connect func(ctx context.Context, url string) (driver.Conn, error)
+	driver  driver.Driver
+}
+
+func (c connector) Connect(ctx context.Context) (driver.Conn, error) {
+	return c.connect(ctx, c.secrets.Get(c.name))
+}
+
+func (c connector) Driver() driver.Driver {
+	return c.driver
+}
+
+func connectPq(ctx context.Context, url string) (driver.Conn, error) {
+	pqConnector, err := pq.NewConnector(url)
+	if err != nil {
+		return nil, err
+	}
+	return pqConnector.Connect(ctx)
+}
+
+func connectPgx(ctx context.Context, url string) (driver.Conn, error) {
+	connConfig, err := pgx.ParseConfig(url)
+	if err != nil {
+		return nil, err
+	}
+	return stdlib.GetConnector(*connConfig).Connect(ctx)
+}
@@ -0,0 +1,347 @@
+// Package fakecognito is an in-process fake of the parts of the Cognito identity provider
+// API the app uses, so that tests and local development need no AWS account. The AWS SDK
+// talks to it over HTTP, as it would to Cognito, once its endpoint is pointed at the fake.
+package fakecognito
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	cognito "github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// targetPrefix starts the X-Amz-Target header that names the operation of a request.
+const targetPrefix = "AWSCognitoIdentityProviderService."
+
+// MinPasswordLength is the shortest password the fake accepts, like Cognito's default policy.
+const MinPasswordLength = 8
+
+// Server fakes a single user pool, keyed by username. Client IDs and pool IDs are
+// accepted without being checked.
+type Server struct {
+	// OnCode, if set, is called with every code the server "sends", such as to log it
+	// during local development.
+	OnCode func(username string, code string)
+	// AutoConfirm confirms users as they sign up, as there is nowhere to enter the code
+	// during local development.
+	AutoConfirm bool
+
+	mu    sync.Mutex
+	users map[string]*user
+	codes map[string][]string
+}
+
+type user struct {
+	sub      string
+	password string
+	status   types.UserStatusType
+	// code is the confirmation or password reset code last sent to the user.
+	code string
+}
+
+func New() *Server {
+	return &Server{
+		users: map[string]*user{},
+		codes: map[string][]string{},
+	}
+}
+
+// AddUser adds a confirmed user, as if they had signed up and confirmed their email. An
+// existing user's password is replaced.
+func (s *Server) AddUser(username string, password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users[username] = &user{sub: newToken(), password: password, status: types.UserStatusTypeConfirmed}
+}
+
+// RequireNewPassword makes a user's next login return the NEW_PASSWORD_REQUIRED challenge,
+// as for users an admin created with a temporary password.
+func (s *Server) RequireNewPassword(username string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u, ok := s.users[username]; ok {
+		u.status = types.UserStatusTypeForceChangePassword
+	}
+}
+
+// Codes returns every code sent to a user, oldest first.
+func (s *Server) Codes(username string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.codes[username]...)
+}
+
+// LastCode returns the code most recently sent to a user, or "" if none was.
+func (s *Server) LastCode(username string) string {
+	codes := s.Codes(username)
+	if len(codes) == 0 {
+		return ""
+	}
+	return codes[len(codes)-1]
+}
+
+// ServeHTTP handles a request in the JSON protocol the SDK uses for Cognito.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	operation, ok := strings.CutPrefix(r.Header.Get("X-Amz-Target"), targetPrefix)
+	if r.Method != http.MethodPost || !ok {
+		writeError(w, "UnknownOperationException", "expected a POST with an X-Amz-Target header")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var output any
+	var err error
+	switch operation {
+	case "SignUp":
+		output, err = handle(r, s.signUp)
+	case "ConfirmSignUp":
+		output, err = handle(r, s.confirmSignUp)
+	case "ResendConfirmationCode":
+		output, err = handle(r, s.resendConfirmationCode)
+	case "InitiateAuth":
+		output, err = handle(r, s.initiateAuth)
+	case "ForgotPassword":
+		output, err = handle(r, s.forgotPassword)
+	case "ConfirmForgotPassword":
+		output, err = handle(r, s.confirmForgotPassword)
+	case "AdminResetUserPassword":
+		output, err = handle(r, s.adminResetUserPassword)
+	default:
+		writeError(w, "UnknownOperationException", "unsupported operation "+operation)
+		return
+	}
+
+	if err != nil {
+		e := err.(*apiError)
+		writeError(w, e.code, e.message)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	_ = json.NewEncoder(w).Encode(output)
+}
+
+// handle decodes a request into the SDK's input type for the operation. Field names match
+// the wire format, so encoding/json needs no tags.
+func handle[I any](r *http.Request, operation func(*I) (any, error)) (any, error) {
+	input := new(I)
+	if err := json.NewDecoder(r.Body).Decode(input); err != nil {
+		return nil, &apiError{"SerializationException", err.Error()}
+	}
+	return operation(input)
+}
+
+type apiError struct {
+	code    string
+	message string
+}
+
+func (e *apiError) Error() string {
+	return e.code + ": " + e.message
+}
+
+func writeError(w http.ResponseWriter, code string, message string) {
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	w.Header().Set("X-Amzn-ErrorType", code)
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(map[string]string{"__type": code, "message": message})
+}
+
+func (s *Server) signUp(input *cognito.SignUpInput) (any, error) {
+	username := value(input.Username)
+	if username == "" {
+		return nil, &apiError{"InvalidParameterException", "Username is required"}
+	}
+	if _, ok := s.users[username]; ok {
+		return nil, &apiError{"UsernameExistsException", "User already exists"}
+	}
+	if err := checkPassword(value(input.Password)); err != nil {
+		return nil, err
+	}
+
+	u := &user{sub: newToken(), password: value(input.Password), status: types.UserStatusTypeUnconfirmed}
+	s.users[username] = u
+	if s.AutoConfirm {
+		u.status = types.UserStatusTypeConfirmed
+		return map[string]any{"UserConfirmed": true, "UserSub": u.sub}, nil
+	}
+
+	return map[string]any{
+		"UserConfirmed":       false,
+		"UserSub":             u.sub,
+		"CodeDeliveryDetails": s.sendCode(username, u),
+	}, nil
+}
+
+func (s *Server) confirmSignUp(input *cognito.ConfirmSignUpInput) (any, error) {
+	u, err := s.user(input.Username)
+	if err != nil {
+		return nil, err
+	}
+	if u.status != types.UserStatusTypeUnconfirmed {
+		return nil, &apiError{"NotAuthorizedException", "User cannot be confirmed. Current status is " + string(u.status)}
+	}
+	if err = checkCode(u, input.ConfirmationCode); err != nil {
+		return nil, err
+	}
+
+	u.status = types.UserStatusTypeConfirmed
+	return map[string]any{}, nil
+}
+
+func (s *Server) resendConfirmationCode(input *cognito.ResendConfirmationCodeInput) (any, error) {
+	u, err := s.user(input.Username)
+	if err != nil {
+		return nil, err
+	}
+	if u.status != types.UserStatusTypeUnconfirmed {
+		return nil, &apiError{"InvalidParameterException", "User is already confirmed."}
+	}
+
+	return map[string]any{"CodeDeliveryDetails": s.sendCode(*input.Username, u)}, nil
+}
+
+func (s *Server) initiateAuth(input *cognito.InitiateAuthInput) (any, error) {
+	if input.AuthFlow != types.AuthFlowTypeUserPasswordAuth {
+		return nil, &apiError{"InvalidParameterException", "unsupported auth flow " + string(input.AuthFlow)}
+	}
+
+	username := input.AuthParameters["USERNAME"]
+	u, err := s.user(&username)
+	if err != nil {
+		return nil, err
+	}
+	if u.status == types.UserStatusTypeResetRequired {
+		return nil, &apiError{"PasswordResetRequiredException", "Password reset required for the user"}
+	}
+	if u.password != input.AuthParameters["PASSWORD"] {
+		return nil, &apiError{"NotAuthorizedException", "Incorrect username or password."}
+	}
+
+	switch u.status {
+	case types.UserStatusTypeUnconfirmed:
+		return nil, &apiError{"UserNotConfirmedException", "User is not confirmed."}
+	case types.UserStatusTypeForceChangePassword:
+		return map[string]any{
+			"ChallengeName":       types.ChallengeNameTypeNewPasswordRequired,
+			"ChallengeParameters": map[string]string{"USER_ID_FOR_SRP": username},
+			"Session":             newToken(),
+		}, nil
+	}
+
+	return map[string]any{
+		"AuthenticationResult": map[string]any{
+			"AccessToken":  newToken(),
+			"IdToken":      newToken(),
+			"RefreshToken": newToken(),
+			"ExpiresIn":    3600,
+			"TokenType":    "Bearer",
+		},
+		"ChallengeParameters": map[string]string{},
+	}, nil
+}
+
+func (s *Server) forgotPassword(input *cognito.ForgotPasswordInput) (any, error) {
+	u, err := s.user(input.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]any{"CodeDeliveryDetails": s.sendCode(*input.Username, u)}, nil
+}
+
+func (s *Server) confirmForgotPassword(input *cognito.ConfirmForgotPasswordInput) (any, error) {
+	u, err := s.user(input.Username)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkPassword(value(input.Password)); err != nil {
+		return nil, err
+	}
+	if err = checkCode(u, input.ConfirmationCode); err != nil {
+		return nil, err
+	}
+
+	u.password = *input.Password
+	u.status = types.UserStatusTypeConfirmed
+	return map[string]any{}, nil
+}
+
+func (s *Server) adminResetUserPassword(input *cognito.AdminResetUserPasswordInput) (any, error) {
+	u, err := s.user(input.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	u.status = types.UserStatusTypeResetRequired
+	s.sendCode(*input.Username, u)
+	return map[string]any{}, nil
+}
+
+func (s *Server) user(username *string) (*user, error) {
+	u, ok := s.users[value(username)]
+	if !ok {
+		return nil, &apiError{"UserNotFoundException", "User does not exist."}
+	}
+	return u, nil
+}
+
+// sendCode records a new code for the user, and returns how it was "delivered".
+func (s *Server) sendCode(username string, u *user) map[string]any {
+	b := make([]byte, 3)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	u.code = fmt.Sprintf("%06d", (int(b[0])<<16|int(b[1])<<8|int(b[2]))%1000000)
+	s.codes[username] = append(s.codes[username], u.code)
+
+	if s.OnCode != nil {
+		s.OnCode(username, u.code)
+	}
+
+	return map[string]any{
+		"AttributeName":  "email",
+		"DeliveryMedium": types.DeliveryMediumTypeEmail,
+		"Destination":    username,
+	}
+}
+
+func checkCode(u *user, code *string) error {
+	if u.code == "" || value(code) != u.code {
+		return &apiError{"CodeMismatchException", "Invalid verification code provided, please try again."}
+	}
+	u.code = ""
+	return nil
+}
+
+func checkPassword(password string) error {
+	if len(password) < MinPasswordLength {
+		return &apiError{"InvalidPasswordException", "Password did not conform with policy: Password not long enough"}
+	}
+	return nil
+}
+
+func value(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func newToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,87 @@
+package fakecognito
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cognito "github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+	"net/http/httptest"
+	"testing"
+)
+
+func newClient(t *testing.T, fake *Server) *cognito.Client {
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	return cognito.New(cognito.Options{
+		Region:       "us-east-1",
+		Credentials:  aws.AnonymousCredentials{},
+		BaseEndpoint: aws.String(server.URL),
+	})
+}
+
+func TestForgotPassword(t *testing.T) {
+	fake := New()
+	client := newClient(t, fake)
+	ctx := context.Background()
+
+	login := func(password string) error {
+		_, err := client.InitiateAuth(ctx, &cognito.InitiateAuthInput{
+			AuthFlow:       types.AuthFlowTypeUserPasswordAuth,
+			ClientId:       aws.String("client"),
+			AuthParameters: map[string]string{"USERNAME": "user@example.com", "PASSWORD": password},
+		})
+		return err
+	}
+
+	forgot := &cognito.ForgotPasswordInput{ClientId: aws.String("client"), Username: aws.String("user@example.com")}
+	var notFound *types.UserNotFoundException
+	_, err := client.ForgotPassword(ctx, forgot)
+	if !errors.As(err, &notFound) {
+		t.Fatalf("expected UserNotFoundException, got %v", err)
+	}
+
+	fake.AddUser("user@example.com", "old password")
+	if _, err = client.ForgotPassword(ctx, forgot); err != nil {
+		t.Fatal(err)
+	}
+
+	confirm := func(code string, password string) error {
+		_, err := client.ConfirmForgotPassword(ctx, &cognito.ConfirmForgotPasswordInput{
+			ClientId:         aws.String("client"),
+			Username:         aws.String("user@example.com"),
+			ConfirmationCode: aws.String(code),
+			Password:         aws.String(password),
+		})
+		return err
+	}
+
+	var mismatch *types.CodeMismatchException
+	if err = confirm("nope", "new password"); !errors.As(err, &mismatch) {
+		t.Fatalf("expected CodeMismatchException, got %v", err)
+	}
+	var invalid *types.InvalidPasswordException
+	if err = confirm(fake.LastCode("user@example.com"), "short"); !errors.As(err, &invalid) {
+		t.Fatalf("expected InvalidPasswordException, got %v", err)
+	}
+	if err = confirm(fake.LastCode("user@example.com"), "new password"); err != nil {
+		t.Fatal(err)
+	}
+
+	// codes work once
+	if err = confirm(fake.LastCode("user@example.com"), "newer password"); !errors.As(err, &mismatch) {
+		t.Fatalf("expected CodeMismatchException, got %v", err)
+	}
+
+	var notAuthorized *types.NotAuthorizedException
+	if err = login("old password"); !errors.As(err, &notAuthorized) {
+		t.Fatalf("expected NotAuthorizedException, got %v", err)
+	}
+	if err = login("new password"); err != nil {
+		t.Fatal(err)
+	}
+	if codes := fake.Codes("user@example.com"); len(codes) != 1 {
+		t.Fatalf("expected one code to be sent, got %v", codes)
+	}
+}
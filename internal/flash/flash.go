@@ -0,0 +1,166 @@
+package flash
+
+import (
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/session"
+	"strings"
+)
+
+// sessionKey holds the queued messages as a []Message.
+const sessionKey = "flash.messages"
+
+// TriggerEvent is the event name used to deliver messages to htmx requests via HX-Trigger.
+const TriggerEvent = "flash"
+
+type Level string
+
+const (
+	Success Level = "success"
+	Info    Level = "info"
+	Warning Level = "warning"
+	Error   Level = "error"
+)
+
+// Message is a notification for the user. If Undo is set, the notification offers to undo
+// what it reports by POSTing to that URL.
+type Message struct {
+	Level Level  `json:"level"`
+	Text  string `json:"text"`
+	Undo  string `json:"undo,omitempty"`
+}
+
+// CssClass returns the Bulma notification modifier for the level.
+func (m Message) CssClass() string {
+	if m.Level == Error {
+		return "is-danger"
+	}
+	return "is-" + string(m.Level)
+}
+
+// Add queues a message in the session, to be shown on the next page or htmx response that
+// is not a redirect. The session store must have []Message registered.
+func Add(c *fiber.Ctx, store *session.Store, level Level, text string) error {
+	return add(c, store, Message{Level: level, Text: text})
+}
+
+// AddUndo queues a message with an Undo button, which POSTs to undoUrl.
+func AddUndo(c *fiber.Ctx, store *session.Store, level Level, text string, undoUrl string) error {
+	return add(c, store, Message{Level: level, Text: text, Undo: undoUrl})
+}
+
+// Now delivers a message with this htmx response instead of queueing it, for responses
+// that are not swapped in, such as a request that was refused before it did anything.
+func Now(c *fiber.Ctx, level Level, text string) error {
+	return addTrigger(c, TriggerEvent, fiber.Map{"messages": []Message{{Level: level, Text: text}}})
+}
+
+// Queue adds a message to a session the caller already has, and will save, such as one
+// that has just been reset and so has a new ID.
+func Queue(sess *session.Session, level Level, text string) {
+	queue(sess, Message{Level: level, Text: text})
+}
+
+func add(c *fiber.Ctx, store *session.Store, message Message) error {
+	sess, err := store.Get(c)
+	if err != nil {
+		return err
+	}
+
+	queue(sess, message)
+	return sess.Save()
+}
+
+func queue(sess *session.Session, message Message) {
+	messages, _ := sess.Get(sessionKey).([]Message)
+	sess.Set(sessionKey, append(messages, message))
+}
+
+// Pop removes and returns the queued messages, so that each is shown exactly once.
+func Pop(c *fiber.Ctx, store *session.Store) ([]Message, error) {
+	sess, err := store.Get(c)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, _ := sess.Get(sessionKey).([]Message)
+	if len(messages) == 0 {
+		return nil, nil
+	}
+
+	sess.Delete(sessionKey)
+	if err = sess.Save(); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// IsFullPage reports whether the response to this request is a whole page, which shows
+// messages itself, rather than a fragment for htmx to swap in.
+func IsFullPage(c *fiber.Ctx) bool {
+	return c.Get("HX-Request") != "true"
+}
+
+// Middleware delivers messages queued during an htmx request as an HX-Trigger event, which
+// static/application.js turns into notifications. Full pages render messages in the layout,
+// and redirects leave them queued for the page being redirected to.
+func Middleware(store *session.Store) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := c.Next(); err != nil {
+			return err
+		}
+
+		if IsFullPage(c) || isRedirect(c) {
+			return nil
+		}
+
+		messages, err := Pop(c, store)
+		if err != nil || len(messages) == 0 {
+			return err
+		}
+
+		return addTrigger(c, TriggerEvent, fiber.Map{"messages": messages})
+	}
+}
+
+func isRedirect(c *fiber.Ctx) bool {
+	status := c.Response().StatusCode()
+	if status >= 300 && status < 400 {
+		return true
+	}
+
+	for _, header := range []string{"HX-Location", "HX-Redirect", "HX-Refresh"} {
+		if len(c.Response().Header.Peek(header)) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// addTrigger adds an event to the HX-Trigger response header, keeping any already set.
+func addTrigger(c *fiber.Ctx, event string, detail any) error {
+	triggers := make(map[string]any)
+
+	if existing := string(c.Response().Header.Peek("HX-Trigger")); existing != "" {
+		if strings.HasPrefix(existing, "{") {
+			if err := json.Unmarshal([]byte(existing), &triggers); err != nil {
+				return err
+			}
+		} else {
+			for _, name := range strings.Split(existing, ",") {
+				triggers[strings.TrimSpace(name)] = nil
+			}
+		}
+	}
+	triggers[event] = detail
+
+	value, err := json.Marshal(triggers)
+	if err != nil {
+		return err
+	}
+	c.Set("HX-Trigger", string(value))
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package flash
+
+import (
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/session"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMessagesAreDeliveredOnce(t *testing.T) {
+	store := session.New()
+	store.RegisterType([]Message{})
+
+	app := fiber.New()
+	app.Use(Middleware(store))
+	app.Post("/redirect", func(c *fiber.Ctx) error {
+		if err := Add(c, store, Success, "Saved."); err != nil {
+			return err
+		}
+		return c.Redirect("/", fiber.StatusFound)
+	})
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+
+	resp, err := app.Test(httptest.NewRequest("POST", "/redirect", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trigger := resp.Header.Get("HX-Trigger"); trigger != "" {
+		t.Fatal("expected messages to be kept for after the redirect, got ", trigger)
+	}
+	cookies := resp.Cookies()
+
+	htmxGet := func() *http.Response {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("HX-Request", "true")
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	var trigger map[string]struct {
+		Messages []Message `json:"messages"`
+	}
+	if err = json.Unmarshal([]byte(htmxGet().Header.Get("HX-Trigger")), &trigger); err != nil {
+		t.Fatal(err)
+	}
+	messages := trigger[TriggerEvent].Messages
+	if len(messages) != 1 || messages[0] != (Message{Level: Success, Text: "Saved."}) {
+		t.Fatalf("unexpected messages: %+v", messages)
+	}
+
+	if trigger := htmxGet().Header.Get("HX-Trigger"); trigger != "" {
+		t.Fatal("expected messages to be consumed, got ", trigger)
+	}
+}
+
+func TestAddTriggerKeepsExistingEvents(t *testing.T) {
+	app := fiber.New()
+	app.Get("/", func(c *fiber.Ctx) error {
+		c.Set("HX-Trigger", "listsChanged")
+		return addTrigger(c, TriggerEvent, fiber.Map{"messages": []Message{}})
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"flash":{"messages":[]},"listsChanged":null}`
+	if trigger := resp.Header.Get("HX-Trigger"); trigger != expected {
+		t.Fatalf("expected %s, got %s", expected, trigger)
+	}
+}
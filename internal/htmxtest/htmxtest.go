@@ -0,0 +1,297 @@
+// Package htmxtest parses the HTML of handler responses, so that tests can make assertions
+// about elements, forms, htmx attributes and HX headers rather than match strings.
+package htmxtest
+
+import (
+	"bytes"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"htmxtodo/internal/constants"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// Page is a parsed response. Its Element is the whole document, so the element assertions
+// search all of it.
+type Page struct {
+	Element
+	Response *http.Response
+}
+
+// Parse reads and parses a response, such as one from fiber's App.Test.
+func Parse(t testing.TB, resp *http.Response) *Page {
+	t.Helper()
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(t, resp, string(b))
+}
+
+// New parses a response whose body was already read. Bodies can be whole documents or
+// the fragments htmx swaps in, including table rows.
+func New(t testing.TB, resp *http.Response, body string) *Page {
+	t.Helper()
+
+	root, err := parse(body)
+	if err != nil {
+		t.Fatalf("invalid HTML: %v\n%s", err, body)
+	}
+	return &Page{Element: Element{t: t, Node: root}, Response: resp}
+}
+
+func parse(body string) (*html.Node, error) {
+	trimmed := strings.ToLower(strings.TrimSpace(body))
+	if strings.HasPrefix(trimmed, "<!doctype") || strings.HasPrefix(trimmed, "<html") {
+		return html.Parse(strings.NewReader(body))
+	}
+
+	// fragments are parsed where they would be swapped in, as table parts are dropped
+	// anywhere but in a table
+	context := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	switch {
+	case strings.HasPrefix(trimmed, "<tr"):
+		context = &html.Node{Type: html.ElementNode, Data: "tbody", DataAtom: atom.Tbody}
+	case strings.HasPrefix(trimmed, "<td"), strings.HasPrefix(trimmed, "<th"):
+		context = &html.Node{Type: html.ElementNode, Data: "tr", DataAtom: atom.Tr}
+	}
+
+	nodes, err := html.ParseFragment(strings.NewReader(body), context)
+	if err != nil {
+		return nil, err
+	}
+	root := &html.Node{Type: html.DocumentNode}
+	for _, n := range nodes {
+		root.AppendChild(n)
+	}
+	return root, nil
+}
+
+// ExpectHeader fails the test unless the response has the header with the value.
+func (p *Page) ExpectHeader(name string, value string) {
+	p.t.Helper()
+	if got := p.Response.Header.Get(name); got != value {
+		p.t.Fatalf("expected header %s to be %q, got %q", name, value, got)
+	}
+}
+
+// ExpectLocation fails the test unless the response tells htmx to navigate to path.
+func (p *Page) ExpectLocation(path string) {
+	p.t.Helper()
+	p.ExpectHeader("HX-Location", path)
+}
+
+// ExpectOOB fails the test unless the response swaps the element with the id out of band,
+// and returns the element.
+func (p *Page) ExpectOOB(id string) Element {
+	p.t.Helper()
+	e := p.Expect("#" + id)
+	if !e.HasAttr("hx-swap-oob") {
+		p.t.Fatalf("expected #%s to be swapped out of band, got %s", id, e)
+	}
+	return e
+}
+
+// CSRFToken returns the CSRF token in the page's forms, to send with later requests.
+func (p *Page) CSRFToken() string {
+	p.t.Helper()
+	token := p.Expect(`input[name="` + constants.CsrfInputName + `"]`).Attr("value")
+	if token == "" {
+		p.t.Fatalf("the page's CSRF token is empty")
+	}
+	return token
+}
+
+// Element is an element of a page, with assertions about it and the elements in it.
+type Element struct {
+	t    testing.TB
+	Node *html.Node
+}
+
+// Find returns the elements in e that match a CSS selector.
+func (e Element) Find(selector string) []Element {
+	e.t.Helper()
+	sel, err := parseSelector(selector)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+
+	var found []Element
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			if sel.matches(child) {
+				found = append(found, Element{t: e.t, Node: child})
+			}
+			walk(child)
+		}
+	}
+	walk(e.Node)
+	return found
+}
+
+// Expect fails the test unless an element in e matches the selector, and returns the first.
+func (e Element) Expect(selector string) Element {
+	e.t.Helper()
+	found := e.Find(selector)
+	if len(found) == 0 {
+		e.t.Fatalf("expected an element matching %q in %s", selector, e)
+	}
+	return found[0]
+}
+
+// ExpectCount fails the test unless n elements in e match the selector.
+func (e Element) ExpectCount(selector string, n int) []Element {
+	e.t.Helper()
+	found := e.Find(selector)
+	if len(found) != n {
+		e.t.Fatalf("expected %d elements matching %q, got %d in %s", n, selector, len(found), e)
+	}
+	return found
+}
+
+// ExpectNone fails the test if any element in e matches the selector.
+func (e Element) ExpectNone(selector string) {
+	e.t.Helper()
+	e.ExpectCount(selector, 0)
+}
+
+// ExpectText fails the test unless the text of the first element matching the selector
+// contains text.
+func (e Element) ExpectText(selector string, text string) {
+	e.t.Helper()
+	if found := e.Expect(selector); !strings.Contains(found.Text(), text) {
+		e.t.Fatalf("expected %q to contain %q, got %q", selector, text, found.Text())
+	}
+}
+
+// ExpectAttr fails the test unless the first element matching the selector has the
+// attribute with the value.
+func (e Element) ExpectAttr(selector string, name string, value string) {
+	e.t.Helper()
+	found := e.Expect(selector)
+	if got, ok := lookupAttr(found.Node, name); !ok || got != value {
+		e.t.Fatalf("expected %s to be %q on %s", name, value, found)
+	}
+}
+
+// ExpectHx is ExpectAttr for an htmx attribute, named without its hx- prefix.
+func (e Element) ExpectHx(selector string, name string, value string) {
+	e.t.Helper()
+	e.ExpectAttr(selector, "hx-"+name, value)
+}
+
+// ExpectField fails the test unless the form field with the name has the value.
+func (e Element) ExpectField(name string, value string) {
+	e.t.Helper()
+	values, ok := e.Values()[name]
+	if !ok {
+		e.t.Fatalf("expected a field named %s in %s", name, e)
+	}
+	if len(values) != 1 || values[0] != value {
+		e.t.Fatalf("expected field %s to be %q, got %q", name, value, values)
+	}
+}
+
+// Values returns the values the form fields in e would submit, which include the CSRF
+// token. Unchecked boxes and disabled fields are left out, like browsers do.
+func (e Element) Values() url.Values {
+	e.t.Helper()
+	values := url.Values{}
+
+	for _, field := range e.Find("[name]") {
+		n := field.Node
+		name := attr(n, "name")
+		if field.HasAttr("disabled") {
+			continue
+		}
+
+		switch n.Data {
+		case "input":
+			switch strings.ToLower(attr(n, "type")) {
+			case "checkbox", "radio":
+				if field.HasAttr("checked") {
+					values.Add(name, valueOr(n, "on"))
+				}
+			case "submit", "button", "reset", "image", "file":
+			default:
+				values.Add(name, attr(n, "value"))
+			}
+		case "textarea":
+			values.Add(name, field.Text())
+		case "select":
+			options := field.Find("option")
+			selected := field.Find("option[selected]")
+			if len(selected) == 0 && len(options) > 0 && !field.HasAttr("multiple") {
+				selected = options[:1]
+			}
+			for _, option := range selected {
+				values.Add(name, valueOr(option.Node, option.Text()))
+			}
+		}
+	}
+	return values
+}
+
+// Attr returns the value of an attribute, or "" if e does not have it.
+func (e Element) Attr(name string) string {
+	return attr(e.Node, name)
+}
+
+// HasAttr reports whether e has an attribute, such as a boolean one like checked.
+func (e Element) HasAttr(name string) bool {
+	_, ok := lookupAttr(e.Node, name)
+	return ok
+}
+
+// Text returns the text in e, with runs of whitespace collapsed.
+func (e Element) Text() string {
+	var b strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(e.Node)
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// String renders e as HTML, for failure messages.
+func (e Element) String() string {
+	var b bytes.Buffer
+	if err := html.Render(&b, e.Node); err != nil {
+		return err.Error()
+	}
+	return b.String()
+}
+
+func attr(n *html.Node, name string) string {
+	value, _ := lookupAttr(n, name)
+	return value
+}
+
+func lookupAttr(n *html.Node, name string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Namespace == "" && a.Key == name {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+// valueOr returns the value attribute, or def if there is none.
+func valueOr(n *html.Node, def string) string {
+	if value, ok := lookupAttr(n, "value"); ok {
+		return value
+	}
+	return def
+}
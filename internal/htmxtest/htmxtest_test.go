@@ -0,0 +1,77 @@
+package htmxtest
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+const testPage = `<!DOCTYPE html>
+<html><body>
+<div id="lists" class="tiles">
+	<div class="tile list-card" id="list-1" hx-target="this"><p>Groceries</p></div>
+	<div class="tile list-card" id="list-2"><section><p>Chores</p></section></div>
+</div>
+<form id="create-list-form" hx-post="/app/lists" hx-swap-oob="true">
+	<input type="hidden" name="_csrf" value="token">
+	<input name="name" value="Draft">
+	<input type="checkbox" name="tag_id" value="1" checked>
+	<input type="checkbox" name="tag_id" value="2">
+	<input name="disabled" value="x" disabled>
+	<select name="sort"><option value="name">Name</option><option value="custom" selected>Custom</option></select>
+	<textarea name="notes">  some
+	notes </textarea>
+	<button name="submit">Submit</button>
+</form>
+</body></html>`
+
+func TestSelectors(t *testing.T) {
+	page := New(t, &http.Response{}, testPage)
+
+	counts := map[string]int{
+		"div":                         3,
+		"#list-1":                     1,
+		".list-card":                  2,
+		"div.tile.list-card":          2,
+		".list-card.missing":          0,
+		"[hx-target]":                 1,
+		`[hx-target="this"]`:          1,
+		"[hx-target=that]":            0,
+		"#lists p":                    2,
+		"#lists > .list-card > p":     1,
+		"#lists>.list-card>section p": 1,
+		"form *":                      10,
+	}
+	for selector, count := range counts {
+		page.ExpectCount(selector, count)
+	}
+
+	for _, invalid := range []string{"", "> p", "p >", "[name", "p,q", "#"} {
+		if _, err := parseSelector(invalid); err == nil {
+			t.Errorf("expected %q to be invalid", invalid)
+		}
+	}
+}
+
+func TestForms(t *testing.T) {
+	page := New(t, &http.Response{}, testPage)
+
+	if token := page.CSRFToken(); token != "token" {
+		t.Fatalf("expected the CSRF token, got %q", token)
+	}
+	form := page.ExpectOOB("create-list-form")
+	form.ExpectField("name", "Draft")
+	form.ExpectField("sort", "custom")
+	form.ExpectField("notes", "some notes")
+
+	values := form.Values()
+	if fmt.Sprint(values["tag_id"]) != "[1]" || values.Has("disabled") || values.Has("submit") {
+		t.Fatalf("expected checked, enabled inputs, got %v", values)
+	}
+}
+
+func TestFragments(t *testing.T) {
+	page := New(t, &http.Response{}, `<tr id="user-1" hx-swap="outerHTML"><td>user@example.com</td></tr>`)
+	page.ExpectHx("#user-1", "swap", "outerHTML")
+	page.ExpectText("tr > td", "user@example.com")
+}
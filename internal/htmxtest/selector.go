@@ -0,0 +1,207 @@
+package htmxtest
+
+import (
+	"fmt"
+	"golang.org/x/net/html"
+	"strings"
+)
+
+// selector is a parsed CSS selector. Only what handler tests need is supported: type, #id,
+// .class and [attr] or [attr="value"] selectors, joined by descendant or child (>)
+// combinators.
+type selector []compound
+
+// compound is the part of a selector that one element must match.
+type compound struct {
+	// combinator relates the element to the one matching the previous compound: ' ' for
+	// an ancestor, '>' for the parent, and 0 for the first compound.
+	combinator byte
+	tag        string
+	id         string
+	classes    []string
+	attrs      []attrSelector
+}
+
+type attrSelector struct {
+	name     string
+	value    string
+	hasValue bool
+}
+
+func parseSelector(s string) (selector, error) {
+	var sel selector
+	var combinator byte
+
+	for i := 0; ; {
+		start := i
+		for i < len(s) && s[i] == ' ' {
+			i++
+		}
+		if i > start && len(sel) > 0 {
+			combinator = ' '
+		}
+		if i == len(s) {
+			break
+		}
+
+		if s[i] == '>' {
+			if len(sel) == 0 {
+				return nil, fmt.Errorf("invalid selector %q: nothing before >", s)
+			}
+			combinator = '>'
+			for i++; i < len(s) && s[i] == ' '; i++ {
+			}
+		}
+
+		c, n, err := parseCompound(s[i:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid selector %q: %w", s, err)
+		}
+		c.combinator = combinator
+		sel = append(sel, c)
+		combinator = 0
+		i += n
+	}
+
+	if len(sel) == 0 {
+		return nil, fmt.Errorf("invalid selector %q: it is empty", s)
+	}
+	if combinator == '>' {
+		return nil, fmt.Errorf("invalid selector %q: nothing after >", s)
+	}
+	return sel, nil
+}
+
+// parseCompound parses the compound at the start of s, returning how much of s it took.
+func parseCompound(s string) (compound, int, error) {
+	var c compound
+	i := 0
+
+	if i < len(s) && s[i] == '*' {
+		i++
+	} else {
+		c.tag = strings.ToLower(ident(s[i:]))
+		i += len(c.tag)
+	}
+
+	for i < len(s) && s[i] != ' ' && s[i] != '>' {
+		switch s[i] {
+		case '#', '.':
+			name := ident(s[i+1:])
+			if name == "" {
+				return c, 0, fmt.Errorf("expected a name after %c", s[i])
+			}
+			if s[i] == '#' {
+				c.id = name
+			} else {
+				c.classes = append(c.classes, name)
+			}
+			i += 1 + len(name)
+		case '[':
+			end := strings.IndexByte(s[i:], ']')
+			if end < 0 {
+				return c, 0, fmt.Errorf("unclosed [")
+			}
+			attr, err := parseAttr(s[i+1 : i+end])
+			if err != nil {
+				return c, 0, err
+			}
+			c.attrs = append(c.attrs, attr)
+			i += end + 1
+		default:
+			return c, 0, fmt.Errorf("unexpected %q", s[i])
+		}
+	}
+
+	if i == 0 {
+		return c, 0, fmt.Errorf("expected a selector")
+	}
+	return c, i, nil
+}
+
+func parseAttr(s string) (attrSelector, error) {
+	name, value, hasValue := strings.Cut(s, "=")
+	attr := attrSelector{name: strings.TrimSpace(name), hasValue: hasValue}
+	if attr.name == "" {
+		return attr, fmt.Errorf("expected an attribute name in [%s]", s)
+	}
+
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		value = value[1 : len(value)-1]
+	}
+	attr.value = value
+	return attr, nil
+}
+
+// ident returns the name at the start of s.
+func ident(s string) string {
+	for i, r := range s {
+		if !(r == '-' || r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return s[:i]
+		}
+	}
+	return s
+}
+
+// matches reports whether n matches the whole selector.
+func (sel selector) matches(n *html.Node) bool {
+	return sel.matchesFrom(n, len(sel)-1)
+}
+
+// matchesFrom reports whether n matches sel[i], with its ancestors matching the compounds
+// before it.
+func (sel selector) matchesFrom(n *html.Node, i int) bool {
+	if !sel[i].matches(n) {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+
+	if sel[i].combinator == '>' {
+		return n.Parent != nil && sel.matchesFrom(n.Parent, i-1)
+	}
+	for p := n.Parent; p != nil; p = p.Parent {
+		if sel.matchesFrom(p, i-1) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c compound) matches(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	if c.tag != "" && n.Data != c.tag {
+		return false
+	}
+	if c.id != "" && attr(n, "id") != c.id {
+		return false
+	}
+
+	classes := strings.Fields(attr(n, "class"))
+	for _, class := range c.classes {
+		if !contains(classes, class) {
+			return false
+		}
+	}
+
+	for _, a := range c.attrs {
+		value, ok := lookupAttr(n, a.name)
+		if !ok || a.hasValue && value != a.value {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+// Package jobs holds the work the server does in the background, outside of any request.
+package jobs
+
+import (
+	"context"
+	"htmxtodo/internal/repo"
+	"log"
+	"time"
+)
+
+// PurgeInterval is how often PurgeTrash looks for lists and items to purge.
+const PurgeInterval = time.Hour
+
+// PurgeTrash permanently deletes lists and items that have been in the trash for longer than
+// retention, once at start and then every interval, until ctx is done.
+func PurgeTrash(ctx context.Context, r repo.Repository, retention time.Duration, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := r.PurgeDeleted(ctx, time.Now().Add(-retention))
+		if err != nil {
+			log.Printf("failed to purge trash: %v", err)
+		} else if purged > 0 {
+			log.Printf("purged %d lists and items from the trash", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"htmxtodo/internal/repo"
+	"testing"
+	"time"
+)
+
+func TestPurgeTrash(t *testing.T) {
+	ctx := context.Background()
+	r := repo.NewMemory()
+
+	user, err := r.FindOrCreateUser(ctx, "purge@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	workspace, err := r.EnsurePersonalWorkspace(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := r.CreateList(ctx, workspace.ID, user.ID, "Old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = r.DeleteListById(ctx, list.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// with the context already done, the trash is purged once before returning
+	done, cancel := context.WithCancel(ctx)
+	cancel()
+
+	PurgeTrash(done, r, time.Hour, time.Hour)
+	if _, err = r.GetDeletedList(ctx, list.ID); err != nil {
+		t.Fatalf("expected the list to be kept until it is older than the retention, got %v", err)
+	}
+
+	PurgeTrash(done, r, -time.Minute, time.Hour)
+	if _, err = r.GetDeletedList(ctx, list.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected the list to be purged, got %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package ratelimit
+
+import (
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"sync"
+	"time"
+)
+
+// Lockout locks a key, such as an account, out after repeated failures. Once a key has
+// failed threshold times, it is locked for base, and each further failure doubles how long,
+// up to max. Failures are forgotten max after the last one, or on success.
+type Lockout struct {
+	storage   fiber.Storage
+	threshold int
+	base      time.Duration
+	max       time.Duration
+
+	// mu keeps this instance's updates of a key from overwriting each other
+	mu sync.Mutex
+}
+
+// NewLockout returns a Lockout. It locks nothing out if threshold is 0.
+func NewLockout(storage fiber.Storage, threshold int, base time.Duration, max time.Duration) *Lockout {
+	return &Lockout{storage: storage, threshold: threshold, base: base, max: max}
+}
+
+// lockoutState is what is stored for a key that has failed.
+type lockoutState struct {
+	failures int
+	until    time.Time
+}
+
+// Locked returns how much longer key is locked out for, or 0 if it is not.
+func (l *Lockout) Locked(key string) (time.Duration, error) {
+	if l.threshold == 0 {
+		return 0, nil
+	}
+
+	state, err := l.get(key)
+	if err != nil {
+		return 0, err
+	}
+	return remaining(state.until), nil
+}
+
+// Fail records a failure for key, and returns how long it is now locked out for, or 0 if
+// it is not.
+func (l *Lockout) Fail(key string) (time.Duration, error) {
+	if l.threshold == 0 {
+		return 0, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	state, err := l.get(key)
+	if err != nil {
+		return 0, err
+	}
+
+	state.failures++
+	if state.failures >= l.threshold {
+		state.until = time.Now().Add(l.lockFor(state.failures))
+	}
+
+	value := fmt.Sprintf("%d:%d", state.failures, state.until.UnixMilli())
+	if err = l.storage.Set(storageKey("lockout", key), []byte(value), l.max); err != nil {
+		return 0, err
+	}
+	return remaining(state.until), nil
+}
+
+// Succeed forgets key's failures.
+func (l *Lockout) Succeed(key string) error {
+	if l.threshold == 0 {
+		return nil
+	}
+	return l.storage.Delete(storageKey("lockout", key))
+}
+
+// lockFor returns how long the given number of failures locks a key out for.
+func (l *Lockout) lockFor(failures int) time.Duration {
+	d := l.base
+	for i := l.threshold; i < failures && d < l.max; i++ {
+		d *= 2
+	}
+	if d > l.max {
+		d = l.max
+	}
+	return d
+}
+
+func (l *Lockout) get(key string) (lockoutState, error) {
+	value, err := l.storage.Get(storageKey("lockout", key))
+	if err != nil || value == nil {
+		return lockoutState{}, err
+	}
+
+	var state lockoutState
+	var until int64
+	if _, err = fmt.Sscanf(string(value), "%d:%d", &state.failures, &until); err != nil {
+		// written by something else; start over rather than lock anyone out for good
+		return lockoutState{}, nil
+	}
+	if until > 0 {
+		state.until = time.UnixMilli(until)
+	}
+	return state, nil
+}
+
+func remaining(until time.Time) time.Duration {
+	if d := time.Until(until); d > 0 {
+		return d
+	}
+	return 0
+}
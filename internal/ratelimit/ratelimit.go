@@ -0,0 +1,98 @@
+// Package ratelimit throttles requests, and locks out repeated failed logins. Its state is
+// kept in a fiber.Storage, so that with a shared one, such as Postgres or Redis, the limits
+// hold across instances. Instances do not lock the storage against each other, so bursts
+// spread over several of them may let a few extra requests through.
+package ratelimit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/limiter"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rate allows Max requests Per period. The zero Rate allows any number.
+type Rate struct {
+	Max int
+	Per time.Duration
+}
+
+// ParseRate parses a rate written as max/period, such as "10/1m", or "off" for none.
+func ParseRate(s string) (Rate, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "off" {
+		return Rate{}, nil
+	}
+
+	max, per, ok := strings.Cut(s, "/")
+	if !ok {
+		return Rate{}, fmt.Errorf("must be max/period, such as 10/1m, or off, got %q", s)
+	}
+
+	var rate Rate
+	var err error
+	if rate.Max, err = strconv.Atoi(max); err != nil || rate.Max < 1 {
+		return Rate{}, fmt.Errorf("must allow at least 1 request, got %q", s)
+	}
+	if rate.Per, err = time.ParseDuration(per); err != nil || rate.Per < time.Second {
+		return Rate{}, fmt.Errorf("must have a period of at least 1s, got %q", s)
+	}
+	return rate, nil
+}
+
+func (r Rate) String() string {
+	if !r.Enabled() {
+		return "off"
+	}
+	return fmt.Sprintf("%d/%s", r.Max, r.Per)
+}
+
+func (r *Rate) UnmarshalText(text []byte) error {
+	rate, err := ParseRate(string(text))
+	if err != nil {
+		return err
+	}
+	*r = rate
+	return nil
+}
+
+// Enabled reports whether the rate limits anything.
+func (r Rate) Enabled() bool {
+	return r.Max > 0
+}
+
+// Limit returns middleware that allows rate requests for each key, such as the client's
+// IP or the current user, and calls limitReached for the rest after setting Retry-After.
+// Requests key returns "" for are not limited. name tells apart the limits that share
+// storage.
+func Limit(storage fiber.Storage, name string, rate Rate, key func(c *fiber.Ctx) string, limitReached fiber.Handler) fiber.Handler {
+	if !rate.Enabled() {
+		return func(c *fiber.Ctx) error {
+			return c.Next()
+		}
+	}
+
+	return limiter.New(limiter.Config{
+		Next: func(c *fiber.Ctx) bool {
+			return key(c) == ""
+		},
+		Max:        rate.Max,
+		Expiration: rate.Per,
+		KeyGenerator: func(c *fiber.Ctx) string {
+			return storageKey(name, key(c))
+		},
+		LimitReached: limitReached,
+		Storage:      storage,
+	})
+}
+
+// storageKey namespaces a key, hashing it so that keys such as email addresses are neither
+// stored as they are nor too long for the fiber_storage table.
+func storageKey(name string, key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "ratelimit:" + name + ":" + hex.EncodeToString(sum[:16])
+}
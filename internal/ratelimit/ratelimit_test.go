@@ -0,0 +1,67 @@
+package ratelimit
+
+import (
+	"htmxtodo/internal/sessionstore"
+	"testing"
+	"time"
+)
+
+func TestParseRate(t *testing.T) {
+	for s, want := range map[string]Rate{
+		"10/1m":  {Max: 10, Per: time.Minute},
+		" 3/1h ": {Max: 3, Per: time.Hour},
+		"off":    {},
+		"":       {},
+	} {
+		got, err := ParseRate(s)
+		if err != nil || got != want {
+			t.Errorf("ParseRate(%q) = %v, %v; expected %v", s, got, err, want)
+		}
+		if again, err := ParseRate(got.String()); err != nil || again != got {
+			t.Errorf("expected %v to parse back from %q, got %v, %v", got, got.String(), again, err)
+		}
+	}
+
+	for _, s := range []string{"10", "0/1m", "ten/1m", "10/forever", "10/1ms"} {
+		if _, err := ParseRate(s); err == nil {
+			t.Errorf("expected ParseRate(%q) to fail", s)
+		}
+	}
+}
+
+func TestLockout(t *testing.T) {
+	lockout := NewLockout(sessionstore.NewMemoryStorage(), 3, time.Minute, 5*time.Minute)
+
+	expectLock := func(d time.Duration, err error, want time.Duration) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// a little may have passed since the lock was set
+		if d > want || d < want-time.Second {
+			t.Fatalf("expected a lock of %s, got %s", want, d)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		d, err := lockout.Fail("user")
+		expectLock(d, err, 0)
+	}
+
+	// each failure from the threshold on doubles the lock, up to the maximum
+	for _, want := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute} {
+		d, err := lockout.Fail("user")
+		expectLock(d, err, want)
+	}
+	d, err := lockout.Locked("user")
+	expectLock(d, err, 5*time.Minute)
+
+	d, err = lockout.Locked("someone else")
+	expectLock(d, err, 0)
+
+	if err = lockout.Succeed("user"); err != nil {
+		t.Fatal(err)
+	}
+	d, err = lockout.Locked("user")
+	expectLock(d, err, 0)
+}
@@ -0,0 +1,292 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	. "github.com/go-jet/jet/v2/postgres"
+	"htmxtodo/gen/htmxtodo_dev/public/model"
+	. "htmxtodo/gen/htmxtodo_dev/public/table"
+	"htmxtodo/internal/constants"
+	"strings"
+	"time"
+)
+
+// The kinds of entity audit events are about.
+const (
+	EntityList      = "list"
+	EntityItem      = "item"
+	EntityTag       = "tag"
+	EntityWorkspace = "workspace"
+	EntityUser      = "user"
+)
+
+// DefaultAuditPageSize is how many audit events FilterAuditEvents returns without a limit.
+const DefaultAuditPageSize = 50
+
+// AuditEntry is an audit event with the email of the user who caused it, if any.
+type AuditEntry struct {
+	model.AuditEvent
+	ActorEmail *string
+}
+
+// AuditQuery selects audit events. Zero fields do not filter. Action matches a whole action,
+// such as "list.share", or all actions with a prefix ending in a dot, such as "list.".
+type AuditQuery struct {
+	WorkspaceID int64
+	ListID      int64
+	ActorEmail  string
+	Action      string
+	EntityType  string
+	From        *time.Time
+	To          *time.Time
+	Limit       int64
+}
+
+// change is a change to a list, item, tag or workspace to record in the audit log. Before
+// and After are marshaled to JSON; either is nil when the entity was created or deleted.
+type change struct {
+	Action      string
+	EntityType  string
+	EntityID    int64
+	WorkspaceID *int64
+	ListID      *int64
+	Before      any
+	After       any
+}
+
+func (r *repository) CreateAuditEvent(ctx context.Context, event model.AuditEvent) (model.AuditEvent, error) {
+	var result model.AuditEvent
+
+	if event.RequestID == nil {
+		event.RequestID = requestId(ctx)
+	}
+
+	stmt := AuditEvent.INSERT(AuditEvent.MutableColumns.Except(AuditEvent.CreatedAt)).
+		MODEL(event).
+		RETURNING(AuditEvent.AllColumns)
+
+	if err := stmt.QueryContext(ctx, r.dbtx, &result); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// FilterAuditEvents returns the audit events a query selects, newest first.
+func (r *repository) FilterAuditEvents(ctx context.Context, query AuditQuery) ([]AuditEntry, error) {
+	r = r.reader(ctx)
+
+	condition := Bool(true)
+	if query.WorkspaceID != 0 {
+		condition = condition.AND(AuditEvent.WorkspaceID.EQ(Int(query.WorkspaceID)))
+	}
+	if query.ListID != 0 {
+		condition = condition.AND(AuditEvent.ListID.EQ(Int(query.ListID)))
+	}
+	if email := strings.TrimSpace(query.ActorEmail); email != "" {
+		condition = condition.AND(LOWER(AppUser.Email).LIKE(String("%" + escapeLike(strings.ToLower(email)) + "%")))
+	}
+	if strings.HasSuffix(query.Action, ".") {
+		condition = condition.AND(AuditEvent.Action.LIKE(String(escapeLike(query.Action) + "%")))
+	} else if query.Action != "" {
+		condition = condition.AND(AuditEvent.Action.EQ(String(query.Action)))
+	}
+	if query.EntityType != "" {
+		condition = condition.AND(AuditEvent.EntityType.EQ(String(query.EntityType)))
+	}
+	if query.From != nil {
+		condition = condition.AND(AuditEvent.CreatedAt.GT_EQ(TimestampzT(*query.From)))
+	}
+	if query.To != nil {
+		condition = condition.AND(AuditEvent.CreatedAt.LT(TimestampzT(*query.To)))
+	}
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = DefaultAuditPageSize
+	}
+
+	stmt := SELECT(
+		AuditEvent.AllColumns,
+		AppUser.Email.AS("audit_entry.actor_email"),
+	).FROM(
+		AuditEvent.LEFT_JOIN(AppUser, AppUser.ID.EQ(AuditEvent.ActorID)),
+	).WHERE(
+		condition,
+	).ORDER_BY(
+		AuditEvent.CreatedAt.DESC(), AuditEvent.ID.DESC(),
+	).LIMIT(limit)
+
+	var results []AuditEntry
+	if err := stmt.QueryContext(ctx, r.dbtx, &results); err != nil {
+		return nil, err
+	}
+
+	if results == nil {
+		results = make([]AuditEntry, 0)
+	}
+
+	return results, nil
+}
+
+// UserAuditEvent describes an action on a user account. A nil actor means the action was
+// taken by the system, such as from the command line.
+func UserAuditEvent(actor *model.AppUser, action string, target model.AppUser) model.AuditEvent {
+	// marshaling strings cannot fail
+	details, _ := json.Marshal(map[string]string{"email": target.Email, "role": target.Role})
+	detailsJson := string(details)
+
+	event := model.AuditEvent{
+		Action:     action,
+		EntityType: EntityUser,
+		EntityID:   target.ID,
+		Details:    &detailsJson,
+	}
+	if actor != nil {
+		event.ActorID = &actor.ID
+	}
+
+	return event
+}
+
+// recordChange appends a change to the audit log. It must be called on the repository
+// making the change, inside its transaction, so that the change and its record commit or
+// roll back together.
+func (r *repository) recordChange(ctx context.Context, c change) error {
+	event, err := newChangeEvent(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.CreateAuditEvent(ctx, event)
+	return err
+}
+
+// newChangeEvent returns the audit event recording a change. The actor is the user logged
+// in to the request ctx belongs to, if any; handlers pass fiber's context, which holds the
+// request's locals as values.
+func newChangeEvent(ctx context.Context, c change) (model.AuditEvent, error) {
+	event := model.AuditEvent{
+		Action:      c.Action,
+		EntityType:  c.EntityType,
+		EntityID:    c.EntityID,
+		WorkspaceID: c.WorkspaceID,
+		ListID:      c.ListID,
+		RequestID:   requestId(ctx),
+	}
+
+	if user, ok := ctx.Value(constants.CurrentUserContextKey).(*model.AppUser); ok && user != nil {
+		event.ActorID = &user.ID
+	}
+	if impersonator, ok := ctx.Value(constants.ImpersonatorContextKey).(*model.AppUser); ok && impersonator != nil {
+		details, err := jsonColumn(map[string]any{"impersonator_id": impersonator.ID})
+		if err != nil {
+			return event, err
+		}
+		event.Details = details
+	}
+
+	var err error
+	if event.Before, err = jsonColumn(c.Before); err != nil {
+		return event, err
+	}
+	if event.After, err = jsonColumn(c.After); err != nil {
+		return event, err
+	}
+
+	return event, nil
+}
+
+// recordListChange records a change to a list, with the list's state before and after it.
+func (r *repository) recordListChange(ctx context.Context, action string, before *model.List, after *model.List) error {
+	return r.recordChange(ctx, listChange(action, before, after))
+}
+
+// listChange is a change to a list. Either state is nil when the list was created or
+// deleted.
+func listChange(action string, before *model.List, after *model.List) change {
+	list := after
+	if list == nil {
+		list = before
+	}
+
+	c := change{
+		Action:      action,
+		EntityType:  EntityList,
+		EntityID:    list.ID,
+		WorkspaceID: &list.WorkspaceID,
+		ListID:      &list.ID,
+	}
+	// leave missing states as untyped nils, which are not recorded
+	if before != nil {
+		c.Before = newListState(*before)
+	}
+	if after != nil {
+		c.After = newListState(*after)
+	}
+
+	return c
+}
+
+// recordItemChange records a change to an item, as activity on its list.
+func (r *repository) recordItemChange(ctx context.Context, action string, itemId int64, before any, after any) error {
+	var list model.List
+	stmt := SELECT(List.ID, List.WorkspaceID).
+		FROM(List.INNER_JOIN(Item, Item.ListID.EQ(List.ID))).
+		WHERE(Item.ID.EQ(Int(itemId)))
+	if err := r.queryRow(ctx, stmt, &list); err != nil {
+		return err
+	}
+
+	return r.recordChange(ctx, change{
+		Action:      action,
+		EntityType:  EntityItem,
+		EntityID:    itemId,
+		WorkspaceID: &list.WorkspaceID,
+		ListID:      &list.ID,
+		Before:      before,
+		After:       after,
+	})
+}
+
+// listState is what the audit log records of a list; the rest is derived or bookkeeping.
+type listState struct {
+	Name        string     `json:"name"`
+	OwnerID     int64      `json:"owner_id"`
+	WorkspaceID int64      `json:"workspace_id"`
+	Position    int32      `json:"position"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+}
+
+func newListState(list model.List) listState {
+	return listState{
+		Name:        list.Name,
+		OwnerID:     list.OwnerID,
+		WorkspaceID: list.WorkspaceID,
+		Position:    list.Position,
+		DeletedAt:   list.DeletedAt,
+	}
+}
+
+// requestId returns the ID fiber's requestid middleware gave the request ctx belongs to.
+func requestId(ctx context.Context) *string {
+	if id, ok := ctx.Value(constants.RequestIdContextKey).(string); ok && id != "" {
+		return &id
+	}
+	return nil
+}
+
+// jsonColumn marshals a value for a jsonb column, which is NULL for nil.
+func jsonColumn(value any) (*string, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	s := string(b)
+	return &s, nil
+}
@@ -0,0 +1,103 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	. "github.com/go-jet/jet/v2/postgres"
+	"htmxtodo/gen/htmxtodo_dev/public/model"
+	. "htmxtodo/gen/htmxtodo_dev/public/table"
+)
+
+// getItem returns an item, or sql.ErrNoRows if there is no such item or it is in the trash.
+func (r *repository) getItem(ctx context.Context, id int64) (model.Item, error) {
+	stmt := Item.SELECT(Item.AllColumns).
+		WHERE(Item.ID.EQ(Int(id)).AND(Item.DeletedAt.IS_NULL()))
+
+	var result model.Item
+	err := r.queryRow(ctx, stmt, &result)
+	return result, err
+}
+
+// CreateItem adds an item to the end of a list.
+func (r *repository) CreateItem(ctx context.Context, listId int64, name string) (model.Item, error) {
+	var result model.Item
+
+	position := RawInt("SELECT COALESCE(MAX(position), 0) + 1 FROM item WHERE list_id = #list_id AND deleted_at IS NULL",
+		RawArgs{"#list_id": listId})
+
+	stmt := Item.INSERT(Item.ListID, Item.Name, Item.Position).
+		VALUES(listId, name, position).
+		RETURNING(Item.AllColumns)
+
+	err := r.inTransaction(ctx, func(rtx *repository) error {
+		if err := rtx.queryRow(ctx, stmt, &result); err != nil {
+			return err
+		}
+		return rtx.recordItemChange(ctx, "item.create", result.ID, nil, newItemState(result))
+	})
+
+	return result, err
+}
+
+// UpdateItemById renames an item, if it is still at the version the caller last read. If it
+// is not, it returns the current item with ErrVersionConflict.
+func (r *repository) UpdateItemById(ctx context.Context, id int64, name string, version int32) (model.Item, error) {
+	stmt := Item.UPDATE(Item.Name, Item.UpdatedAt, Item.Version).
+		SET(name, NOW(), Item.Version.ADD(Int(1))).
+		WHERE(Item.ID.EQ(Int(id)).AND(Item.Version.EQ(Int32(version))).AND(Item.DeletedAt.IS_NULL())).
+		RETURNING(Item.AllColumns)
+
+	return r.updateItem(ctx, id, version, "item.update", stmt)
+}
+
+// SetItemCompleted marks an item done, or not done, if it is still at the version the caller
+// last read. If it is not, it returns the current item with ErrVersionConflict. Completing
+// an item that is already done keeps the time it was done.
+func (r *repository) SetItemCompleted(ctx context.Context, id int64, completed bool, version int32) (model.Item, error) {
+	var completedAt Expression = NULL
+	action := "item.reopen"
+	if completed {
+		completedAt = COALESCE(Item.CompletedAt, NOW())
+		action = "item.complete"
+	}
+
+	stmt := Item.UPDATE(Item.CompletedAt, Item.UpdatedAt, Item.Version).
+		SET(completedAt, NOW(), Item.Version.ADD(Int(1))).
+		WHERE(Item.ID.EQ(Int(id)).AND(Item.Version.EQ(Int32(version))).AND(Item.DeletedAt.IS_NULL())).
+		RETURNING(Item.AllColumns)
+
+	return r.updateItem(ctx, id, version, action, stmt)
+}
+
+// updateItem runs an update of an item at a version, recording it as action.
+func (r *repository) updateItem(ctx context.Context, id int64, version int32, action string, stmt Statement) (model.Item, error) {
+	var result model.Item
+
+	err := r.inTransaction(ctx, func(rtx *repository) error {
+		before, err := rtx.getItem(ctx, id)
+		if err != nil {
+			return err
+		}
+		if before.Version != version {
+			result = before
+			return ErrVersionConflict
+		}
+
+		err = rtx.queryRow(ctx, stmt, &result)
+		if errors.Is(err, sql.ErrNoRows) {
+			// changed since it was read above
+			if result, err = rtx.getItem(ctx, id); err != nil {
+				return err
+			}
+			return ErrVersionConflict
+		}
+		if err != nil {
+			return err
+		}
+
+		return rtx.recordItemChange(ctx, action, id, newItemState(before), newItemState(result))
+	})
+
+	return result, err
+}
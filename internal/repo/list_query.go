@@ -0,0 +1,225 @@
+package repo
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	. "github.com/go-jet/jet/v2/postgres"
+	"htmxtodo/gen/htmxtodo_dev/public/model"
+	. "htmxtodo/gen/htmxtodo_dev/public/table"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Orders lists can be sorted in. Names and custom positions sort ascending, times newest
+// first, and search results best match first.
+const (
+	ListSortName      = "name"
+	ListSortCreated   = "created"
+	ListSortUpdated   = "updated"
+	ListSortCustom    = "custom"
+	ListSortRelevance = "relevance"
+)
+
+// DefaultListPageSize is how many lists FilterLists returns when the query has no Limit.
+const DefaultListPageSize = 24
+
+var ErrInvalidCursor = errors.New("invalid page cursor")
+
+// ListQuery selects a page of the lists in a workspace.
+type ListQuery struct {
+	WorkspaceID int64
+	// Search is a web search style query, e.g. `milk -oat "olive oil"`. Only lists whose name
+	// or items match it are returned.
+	Search string
+	Filter ListFilter
+	// Sort is one of the ListSort constants. It defaults to relevance when searching, and
+	// name otherwise.
+	Sort string
+	// After is the Next cursor of the previous page, or "" for the first page.
+	After string
+	Limit int64
+}
+
+// ListFilter narrows down the lists FilterLists returns. Zero values match everything, and
+// time ranges include their start but not their end.
+type ListFilter struct {
+	NameContains       string
+	CreatedFrom        *time.Time
+	CreatedTo          *time.Time
+	UpdatedFrom        *time.Time
+	UpdatedTo          *time.Time
+	HasIncompleteItems bool
+	// TagID selects lists with the tag, unless it is 0.
+	TagID int64
+}
+
+// ListResult is a list returned by FilterLists. Rank and Snippet are only set when searching.
+type ListResult struct {
+	model.List
+	Rank float64
+	// Snippet is the matching list or item names, with highlighted words marked. Split it
+	// with SnippetParts before displaying it.
+	Snippet string
+}
+
+// ListPage is one page of FilterLists results.
+type ListPage struct {
+	Results []ListResult
+	// Next is the cursor for the following page, or "" if this is the last one.
+	Next string
+}
+
+// IsListSort reports whether sort is an order lists can be sorted in.
+func IsListSort(sort string) bool {
+	switch sort {
+	case ListSortName, ListSortCreated, ListSortUpdated, ListSortCustom, ListSortRelevance:
+		return true
+	}
+	return false
+}
+
+// listCursor is the position of the last list on a page, in the page's sort order.
+type listCursor struct {
+	Value string `json:"v"`
+	ID    int64  `json:"id"`
+}
+
+func encodeListCursor(sort string, result ListResult) string {
+	cursor := listCursor{ID: result.ID}
+	switch sort {
+	case ListSortName:
+		cursor.Value = result.Name
+	case ListSortCreated:
+		cursor.Value = result.CreatedAt.Format(time.RFC3339Nano)
+	case ListSortUpdated:
+		cursor.Value = result.UpdatedAt.Format(time.RFC3339Nano)
+	case ListSortCustom:
+		cursor.Value = strconv.FormatInt(int64(result.Position), 10)
+	case ListSortRelevance:
+		cursor.Value = strconv.FormatFloat(result.Rank, 'g', -1, 64)
+	}
+
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeListCursor(encoded string) (listCursor, error) {
+	var cursor listCursor
+
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return cursor, ErrInvalidCursor
+	}
+	if err = json.Unmarshal(data, &cursor); err != nil {
+		return cursor, ErrInvalidCursor
+	}
+
+	return cursor, nil
+}
+
+// listOrder returns the ORDER BY clauses for a sort, and the condition selecting the lists
+// after a cursor in that order. Ties are broken by ID so that every list has a distinct
+// position.
+func listOrder(sort string, rank FloatExpression, after string) ([]OrderByClause, BoolExpression, error) {
+	var cursor *listCursor
+	if after != "" {
+		decoded, err := decodeListCursor(after)
+		if err != nil {
+			return nil, nil, err
+		}
+		cursor = &decoded
+	}
+
+	switch sort {
+	case ListSortCreated, ListSortUpdated:
+		column := List.CreatedAt
+		if sort == ListSortUpdated {
+			column = List.UpdatedAt
+		}
+		orderBy := []OrderByClause{column.DESC(), List.ID.DESC()}
+		if cursor == nil {
+			return orderBy, nil, nil
+		}
+		t, err := time.Parse(time.RFC3339Nano, cursor.Value)
+		if err != nil {
+			return nil, nil, ErrInvalidCursor
+		}
+		return orderBy, column.LT(TimestampzT(t)).
+			OR(column.EQ(TimestampzT(t)).AND(List.ID.LT(Int(cursor.ID)))), nil
+
+	case ListSortCustom:
+		orderBy := []OrderByClause{List.Position.ASC(), List.ID.ASC()}
+		if cursor == nil {
+			return orderBy, nil, nil
+		}
+		position, err := strconv.ParseInt(cursor.Value, 10, 32)
+		if err != nil {
+			return nil, nil, ErrInvalidCursor
+		}
+		return orderBy, List.Position.GT(Int(position)).
+			OR(List.Position.EQ(Int(position)).AND(List.ID.GT(Int(cursor.ID)))), nil
+
+	case ListSortRelevance:
+		orderBy := []OrderByClause{rank.DESC(), List.ID.ASC()}
+		if cursor == nil {
+			return orderBy, nil, nil
+		}
+		value, err := strconv.ParseFloat(cursor.Value, 64)
+		if err != nil {
+			return nil, nil, ErrInvalidCursor
+		}
+		return orderBy, rank.LT(Float(value)).
+			OR(rank.EQ(Float(value)).AND(List.ID.GT(Int(cursor.ID)))), nil
+
+	default:
+		orderBy := []OrderByClause{List.Name.ASC(), List.ID.ASC()}
+		if cursor == nil {
+			return orderBy, nil, nil
+		}
+		return orderBy, List.Name.GT(String(cursor.Value)).
+			OR(List.Name.EQ(String(cursor.Value)).AND(List.ID.GT(Int(cursor.ID)))), nil
+	}
+}
+
+// listFilterCondition returns the condition matching the lists a filter selects.
+func listFilterCondition(filter ListFilter) BoolExpression {
+	condition := Bool(true)
+
+	if name := strings.TrimSpace(filter.NameContains); name != "" {
+		condition = condition.AND(LOWER(List.Name).LIKE(String("%" + escapeLike(strings.ToLower(name)) + "%")))
+	}
+	if filter.CreatedFrom != nil {
+		condition = condition.AND(List.CreatedAt.GT_EQ(TimestampzT(*filter.CreatedFrom)))
+	}
+	if filter.CreatedTo != nil {
+		condition = condition.AND(List.CreatedAt.LT(TimestampzT(*filter.CreatedTo)))
+	}
+	if filter.UpdatedFrom != nil {
+		condition = condition.AND(List.UpdatedAt.GT_EQ(TimestampzT(*filter.UpdatedFrom)))
+	}
+	if filter.UpdatedTo != nil {
+		condition = condition.AND(List.UpdatedAt.LT(TimestampzT(*filter.UpdatedTo)))
+	}
+	if filter.HasIncompleteItems {
+		condition = condition.AND(EXISTS(
+			SELECT(Item.ID).
+				FROM(Item).
+				WHERE(Item.ListID.EQ(List.ID).AND(Item.CompletedAt.IS_NULL()).AND(Item.DeletedAt.IS_NULL())),
+		))
+	}
+
+	if filter.TagID != 0 {
+		condition = condition.AND(EXISTS(
+			SELECT(ListTag.TagID).
+				FROM(ListTag).
+				WHERE(ListTag.ListID.EQ(List.ID).AND(ListTag.TagID.EQ(Int(filter.TagID)))),
+		))
+	}
+
+	return condition
+}
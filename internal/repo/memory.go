@@ -0,0 +1,559 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"htmxtodo/gen/htmxtodo_dev/public/model"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryRepository keeps everything in process memory. Data is lost on restart and is not
+// shared between instances, so it is only suitable for tests and local development.
+//
+// It behaves like the Postgres repository, which the conformance tests check, except that
+// search matches words by prefix instead of by their English stems, and ranks results
+// roughly. Each call holds a lock for its duration, and WithTx holds it until fn returns,
+// so fn must not call the repository WithTx was called on, only the one it is given.
+//
+// Strings are copied before they are kept, because fiber reuses the memory behind request
+// values once a handler returns.
+type MemoryRepository struct {
+	mu   *sync.Mutex
+	data *memoryData
+	// inTx is set on the repository given to WithTx's fn, which already holds the lock
+	inTx bool
+}
+
+// memoryData are the tables of a MemoryRepository. Rows are stored by value and replaced
+// rather than modified, so that a shallow copy of the maps is a snapshot.
+type memoryData struct {
+	lastId int64
+
+	users            map[int64]model.AppUser
+	workspaces       map[int64]model.Workspace
+	workspaceMembers map[memberKey]model.WorkspaceMember
+	lists            map[int64]model.List
+	items            map[int64]model.Item
+	listMembers      map[memberKey]model.ListMember
+	invitations      map[int64]model.ListInvitation
+	shareLinks       map[int64]model.ShareLink
+	tags             map[int64]model.Tag
+	listTags         map[model.ListTag]bool
+	auditEvents      []model.AuditEvent
+}
+
+// memberKey identifies a membership of a user in a workspace or list.
+type memberKey struct {
+	ID     int64
+	UserID int64
+}
+
+func NewMemory() *MemoryRepository {
+	return &MemoryRepository{
+		mu: &sync.Mutex{},
+		data: &memoryData{
+			users:            make(map[int64]model.AppUser),
+			workspaces:       make(map[int64]model.Workspace),
+			workspaceMembers: make(map[memberKey]model.WorkspaceMember),
+			lists:            make(map[int64]model.List),
+			items:            make(map[int64]model.Item),
+			listMembers:      make(map[memberKey]model.ListMember),
+			invitations:      make(map[int64]model.ListInvitation),
+			shareLinks:       make(map[int64]model.ShareLink),
+			tags:             make(map[int64]model.Tag),
+			listTags:         make(map[model.ListTag]bool),
+		},
+	}
+}
+
+// lock locks the repository for a call, returning the function that unlocks it.
+func (m *MemoryRepository) lock() func() {
+	if m.inTx {
+		return func() {}
+	}
+	m.mu.Lock()
+	return m.mu.Unlock
+}
+
+// WithTx runs fn with a repository whose changes are undone if fn returns an error or
+// panics. Nested calls undo only their own changes, like savepoints.
+func (m *MemoryRepository) WithTx(ctx context.Context, fn func(Repository) error) error {
+	return m.atomically(func(d *memoryData) error {
+		return fn(&MemoryRepository{mu: m.mu, data: d, inTx: true})
+	})
+}
+
+// atomically runs fn on the repository's data, restoring the data as it was if fn fails
+// part way through.
+func (m *MemoryRepository) atomically(fn func(d *memoryData) error) error {
+	defer m.lock()()
+
+	snapshot := m.data.clone()
+	done := false
+	defer func() {
+		if !done {
+			*m.data = snapshot
+		}
+	}()
+
+	if err := fn(m.data); err != nil {
+		return err
+	}
+
+	done = true
+	return nil
+}
+
+func (d *memoryData) clone() memoryData {
+	return memoryData{
+		lastId:           d.lastId,
+		users:            maps.Clone(d.users),
+		workspaces:       maps.Clone(d.workspaces),
+		workspaceMembers: maps.Clone(d.workspaceMembers),
+		lists:            maps.Clone(d.lists),
+		items:            maps.Clone(d.items),
+		listMembers:      maps.Clone(d.listMembers),
+		invitations:      maps.Clone(d.invitations),
+		shareLinks:       maps.Clone(d.shareLinks),
+		tags:             maps.Clone(d.tags),
+		listTags:         maps.Clone(d.listTags),
+		auditEvents:      slices.Clone(d.auditEvents),
+	}
+}
+
+func (d *memoryData) nextId() int64 {
+	d.lastId++
+	return d.lastId
+}
+
+// memoryNow returns the current time at the precision Postgres stores.
+func memoryNow() time.Time {
+	return time.Now().Truncate(time.Microsecond)
+}
+
+// sortedValues returns the values of a map in the order less gives.
+func sortedValues[K comparable, V any](m map[K]V, less func(a, b V) bool) []V {
+	values := make([]V, 0, len(m))
+	for _, v := range m {
+		values = append(values, v)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		return less(values[i], values[j])
+	})
+	return values
+}
+
+func (m *MemoryRepository) GetUserById(ctx context.Context, id int64) (model.AppUser, error) {
+	defer m.lock()()
+	return m.data.getUser(id)
+}
+
+func (d *memoryData) getUser(id int64) (model.AppUser, error) {
+	user, ok := d.users[id]
+	if !ok {
+		return user, sql.ErrNoRows
+	}
+	return user, nil
+}
+
+func (m *MemoryRepository) GetUserByEmail(ctx context.Context, email string) (model.AppUser, error) {
+	defer m.lock()()
+	return m.data.getUserByEmail(email)
+}
+
+func (d *memoryData) getUserByEmail(email string) (model.AppUser, error) {
+	for _, user := range d.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return model.AppUser{}, sql.ErrNoRows
+}
+
+func (m *MemoryRepository) FindOrCreateUser(ctx context.Context, email string) (model.AppUser, error) {
+	email = strings.Clone(email)
+
+	defer m.lock()()
+
+	if user, err := m.data.getUserByEmail(email); err == nil {
+		return user, nil
+	}
+
+	now := memoryNow()
+	user := model.AppUser{
+		ID:        m.data.nextId(),
+		Email:     email,
+		Role:      RoleUser,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	m.data.users[user.ID] = user
+	return user, nil
+}
+
+func (m *MemoryRepository) FilterUsers(ctx context.Context, query string) ([]model.AppUser, error) {
+	defer m.lock()()
+
+	query = strings.ToLower(strings.TrimSpace(query))
+	results := make([]model.AppUser, 0)
+	for _, user := range sortedValues(m.data.users, func(a, b model.AppUser) bool { return a.Email < b.Email }) {
+		if strings.Contains(strings.ToLower(user.Email), query) {
+			results = append(results, user)
+		}
+	}
+	return results, nil
+}
+
+func (m *MemoryRepository) UpdateUserRole(ctx context.Context, id int64, role string) (model.AppUser, error) {
+	role = strings.Clone(role)
+
+	return m.updateUser(id, func(user *model.AppUser) {
+		user.Role = role
+	})
+}
+
+func (m *MemoryRepository) UpdateUserDisabled(ctx context.Context, id int64, disabled bool) (model.AppUser, error) {
+	return m.updateUser(id, func(user *model.AppUser) {
+		user.DisabledAt = nil
+		if disabled {
+			now := memoryNow()
+			user.DisabledAt = &now
+		}
+	})
+}
+
+func (m *MemoryRepository) RevokeUserSessions(ctx context.Context, id int64) (model.AppUser, error) {
+	return m.updateUser(id, func(user *model.AppUser) {
+		now := memoryNow()
+		user.SessionsRevokedAt = &now
+	})
+}
+
+func (m *MemoryRepository) updateUser(id int64, update func(user *model.AppUser)) (model.AppUser, error) {
+	defer m.lock()()
+
+	user, err := m.data.getUser(id)
+	if err != nil {
+		return user, err
+	}
+
+	update(&user)
+	user.UpdatedAt = memoryNow()
+	m.data.users[id] = user
+	return user, nil
+}
+
+func (m *MemoryRepository) CreateAuditEvent(ctx context.Context, event model.AuditEvent) (model.AuditEvent, error) {
+	defer m.lock()()
+	return m.data.createAuditEvent(ctx, event), nil
+}
+
+func (d *memoryData) createAuditEvent(ctx context.Context, event model.AuditEvent) model.AuditEvent {
+	event.ID = d.nextId()
+	event.CreatedAt = memoryNow()
+	if event.RequestID == nil {
+		event.RequestID = requestId(ctx)
+	}
+
+	d.auditEvents = append(d.auditEvents, event)
+	return event
+}
+
+// recordChange appends a change to the audit log.
+func (d *memoryData) recordChange(ctx context.Context, c change) error {
+	event, err := newChangeEvent(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	d.createAuditEvent(ctx, event)
+	return nil
+}
+
+// recordListEvent records a change to something belonging to a list.
+func (d *memoryData) recordListEvent(ctx context.Context, action string, listId int64, before any, after any) error {
+	list, ok := d.lists[listId]
+	if !ok {
+		return sql.ErrNoRows
+	}
+
+	return d.recordChange(ctx, change{
+		Action:      action,
+		EntityType:  EntityList,
+		EntityID:    listId,
+		WorkspaceID: &list.WorkspaceID,
+		ListID:      &listId,
+		Before:      before,
+		After:       after,
+	})
+}
+
+// recordItemChange records a change to an item, as activity on its list.
+func (d *memoryData) recordItemChange(ctx context.Context, action string, itemId int64, before any, after any) error {
+	list, ok := d.lists[d.items[itemId].ListID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+
+	return d.recordChange(ctx, change{
+		Action:      action,
+		EntityType:  EntityItem,
+		EntityID:    itemId,
+		WorkspaceID: &list.WorkspaceID,
+		ListID:      &list.ID,
+		Before:      before,
+		After:       after,
+	})
+}
+
+// recordWorkspaceChange records a change to a workspace or its members.
+func (d *memoryData) recordWorkspaceChange(ctx context.Context, action string, workspaceId int64, before any, after any) error {
+	return d.recordChange(ctx, change{
+		Action:      action,
+		EntityType:  EntityWorkspace,
+		EntityID:    workspaceId,
+		WorkspaceID: &workspaceId,
+		Before:      before,
+		After:       after,
+	})
+}
+
+func (m *MemoryRepository) FilterAuditEvents(ctx context.Context, query AuditQuery) ([]AuditEntry, error) {
+	defer m.lock()()
+
+	email := strings.ToLower(strings.TrimSpace(query.ActorEmail))
+	limit := query.Limit
+	if limit <= 0 {
+		limit = DefaultAuditPageSize
+	}
+
+	results := make([]AuditEntry, 0)
+	for i := len(m.data.auditEvents) - 1; i >= 0 && int64(len(results)) < limit; i-- {
+		event := m.data.auditEvents[i]
+
+		entry := AuditEntry{AuditEvent: event}
+		if event.ActorID != nil {
+			if actor, ok := m.data.users[*event.ActorID]; ok {
+				entry.ActorEmail = &actor.Email
+			}
+		}
+
+		switch {
+		case query.WorkspaceID != 0 && (event.WorkspaceID == nil || *event.WorkspaceID != query.WorkspaceID):
+		case query.ListID != 0 && (event.ListID == nil || *event.ListID != query.ListID):
+		case email != "" && (entry.ActorEmail == nil || !strings.Contains(strings.ToLower(*entry.ActorEmail), email)):
+		case strings.HasSuffix(query.Action, ".") && !strings.HasPrefix(event.Action, query.Action):
+		case query.Action != "" && !strings.HasSuffix(query.Action, ".") && event.Action != query.Action:
+		case query.EntityType != "" && event.EntityType != query.EntityType:
+		case query.From != nil && event.CreatedAt.Before(*query.From):
+		case query.To != nil && !event.CreatedAt.Before(*query.To):
+		default:
+			results = append(results, entry)
+		}
+	}
+
+	return results, nil
+}
+
+func (m *MemoryRepository) GetStats(ctx context.Context) (Stats, error) {
+	defer m.lock()()
+
+	var stats Stats
+	for _, user := range m.data.users {
+		stats.Users++
+		if user.Role == RoleAdmin {
+			stats.Admins++
+		}
+		if user.DisabledAt != nil {
+			stats.DisabledUsers++
+		}
+	}
+	for _, list := range m.data.lists {
+		if list.DeletedAt == nil {
+			stats.Lists++
+		}
+	}
+	for _, item := range m.data.items {
+		if item.DeletedAt == nil {
+			stats.Items++
+		}
+	}
+
+	return stats, nil
+}
+
+func (m *MemoryRepository) FilterWorkspaces(ctx context.Context, userId int64) ([]UserWorkspace, error) {
+	defer m.lock()()
+
+	results := make([]UserWorkspace, 0)
+	for key, member := range m.data.workspaceMembers {
+		if key.UserID == userId {
+			results = append(results, UserWorkspace{Workspace: m.data.workspaces[key.ID], Role: member.Role})
+		}
+	}
+
+	// personal workspace first
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.IsPersonal() != b.IsPersonal() {
+			return a.IsPersonal()
+		}
+		return a.Name < b.Name
+	})
+
+	return results, nil
+}
+
+func (m *MemoryRepository) GetWorkspaceRole(ctx context.Context, workspaceId int64, userId int64) (string, error) {
+	defer m.lock()()
+	return m.data.workspaceMembers[memberKey{workspaceId, userId}].Role, nil
+}
+
+func (m *MemoryRepository) GetUserWorkspace(ctx context.Context, workspaceId int64, userId int64) (UserWorkspace, error) {
+	defer m.lock()()
+
+	member, ok := m.data.workspaceMembers[memberKey{workspaceId, userId}]
+	if !ok {
+		return UserWorkspace{}, sql.ErrNoRows
+	}
+	return UserWorkspace{Workspace: m.data.workspaces[workspaceId], Role: member.Role}, nil
+}
+
+func (m *MemoryRepository) EnsurePersonalWorkspace(ctx context.Context, user model.AppUser) (model.Workspace, error) {
+	defer m.lock()()
+	return m.data.ensurePersonalWorkspace(user.ID), nil
+}
+
+func (d *memoryData) ensurePersonalWorkspace(userId int64) model.Workspace {
+	for _, workspace := range d.workspaces {
+		if workspace.PersonalUserID != nil && *workspace.PersonalUserID == userId {
+			d.addWorkspaceMember(workspace.ID, userId, WorkspaceRoleOwner)
+			return workspace
+		}
+	}
+
+	workspace := d.createWorkspace(personalWorkspaceName)
+	workspace.PersonalUserID = &userId
+	d.workspaces[workspace.ID] = workspace
+	d.addWorkspaceMember(workspace.ID, userId, WorkspaceRoleOwner)
+	return workspace
+}
+
+func (m *MemoryRepository) CreateWorkspace(ctx context.Context, name string, ownerId int64) (model.Workspace, error) {
+	name = strings.Clone(name)
+
+	var result model.Workspace
+
+	err := m.atomically(func(d *memoryData) error {
+		result = d.createWorkspace(name)
+		d.addWorkspaceMember(result.ID, ownerId, WorkspaceRoleOwner)
+		return d.recordWorkspaceChange(ctx, "workspace.create", result.ID, nil, map[string]string{"name": result.Name})
+	})
+
+	return result, err
+}
+
+func (d *memoryData) createWorkspace(name string) model.Workspace {
+	now := memoryNow()
+	workspace := model.Workspace{
+		ID:        d.nextId(),
+		Name:      name,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	d.workspaces[workspace.ID] = workspace
+	return workspace
+}
+
+// addWorkspaceMember adds a user to a workspace, unless they are already a member.
+func (d *memoryData) addWorkspaceMember(workspaceId int64, userId int64, role string) {
+	key := memberKey{workspaceId, userId}
+	if _, ok := d.workspaceMembers[key]; ok {
+		return
+	}
+
+	d.workspaceMembers[key] = model.WorkspaceMember{
+		WorkspaceID: workspaceId,
+		UserID:      userId,
+		Role:        role,
+		CreatedAt:   memoryNow(),
+	}
+}
+
+func (m *MemoryRepository) FilterWorkspaceMembers(ctx context.Context, workspaceId int64) ([]WorkspaceUser, error) {
+	defer m.lock()()
+
+	results := make([]WorkspaceUser, 0)
+	for key, member := range m.data.workspaceMembers {
+		if key.ID == workspaceId {
+			results = append(results, WorkspaceUser{WorkspaceMember: member, Email: m.data.users[key.UserID].Email})
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Email < results[j].Email
+	})
+
+	return results, nil
+}
+
+func (m *MemoryRepository) AddWorkspaceMember(ctx context.Context, workspaceId int64, email string, role string) (model.AppUser, error) {
+	email = strings.Clone(email)
+	role = strings.Clone(role)
+
+	var user model.AppUser
+
+	err := m.atomically(func(d *memoryData) error {
+		workspace, ok := d.workspaces[workspaceId]
+		if !ok {
+			return sql.ErrNoRows
+		}
+		if workspace.PersonalUserID != nil {
+			return ErrPersonalWorkspace
+		}
+
+		var err error
+		if user, err = d.getUserByEmail(email); err != nil {
+			return err
+		}
+
+		key := memberKey{workspaceId, user.ID}
+		previous, isMember := d.workspaceMembers[key]
+		if previous.Role == WorkspaceRoleOwner {
+			// the owner's role never changes
+			return nil
+		}
+
+		var before any
+		if isMember {
+			before = workspaceMemberState{UserID: user.ID, Email: user.Email, Role: previous.Role}
+		} else {
+			previous = model.WorkspaceMember{WorkspaceID: workspaceId, UserID: user.ID, CreatedAt: memoryNow()}
+		}
+		previous.Role = role
+		d.workspaceMembers[key] = previous
+
+		return d.recordWorkspaceChange(ctx, "workspace.add_member", workspaceId, before,
+			workspaceMemberState{UserID: user.ID, Email: user.Email, Role: role})
+	})
+
+	return user, err
+}
+
+func (m *MemoryRepository) RemoveWorkspaceMember(ctx context.Context, workspaceId int64, userId int64) error {
+	return m.atomically(func(d *memoryData) error {
+		key := memberKey{workspaceId, userId}
+		member, ok := d.workspaceMembers[key]
+		if !ok || member.Role == WorkspaceRoleOwner {
+			return nil
+		}
+
+		delete(d.workspaceMembers, key)
+		return d.recordWorkspaceChange(ctx, "workspace.remove_member", workspaceId,
+			workspaceMemberState{UserID: userId, Role: member.Role}, nil)
+	})
+}
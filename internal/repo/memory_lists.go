@@ -0,0 +1,1210 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"htmxtodo/gen/htmxtodo_dev/public/model"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func (m *MemoryRepository) FilterLists(ctx context.Context, query ListQuery) (ListPage, error) {
+	defer m.lock()()
+
+	var search *memorySearch
+	sortBy := query.Sort
+	if s := strings.TrimSpace(query.Search); s != "" {
+		search = newMemorySearch(s)
+		if !IsListSort(sortBy) {
+			sortBy = ListSortRelevance
+		}
+	} else if sortBy == ListSortRelevance || !IsListSort(sortBy) {
+		sortBy = ListSortName
+	}
+
+	var after *ListResult
+	if query.After != "" {
+		cursor, err := memoryListCursor(sortBy, query.After)
+		if err != nil {
+			return ListPage{}, err
+		}
+		after = &cursor
+	}
+
+	var results []ListResult
+	for _, list := range m.data.lists {
+		if list.WorkspaceID != query.WorkspaceID || list.DeletedAt != nil {
+			continue
+		}
+		if !m.data.listMatchesFilter(list, query.Filter) {
+			continue
+		}
+
+		result := ListResult{List: list}
+		if search != nil {
+			var ok bool
+			if result.Rank, result.Snippet, ok = search.matchList(list, m.data.listItems(list.ID)); !ok {
+				continue
+			}
+		}
+		if after != nil && !listBefore(sortBy, *after, result) {
+			continue
+		}
+
+		results = append(results, result)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return listBefore(sortBy, results[i], results[j])
+	})
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = DefaultListPageSize
+	}
+
+	page := ListPage{Results: results}
+	if int64(len(results)) > limit {
+		page.Results = results[:limit]
+		page.Next = encodeListCursor(sortBy, page.Results[limit-1])
+	}
+	if page.Results == nil {
+		page.Results = make([]ListResult, 0)
+	}
+
+	return page, nil
+}
+
+// memoryListCursor decodes a cursor into the fields of a result its sort compares.
+func memoryListCursor(sortBy string, after string) (ListResult, error) {
+	cursor, err := decodeListCursor(after)
+	if err != nil {
+		return ListResult{}, err
+	}
+
+	result := ListResult{List: model.List{ID: cursor.ID}}
+	switch sortBy {
+	case ListSortCreated, ListSortUpdated:
+		t, err := time.Parse(time.RFC3339Nano, cursor.Value)
+		if err != nil {
+			return result, ErrInvalidCursor
+		}
+		result.CreatedAt, result.UpdatedAt = t, t
+	case ListSortCustom:
+		position, err := strconv.ParseInt(cursor.Value, 10, 32)
+		if err != nil {
+			return result, ErrInvalidCursor
+		}
+		result.Position = int32(position)
+	case ListSortRelevance:
+		if result.Rank, err = strconv.ParseFloat(cursor.Value, 64); err != nil {
+			return result, ErrInvalidCursor
+		}
+	default:
+		result.Name = cursor.Value
+	}
+
+	return result, nil
+}
+
+// listBefore reports whether a comes before b in a sort. Ties are broken by ID, like
+// listOrder does.
+func listBefore(sortBy string, a ListResult, b ListResult) bool {
+	switch sortBy {
+	case ListSortCreated, ListSortUpdated:
+		ta, tb := a.CreatedAt, b.CreatedAt
+		if sortBy == ListSortUpdated {
+			ta, tb = a.UpdatedAt, b.UpdatedAt
+		}
+		if !ta.Equal(tb) {
+			return ta.After(tb)
+		}
+		return a.ID > b.ID
+	case ListSortCustom:
+		if a.Position != b.Position {
+			return a.Position < b.Position
+		}
+	case ListSortRelevance:
+		if a.Rank != b.Rank {
+			return a.Rank > b.Rank
+		}
+	default:
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+	}
+	return a.ID < b.ID
+}
+
+// listMatchesFilter reports whether a list is one a filter selects.
+func (d *memoryData) listMatchesFilter(list model.List, filter ListFilter) bool {
+	if name := strings.ToLower(strings.TrimSpace(filter.NameContains)); name != "" && !strings.Contains(strings.ToLower(list.Name), name) {
+		return false
+	}
+	if !inRange(list.CreatedAt, filter.CreatedFrom, filter.CreatedTo) || !inRange(list.UpdatedAt, filter.UpdatedFrom, filter.UpdatedTo) {
+		return false
+	}
+	if filter.HasIncompleteItems {
+		incomplete := false
+		for _, item := range d.listItems(list.ID) {
+			incomplete = incomplete || item.CompletedAt == nil
+		}
+		if !incomplete {
+			return false
+		}
+	}
+	if filter.TagID != 0 && !d.listTags[model.ListTag{ListID: list.ID, TagID: filter.TagID}] {
+		return false
+	}
+	return true
+}
+
+// inRange reports whether t is in a time range that includes its start but not its end.
+// A nil start or end leaves that side open.
+func inRange(t time.Time, from *time.Time, to *time.Time) bool {
+	return (from == nil || !t.Before(*from)) && (to == nil || t.Before(*to))
+}
+
+// listItems returns a list's items outside the trash, in order.
+func (d *memoryData) listItems(listId int64) []model.Item {
+	var items []model.Item
+	for _, item := range d.items {
+		if item.ListID == listId && item.DeletedAt == nil {
+			items = append(items, item)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Position != items[j].Position {
+			return items[i].Position < items[j].Position
+		}
+		return items[i].ID < items[j].ID
+	})
+	return items
+}
+
+// memorySearch approximates websearch_to_tsquery: every word must match and no excluded
+// word may. Words match the start of a word in the text, so "apple" finds "apples", and
+// quoted phrases match anywhere in it.
+type memorySearch struct {
+	terms    []string
+	excluded []string
+}
+
+func newMemorySearch(search string) *memorySearch {
+	s := &memorySearch{}
+
+	for i, part := range strings.Split(strings.ToLower(search), `"`) {
+		if i%2 == 1 {
+			if phrase := strings.TrimSpace(part); phrase != "" {
+				s.terms = append(s.terms, phrase)
+			}
+			continue
+		}
+		for _, word := range strings.Fields(part) {
+			if excluded, ok := strings.CutPrefix(word, "-"); ok && excluded != "" {
+				s.excluded = append(s.excluded, excluded)
+			} else if word != "or" && word != "-" {
+				s.terms = append(s.terms, word)
+			}
+		}
+	}
+
+	return s
+}
+
+// matches reports whether a name matches the search.
+func (s *memorySearch) matches(name string) bool {
+	name = strings.ToLower(name)
+	for _, term := range s.excluded {
+		if s.termMatches(name, term) {
+			return false
+		}
+	}
+	for _, term := range s.terms {
+		if !s.termMatches(name, term) {
+			return false
+		}
+	}
+	return len(s.terms) > 0
+}
+
+func (s *memorySearch) termMatches(name string, term string) bool {
+	if strings.Contains(term, " ") {
+		return strings.Contains(name, term)
+	}
+	for _, word := range strings.FieldsFunc(name, isNotWordRune) {
+		if strings.HasPrefix(word, term) {
+			return true
+		}
+	}
+	return false
+}
+
+func isNotWordRune(r rune) bool {
+	return !(r == '\'' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r > 127)
+}
+
+// highlight marks the words in a name that match a search term.
+func (s *memorySearch) highlight(name string) string {
+	var b strings.Builder
+	word := 0
+	flush := func(end int) {
+		if w := name[word:end]; w != "" {
+			lower := strings.ToLower(w)
+			for _, term := range s.terms {
+				if !strings.Contains(term, " ") && strings.HasPrefix(lower, term) {
+					w = highlightStart + w + highlightStop
+					break
+				}
+			}
+			b.WriteString(w)
+		}
+	}
+	for i, r := range name {
+		if isNotWordRune(r) && !(r >= 'A' && r <= 'Z') {
+			flush(i)
+			b.WriteRune(r)
+			word = i + len(string(r))
+		}
+	}
+	flush(len(name))
+	return b.String()
+}
+
+// matchList returns how well a list or its items match the search, and the snippet to show
+// for it, like newListSearch. ok is false if neither does.
+func (s *memorySearch) matchList(list model.List, items []model.Item) (rank float64, snippet string, ok bool) {
+	var matching []string
+	for _, item := range items {
+		if s.matches(item.Name) {
+			matching = append(matching, s.highlight(item.Name))
+		}
+	}
+	if len(matching) > 0 {
+		rank += 0.5
+	}
+
+	if s.matches(list.Name) {
+		return rank + 1, s.highlight(list.Name), true
+	}
+	return rank, strings.Join(matching, snippetSeparator), len(matching) > 0
+}
+
+func (m *MemoryRepository) GetListById(ctx context.Context, id int64) (model.List, error) {
+	defer m.lock()()
+	return m.data.getList(id)
+}
+
+func (d *memoryData) getList(id int64) (model.List, error) {
+	list, ok := d.lists[id]
+	if !ok || list.DeletedAt != nil {
+		return model.List{}, sql.ErrNoRows
+	}
+	return list, nil
+}
+
+// listNameTaken reports whether a list other than exceptId in a workspace has a name.
+func (d *memoryData) listNameTaken(workspaceId int64, name string, exceptId int64) bool {
+	for _, list := range d.lists {
+		if list.WorkspaceID == workspaceId && list.Name == name && list.ID != exceptId && list.DeletedAt == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// nextListPosition returns the position at the end of a workspace's custom order.
+func (d *memoryData) nextListPosition(workspaceId int64) int32 {
+	var position int32
+	for _, list := range d.lists {
+		if list.WorkspaceID == workspaceId && list.DeletedAt == nil {
+			position = max(position, list.Position)
+		}
+	}
+	return position + 1
+}
+
+func (m *MemoryRepository) CreateList(ctx context.Context, workspaceId int64, ownerId int64, name string) (model.List, error) {
+	name = strings.Clone(name)
+
+	var result model.List
+
+	err := m.atomically(func(d *memoryData) error {
+		if d.listNameTaken(workspaceId, name, 0) {
+			return ErrListNameTaken
+		}
+
+		now := memoryNow()
+		result = model.List{
+			ID:          d.nextId(),
+			Name:        name,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+			OwnerID:     ownerId,
+			WorkspaceID: workspaceId,
+			Position:    d.nextListPosition(workspaceId),
+			Version:     1,
+		}
+		d.lists[result.ID] = result
+
+		return d.recordChange(ctx, listChange("list.create", nil, &result))
+	})
+
+	return result, err
+}
+
+func (m *MemoryRepository) UpdateListById(ctx context.Context, id int64, name string, version int32) (model.List, error) {
+	name = strings.Clone(name)
+
+	var result model.List
+
+	err := m.atomically(func(d *memoryData) error {
+		before, err := d.getList(id)
+		if err != nil {
+			return err
+		}
+		result = before
+
+		if before.Version != version {
+			return ErrVersionConflict
+		}
+		if before.Name == name {
+			return nil
+		}
+		if d.listNameTaken(before.WorkspaceID, name, id) {
+			return ErrListNameTaken
+		}
+
+		result.Name = name
+		result.UpdatedAt = memoryNow()
+		result.Version++
+		d.lists[id] = result
+
+		return d.recordChange(ctx, listChange("list.update", &before, &result))
+	})
+
+	return result, err
+}
+
+func (m *MemoryRepository) DeleteListById(ctx context.Context, id int64) error {
+	return m.atomically(func(d *memoryData) error {
+		before, err := d.getList(id)
+		if err != nil {
+			return nil
+		}
+
+		after := before
+		now := memoryNow()
+		after.DeletedAt = &now
+		after.Version++
+		d.lists[id] = after
+
+		return d.recordChange(ctx, listChange("list.delete", &before, &after))
+	})
+}
+
+func (m *MemoryRepository) GetDeletedList(ctx context.Context, id int64) (model.List, error) {
+	defer m.lock()()
+
+	list, ok := m.data.lists[id]
+	if !ok || list.DeletedAt == nil {
+		return model.List{}, sql.ErrNoRows
+	}
+	return list, nil
+}
+
+func (m *MemoryRepository) FilterDeletedLists(ctx context.Context, workspaceId int64) ([]model.List, error) {
+	defer m.lock()()
+
+	results := make([]model.List, 0)
+	for _, list := range m.data.lists {
+		if list.WorkspaceID == workspaceId && list.DeletedAt != nil {
+			results = append(results, list)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if !a.DeletedAt.Equal(*b.DeletedAt) {
+			return a.DeletedAt.After(*b.DeletedAt)
+		}
+		return a.ID > b.ID
+	})
+
+	return results, nil
+}
+
+func (m *MemoryRepository) RestoreList(ctx context.Context, id int64) (model.List, error) {
+	var result model.List
+
+	err := m.atomically(func(d *memoryData) error {
+		before, ok := d.lists[id]
+		if !ok || before.DeletedAt == nil {
+			return sql.ErrNoRows
+		}
+		result = before
+
+		if d.listNameTaken(before.WorkspaceID, before.Name, id) {
+			return ErrListNameTaken
+		}
+
+		result.DeletedAt = nil
+		result.Position = d.nextListPosition(before.WorkspaceID)
+		result.UpdatedAt = memoryNow()
+		result.Version++
+		d.lists[id] = result
+
+		return d.recordChange(ctx, listChange("list.restore", &before, &result))
+	})
+
+	return result, err
+}
+
+func (m *MemoryRepository) PurgeList(ctx context.Context, id int64) error {
+	return m.atomically(func(d *memoryData) error {
+		list, ok := d.lists[id]
+		if !ok || list.DeletedAt == nil {
+			return sql.ErrNoRows
+		}
+
+		if err := d.recordChange(ctx, listChange("list.purge", &list, nil)); err != nil {
+			return err
+		}
+
+		d.deleteList(id)
+		return nil
+	})
+}
+
+// deleteList deletes a list and everything that belongs to it.
+func (d *memoryData) deleteList(id int64) {
+	delete(d.lists, id)
+
+	for _, item := range d.items {
+		if item.ListID == id {
+			delete(d.items, item.ID)
+		}
+	}
+	for key := range d.listMembers {
+		if key.ID == id {
+			delete(d.listMembers, key)
+		}
+	}
+	for _, invitation := range d.invitations {
+		if invitation.ListID == id {
+			delete(d.invitations, invitation.ID)
+		}
+	}
+	for _, link := range d.shareLinks {
+		if link.ListID == id {
+			delete(d.shareLinks, link.ID)
+		}
+	}
+	for listTag := range d.listTags {
+		if listTag.ListID == id {
+			delete(d.listTags, listTag)
+		}
+	}
+}
+
+func (m *MemoryRepository) CreateItem(ctx context.Context, listId int64, name string) (model.Item, error) {
+	name = strings.Clone(name)
+
+	var result model.Item
+
+	err := m.atomically(func(d *memoryData) error {
+		if _, ok := d.lists[listId]; !ok {
+			return sql.ErrNoRows
+		}
+
+		var position int32
+		for _, item := range d.listItems(listId) {
+			position = max(position, item.Position)
+		}
+
+		now := memoryNow()
+		result = model.Item{
+			ID:        d.nextId(),
+			ListID:    listId,
+			Position:  position + 1,
+			Name:      name,
+			CreatedAt: now,
+			UpdatedAt: now,
+			Version:   1,
+		}
+		d.items[result.ID] = result
+
+		return d.recordItemChange(ctx, "item.create", result.ID, nil, newItemState(result))
+	})
+
+	return result, err
+}
+
+func (m *MemoryRepository) UpdateItemById(ctx context.Context, id int64, name string, version int32) (model.Item, error) {
+	name = strings.Clone(name)
+
+	return m.updateItem(ctx, id, version, "item.update", func(item *model.Item) {
+		item.Name = name
+	})
+}
+
+func (m *MemoryRepository) SetItemCompleted(ctx context.Context, id int64, completed bool, version int32) (model.Item, error) {
+	action := "item.reopen"
+	if completed {
+		action = "item.complete"
+	}
+
+	return m.updateItem(ctx, id, version, action, func(item *model.Item) {
+		if !completed {
+			item.CompletedAt = nil
+		} else if item.CompletedAt == nil {
+			now := memoryNow()
+			item.CompletedAt = &now
+		}
+	})
+}
+
+// updateItem applies a change to an item at a version, recording it as action.
+func (m *MemoryRepository) updateItem(ctx context.Context, id int64, version int32, action string, update func(item *model.Item)) (model.Item, error) {
+	var result model.Item
+
+	err := m.atomically(func(d *memoryData) error {
+		before, ok := d.items[id]
+		if !ok || before.DeletedAt != nil {
+			return sql.ErrNoRows
+		}
+		result = before
+
+		if before.Version != version {
+			return ErrVersionConflict
+		}
+
+		update(&result)
+		result.UpdatedAt = memoryNow()
+		result.Version++
+		d.items[id] = result
+
+		return d.recordItemChange(ctx, action, id, newItemState(before), newItemState(result))
+	})
+
+	return result, err
+}
+
+func (m *MemoryRepository) DeleteItemById(ctx context.Context, id int64) error {
+	return m.atomically(func(d *memoryData) error {
+		item, ok := d.items[id]
+		if !ok || item.DeletedAt != nil {
+			return nil
+		}
+
+		now := memoryNow()
+		item.DeletedAt = &now
+		item.Version++
+		d.items[id] = item
+
+		return d.recordItemChange(ctx, "item.delete", id, newItemState(item), nil)
+	})
+}
+
+func (m *MemoryRepository) RestoreItem(ctx context.Context, id int64) (model.Item, error) {
+	var result model.Item
+
+	err := m.atomically(func(d *memoryData) error {
+		var ok bool
+		result, ok = d.items[id]
+		if !ok || result.DeletedAt == nil {
+			return sql.ErrNoRows
+		}
+
+		result.DeletedAt = nil
+		result.UpdatedAt = memoryNow()
+		result.Version++
+		d.items[id] = result
+
+		return d.recordItemChange(ctx, "item.restore", id, nil, newItemState(result))
+	})
+
+	return result, err
+}
+
+func (m *MemoryRepository) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	defer m.lock()()
+
+	var purged int64
+	for _, item := range m.data.items {
+		if item.DeletedAt != nil && item.DeletedAt.Before(before) {
+			delete(m.data.items, item.ID)
+			purged++
+		}
+	}
+	for _, list := range m.data.lists {
+		if list.DeletedAt != nil && list.DeletedAt.Before(before) {
+			m.data.deleteList(list.ID)
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+func (m *MemoryRepository) FilterSharedLists(ctx context.Context, workspaceId int64, userId int64) ([]SharedList, error) {
+	defer m.lock()()
+
+	results := make([]SharedList, 0)
+	for key, member := range m.data.listMembers {
+		list, ok := m.data.lists[key.ID]
+		if key.UserID != userId || !ok || list.DeletedAt != nil {
+			continue
+		}
+		if list.WorkspaceID == workspaceId {
+			continue
+		}
+
+		results = append(results, SharedList{
+			List:       list,
+			Permission: member.Permission,
+			OwnerEmail: m.data.users[list.OwnerID].Email,
+		})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Name < results[j].Name
+	})
+
+	return results, nil
+}
+
+func (m *MemoryRepository) GetListPermission(ctx context.Context, workspaceId int64, listId int64, userId int64) (string, error) {
+	defer m.lock()()
+
+	list, err := m.data.getList(listId)
+	if err != nil {
+		return "", err
+	}
+
+	var permission string
+	if list.WorkspaceID == workspaceId || list.OwnerID == userId {
+		role := m.data.workspaceMembers[memberKey{list.WorkspaceID, userId}].Role
+		permission = WorkspaceListPermission(role, list, userId)
+	}
+
+	memberPermission := m.data.listMembers[memberKey{listId, userId}].Permission
+	if permissionLevels[memberPermission] > permissionLevels[permission] {
+		permission = memberPermission
+	}
+
+	return permission, nil
+}
+
+func (m *MemoryRepository) FilterListMembers(ctx context.Context, listId int64) ([]Member, error) {
+	defer m.lock()()
+
+	results := make([]Member, 0)
+	for key, member := range m.data.listMembers {
+		if key.ID == listId {
+			results = append(results, Member{ListMember: member, Email: m.data.users[key.UserID].Email})
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Email < results[j].Email
+	})
+
+	return results, nil
+}
+
+func (m *MemoryRepository) FilterListInvitations(ctx context.Context, listId int64) ([]model.ListInvitation, error) {
+	defer m.lock()()
+
+	results := make([]model.ListInvitation, 0)
+	for _, invitation := range m.data.invitations {
+		if invitation.ListID == listId {
+			results = append(results, invitation)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Email < results[j].Email
+	})
+
+	return results, nil
+}
+
+func (m *MemoryRepository) ShareList(ctx context.Context, listId int64, email string, permission string, invitedById int64) (bool, error) {
+	email = strings.Clone(email)
+	permission = strings.Clone(permission)
+
+	invited := false
+
+	err := m.atomically(func(d *memoryData) error {
+		user, err := d.getUserByEmail(email)
+		if err != nil {
+			invited = true
+			return d.invite(ctx, listId, email, permission, invitedById)
+		}
+
+		list, err := d.getList(listId)
+		if err != nil {
+			return err
+		}
+		if list.OwnerID == user.ID {
+			return ErrAlreadyOwner
+		}
+
+		key := memberKey{listId, user.ID}
+		member, isMember := d.listMembers[key]
+		var before any
+		if isMember {
+			before = sharingState{UserID: user.ID, Email: user.Email, Permission: member.Permission}
+		} else {
+			member = model.ListMember{ListID: listId, UserID: user.ID, CreatedAt: memoryNow()}
+		}
+		member.Permission = permission
+		d.listMembers[key] = member
+
+		return d.recordListEvent(ctx, "list.share", listId, before,
+			sharingState{UserID: user.ID, Email: user.Email, Permission: permission})
+	})
+
+	return invited, err
+}
+
+// invite invites an email to a list, or changes the permission it was invited with.
+func (d *memoryData) invite(ctx context.Context, listId int64, email string, permission string, invitedById int64) error {
+	if _, ok := d.lists[listId]; !ok {
+		return sql.ErrNoRows
+	}
+
+	invitation := model.ListInvitation{ID: d.nextId(), ListID: listId, Email: email, CreatedAt: memoryNow()}
+	for _, existing := range d.invitations {
+		if existing.ListID == listId && existing.Email == email {
+			invitation = existing
+		}
+	}
+	invitation.Permission = permission
+	invitation.InvitedByID = invitedById
+	d.invitations[invitation.ID] = invitation
+
+	return d.recordListEvent(ctx, "list.invite", listId, nil, sharingState{Email: email, Permission: permission})
+}
+
+func (m *MemoryRepository) RevokeListMember(ctx context.Context, listId int64, userId int64) error {
+	return m.atomically(func(d *memoryData) error {
+		return d.revokeListMember(ctx, listId, userId)
+	})
+}
+
+func (d *memoryData) revokeListMember(ctx context.Context, listId int64, userId int64) error {
+	key := memberKey{listId, userId}
+	member, ok := d.listMembers[key]
+	if !ok {
+		return nil
+	}
+
+	delete(d.listMembers, key)
+	return d.recordListEvent(ctx, "list.unshare", listId, sharingState{UserID: userId, Permission: member.Permission}, nil)
+}
+
+func (m *MemoryRepository) DeleteListInvitation(ctx context.Context, listId int64, id int64) error {
+	return m.atomically(func(d *memoryData) error {
+		invitation, ok := d.invitations[id]
+		if !ok || invitation.ListID != listId {
+			return nil
+		}
+
+		delete(d.invitations, id)
+		return d.recordListEvent(ctx, "list.uninvite", listId,
+			sharingState{Email: invitation.Email, Permission: invitation.Permission}, nil)
+	})
+}
+
+func (m *MemoryRepository) TransferListOwnership(ctx context.Context, listId int64, newOwnerId int64) (model.List, error) {
+	var list model.List
+
+	err := m.atomically(func(d *memoryData) error {
+		var err error
+		if list, err = d.getList(listId); err != nil {
+			return err
+		}
+		before := list
+
+		if list.OwnerID == newOwnerId {
+			return ErrAlreadyOwner
+		}
+		if _, ok := d.listMembers[memberKey{listId, newOwnerId}]; !ok {
+			return ErrNotMember
+		}
+
+		// a list in its owner's personal workspace moves to the new owner's, but a list in a
+		// team workspace stays there, so only another member of it can own the list
+		workspaceId := list.WorkspaceID
+		workspace, ok := d.workspaces[workspaceId]
+		if !ok {
+			return sql.ErrNoRows
+		}
+		if workspace.PersonalUserID != nil && *workspace.PersonalUserID == list.OwnerID {
+			workspaceId = d.ensurePersonalWorkspace(newOwnerId).ID
+		} else if _, ok = d.workspaceMembers[memberKey{workspaceId, newOwnerId}]; !ok {
+			return ErrNotWorkspaceMember
+		}
+
+		if d.listNameTaken(workspaceId, list.Name, listId) {
+			return ErrListNameTaken
+		}
+
+		if err = d.revokeListMember(ctx, listId, newOwnerId); err != nil {
+			return err
+		}
+
+		d.listMembers[memberKey{listId, list.OwnerID}] = model.ListMember{
+			ListID:     listId,
+			UserID:     list.OwnerID,
+			Permission: PermissionEditor,
+			CreatedAt:  memoryNow(),
+		}
+
+		list.OwnerID = newOwnerId
+		list.WorkspaceID = workspaceId
+		list.UpdatedAt = memoryNow()
+		list.Version++
+		d.lists[listId] = list
+
+		return d.recordChange(ctx, listChange("list.transfer", &before, &list))
+	})
+
+	return list, err
+}
+
+func (m *MemoryRepository) AcceptListInvitations(ctx context.Context, user model.AppUser) error {
+	return m.atomically(func(d *memoryData) error {
+		for _, invitation := range d.invitations {
+			if invitation.Email != user.Email {
+				continue
+			}
+			delete(d.invitations, invitation.ID)
+
+			list, ok := d.lists[invitation.ListID]
+			key := memberKey{invitation.ListID, user.ID}
+			if _, isMember := d.listMembers[key]; !ok || isMember || list.OwnerID == user.ID {
+				continue
+			}
+
+			d.listMembers[key] = model.ListMember{
+				ListID:     invitation.ListID,
+				UserID:     user.ID,
+				Permission: invitation.Permission,
+				CreatedAt:  memoryNow(),
+			}
+
+			err := d.recordListEvent(ctx, "list.share.accept", invitation.ListID,
+				sharingState{Email: user.Email, Permission: invitation.Permission},
+				sharingState{UserID: user.ID, Email: user.Email, Permission: invitation.Permission})
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// isActive reports whether a share link has been neither revoked nor expired.
+func isActive(link model.ShareLink) bool {
+	return link.RevokedAt == nil && (link.ExpiresAt == nil || link.ExpiresAt.After(time.Now()))
+}
+
+func (m *MemoryRepository) CreateShareLink(ctx context.Context, listId int64, createdById int64, expiresAt *time.Time) (model.ShareLink, error) {
+	var result model.ShareLink
+
+	token, err := newShareToken()
+	if err != nil {
+		return result, err
+	}
+
+	err = m.atomically(func(d *memoryData) error {
+		if _, ok := d.lists[listId]; !ok {
+			return sql.ErrNoRows
+		}
+		if err := d.revokeShareLinks(ctx, listId); err != nil {
+			return err
+		}
+
+		result = model.ShareLink{
+			ID:          d.nextId(),
+			ListID:      listId,
+			Token:       token,
+			CreatedByID: createdById,
+			ExpiresAt:   expiresAt,
+			CreatedAt:   memoryNow(),
+		}
+		d.shareLinks[result.ID] = result
+
+		return d.recordListEvent(ctx, "list.link_create", listId, nil, shareLinkState{ID: result.ID, ExpiresAt: result.ExpiresAt})
+	})
+
+	return result, err
+}
+
+func (m *MemoryRepository) FilterActiveShareLinks(ctx context.Context, workspaceId int64) ([]model.ShareLink, error) {
+	defer m.lock()()
+
+	results := make([]model.ShareLink, 0)
+	for _, link := range sortedValues(m.data.shareLinks, func(a, b model.ShareLink) bool { return a.ID < b.ID }) {
+		list := m.data.lists[link.ListID]
+		if list.WorkspaceID == workspaceId && isActive(link) {
+			results = append(results, link)
+		}
+	}
+
+	return results, nil
+}
+
+func (m *MemoryRepository) GetActiveShareLink(ctx context.Context, listId int64) (model.ShareLink, error) {
+	defer m.lock()()
+
+	for _, link := range sortedValues(m.data.shareLinks, func(a, b model.ShareLink) bool { return a.ID < b.ID }) {
+		if link.ListID == listId && isActive(link) {
+			return link, nil
+		}
+	}
+	return model.ShareLink{}, sql.ErrNoRows
+}
+
+func (m *MemoryRepository) GetListByShareToken(ctx context.Context, token string) (model.List, error) {
+	defer m.lock()()
+
+	for _, link := range m.data.shareLinks {
+		if link.Token == token && isActive(link) {
+			return m.data.getList(link.ListID)
+		}
+	}
+	return model.List{}, sql.ErrNoRows
+}
+
+func (m *MemoryRepository) RevokeShareLinks(ctx context.Context, listId int64) error {
+	return m.atomically(func(d *memoryData) error {
+		return d.revokeShareLinks(ctx, listId)
+	})
+}
+
+func (d *memoryData) revokeShareLinks(ctx context.Context, listId int64) error {
+	for _, link := range sortedValues(d.shareLinks, func(a, b model.ShareLink) bool { return a.ID < b.ID }) {
+		if link.ListID != listId || link.RevokedAt != nil {
+			continue
+		}
+
+		now := memoryNow()
+		link.RevokedAt = &now
+		d.shareLinks[link.ID] = link
+
+		err := d.recordListEvent(ctx, "list.link_revoke", listId, shareLinkState{ID: link.ID, ExpiresAt: link.ExpiresAt}, nil)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MemoryRepository) FilterTags(ctx context.Context, workspaceId int64) ([]TagCount, error) {
+	defer m.lock()()
+
+	results := make([]TagCount, 0)
+	for _, tag := range sortedValues(m.data.tags, func(a, b model.Tag) bool { return a.Name < b.Name }) {
+		if tag.WorkspaceID != workspaceId {
+			continue
+		}
+
+		count := TagCount{Tag: tag}
+		for listTag := range m.data.listTags {
+			if list, ok := m.data.lists[listTag.ListID]; ok && listTag.TagID == tag.ID && list.DeletedAt == nil {
+				count.Lists++
+			}
+		}
+		results = append(results, count)
+	}
+
+	return results, nil
+}
+
+func (m *MemoryRepository) GetTag(ctx context.Context, workspaceId int64, id int64) (model.Tag, error) {
+	defer m.lock()()
+	return m.data.getTag(workspaceId, id)
+}
+
+func (d *memoryData) getTag(workspaceId int64, id int64) (model.Tag, error) {
+	tag, ok := d.tags[id]
+	if !ok || tag.WorkspaceID != workspaceId {
+		return model.Tag{}, sql.ErrNoRows
+	}
+	return tag, nil
+}
+
+// tagNameTaken reports whether a tag other than exceptId in a workspace has a name.
+func (d *memoryData) tagNameTaken(workspaceId int64, name string, exceptId int64) bool {
+	for _, tag := range d.tags {
+		if tag.WorkspaceID == workspaceId && tag.Name == name && tag.ID != exceptId {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MemoryRepository) CreateTag(ctx context.Context, workspaceId int64, name string, color string) (model.Tag, error) {
+	name = strings.Clone(name)
+	color = strings.Clone(color)
+
+	var result model.Tag
+
+	err := m.atomically(func(d *memoryData) error {
+		if d.tagNameTaken(workspaceId, name, 0) {
+			return ErrTagNameTaken
+		}
+
+		now := memoryNow()
+		result = model.Tag{
+			ID:          d.nextId(),
+			WorkspaceID: workspaceId,
+			Name:        name,
+			Color:       color,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		d.tags[result.ID] = result
+
+		return d.recordChange(ctx, tagChange("tag.create", nil, &result))
+	})
+
+	return result, err
+}
+
+func (m *MemoryRepository) UpdateTag(ctx context.Context, workspaceId int64, id int64, name string, color string) (model.Tag, error) {
+	name = strings.Clone(name)
+	color = strings.Clone(color)
+
+	var result model.Tag
+
+	err := m.atomically(func(d *memoryData) error {
+		if d.tagNameTaken(workspaceId, name, id) {
+			return ErrTagNameTaken
+		}
+
+		before, err := d.getTag(workspaceId, id)
+		if err != nil {
+			return err
+		}
+
+		result = before
+		result.Name = name
+		result.Color = color
+		result.UpdatedAt = memoryNow()
+		d.tags[id] = result
+
+		return d.recordChange(ctx, tagChange("tag.update", &before, &result))
+	})
+
+	return result, err
+}
+
+func (m *MemoryRepository) MergeTags(ctx context.Context, workspaceId int64, sourceId int64, targetId int64) (model.Tag, error) {
+	var target model.Tag
+
+	err := m.atomically(func(d *memoryData) error {
+		source, err := d.getTag(workspaceId, sourceId)
+		if err != nil || sourceId == targetId {
+			return sql.ErrNoRows
+		}
+		if target, err = d.getTag(workspaceId, targetId); err != nil {
+			return err
+		}
+
+		for listTag := range d.listTags {
+			if listTag.TagID == sourceId {
+				d.listTags[model.ListTag{ListID: listTag.ListID, TagID: targetId}] = true
+			}
+		}
+
+		err = d.recordChange(ctx, change{
+			Action:      "tag.merge",
+			EntityType:  EntityTag,
+			EntityID:    sourceId,
+			WorkspaceID: &workspaceId,
+			Before:      newTagState(source),
+			After:       newTagState(target),
+		})
+		if err != nil {
+			return err
+		}
+
+		return d.deleteTag(ctx, workspaceId, sourceId)
+	})
+
+	return target, err
+}
+
+func (m *MemoryRepository) DeleteTag(ctx context.Context, workspaceId int64, id int64) error {
+	return m.atomically(func(d *memoryData) error {
+		return d.deleteTag(ctx, workspaceId, id)
+	})
+}
+
+func (d *memoryData) deleteTag(ctx context.Context, workspaceId int64, id int64) error {
+	tag, err := d.getTag(workspaceId, id)
+	if err != nil {
+		return nil
+	}
+
+	delete(d.tags, id)
+	for listTag := range d.listTags {
+		if listTag.TagID == id {
+			delete(d.listTags, listTag)
+		}
+	}
+
+	return d.recordChange(ctx, tagChange("tag.delete", &tag, nil))
+}
+
+func (m *MemoryRepository) FilterListTags(ctx context.Context, listIds []int64) (ListTags, error) {
+	defer m.lock()()
+
+	results := make(ListTags, len(listIds))
+	for _, tag := range sortedValues(m.data.tags, func(a, b model.Tag) bool { return a.Name < b.Name }) {
+		for _, listId := range listIds {
+			if m.data.listTags[model.ListTag{ListID: listId, TagID: tag.ID}] {
+				results[listId] = append(results[listId], tag)
+			}
+		}
+	}
+
+	return results, nil
+}
+
+func (m *MemoryRepository) SetListTags(ctx context.Context, listId int64, tagIds []int64) error {
+	return m.atomically(func(d *memoryData) error {
+		list, ok := d.lists[listId]
+		if !ok {
+			return sql.ErrNoRows
+		}
+
+		before := d.listTagIds(listId)
+		for _, id := range before {
+			delete(d.listTags, model.ListTag{ListID: listId, TagID: id})
+		}
+		for _, id := range tagIds {
+			if tag, ok := d.tags[id]; ok && tag.WorkspaceID == list.WorkspaceID {
+				d.listTags[model.ListTag{ListID: listId, TagID: id}] = true
+			}
+		}
+
+		return d.recordListEvent(ctx, "list.tag", listId, before, d.listTagIds(listId))
+	})
+}
+
+// listTagIds returns the IDs of the tags on a list, in order.
+func (d *memoryData) listTagIds(listId int64) []int64 {
+	ids := make([]int64, 0)
+	for listTag := range d.listTags {
+		if listTag.ListID == listId {
+			ids = append(ids, listTag.TagID)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
@@ -0,0 +1,85 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"github.com/joho/godotenv"
+	"htmxtodo/internal/testdb"
+	"testing"
+)
+
+// The migration tests load rows the way they were before a migration, and check that the
+// migration carries them over rather than leaving them unreachable.
+
+const listOwnersMigration = "20261018130000"
+
+func loadTestEnv(t *testing.T) {
+	if err := godotenv.Load("../../.env.test"); err != nil {
+		t.Logf("Not loading .env.test file: %s", err.Error())
+	}
+}
+
+// expectVisibleTo checks that a user sees lists in their personal workspace.
+func expectVisibleTo(t *testing.T, conn *sql.DB, email string, names ...string) {
+	t.Helper()
+	ctx := context.Background()
+	r := New(conn, nil)
+
+	user, err := r.GetUserByEmail(ctx, email)
+	if err != nil {
+		t.Fatalf("expected user %s: %v", email, err)
+	}
+	personal, err := r.EnsurePersonalWorkspace(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := r.FilterLists(ctx, ListQuery{WorkspaceID: personal.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make([]string, 0, len(page.Results))
+	for _, result := range page.Results {
+		got = append(got, result.Name)
+	}
+	expectNames(t, got, names...)
+}
+
+func TestMigrateListOwners(t *testing.T) {
+	loadTestEnv(t)
+
+	t.Run("to the first user", func(t *testing.T) {
+		conn := testdb.NewBefore(t, listOwnersMigration)
+		exec(t, conn, `INSERT INTO list (name) VALUES ('Groceries'), ('Chores')`)
+		exec(t, conn, `INSERT INTO app_user (email) VALUES ('first@example.com'), ('second@example.com')`)
+
+		testdb.MigrateFrom(t, conn, listOwnersMigration)
+		expectVisibleTo(t, conn, "first@example.com", "Chores", "Groceries")
+		expectVisibleTo(t, conn, "second@example.com")
+	})
+
+	t.Run("to a placeholder", func(t *testing.T) {
+		conn := testdb.NewBefore(t, listOwnersMigration)
+		exec(t, conn, `INSERT INTO list (name) VALUES ('Groceries')`)
+
+		testdb.MigrateFrom(t, conn, listOwnersMigration)
+		expectVisibleTo(t, conn, "legacy-lists@htmxtodo.invalid", "Groceries")
+	})
+
+	t.Run("with nothing to carry over", func(t *testing.T) {
+		conn := testdb.NewBefore(t, listOwnersMigration)
+		testdb.MigrateFrom(t, conn, listOwnersMigration)
+
+		var users int
+		if err := conn.QueryRow(`SELECT COUNT(*) FROM app_user`).Scan(&users); err != nil || users != 0 {
+			t.Fatalf("expected no placeholder user without lists, got %d, %v", users, err)
+		}
+	})
+}
+
+func exec(t *testing.T, conn *sql.DB, query string) {
+	t.Helper()
+	if _, err := conn.Exec(query); err != nil {
+		t.Fatal(err)
+	}
+}
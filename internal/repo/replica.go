@@ -0,0 +1,89 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+type usePrimaryKey struct{}
+
+// WithPrimary returns a context whose reads go to the primary instead of the replica,
+// such as for a user who has just written something and expects to see it, before the
+// replica may have caught up.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, usePrimaryKey{}, true)
+}
+
+func usePrimary(ctx context.Context) bool {
+	primary, _ := ctx.Value(usePrimaryKey{}).(bool)
+	return primary
+}
+
+// reader returns the repository a read should run on: one using the replica, unless
+// there is none, r is in a transaction, or ctx asks for the primary. Read methods start
+// with r = r.reader(ctx); anything that writes must not.
+func (r *repository) reader(ctx context.Context) *repository {
+	if r.replica == nil || r.dbtx != r.db || usePrimary(ctx) {
+		return r
+	}
+	return &repository{
+		db:      r.db,
+		dbtx:    r.replica,
+		replica: r.replica,
+	}
+}
+
+// replicaDB runs queries on a read replica, and on the primary instead when the replica
+// cannot serve them, so that losing the replica only loses its extra capacity. Anything
+// else goes to the primary.
+type replicaDB struct {
+	primary *sql.DB
+	replica *sql.DB
+}
+
+func (db replicaDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return db.primary.ExecContext(ctx, query, args...)
+}
+
+func (db replicaDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return db.primary.PrepareContext(ctx, query)
+}
+
+func (db replicaDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	rows, err := db.replica.QueryContext(ctx, query, args...)
+	if replicaUnavailable(ctx, err) {
+		return db.primary.QueryContext(ctx, query, args...)
+	}
+	return rows, err
+}
+
+func (db replicaDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	row := db.replica.QueryRowContext(ctx, query, args...)
+	if replicaUnavailable(ctx, row.Err()) {
+		return db.primary.QueryRowContext(ctx, query, args...)
+	}
+	return row
+}
+
+// replicaUnavailable reports whether a query failed because of the replica rather than
+// the query: it could not be reached, is shutting down or overloaded, or cancelled the
+// query to replay changes from the primary. Errors with no SQLSTATE come from the driver
+// or the network.
+func replicaUnavailable(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil || errors.Is(err, sql.ErrNoRows) {
+		return false
+	}
+
+	code, _ := postgresError(err)
+	switch {
+	case code == "", code == "40001":
+		return true
+	case strings.HasPrefix(code, "08"), strings.HasPrefix(code, "53"), strings.HasPrefix(code, "57"):
+		// connection exception, insufficient resources, operator intervention
+		return true
+	default:
+		return false
+	}
+}
@@ -3,17 +3,90 @@ package repo
 import (
 	"context"
 	"database/sql"
+	"errors"
 	. "github.com/go-jet/jet/v2/postgres"
+	"github.com/go-jet/jet/v2/qrm"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/lib/pq"
 	"htmxtodo/gen/htmxtodo_dev/public/model"
 	. "htmxtodo/gen/htmxtodo_dev/public/table"
+	"strings"
+	"time"
 )
 
+// ErrVersionConflict is returned when a list or item is updated from a stale copy, because
+// someone else changed it after the version the caller read.
+var ErrVersionConflict = errors.New("changed by someone else since it was read")
+
+// listNameConstraint keeps list names unique within a workspace, outside the trash.
+const listNameConstraint = "list_workspace_id_name_key"
+
 type Repository interface {
-	FilterLists(ctx context.Context) ([]model.List, error)
+	WithTx(ctx context.Context, fn func(Repository) error) error
+
+	FilterLists(ctx context.Context, query ListQuery) (ListPage, error)
 	GetListById(ctx context.Context, id int64) (model.List, error)
-	CreateList(ctx context.Context, name string) (model.List, error)
-	UpdateListById(ctx context.Context, id int64, name string) (model.List, error)
+	CreateList(ctx context.Context, workspaceId int64, ownerId int64, name string) (model.List, error)
+	UpdateListById(ctx context.Context, id int64, name string, version int32) (model.List, error)
 	DeleteListById(ctx context.Context, id int64) error
+
+	GetDeletedList(ctx context.Context, id int64) (model.List, error)
+	FilterDeletedLists(ctx context.Context, workspaceId int64) ([]model.List, error)
+	RestoreList(ctx context.Context, id int64) (model.List, error)
+	PurgeList(ctx context.Context, id int64) error
+	CreateItem(ctx context.Context, listId int64, name string) (model.Item, error)
+	UpdateItemById(ctx context.Context, id int64, name string, version int32) (model.Item, error)
+	SetItemCompleted(ctx context.Context, id int64, completed bool, version int32) (model.Item, error)
+	DeleteItemById(ctx context.Context, id int64) error
+	RestoreItem(ctx context.Context, id int64) (model.Item, error)
+	PurgeDeleted(ctx context.Context, before time.Time) (int64, error)
+
+	FilterSharedLists(ctx context.Context, workspaceId int64, userId int64) ([]SharedList, error)
+	GetListPermission(ctx context.Context, workspaceId int64, listId int64, userId int64) (string, error)
+	FilterListMembers(ctx context.Context, listId int64) ([]Member, error)
+	FilterListInvitations(ctx context.Context, listId int64) ([]model.ListInvitation, error)
+	ShareList(ctx context.Context, listId int64, email string, permission string, invitedById int64) (invited bool, err error)
+	RevokeListMember(ctx context.Context, listId int64, userId int64) error
+	DeleteListInvitation(ctx context.Context, listId int64, id int64) error
+	TransferListOwnership(ctx context.Context, listId int64, newOwnerId int64) (model.List, error)
+	AcceptListInvitations(ctx context.Context, user model.AppUser) error
+
+	CreateShareLink(ctx context.Context, listId int64, createdById int64, expiresAt *time.Time) (model.ShareLink, error)
+	FilterActiveShareLinks(ctx context.Context, workspaceId int64) ([]model.ShareLink, error)
+	GetActiveShareLink(ctx context.Context, listId int64) (model.ShareLink, error)
+	GetListByShareToken(ctx context.Context, token string) (model.List, error)
+	RevokeShareLinks(ctx context.Context, listId int64) error
+
+	FilterTags(ctx context.Context, workspaceId int64) ([]TagCount, error)
+	GetTag(ctx context.Context, workspaceId int64, id int64) (model.Tag, error)
+	CreateTag(ctx context.Context, workspaceId int64, name string, color string) (model.Tag, error)
+	UpdateTag(ctx context.Context, workspaceId int64, id int64, name string, color string) (model.Tag, error)
+	MergeTags(ctx context.Context, workspaceId int64, sourceId int64, targetId int64) (model.Tag, error)
+	DeleteTag(ctx context.Context, workspaceId int64, id int64) error
+	FilterListTags(ctx context.Context, listIds []int64) (ListTags, error)
+	SetListTags(ctx context.Context, listId int64, tagIds []int64) error
+
+	FilterWorkspaces(ctx context.Context, userId int64) ([]UserWorkspace, error)
+	GetWorkspaceRole(ctx context.Context, workspaceId int64, userId int64) (string, error)
+	GetUserWorkspace(ctx context.Context, workspaceId int64, userId int64) (UserWorkspace, error)
+	EnsurePersonalWorkspace(ctx context.Context, user model.AppUser) (model.Workspace, error)
+	CreateWorkspace(ctx context.Context, name string, ownerId int64) (model.Workspace, error)
+	FilterWorkspaceMembers(ctx context.Context, workspaceId int64) ([]WorkspaceUser, error)
+	AddWorkspaceMember(ctx context.Context, workspaceId int64, email string, role string) (model.AppUser, error)
+	RemoveWorkspaceMember(ctx context.Context, workspaceId int64, userId int64) error
+
+	GetUserById(ctx context.Context, id int64) (model.AppUser, error)
+	GetUserByEmail(ctx context.Context, email string) (model.AppUser, error)
+	FindOrCreateUser(ctx context.Context, email string) (model.AppUser, error)
+	FilterUsers(ctx context.Context, query string) ([]model.AppUser, error)
+	UpdateUserRole(ctx context.Context, id int64, role string) (model.AppUser, error)
+	UpdateUserDisabled(ctx context.Context, id int64, disabled bool) (model.AppUser, error)
+	RevokeUserSessions(ctx context.Context, id int64) (model.AppUser, error)
+
+	CreateAuditEvent(ctx context.Context, event model.AuditEvent) (model.AuditEvent, error)
+	FilterAuditEvents(ctx context.Context, query AuditQuery) ([]AuditEntry, error)
+
+	GetStats(ctx context.Context) (Stats, error)
 }
 
 // DBTX is an interface that matches the standard library sql.DB and sql.Tx interfaces.
@@ -24,19 +97,27 @@ type DBTX interface {
 	QueryRowContext(context.Context, string, ...interface{}) *sql.Row
 }
 
-func New(db *sql.DB) Repository {
-	return &repository{
+// New returns a repository using the primary db, and for reads that may lag behind it, the
+// replica if it is not nil.
+func New(db *sql.DB, replica *sql.DB) Repository {
+	r := &repository{
 		// db is the database connection
 		db: db,
 		// dbtx is either the database connection or a transaction, allows nested repository calls
 		// to use the same transaction
 		dbtx: db,
 	}
+	if replica != nil {
+		r.replica = replicaDB{primary: db, replica: replica}
+	}
+	return r
 }
 
 type repository struct {
 	db   *sql.DB
 	dbtx DBTX
+	// replica is used by reads outside transactions, if there is one
+	replica DBTX
 }
 
 // withTransaction returns a new repository that uses the provided transaction as dbtx
@@ -47,88 +128,181 @@ func (r *repository) withTransaction(tx *sql.Tx) *repository {
 	}
 }
 
-func (r *repository) FilterLists(ctx context.Context) ([]model.List, error) {
-	stmt := List.SELECT(List.AllColumns).ORDER_BY(List.Name.ASC())
+// isUniqueViolation reports whether err is a violation of the named unique constraint or
+// index.
+func isUniqueViolation(err error, constraint string) bool {
+	code, violated := postgresError(err)
+	return code == "23505" && violated == constraint
+}
 
-	var results []model.List
-	if err := stmt.QueryContext(ctx, r.dbtx, &results); err != nil {
-		return nil, err
+// postgresError returns the SQLSTATE code and constraint of an error from the server,
+// whichever driver the pool uses, or empty strings if err did not come from it.
+func postgresError(err error) (code string, constraint string) {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return string(pqErr.Code), pqErr.Constraint
 	}
-
-	if results == nil {
-		results = make([]model.List, 0)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code, pgErr.ConstraintName
 	}
+	return "", ""
+}
 
-	return results, nil
+// queryRow runs a statement expected to return one row into dest. Unlike QueryContext, an
+// empty result is reported as sql.ErrNoRows, which handlers turn into 404 Not Found.
+func (r *repository) queryRow(ctx context.Context, stmt Statement, dest interface{}) error {
+	err := stmt.QueryContext(ctx, r.dbtx, dest)
+	if errors.Is(err, qrm.ErrNoRows) {
+		return sql.ErrNoRows
+	}
+	return err
 }
 
-func (r *repository) GetListById(ctx context.Context, id int64) (model.List, error) {
-	stmt := List.SELECT(List.AllColumns).WHERE(List.ID.EQ(Int(id))).LIMIT(1)
+// FilterLists returns a page of the lists in a workspace. Lists shared with a user from
+// elsewhere come from FilterSharedLists. It returns ErrInvalidCursor if query.After is not a
+// cursor FilterLists returned.
+func (r *repository) FilterLists(ctx context.Context, query ListQuery) (ListPage, error) {
+	r = r.reader(ctx)
 
-	var result model.List
-	if err := stmt.QueryContext(ctx, r.dbtx, &result); err != nil {
-		return result, err
+	condition := List.WorkspaceID.EQ(Int(query.WorkspaceID)).
+		AND(List.DeletedAt.IS_NULL()).
+		AND(listFilterCondition(query.Filter))
+	projection := ProjectionList{List.AllColumns}
+
+	sort := query.Sort
+	var rank FloatExpression
+	if search := strings.TrimSpace(query.Search); search != "" {
+		s := newListSearch(search)
+		condition = condition.AND(s.matches)
+		projection = append(projection, s.rank.AS("list_result.rank"), s.snippet.AS("list_result.snippet"))
+		rank = s.rank
+		if !IsListSort(sort) {
+			sort = ListSortRelevance
+		}
+	} else if sort == ListSortRelevance || !IsListSort(sort) {
+		sort = ListSortName
 	}
 
-	if result.ID == 0 {
-		return result, sql.ErrNoRows
+	orderBy, after, err := listOrder(sort, rank, query.After)
+	if err != nil {
+		return ListPage{}, err
+	}
+	if after != nil {
+		condition = condition.AND(after)
 	}
 
-	return result, nil
-}
+	limit := query.Limit
+	if limit <= 0 {
+		limit = DefaultListPageSize
+	}
 
-func (r *repository) CreateList(ctx context.Context, name string) (model.List, error) {
-	var result model.List
+	// one extra list tells us whether there is another page
+	stmt := SELECT(projection).
+		FROM(List).
+		WHERE(condition).
+		ORDER_BY(orderBy...).
+		LIMIT(limit + 1)
 
-	stmt := List.INSERT(List.Name).
-		VALUES(name).
-		RETURNING(List.AllColumns)
+	var results []ListResult
+	if err = stmt.QueryContext(ctx, r.dbtx, &results); err != nil {
+		return ListPage{}, err
+	}
 
-	if err := stmt.QueryContext(ctx, r.dbtx, &result); err != nil {
-		return result, err
+	page := ListPage{Results: results}
+	if int64(len(results)) > limit {
+		page.Results = results[:limit]
+		page.Next = encodeListCursor(sort, page.Results[limit-1])
+	}
+	if page.Results == nil {
+		page.Results = make([]ListResult, 0)
 	}
 
-	return result, nil
+	return page, nil
 }
 
-func (r *repository) UpdateListById(ctx context.Context, id int64, name string) (model.List, error) {
-	tx, err := r.db.BeginTx(ctx, nil)
-	if err != nil {
-		panic(err) // unrecoverable
-	}
-	defer tx.Rollback()
-	rtx := r.withTransaction(tx)
+// GetListById returns a list, or sql.ErrNoRows if there is no such list or it is in the
+// trash.
+func (r *repository) GetListById(ctx context.Context, id int64) (model.List, error) {
+	r = r.reader(ctx)
 
-	existing, err := rtx.GetListById(ctx, id)
+	stmt := List.SELECT(List.AllColumns).WHERE(List.ID.EQ(Int(id)).AND(List.DeletedAt.IS_NULL())).LIMIT(1)
 
-	if existing.Name == name {
-		// No update needed
-		return existing, nil
-	}
+	var result model.List
+	err := r.queryRow(ctx, stmt, &result)
+	return result, err
+}
 
-	updateStmt := List.UPDATE(List.Name, List.UpdatedAt).
-		SET(name, NOW()).
-		WHERE(List.ID.EQ(Int(id))).
-		RETURNING(List.AllColumns)
+// CreateList adds a list to the end of a workspace. It returns ErrListNameTaken if the
+// workspace already has a list with the name.
+func (r *repository) CreateList(ctx context.Context, workspaceId int64, ownerId int64, name string) (model.List, error) {
+	var result model.List
 
-	if err = updateStmt.QueryContext(ctx, tx, &existing); err != nil {
-		return existing, err
-	}
+	// new lists go at the end of the workspace's custom order
+	position := RawInt("SELECT COALESCE(MAX(position), 0) + 1 FROM list WHERE workspace_id = #workspace_id AND deleted_at IS NULL",
+		RawArgs{"#workspace_id": workspaceId})
 
-	if err = tx.Commit(); err != nil {
-		return existing, err
-	}
+	stmt := List.INSERT(List.Name, List.OwnerID, List.WorkspaceID, List.Position).
+		VALUES(name, ownerId, workspaceId, position).
+		RETURNING(List.AllColumns)
+
+	err := r.inTransaction(ctx, func(rtx *repository) error {
+		err := stmt.QueryContext(ctx, rtx.dbtx, &result)
+		if isUniqueViolation(err, listNameConstraint) {
+			return ErrListNameTaken
+		}
+		if err != nil {
+			return err
+		}
+		return rtx.recordListChange(ctx, "list.create", nil, &result)
+	})
 
-	return existing, nil
+	return result, err
 }
 
-func (r *repository) DeleteListById(ctx context.Context, id int64) error {
-	deleteStmt := List.DELETE().
-		WHERE(List.ID.EQ(Int(id)))
+// UpdateListById renames a list, if it is still at the version the caller last read. If it
+// is not, it returns the current list with ErrVersionConflict, so that the caller can show
+// both names. It returns ErrListNameTaken if another list in the workspace has the name.
+func (r *repository) UpdateListById(ctx context.Context, id int64, name string, version int32) (model.List, error) {
+	var result model.List
 
-	if _, err := deleteStmt.ExecContext(ctx, r.dbtx); err != nil {
-		return err
-	}
+	err := r.inTransaction(ctx, func(rtx *repository) error {
+		existing, err := rtx.GetListById(ctx, id)
+		if err != nil {
+			return err
+		}
+		result = existing
+
+		if existing.Version != version {
+			return ErrVersionConflict
+		}
+		if existing.Name == name {
+			// No update needed
+			return nil
+		}
+
+		updateStmt := List.UPDATE(List.Name, List.UpdatedAt, List.Version).
+			SET(name, NOW(), List.Version.ADD(Int(1))).
+			WHERE(List.ID.EQ(Int(id)).AND(List.Version.EQ(Int32(version)))).
+			RETURNING(List.AllColumns)
+
+		err = rtx.queryRow(ctx, updateStmt, &result)
+		if errors.Is(err, sql.ErrNoRows) {
+			// changed since it was read above
+			if result, err = rtx.GetListById(ctx, id); err != nil {
+				return err
+			}
+			return ErrVersionConflict
+		}
+		if isUniqueViolation(err, listNameConstraint) {
+			return ErrListNameTaken
+		}
+		if err != nil {
+			return err
+		}
+
+		return rtx.recordListChange(ctx, "list.update", &existing, &result)
+	})
 
-	return nil
+	return result, err
 }
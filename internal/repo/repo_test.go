@@ -0,0 +1,1285 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"htmxtodo/gen/htmxtodo_dev/public/model"
+	"htmxtodo/internal/testdb"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// The conformance tests run against every Repository implementation, so that the in-memory
+// one can stand in for Postgres in handler tests. Each test gets an empty repository, and
+// against Postgres a schema of its own, so they run in parallel.
+
+func TestMemory(t *testing.T) {
+	runConformance(t, func(t *testing.T) Repository {
+		return NewMemory()
+	})
+}
+
+func TestPostgres(t *testing.T) {
+	loadTestEnv(t)
+
+	runConformance(t, func(t *testing.T) Repository {
+		return New(testdb.New(t), nil)
+	})
+}
+
+// TestPostgresReplica runs the conformance tests with reads going through the replica
+// routing, to a replica that is the primary itself, and to one that is down, which reads
+// must fall back from.
+func TestPostgresReplica(t *testing.T) {
+	loadTestEnv(t)
+
+	t.Run("up", func(t *testing.T) {
+		runConformance(t, func(t *testing.T) Repository {
+			db := testdb.New(t)
+			return New(db, db)
+		})
+	})
+
+	t.Run("down", func(t *testing.T) {
+		down, err := sql.Open("postgres", "postgres://127.0.0.1:1/down?sslmode=disable&connect_timeout=1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			_ = down.Close()
+		})
+
+		runConformance(t, func(t *testing.T) Repository {
+			return New(testdb.New(t), down)
+		})
+	})
+}
+
+func TestReplicaRouting(t *testing.T) {
+	primary, replica := &sql.DB{}, &sql.DB{}
+	r := New(primary, replica).(*repository)
+	ctx := context.Background()
+
+	if _, ok := r.reader(ctx).dbtx.(replicaDB); !ok {
+		t.Error("expected reads to go to the replica")
+	}
+	if r.reader(WithPrimary(ctx)).dbtx != DBTX(primary) {
+		t.Error("expected reads to go to the primary when asked to")
+	}
+	if tx := r.withTransaction(&sql.Tx{}); tx.reader(ctx) != tx {
+		t.Error("expected reads in a transaction to stay in it")
+	}
+	if r := New(primary, nil).(*repository); r.reader(ctx) != r {
+		t.Error("expected reads to go to the primary without a replica")
+	}
+}
+
+func runConformance(t *testing.T, newRepo func(t *testing.T) Repository) {
+	tests := map[string]func(t *testing.T, f *fixture){
+		"users":                testUsers,
+		"lists":                testLists,
+		"list versions":        testListVersions,
+		"list pages":           testListPages,
+		"list search":          testListSearch,
+		"list page ties":       testListPageTies,
+		"list filters":         testListFilters,
+		"trash":                testTrash,
+		"purge":                testPurge,
+		"items":                testItems,
+		"sharing":              testSharing,
+		"invitations":          testInvitations,
+		"ownership transfer":   testOwnershipTransfer,
+		"share links":          testShareLinks,
+		"tags":                 testTags,
+		"workspaces":           testWorkspaces,
+		"audit":                testAudit,
+		"transactions":         testTransactions,
+		"concurrent creations": testConcurrentCreations,
+		"concurrent renames":   testConcurrentRenames,
+		"concurrent edits":     testConcurrentEdits,
+		"fixtures":             testFixtures,
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			r := newRepo(t)
+			test(t, newFixture(t, r))
+		})
+	}
+}
+
+// fixture is a user with a team workspace of their own.
+type fixture struct {
+	t         *testing.T
+	ctx       context.Context
+	r         Repository
+	user      model.AppUser
+	workspace model.Workspace
+}
+
+var fixtureCount atomic.Int64
+
+// uniqueEmail returns an email no other user in a test has.
+func uniqueEmail(name string) string {
+	return fmt.Sprintf("%s-%d@example.com", name, fixtureCount.Add(1))
+}
+
+func newFixture(t *testing.T, r Repository) *fixture {
+	ctx := context.Background()
+	f := &fixture{t: t, ctx: ctx, r: r}
+
+	f.user = f.newUser("owner")
+
+	var err error
+	if f.workspace, err = r.CreateWorkspace(ctx, "Team", f.user.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	return f
+}
+
+func (f *fixture) newUser(name string) model.AppUser {
+	f.t.Helper()
+	user, err := f.r.FindOrCreateUser(f.ctx, uniqueEmail(name))
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return user
+}
+
+func (f *fixture) createList(name string) model.List {
+	f.t.Helper()
+	list, err := f.r.CreateList(f.ctx, f.workspace.ID, f.user.ID, name)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return list
+}
+
+func (f *fixture) createItem(list model.List, name string) model.Item {
+	f.t.Helper()
+	item, err := f.r.CreateItem(f.ctx, list.ID, name)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return item
+}
+
+// listNames returns the names of the lists FilterLists returns for a query in the
+// fixture's workspace.
+func (f *fixture) listNames(query ListQuery) ([]string, string) {
+	f.t.Helper()
+	query.WorkspaceID = f.workspace.ID
+	page, err := f.r.FilterLists(f.ctx, query)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+
+	names := make([]string, 0, len(page.Results))
+	for _, result := range page.Results {
+		names = append(names, result.Name)
+	}
+	return names, page.Next
+}
+
+func expectNames(t *testing.T, got []string, want ...string) {
+	t.Helper()
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func expectError(t *testing.T, err error, want error) {
+	t.Helper()
+	if !errors.Is(err, want) {
+		t.Fatalf("expected %v, got %v", want, err)
+	}
+}
+
+func testUsers(t *testing.T, f *fixture) {
+	again, err := f.r.FindOrCreateUser(f.ctx, f.user.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != f.user.ID {
+		t.Fatalf("expected the existing user %d, got %d", f.user.ID, again.ID)
+	}
+	if f.user.Role != RoleUser {
+		t.Fatalf("expected new users to have the user role, got %q", f.user.Role)
+	}
+
+	_, err = f.r.GetUserByEmail(f.ctx, uniqueEmail("nobody"))
+	expectError(t, err, sql.ErrNoRows)
+
+	user, err := f.r.UpdateUserRole(f.ctx, f.user.ID, RoleAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Role != RoleAdmin {
+		t.Fatalf("expected the admin role, got %q", user.Role)
+	}
+
+	user, err = f.r.UpdateUserDisabled(f.ctx, f.user.ID, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.DisabledAt == nil {
+		t.Fatal("expected the user to be disabled")
+	}
+
+	_, err = f.r.UpdateUserRole(f.ctx, -1, RoleAdmin)
+	expectError(t, err, sql.ErrNoRows)
+}
+
+func testLists(t *testing.T, f *fixture) {
+	groceries := f.createList("Groceries")
+	if groceries.Version != 1 || groceries.Position != 1 {
+		t.Fatalf("expected version 1 at position 1, got %d at %d", groceries.Version, groceries.Position)
+	}
+	chores := f.createList("Chores")
+	if chores.Position != 2 {
+		t.Fatalf("expected position 2, got %d", chores.Position)
+	}
+
+	_, err := f.r.CreateList(f.ctx, f.workspace.ID, f.user.ID, "Groceries")
+	expectError(t, err, ErrListNameTaken)
+
+	got, err := f.r.GetListById(f.ctx, groceries.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "Groceries" || got.OwnerID != f.user.ID || got.WorkspaceID != f.workspace.ID {
+		t.Fatalf("unexpected list %+v", got)
+	}
+
+	_, err = f.r.UpdateListById(f.ctx, chores.ID, "Groceries", chores.Version)
+	expectError(t, err, ErrListNameTaken)
+
+	names, next := f.listNames(ListQuery{})
+	expectNames(t, names, "Chores", "Groceries")
+	if next != "" {
+		t.Fatalf("expected one page, got cursor %q", next)
+	}
+
+	if err = f.r.DeleteListById(f.ctx, groceries.ID); err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.r.GetListById(f.ctx, groceries.ID)
+	expectError(t, err, sql.ErrNoRows)
+
+	// deleted lists free their names
+	f.createList("Groceries")
+}
+
+func testListVersions(t *testing.T, f *fixture) {
+	list := f.createList("Draft")
+
+	renamed, err := f.r.UpdateListById(f.ctx, list.ID, "Final", list.Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renamed.Name != "Final" || renamed.Version != list.Version+1 {
+		t.Fatalf("expected Final at version %d, got %s at %d", list.Version+1, renamed.Name, renamed.Version)
+	}
+
+	current, err := f.r.UpdateListById(f.ctx, list.ID, "Stale", list.Version)
+	expectError(t, err, ErrVersionConflict)
+	if current.Name != "Final" || current.Version != renamed.Version {
+		t.Fatalf("expected the current list with the conflict, got %+v", current)
+	}
+
+	// renaming to the same name changes nothing
+	same, err := f.r.UpdateListById(f.ctx, list.ID, "Final", renamed.Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if same.Version != renamed.Version {
+		t.Fatalf("expected version %d, got %d", renamed.Version, same.Version)
+	}
+
+	// going through the trash is a change too
+	if err = f.r.DeleteListById(f.ctx, list.ID); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := f.r.RestoreList(f.ctx, list.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.r.UpdateListById(f.ctx, list.ID, "Stale", renamed.Version)
+	expectError(t, err, ErrVersionConflict)
+	if _, err = f.r.UpdateListById(f.ctx, list.ID, "Current", restored.Version); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = f.r.UpdateListById(f.ctx, -1, "Missing", 1)
+	expectError(t, err, sql.ErrNoRows)
+}
+
+func testListPages(t *testing.T, f *fixture) {
+	for _, name := range []string{"e", "c", "a", "d", "b"} {
+		f.createList(name)
+	}
+
+	var all []string
+	after := ""
+	for page := 0; ; page++ {
+		names, next := f.listNames(ListQuery{Limit: 2, After: after})
+		if len(names) > 2 {
+			t.Fatalf("expected at most 2 lists, got %q", names)
+		}
+		all = append(all, names...)
+		if next == "" {
+			break
+		}
+		if page > 3 {
+			t.Fatal("too many pages")
+		}
+		after = next
+	}
+	expectNames(t, all, "a", "b", "c", "d", "e")
+
+	names, _ := f.listNames(ListQuery{Sort: ListSortCustom})
+	expectNames(t, names, "e", "c", "a", "d", "b")
+
+	names, _ = f.listNames(ListQuery{Sort: ListSortCreated, Limit: 3})
+	expectNames(t, names, "b", "d", "a")
+
+	_, err := f.r.FilterLists(f.ctx, ListQuery{WorkspaceID: f.workspace.ID, After: "not a cursor"})
+	expectError(t, err, ErrInvalidCursor)
+
+	// a cursor only works in the order it came from
+	_, next := f.listNames(ListQuery{Limit: 2})
+	_, err = f.r.FilterLists(f.ctx, ListQuery{WorkspaceID: f.workspace.ID, Sort: ListSortCreated, After: next})
+	expectError(t, err, ErrInvalidCursor)
+}
+
+// allListNames pages through every list a query returns, a page of one at a time.
+func (f *fixture) allListNames(query ListQuery) []string {
+	f.t.Helper()
+	query.Limit = 1
+
+	var all []string
+	for {
+		names, next := f.listNames(query)
+		all = append(all, names...)
+		if next == "" {
+			return all
+		}
+		if len(all) > 10 {
+			f.t.Fatalf("too many pages, got %q", all)
+		}
+		query.After = next
+	}
+}
+
+func testListPageTies(t *testing.T, f *fixture) {
+	// lists created in one transaction have the same creation time, and lists matching a
+	// search the same way rank the same, so the pages fall back to their IDs
+	err := f.r.WithTx(f.ctx, func(r Repository) error {
+		for _, name := range []string{"Apple one", "Apple two", "Apple six"} {
+			if _, err := r.CreateList(f.ctx, f.workspace.ID, f.user.ID, name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectNames(t, f.allListNames(ListQuery{Sort: ListSortCreated}), "Apple six", "Apple two", "Apple one")
+	expectNames(t, f.allListNames(ListQuery{Sort: ListSortUpdated}), "Apple six", "Apple two", "Apple one")
+	expectNames(t, f.allListNames(ListQuery{Search: "apple"}), "Apple one", "Apple two", "Apple six")
+}
+
+func TestListCursors(t *testing.T) {
+	result := ListResult{
+		List: model.List{
+			ID:        7,
+			Name:      "Groceries",
+			CreatedAt: time.Date(2026, 10, 18, 12, 0, 0, 123456000, time.UTC),
+			UpdatedAt: time.Date(2026, 10, 18, 13, 0, 0, 654321000, time.UTC),
+			Position:  3,
+		},
+		Rank: 0.0607927,
+	}
+	want := map[string]string{
+		ListSortName:      "Groceries",
+		ListSortCreated:   "2026-10-18T12:00:00.123456Z",
+		ListSortUpdated:   "2026-10-18T13:00:00.654321Z",
+		ListSortCustom:    "3",
+		ListSortRelevance: "0.0607927",
+	}
+
+	for sort, value := range want {
+		cursor, err := decodeListCursor(encodeListCursor(sort, result))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cursor.Value != value || cursor.ID != result.ID {
+			t.Fatalf("expected %s cursor %q at %d, got %+v", sort, value, result.ID, cursor)
+		}
+	}
+
+	for _, invalid := range []string{"%%%", "bm90IGpzb24"} {
+		_, err := decodeListCursor(invalid)
+		expectError(t, err, ErrInvalidCursor)
+	}
+}
+
+func testListSearch(t *testing.T, f *fixture) {
+	f.createList("Weekend groceries")
+	party := f.createList("Party")
+	apples := f.createItem(party, "Buy apples")
+	f.createList("Apple pie")
+	f.createList("Chores")
+
+	names, _ := f.listNames(ListQuery{Search: "apple"})
+	expectNames(t, names, "Apple pie", "Party")
+
+	names, _ = f.listNames(ListQuery{Search: "apple -pie"})
+	expectNames(t, names, "Party")
+
+	names, _ = f.listNames(ListQuery{Search: "groceries", Sort: ListSortName})
+	expectNames(t, names, "Weekend groceries")
+
+	page, err := f.r.FilterLists(f.ctx, ListQuery{WorkspaceID: f.workspace.ID, Search: "apples"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Results) == 0 || page.Results[len(page.Results)-1].Snippet == "" {
+		t.Fatalf("expected a snippet for the matching item, got %+v", page.Results)
+	}
+
+	// results page in rank order
+	var all []string
+	after := ""
+	for {
+		names, next := f.listNames(ListQuery{Search: "apple", Limit: 1, After: after})
+		all = append(all, names...)
+		if next == "" {
+			break
+		}
+		after = next
+	}
+	expectNames(t, all, "Apple pie", "Party")
+
+	// lists in other workspaces, and deleted items, do not match
+	other, err := f.r.CreateWorkspace(f.ctx, "Other", f.user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.r.CreateList(f.ctx, other.ID, f.user.ID, "Apple crumble"); err != nil {
+		t.Fatal(err)
+	}
+	if err = f.r.DeleteItemById(f.ctx, apples.ID); err != nil {
+		t.Fatal(err)
+	}
+	names, _ = f.listNames(ListQuery{Search: "apple"})
+	expectNames(t, names, "Apple pie")
+}
+
+func TestSnippetParts(t *testing.T) {
+	snippet := "Buy " + highlightStart + "apples" + highlightStop + " and " + highlightStart + "pears"
+	want := []SnippetPart{
+		{Text: "Buy "},
+		{Text: "apples", Highlight: true},
+		{Text: " and "},
+		{Text: "pears", Highlight: true},
+	}
+	if got := SnippetParts(snippet); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+	if got := SnippetParts(""); len(got) != 0 {
+		t.Fatalf("expected no parts, got %+v", got)
+	}
+}
+
+func testListFilters(t *testing.T, f *fixture) {
+	done := f.createList("Done")
+	item := f.createItem(done, "Finished")
+	todo := f.createList("Todo")
+	f.createItem(todo, "Not yet")
+	f.createList("Empty")
+
+	names, _ := f.listNames(ListQuery{Filter: ListFilter{NameContains: "DO"}})
+	expectNames(t, names, "Done", "Todo")
+
+	names, _ = f.listNames(ListQuery{Filter: ListFilter{HasIncompleteItems: true}})
+	expectNames(t, names, "Done", "Todo")
+
+	completed, err := f.r.SetItemCompleted(f.ctx, item.ID, true, item.Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if completed.CompletedAt == nil || completed.Version != item.Version+1 {
+		t.Fatalf("expected the item to be completed at the next version, got %+v", completed)
+	}
+	names, _ = f.listNames(ListQuery{Filter: ListFilter{HasIncompleteItems: true}})
+	expectNames(t, names, "Todo")
+
+	_, err = f.r.SetItemCompleted(f.ctx, item.ID, false, item.Version)
+	expectError(t, err, ErrVersionConflict)
+	reopened, err := f.r.SetItemCompleted(f.ctx, item.ID, false, completed.Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reopened.CompletedAt != nil {
+		t.Fatalf("expected the item to be reopened, got %+v", reopened)
+	}
+	names, _ = f.listNames(ListQuery{Filter: ListFilter{HasIncompleteItems: true}})
+	expectNames(t, names, "Done", "Todo")
+
+	// deleted items are neither complete nor incomplete
+	if err = f.r.DeleteItemById(f.ctx, reopened.ID); err != nil {
+		t.Fatal(err)
+	}
+	names, _ = f.listNames(ListQuery{Filter: ListFilter{HasIncompleteItems: true}})
+	expectNames(t, names, "Todo")
+
+	future := time.Now().Add(time.Hour)
+	names, _ = f.listNames(ListQuery{Filter: ListFilter{CreatedFrom: &future}})
+	expectNames(t, names)
+}
+
+func testTrash(t *testing.T, f *fixture) {
+	list := f.createList("Old")
+	f.createList("Newer")
+
+	if err := f.r.DeleteListById(f.ctx, list.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, err := f.r.FilterDeletedLists(f.ctx, f.workspace.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 1 || deleted[0].ID != list.ID || deleted[0].DeletedAt == nil {
+		t.Fatalf("expected the deleted list in the trash, got %+v", deleted)
+	}
+
+	// a new list takes the name while the old one is in the trash
+	f.createList("Old")
+	_, err = f.r.RestoreList(f.ctx, list.ID)
+	expectError(t, err, ErrListNameTaken)
+
+	_, err = f.r.GetListById(f.ctx, list.ID)
+	expectError(t, err, sql.ErrNoRows)
+
+	other := f.createList("Other")
+	if err = f.r.DeleteListById(f.ctx, other.ID); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := f.r.RestoreList(f.ctx, other.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.DeletedAt != nil {
+		t.Fatal("expected the list to be restored")
+	}
+
+	if err = f.r.PurgeList(f.ctx, list.ID); err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.r.GetDeletedList(f.ctx, list.ID)
+	expectError(t, err, sql.ErrNoRows)
+
+	// only lists in the trash can be purged
+	err = f.r.PurgeList(f.ctx, other.ID)
+	expectError(t, err, sql.ErrNoRows)
+}
+
+func testPurge(t *testing.T, f *fixture) {
+	// lists with items go into the trash and out of it with their items
+	list := f.createList("Full")
+	f.createItem(list, "Kept with the list")
+	kept := f.createList("Kept")
+	deletedItem := f.createItem(kept, "Deleted")
+	keptItem := f.createItem(kept, "Kept")
+	if err := f.r.DeleteListById(f.ctx, list.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.r.DeleteItemById(f.ctx, deletedItem.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	purged, err := f.r.PurgeDeleted(f.ctx, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purged != 0 {
+		t.Fatalf("expected nothing recent to be purged, got %d", purged)
+	}
+	if _, err = f.r.GetDeletedList(f.ctx, list.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	purged, err = f.r.PurgeDeleted(f.ctx, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purged != 2 {
+		t.Fatalf("expected the list and the item to be purged, got %d", purged)
+	}
+	_, err = f.r.GetDeletedList(f.ctx, list.ID)
+	expectError(t, err, sql.ErrNoRows)
+	_, err = f.r.RestoreItem(f.ctx, deletedItem.ID)
+	expectError(t, err, sql.ErrNoRows)
+
+	// what is not in the trash stays
+	if _, err = f.r.UpdateItemById(f.ctx, keptItem.ID, "Still here", keptItem.Version); err != nil {
+		t.Fatal(err)
+	}
+	names, _ := f.listNames(ListQuery{})
+	expectNames(t, names, "Kept")
+}
+
+func testItems(t *testing.T, f *fixture) {
+	list := f.createList("Items")
+	first := f.createItem(list, "First")
+	second := f.createItem(list, "Second")
+	if second.Position != first.Position+1 {
+		t.Fatalf("expected position %d, got %d", first.Position+1, second.Position)
+	}
+
+	updated, err := f.r.UpdateItemById(f.ctx, first.ID, "Renamed", first.Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Name != "Renamed" || updated.Version != first.Version+1 {
+		t.Fatalf("unexpected item %+v", updated)
+	}
+
+	_, err = f.r.UpdateItemById(f.ctx, first.ID, "Stale", first.Version)
+	expectError(t, err, ErrVersionConflict)
+
+	if err = f.r.DeleteItemById(f.ctx, first.ID); err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.r.UpdateItemById(f.ctx, first.ID, "Deleted", updated.Version+1)
+	expectError(t, err, sql.ErrNoRows)
+
+	restored, err := f.r.RestoreItem(f.ctx, first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.DeletedAt != nil {
+		t.Fatal("expected the item to be restored")
+	}
+
+	_, err = f.r.RestoreItem(f.ctx, second.ID)
+	expectError(t, err, sql.ErrNoRows)
+}
+
+func testSharing(t *testing.T, f *fixture) {
+	list := f.createList("Shared")
+	friend := f.newUser("friend")
+
+	invited, err := f.r.ShareList(f.ctx, list.ID, friend.Email, PermissionViewer, f.user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if invited {
+		t.Fatal("expected an existing user to be added, not invited")
+	}
+
+	permission, err := f.r.GetListPermission(f.ctx, f.workspace.ID, list.ID, f.user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if permission != PermissionOwner {
+		t.Fatalf("expected owner, got %q", permission)
+	}
+
+	friendWorkspace, err := f.r.EnsurePersonalWorkspace(f.ctx, friend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	permission, err = f.r.GetListPermission(f.ctx, friendWorkspace.ID, list.ID, friend.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if permission != PermissionViewer {
+		t.Fatalf("expected viewer, got %q", permission)
+	}
+
+	// sharing again changes the permission
+	if _, err = f.r.ShareList(f.ctx, list.ID, friend.Email, PermissionEditor, f.user.ID); err != nil {
+		t.Fatal(err)
+	}
+	members, err := f.r.FilterListMembers(f.ctx, list.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 1 || members[0].Email != friend.Email || members[0].Permission != PermissionEditor {
+		t.Fatalf("expected the friend as an editor, got %+v", members)
+	}
+
+	shared, err := f.r.FilterSharedLists(f.ctx, friendWorkspace.ID, friend.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shared) != 1 || shared[0].ID != list.ID || shared[0].OwnerEmail != f.user.Email {
+		t.Fatalf("expected the list to be shared with the friend, got %+v", shared)
+	}
+
+	_, err = f.r.ShareList(f.ctx, list.ID, f.user.Email, PermissionEditor, f.user.ID)
+	expectError(t, err, ErrAlreadyOwner)
+
+	if err = f.r.RevokeListMember(f.ctx, list.ID, friend.ID); err != nil {
+		t.Fatal(err)
+	}
+	permission, err = f.r.GetListPermission(f.ctx, friendWorkspace.ID, list.ID, friend.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if permission != "" {
+		t.Fatalf("expected no permission, got %q", permission)
+	}
+}
+
+func testInvitations(t *testing.T, f *fixture) {
+	list := f.createList("Invited")
+	email := uniqueEmail("invitee")
+
+	invited, err := f.r.ShareList(f.ctx, list.ID, email, PermissionEditor, f.user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !invited {
+		t.Fatal("expected an unknown email to be invited")
+	}
+
+	invitations, err := f.r.FilterListInvitations(f.ctx, list.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(invitations) != 1 || invitations[0].Email != email {
+		t.Fatalf("expected one invitation for %s, got %+v", email, invitations)
+	}
+
+	user, err := f.r.FindOrCreateUser(f.ctx, email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = f.r.AcceptListInvitations(f.ctx, user); err != nil {
+		t.Fatal(err)
+	}
+
+	invitations, err = f.r.FilterListInvitations(f.ctx, list.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(invitations) != 0 {
+		t.Fatalf("expected the invitation to be accepted, got %+v", invitations)
+	}
+	members, err := f.r.FilterListMembers(f.ctx, list.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 1 || members[0].UserID != user.ID || members[0].Permission != PermissionEditor {
+		t.Fatalf("expected the invitee as an editor, got %+v", members)
+	}
+
+	// accepting is recorded with the other sharing changes
+	entries, err := f.r.FilterAuditEvents(f.ctx, AuditQuery{ListID: list.ID, Action: "list.share.accept"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].After == nil || !strings.Contains(*entries[0].After, email) {
+		t.Fatalf("expected the acceptance in the audit log, got %+v", entries)
+	}
+
+	// deleting an invitation of another list does nothing
+	other := f.createList("Other")
+	if _, err = f.r.ShareList(f.ctx, other.ID, uniqueEmail("other"), PermissionViewer, f.user.ID); err != nil {
+		t.Fatal(err)
+	}
+	invitations, _ = f.r.FilterListInvitations(f.ctx, other.ID)
+	if err = f.r.DeleteListInvitation(f.ctx, list.ID, invitations[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if remaining, _ := f.r.FilterListInvitations(f.ctx, other.ID); len(remaining) != 1 {
+		t.Fatalf("expected the invitation to remain, got %+v", remaining)
+	}
+}
+
+func testOwnershipTransfer(t *testing.T, f *fixture) {
+	list := f.createList("Transferred")
+	friend := f.newUser("friend")
+
+	_, err := f.r.TransferListOwnership(f.ctx, list.ID, friend.ID)
+	expectError(t, err, ErrNotMember)
+
+	if _, err = f.r.ShareList(f.ctx, list.ID, friend.Email, PermissionEditor, f.user.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// the list stays in the team workspace, which the friend has to belong to
+	_, err = f.r.TransferListOwnership(f.ctx, list.ID, friend.ID)
+	expectError(t, err, ErrNotWorkspaceMember)
+	if _, err = f.r.AddWorkspaceMember(f.ctx, f.workspace.ID, friend.Email, WorkspaceRoleMember); err != nil {
+		t.Fatal(err)
+	}
+
+	transferred, err := f.r.TransferListOwnership(f.ctx, list.ID, friend.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transferred.OwnerID != friend.ID || transferred.WorkspaceID != f.workspace.ID {
+		t.Fatalf("expected the friend to own the list in the same workspace, got %+v", transferred)
+	}
+
+	members, err := f.r.FilterListMembers(f.ctx, list.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 1 || members[0].UserID != f.user.ID || members[0].Permission != PermissionEditor {
+		t.Fatalf("expected the old owner to become an editor, got %+v", members)
+	}
+}
+
+func testShareLinks(t *testing.T, f *fixture) {
+	list := f.createList("Public")
+
+	_, err := f.r.GetActiveShareLink(f.ctx, list.ID)
+	expectError(t, err, sql.ErrNoRows)
+
+	link, err := f.r.CreateShareLink(f.ctx, list.ID, f.user.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := f.r.GetListByShareToken(f.ctx, link.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != list.ID {
+		t.Fatalf("expected list %d, got %d", list.ID, got.ID)
+	}
+
+	// a new link replaces the old one
+	replacement, err := f.r.CreateShareLink(f.ctx, list.ID, f.user.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.r.GetListByShareToken(f.ctx, link.Token)
+	expectError(t, err, sql.ErrNoRows)
+
+	active, err := f.r.FilterActiveShareLinks(f.ctx, f.workspace.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(active) != 1 || active[0].ID != replacement.ID {
+		t.Fatalf("expected only the replacement to be active, got %+v", active)
+	}
+
+	expired := time.Now().Add(-time.Minute)
+	expiredLink, err := f.r.CreateShareLink(f.ctx, list.ID, f.user.ID, &expired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.r.GetListByShareToken(f.ctx, expiredLink.Token)
+	expectError(t, err, sql.ErrNoRows)
+
+	if err = f.r.RevokeShareLinks(f.ctx, list.ID); err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.r.GetActiveShareLink(f.ctx, list.ID)
+	expectError(t, err, sql.ErrNoRows)
+}
+
+func testTags(t *testing.T, f *fixture) {
+	urgent, err := f.r.CreateTag(f.ctx, f.workspace.ID, "urgent", "red")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.r.CreateTag(f.ctx, f.workspace.ID, "urgent", "blue")
+	expectError(t, err, ErrTagNameTaken)
+
+	important, err := f.r.CreateTag(f.ctx, f.workspace.ID, "important", "blue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.r.UpdateTag(f.ctx, f.workspace.ID, important.ID, "urgent", "blue")
+	expectError(t, err, ErrTagNameTaken)
+
+	list := f.createList("Tagged")
+	both := f.createList("Both")
+	if err = f.r.SetListTags(f.ctx, list.ID, []int64{urgent.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if err = f.r.SetListTags(f.ctx, both.ID, []int64{urgent.ID, important.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	names, _ := f.listNames(ListQuery{Filter: ListFilter{TagID: urgent.ID}})
+	expectNames(t, names, "Both", "Tagged")
+
+	merged, err := f.r.MergeTags(f.ctx, f.workspace.ID, important.ID, urgent.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged.ID != urgent.ID {
+		t.Fatalf("expected to merge into %d, got %d", urgent.ID, merged.ID)
+	}
+	_, err = f.r.GetTag(f.ctx, f.workspace.ID, important.ID)
+	expectError(t, err, sql.ErrNoRows)
+
+	counts, err := f.r.FilterTags(f.ctx, f.workspace.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(counts) != 1 || counts[0].Lists != 2 {
+		t.Fatalf("expected urgent on both lists once, got %+v", counts)
+	}
+
+	listTags, err := f.r.FilterListTags(f.ctx, []int64{list.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listTags[list.ID]) != 1 || listTags[list.ID][0].ID != urgent.ID {
+		t.Fatalf("expected urgent on the list, got %+v", listTags)
+	}
+
+	// tags from other workspaces are ignored
+	other, err := f.r.CreateWorkspace(f.ctx, "Other", f.user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	foreign, err := f.r.CreateTag(f.ctx, other.ID, "foreign", "green")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = f.r.SetListTags(f.ctx, list.ID, []int64{foreign.ID}); err != nil {
+		t.Fatal(err)
+	}
+	listTags, _ = f.r.FilterListTags(f.ctx, []int64{list.ID})
+	if len(listTags[list.ID]) != 0 {
+		t.Fatalf("expected no tags, got %+v", listTags[list.ID])
+	}
+
+	if err = f.r.DeleteTag(f.ctx, f.workspace.ID, urgent.ID); err != nil {
+		t.Fatal(err)
+	}
+	counts, _ = f.r.FilterTags(f.ctx, f.workspace.ID)
+	if len(counts) != 0 {
+		t.Fatalf("expected no tags, got %+v", counts)
+	}
+}
+
+func testWorkspaces(t *testing.T, f *fixture) {
+	personal, err := f.r.EnsurePersonalWorkspace(f.ctx, f.user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := f.r.EnsurePersonalWorkspace(f.ctx, f.user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != personal.ID {
+		t.Fatalf("expected the same personal workspace, got %d and %d", personal.ID, again.ID)
+	}
+
+	workspaces, err := f.r.FilterWorkspaces(f.ctx, f.user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(workspaces) != 2 || !workspaces[0].IsPersonal() || workspaces[1].ID != f.workspace.ID {
+		t.Fatalf("expected the personal workspace then the team, got %+v", workspaces)
+	}
+
+	friend := f.newUser("friend")
+	if _, err = f.r.AddWorkspaceMember(f.ctx, f.workspace.ID, friend.Email, WorkspaceRoleMember); err != nil {
+		t.Fatal(err)
+	}
+	role, err := f.r.GetWorkspaceRole(f.ctx, f.workspace.ID, friend.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if role != WorkspaceRoleMember {
+		t.Fatalf("expected member, got %q", role)
+	}
+
+	_, err = f.r.AddWorkspaceMember(f.ctx, personal.ID, friend.Email, WorkspaceRoleMember)
+	expectError(t, err, ErrPersonalWorkspace)
+
+	workspace, err := f.r.GetUserWorkspace(f.ctx, f.workspace.ID, friend.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if workspace.Name != "Team" || workspace.Role != WorkspaceRoleMember || workspace.CanManage() {
+		t.Fatalf("expected the team workspace as a member, got %+v", workspace)
+	}
+	_, err = f.r.GetUserWorkspace(f.ctx, personal.ID, friend.ID)
+	expectError(t, err, sql.ErrNoRows)
+
+	// members own the lists they create, for as long as they stay
+	list, err := f.r.CreateList(f.ctx, f.workspace.ID, friend.ID, "Friend's")
+	if err != nil {
+		t.Fatal(err)
+	}
+	friendPersonal, err := f.r.EnsurePersonalWorkspace(f.ctx, friend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if permission, _ := f.r.GetListPermission(f.ctx, friendPersonal.ID, list.ID, friend.ID); permission != PermissionOwner {
+		t.Fatalf("expected the creator to own the list, got %q", permission)
+	}
+
+	if err = f.r.RemoveWorkspaceMember(f.ctx, f.workspace.ID, friend.ID); err != nil {
+		t.Fatal(err)
+	}
+	role, err = f.r.GetWorkspaceRole(f.ctx, f.workspace.ID, friend.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if role != "" {
+		t.Fatalf("expected no role, got %q", role)
+	}
+	_, err = f.r.GetUserWorkspace(f.ctx, f.workspace.ID, friend.ID)
+	expectError(t, err, sql.ErrNoRows)
+
+	for _, workspaceId := range []int64{f.workspace.ID, friendPersonal.ID} {
+		if permission, _ := f.r.GetListPermission(f.ctx, workspaceId, list.ID, friend.ID); permission != "" {
+			t.Fatalf("expected a removed member to lose their lists, got %q", permission)
+		}
+	}
+	if permission, _ := f.r.GetListPermission(f.ctx, f.workspace.ID, list.ID, f.user.ID); permission != PermissionOwner {
+		t.Fatalf("expected the workspace owner to keep the list, got %q", permission)
+	}
+}
+
+func testAudit(t *testing.T, f *fixture) {
+	list := f.createList("Audited")
+	if _, err := f.r.UpdateListById(f.ctx, list.ID, "Renamed", list.Version); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := f.r.FilterAuditEvents(f.ctx, AuditQuery{ListID: list.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actions []string
+	for _, entry := range entries {
+		actions = append(actions, entry.Action)
+	}
+	expectNames(t, actions, "list.update", "list.create")
+
+	entries, err = f.r.FilterAuditEvents(f.ctx, AuditQuery{WorkspaceID: f.workspace.ID, Action: "workspace.create"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected the workspace's creation, got %+v", entries)
+	}
+}
+
+func testTransactions(t *testing.T, f *fixture) {
+	failure := errors.New("failure")
+
+	err := f.r.WithTx(f.ctx, func(r Repository) error {
+		if _, err := r.CreateList(f.ctx, f.workspace.ID, f.user.ID, "Rolled back"); err != nil {
+			return err
+		}
+		return failure
+	})
+	expectError(t, err, failure)
+
+	names, _ := f.listNames(ListQuery{})
+	expectNames(t, names)
+
+	err = f.r.WithTx(f.ctx, func(r Repository) error {
+		if _, err := r.CreateList(f.ctx, f.workspace.ID, f.user.ID, "Kept"); err != nil {
+			return err
+		}
+
+		// a failed nested transaction only undoes its own changes
+		err := r.WithTx(f.ctx, func(r Repository) error {
+			if _, err := r.CreateList(f.ctx, f.workspace.ID, f.user.ID, "Nested"); err != nil {
+				return err
+			}
+			return failure
+		})
+		expectError(t, err, failure)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names, _ = f.listNames(ListQuery{})
+	expectNames(t, names, "Kept")
+
+	// errors that repository methods make of failed statements can be recovered from, and the
+	// transaction goes on
+	err = f.r.WithTx(f.ctx, func(r Repository) error {
+		_, err := r.CreateList(f.ctx, f.workspace.ID, f.user.ID, "Kept")
+		expectError(t, err, ErrListNameTaken)
+
+		_, err = r.CreateList(f.ctx, f.workspace.ID, f.user.ID, "Kept (2)")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names, _ = f.listNames(ListQuery{})
+	expectNames(t, names, "Kept", "Kept (2)")
+}
+
+func testConcurrentCreations(t *testing.T, f *fixture) {
+	const creators = 10
+
+	var wg sync.WaitGroup
+	errs := make([]error, creators)
+	for i := 0; i < creators; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = f.r.CreateList(f.ctx, f.workspace.ID, f.user.ID, fmt.Sprintf("List %d", i))
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	names, _ := f.listNames(ListQuery{Limit: creators + 1})
+	if len(names) != creators {
+		t.Fatalf("expected %d lists, got %q", creators, names)
+	}
+}
+
+func testConcurrentRenames(t *testing.T, f *fixture) {
+	const renamers = 10
+
+	tags := make([]model.Tag, renamers)
+	for i := range tags {
+		var err error
+		if tags[i], err = f.r.CreateTag(f.ctx, f.workspace.ID, fmt.Sprintf("Tag %d", i), "blue"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, renamers)
+	for i, tag := range tags {
+		wg.Add(1)
+		go func(i int, tag model.Tag) {
+			defer wg.Done()
+			_, errs[i] = f.r.UpdateTag(f.ctx, f.workspace.ID, tag.ID, "same", "red")
+		}(i, tag)
+	}
+	wg.Wait()
+
+	renamed := 0
+	for _, err := range errs {
+		if err == nil {
+			renamed++
+			continue
+		}
+		expectError(t, err, ErrTagNameTaken)
+	}
+	if renamed != 1 {
+		t.Fatalf("expected one tag to be renamed, got %d", renamed)
+	}
+}
+
+func testConcurrentEdits(t *testing.T, f *fixture) {
+	const editors = 10
+
+	list := f.createList("Contested")
+	item := f.createItem(list, "Contested")
+
+	var wg sync.WaitGroup
+	listErrs := make([]error, editors)
+	itemErrs := make([]error, editors)
+	for i := 0; i < editors; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			_, listErrs[i] = f.r.UpdateListById(f.ctx, list.ID, fmt.Sprintf("List %d", i), list.Version)
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			_, itemErrs[i] = f.r.UpdateItemById(f.ctx, item.ID, fmt.Sprintf("Item %d", i), item.Version)
+		}(i)
+	}
+	wg.Wait()
+
+	// every editor read the same version, so only the first edit of each applies
+	for _, errs := range [][]error{listErrs, itemErrs} {
+		applied := 0
+		for _, err := range errs {
+			if err == nil {
+				applied++
+				continue
+			}
+			expectError(t, err, ErrVersionConflict)
+		}
+		if applied != 1 {
+			t.Fatalf("expected one edit to apply, got %d", applied)
+		}
+	}
+
+	current, err := f.r.GetListById(f.ctx, list.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.Version != list.Version+1 {
+		t.Fatalf("expected version %d, got %d", list.Version+1, current.Version)
+	}
+}
+
+func testFixtures(t *testing.T, f *fixture) {
+	loaded := testdb.ReadFixtures(t, "testdata/fixtures.yaml").Load(t, f.r)
+
+	alice, bob := loaded.Users["alice@example.com"], loaded.Users["bob@example.com"]
+	if alice.Role != RoleAdmin || bob.Role != RoleUser {
+		t.Fatalf("expected alice to be an admin and bob a user, got %q and %q", alice.Role, bob.Role)
+	}
+
+	team := loaded.Workspaces["Team"]
+	role, err := f.r.GetWorkspaceRole(f.ctx, team.ID, bob.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if role != WorkspaceRoleMember {
+		t.Fatalf("expected bob to be a member of the team, got %q", role)
+	}
+
+	groceries := loaded.Lists["Groceries"]
+	if groceries.WorkspaceID != loaded.Personal[bob.Email].ID {
+		t.Fatalf("expected groceries in bob's personal workspace, got %d", groceries.WorkspaceID)
+	}
+	if item := loaded.Items["Eggs"]; item.ListID != groceries.ID {
+		t.Fatalf("expected eggs on the groceries list, got %+v", item)
+	}
+
+	permission, err := f.r.GetListPermission(f.ctx, loaded.Personal[alice.Email].ID, groceries.ID, alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if permission != PermissionViewer {
+		t.Fatalf("expected alice to view groceries, got %q", permission)
+	}
+
+	names := []string{}
+	page, err := f.r.FilterLists(f.ctx, ListQuery{WorkspaceID: team.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, result := range page.Results {
+		names = append(names, result.Name)
+	}
+	expectNames(t, names, "Launch")
+}
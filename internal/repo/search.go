@@ -0,0 +1,84 @@
+package repo
+
+import (
+	. "github.com/go-jet/jet/v2/postgres"
+	"strings"
+)
+
+// Highlighted words in search snippets are wrapped in these private-use characters rather
+// than HTML, so that snippets can be escaped like any other text when they are rendered.
+// They must never appear in user text, or the text would be taken for a highlight.
+const (
+	highlightStart = "\uE000"
+	highlightStop  = "\uE001"
+)
+
+// headlineOptions configures ts_headline for snippets of short text like list and item names.
+const headlineOptions = "StartSel=" + highlightStart + ", StopSel=" + highlightStop + ", HighlightAll=true"
+
+// snippetSeparator joins the names of several matching items in one snippet.
+const snippetSeparator = " · "
+
+// SnippetPart is part of a search snippet, either highlighted or not.
+type SnippetPart struct {
+	Text      string
+	Highlight bool
+}
+
+// SnippetParts splits a search snippet into highlighted and plain parts.
+func SnippetParts(snippet string) []SnippetPart {
+	var parts []SnippetPart
+	for snippet != "" {
+		start := strings.Index(snippet, highlightStart)
+		if start == -1 {
+			return append(parts, SnippetPart{Text: snippet})
+		}
+		if start > 0 {
+			parts = append(parts, SnippetPart{Text: snippet[:start]})
+		}
+		snippet = snippet[start+len(highlightStart):]
+
+		stop := strings.Index(snippet, highlightStop)
+		if stop == -1 {
+			stop = len(snippet)
+		}
+		parts = append(parts, SnippetPart{Text: snippet[:stop], Highlight: true})
+		snippet = strings.TrimPrefix(snippet[stop:], highlightStop)
+	}
+	return parts
+}
+
+// listSearch builds the expressions FilterLists needs to search lists and their items.
+type listSearch struct {
+	matches BoolExpression
+	rank    FloatExpression
+	snippet StringExpression
+}
+
+func newListSearch(search string) listSearch {
+	args := RawArgs{"#search": search}
+	headlineArgs := RawArgs{"#search": search, "#options": headlineOptions, "#separator": snippetSeparator}
+
+	const tsquery = "websearch_to_tsquery('english', #search)"
+	const matchingItems = "FROM item WHERE item.list_id = list.id AND item.deleted_at IS NULL AND item.search_vector @@ " + tsquery
+
+	return listSearch{
+		matches: RawBool(
+			"list.search_vector @@ "+tsquery+" OR EXISTS (SELECT 1 "+matchingItems+")",
+			args,
+		),
+		// double precision, so that ranks survive the round trip through a page cursor
+		rank: RawFloat(
+			"CAST(ts_rank(list.search_vector, "+tsquery+") + COALESCE((SELECT max(ts_rank(item.search_vector, "+tsquery+")) "+matchingItems+"), 0) AS double precision)",
+			args,
+		),
+		// a matching list name makes the best snippet, otherwise show the matching items
+		snippet: RawString(
+			"CASE WHEN list.search_vector @@ "+tsquery+
+				" THEN ts_headline('english', list.name, "+tsquery+", #options)"+
+				" ELSE (SELECT string_agg(ts_headline('english', item.name, "+tsquery+", #options), #separator ORDER BY item.position) "+matchingItems+")"+
+				" END",
+			headlineArgs,
+		),
+	}
+}
@@ -0,0 +1,135 @@
+package repo
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	. "github.com/go-jet/jet/v2/postgres"
+	"htmxtodo/gen/htmxtodo_dev/public/model"
+	. "htmxtodo/gen/htmxtodo_dev/public/table"
+	"time"
+)
+
+// shareTokenBytes is the amount of randomness in a share link token, enough that tokens
+// cannot be guessed.
+const shareTokenBytes = 32
+
+// activeShareLink matches links that have been neither revoked nor expired.
+func activeShareLink() BoolExpression {
+	return ShareLink.RevokedAt.IS_NULL().
+		AND(ShareLink.ExpiresAt.IS_NULL().OR(ShareLink.ExpiresAt.GT(NOW())))
+}
+
+// CreateShareLink creates a public link to a list, revoking any link it already had. A
+// nil expiresAt makes a link that lasts until it is revoked.
+func (r *repository) CreateShareLink(ctx context.Context, listId int64, createdById int64, expiresAt *time.Time) (model.ShareLink, error) {
+	var result model.ShareLink
+
+	token, err := newShareToken()
+	if err != nil {
+		return result, err
+	}
+
+	err = r.inTransaction(ctx, func(rtx *repository) error {
+		if err = rtx.RevokeShareLinks(ctx, listId); err != nil {
+			return err
+		}
+
+		stmt := ShareLink.INSERT(ShareLink.ListID, ShareLink.Token, ShareLink.CreatedByID, ShareLink.ExpiresAt).
+			VALUES(listId, token, createdById, expiresAt).
+			RETURNING(ShareLink.AllColumns)
+		if err = rtx.queryRow(ctx, stmt, &result); err != nil {
+			return err
+		}
+
+		return rtx.recordListEvent(ctx, "list.link_create", listId, nil, shareLinkState{ID: result.ID, ExpiresAt: result.ExpiresAt})
+	})
+
+	return result, err
+}
+
+// FilterActiveShareLinks returns the usable links to lists in a workspace.
+func (r *repository) FilterActiveShareLinks(ctx context.Context, workspaceId int64) ([]model.ShareLink, error) {
+	r = r.reader(ctx)
+
+	stmt := SELECT(ShareLink.AllColumns).
+		FROM(ShareLink.INNER_JOIN(List, List.ID.EQ(ShareLink.ListID))).
+		WHERE(List.WorkspaceID.EQ(Int(workspaceId)).AND(activeShareLink()))
+
+	var results []model.ShareLink
+	if err := stmt.QueryContext(ctx, r.dbtx, &results); err != nil {
+		return nil, err
+	}
+
+	if results == nil {
+		results = make([]model.ShareLink, 0)
+	}
+
+	return results, nil
+}
+
+// GetActiveShareLink returns the usable link to a list, or sql.ErrNoRows if it has none.
+func (r *repository) GetActiveShareLink(ctx context.Context, listId int64) (model.ShareLink, error) {
+	r = r.reader(ctx)
+
+	stmt := ShareLink.SELECT(ShareLink.AllColumns).
+		WHERE(ShareLink.ListID.EQ(Int(listId)).AND(activeShareLink())).
+		LIMIT(1)
+
+	var result model.ShareLink
+	err := r.queryRow(ctx, stmt, &result)
+	return result, err
+}
+
+// GetListByShareToken returns the list a usable link points to, or sql.ErrNoRows if the
+// token is unknown, revoked or expired, or the list is in the trash.
+func (r *repository) GetListByShareToken(ctx context.Context, token string) (model.List, error) {
+	r = r.reader(ctx)
+
+	stmt := SELECT(List.AllColumns).
+		FROM(List.INNER_JOIN(ShareLink, ShareLink.ListID.EQ(List.ID))).
+		WHERE(ShareLink.Token.EQ(String(token)).AND(activeShareLink()).AND(List.DeletedAt.IS_NULL())).
+		LIMIT(1)
+
+	var result model.List
+	err := r.queryRow(ctx, stmt, &result)
+	return result, err
+}
+
+func (r *repository) RevokeShareLinks(ctx context.Context, listId int64) error {
+	stmt := ShareLink.UPDATE(ShareLink.RevokedAt).
+		SET(NOW()).
+		WHERE(ShareLink.ListID.EQ(Int(listId)).AND(ShareLink.RevokedAt.IS_NULL())).
+		RETURNING(ShareLink.AllColumns)
+
+	return r.inTransaction(ctx, func(rtx *repository) error {
+		var links []model.ShareLink
+		if err := stmt.QueryContext(ctx, rtx.dbtx, &links); err != nil {
+			return err
+		}
+
+		for _, link := range links {
+			err := rtx.recordListEvent(ctx, "list.link_revoke", listId, shareLinkState{ID: link.ID, ExpiresAt: link.ExpiresAt}, nil)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// shareLinkState is what the audit log records of a share link. Tokens are left out, as
+// anyone with one can read the list.
+type shareLinkState struct {
+	ID        int64      `json:"id"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func newShareToken() (string, error) {
+	b := make([]byte, shareTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
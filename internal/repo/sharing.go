@@ -0,0 +1,421 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	. "github.com/go-jet/jet/v2/postgres"
+	"htmxtodo/gen/htmxtodo_dev/public/model"
+	. "htmxtodo/gen/htmxtodo_dev/public/table"
+)
+
+// Permissions on a list, from least to most privileged. Owners are recorded on the list
+// itself; viewers and editors are list members.
+const (
+	PermissionViewer = "viewer"
+	PermissionEditor = "editor"
+	PermissionOwner  = "owner"
+)
+
+var permissionLevels = map[string]int{
+	PermissionViewer: 1,
+	PermissionEditor: 2,
+	PermissionOwner:  3,
+}
+
+var (
+	ErrAlreadyOwner       = errors.New("user already owns the list")
+	ErrNotMember          = errors.New("user is not a member of the list")
+	ErrNotWorkspaceMember = errors.New("user is not a member of the list's workspace")
+	ErrListNameTaken      = errors.New("user already has a list with that name")
+)
+
+// PermissionAllows reports whether having one permission grants another, e.g. editors may
+// do anything viewers may. No permission ("") allows nothing.
+func PermissionAllows(have string, need string) bool {
+	return have != "" && permissionLevels[have] >= permissionLevels[need]
+}
+
+// IsMemberPermission reports whether permission can be given to a list member.
+func IsMemberPermission(permission string) bool {
+	return permission == PermissionViewer || permission == PermissionEditor
+}
+
+// SharedList is a list shared with a user, with their permission on it.
+type SharedList struct {
+	model.List
+	Permission string
+	OwnerEmail string
+}
+
+// Member is a user a list is shared with.
+type Member struct {
+	model.ListMember
+	Email string
+}
+
+// FilterSharedLists returns the lists other users have shared with a user, except those in
+// the given workspace, which FilterLists already returns.
+func (r *repository) FilterSharedLists(ctx context.Context, workspaceId int64, userId int64) ([]SharedList, error) {
+	r = r.reader(ctx)
+
+	stmt := SELECT(
+		List.AllColumns,
+		ListMember.Permission.AS("shared_list.permission"),
+		AppUser.Email.AS("shared_list.owner_email"),
+	).FROM(
+		List.
+			INNER_JOIN(ListMember, ListMember.ListID.EQ(List.ID)).
+			INNER_JOIN(AppUser, AppUser.ID.EQ(List.OwnerID)),
+	).WHERE(
+		ListMember.UserID.EQ(Int(userId)).
+			AND(List.DeletedAt.IS_NULL()).
+			AND(List.WorkspaceID.NOT_EQ(Int(workspaceId))),
+	).ORDER_BY(List.Name.ASC())
+
+	var results []SharedList
+	if err := stmt.QueryContext(ctx, r.dbtx, &results); err != nil {
+		return nil, err
+	}
+
+	if results == nil {
+		results = make([]SharedList, 0)
+	}
+
+	return results, nil
+}
+
+// GetListPermission returns a user's permission on a list, or "" if they have none. Owners
+// and members the list is shared with have access from any workspace, but access through
+// workspace roles is limited to the active workspace. Owners only keep their lists while
+// they are members of the lists' workspace. It returns sql.ErrNoRows if the list does not
+// exist.
+func (r *repository) GetListPermission(ctx context.Context, workspaceId int64, listId int64, userId int64) (string, error) {
+	r = r.reader(ctx)
+
+	list, err := r.GetListById(ctx, listId)
+	if err != nil {
+		return "", err
+	}
+
+	var permission string
+	if list.WorkspaceID == workspaceId || list.OwnerID == userId {
+		role, err := r.GetWorkspaceRole(ctx, list.WorkspaceID, userId)
+		if err != nil {
+			return "", err
+		}
+		permission = WorkspaceListPermission(role, list, userId)
+	}
+
+	memberPermission, err := r.getListMemberPermission(ctx, listId, userId)
+	if err != nil {
+		return "", err
+	}
+	if permissionLevels[memberPermission] > permissionLevels[permission] {
+		permission = memberPermission
+	}
+
+	return permission, nil
+}
+
+// getListMemberPermission returns the permission a list was shared with a user with, or ""
+// if it was not.
+func (r *repository) getListMemberPermission(ctx context.Context, listId int64, userId int64) (string, error) {
+	stmt := ListMember.SELECT(ListMember.AllColumns).
+		WHERE(ListMember.ListID.EQ(Int(listId)).AND(ListMember.UserID.EQ(Int(userId))))
+
+	var member model.ListMember
+	err := r.queryRow(ctx, stmt, &member)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+
+	return member.Permission, err
+}
+
+func (r *repository) FilterListMembers(ctx context.Context, listId int64) ([]Member, error) {
+	r = r.reader(ctx)
+
+	stmt := SELECT(
+		ListMember.AllColumns,
+		AppUser.Email.AS("member.email"),
+	).FROM(
+		ListMember.INNER_JOIN(AppUser, AppUser.ID.EQ(ListMember.UserID)),
+	).WHERE(
+		ListMember.ListID.EQ(Int(listId)),
+	).ORDER_BY(AppUser.Email.ASC())
+
+	var results []Member
+	if err := stmt.QueryContext(ctx, r.dbtx, &results); err != nil {
+		return nil, err
+	}
+
+	if results == nil {
+		results = make([]Member, 0)
+	}
+
+	return results, nil
+}
+
+func (r *repository) FilterListInvitations(ctx context.Context, listId int64) ([]model.ListInvitation, error) {
+	r = r.reader(ctx)
+
+	stmt := ListInvitation.SELECT(ListInvitation.AllColumns).
+		WHERE(ListInvitation.ListID.EQ(Int(listId))).
+		ORDER_BY(ListInvitation.Email.ASC())
+
+	var results []model.ListInvitation
+	if err := stmt.QueryContext(ctx, r.dbtx, &results); err != nil {
+		return nil, err
+	}
+
+	if results == nil {
+		results = make([]model.ListInvitation, 0)
+	}
+
+	return results, nil
+}
+
+// ShareList gives the user with the given email a permission on a list, replacing any they
+// already had. If nobody has registered with the email yet, they are invited instead, and
+// become a member on their first login.
+func (r *repository) ShareList(ctx context.Context, listId int64, email string, permission string, invitedById int64) (bool, error) {
+	user, err := r.GetUserByEmail(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		stmt := ListInvitation.INSERT(ListInvitation.ListID, ListInvitation.Email, ListInvitation.Permission, ListInvitation.InvitedByID).
+			VALUES(listId, email, permission, invitedById).
+			ON_CONFLICT(ListInvitation.ListID, ListInvitation.Email).
+			DO_UPDATE(SET(
+				ListInvitation.Permission.SET(ListInvitation.EXCLUDED.Permission),
+				ListInvitation.InvitedByID.SET(ListInvitation.EXCLUDED.InvitedByID),
+			))
+
+		err = r.inTransaction(ctx, func(rtx *repository) error {
+			if _, err := stmt.ExecContext(ctx, rtx.dbtx); err != nil {
+				return err
+			}
+			return rtx.recordListEvent(ctx, "list.invite", listId, nil,
+				sharingState{Email: email, Permission: permission})
+		})
+		return true, err
+	}
+	if err != nil {
+		return false, err
+	}
+
+	list, err := r.GetListById(ctx, listId)
+	if err != nil {
+		return false, err
+	}
+	if list.OwnerID == user.ID {
+		return false, ErrAlreadyOwner
+	}
+
+	stmt := ListMember.INSERT(ListMember.ListID, ListMember.UserID, ListMember.Permission).
+		VALUES(listId, user.ID, permission).
+		ON_CONFLICT(ListMember.ListID, ListMember.UserID).
+		DO_UPDATE(SET(ListMember.Permission.SET(ListMember.EXCLUDED.Permission)))
+
+	err = r.inTransaction(ctx, func(rtx *repository) error {
+		previous, err := rtx.getListMemberPermission(ctx, listId, user.ID)
+		if err != nil {
+			return err
+		}
+
+		if _, err = stmt.ExecContext(ctx, rtx.dbtx); err != nil {
+			return err
+		}
+
+		var before any
+		if previous != "" {
+			before = sharingState{UserID: user.ID, Email: user.Email, Permission: previous}
+		}
+		return rtx.recordListEvent(ctx, "list.share", listId, before,
+			sharingState{UserID: user.ID, Email: user.Email, Permission: permission})
+	})
+	return false, err
+}
+
+func (r *repository) RevokeListMember(ctx context.Context, listId int64, userId int64) error {
+	stmt := ListMember.DELETE().
+		WHERE(ListMember.ListID.EQ(Int(listId)).AND(ListMember.UserID.EQ(Int(userId))))
+
+	return r.inTransaction(ctx, func(rtx *repository) error {
+		previous, err := rtx.getListMemberPermission(ctx, listId, userId)
+		if err != nil || previous == "" {
+			return err
+		}
+
+		if _, err = stmt.ExecContext(ctx, rtx.dbtx); err != nil {
+			return err
+		}
+
+		return rtx.recordListEvent(ctx, "list.unshare", listId,
+			sharingState{UserID: userId, Permission: previous}, nil)
+	})
+}
+
+func (r *repository) DeleteListInvitation(ctx context.Context, listId int64, id int64) error {
+	stmt := ListInvitation.DELETE().
+		WHERE(ListInvitation.ListID.EQ(Int(listId)).AND(ListInvitation.ID.EQ(Int(id)))).
+		RETURNING(ListInvitation.AllColumns)
+
+	return r.inTransaction(ctx, func(rtx *repository) error {
+		var invitations []model.ListInvitation
+		if err := stmt.QueryContext(ctx, rtx.dbtx, &invitations); err != nil || len(invitations) == 0 {
+			return err
+		}
+
+		invitation := invitations[0]
+		return rtx.recordListEvent(ctx, "list.uninvite", listId,
+			sharingState{Email: invitation.Email, Permission: invitation.Permission}, nil)
+	})
+}
+
+// sharingState is what the audit log records of someone a list is shared with or who is
+// invited to it.
+type sharingState struct {
+	UserID     int64  `json:"user_id,omitempty"`
+	Email      string `json:"email,omitempty"`
+	Permission string `json:"permission"`
+}
+
+// recordListEvent records a change to something belonging to a list, such as who it is
+// shared with or its tags, rather than to the list itself.
+func (r *repository) recordListEvent(ctx context.Context, action string, listId int64, before any, after any) error {
+	var list model.List
+	stmt := List.SELECT(List.ID, List.WorkspaceID).WHERE(List.ID.EQ(Int(listId)))
+	if err := r.queryRow(ctx, stmt, &list); err != nil {
+		return err
+	}
+
+	return r.recordChange(ctx, change{
+		Action:      action,
+		EntityType:  EntityList,
+		EntityID:    listId,
+		WorkspaceID: &list.WorkspaceID,
+		ListID:      &listId,
+		Before:      before,
+		After:       after,
+	})
+}
+
+// TransferListOwnership makes a member the owner of a list. The previous owner stays on as
+// an editor, so handing a list over never locks anyone out by accident. Lists in the previous
+// owner's personal workspace move to the new owner's.
+func (r *repository) TransferListOwnership(ctx context.Context, listId int64, newOwnerId int64) (model.List, error) {
+	var list model.List
+
+	err := r.inTransaction(ctx, func(rtx *repository) error {
+		// lock the list so concurrent transfers cannot both succeed
+		lockStmt := List.SELECT(List.AllColumns).
+			WHERE(List.ID.EQ(Int(listId)).AND(List.DeletedAt.IS_NULL())).
+			FOR(UPDATE())
+		if err := rtx.queryRow(ctx, lockStmt, &list); err != nil {
+			return err
+		}
+		before := list
+
+		if list.OwnerID == newOwnerId {
+			return ErrAlreadyOwner
+		}
+		permission, err := rtx.getListMemberPermission(ctx, listId, newOwnerId)
+		if err != nil {
+			return err
+		}
+		if permission == "" {
+			return ErrNotMember
+		}
+
+		// a list in its owner's personal workspace moves to the new owner's, but a list in a
+		// team workspace stays there, so only another member of it can own the list
+		workspaceId := list.WorkspaceID
+		workspace, err := rtx.getWorkspace(ctx, workspaceId)
+		if err != nil {
+			return err
+		}
+		if workspace.PersonalUserID != nil && *workspace.PersonalUserID == list.OwnerID {
+			personal, err := rtx.ensurePersonalWorkspace(ctx, newOwnerId)
+			if err != nil {
+				return err
+			}
+			workspaceId = personal.ID
+		} else {
+			role, err := rtx.GetWorkspaceRole(ctx, workspaceId, newOwnerId)
+			if err != nil {
+				return err
+			}
+			if role == "" {
+				return ErrNotWorkspaceMember
+			}
+		}
+
+		var existing []model.List
+		nameStmt := List.SELECT(List.ID).
+			WHERE(List.WorkspaceID.EQ(Int(workspaceId)).
+				AND(List.Name.EQ(String(list.Name))).
+				AND(List.ID.NOT_EQ(Int(listId))).
+				AND(List.DeletedAt.IS_NULL()))
+		if err = nameStmt.QueryContext(ctx, rtx.dbtx, &existing); err != nil {
+			return err
+		}
+		if len(existing) > 0 {
+			return ErrListNameTaken
+		}
+
+		if err = rtx.RevokeListMember(ctx, listId, newOwnerId); err != nil {
+			return err
+		}
+
+		memberStmt := ListMember.INSERT(ListMember.ListID, ListMember.UserID, ListMember.Permission).
+			VALUES(listId, list.OwnerID, PermissionEditor)
+		if _, err = memberStmt.ExecContext(ctx, rtx.dbtx); err != nil {
+			return err
+		}
+
+		updateStmt := List.UPDATE(List.OwnerID, List.WorkspaceID, List.UpdatedAt, List.Version).
+			SET(newOwnerId, workspaceId, NOW(), List.Version.ADD(Int(1))).
+			WHERE(List.ID.EQ(Int(listId))).
+			RETURNING(List.AllColumns)
+		if err = rtx.queryRow(ctx, updateStmt, &list); err != nil {
+			return err
+		}
+
+		return rtx.recordListChange(ctx, "list.transfer", &before, &list)
+	})
+
+	return list, err
+}
+
+// AcceptListInvitations turns the invitations sent to a user's email into memberships.
+func (r *repository) AcceptListInvitations(ctx context.Context, user model.AppUser) error {
+	return r.inTransaction(ctx, func(rtx *repository) error {
+		insertStmt := ListMember.INSERT(ListMember.ListID, ListMember.UserID, ListMember.Permission).
+			QUERY(
+				SELECT(ListInvitation.ListID, Int64(user.ID), ListInvitation.Permission).
+					FROM(ListInvitation.INNER_JOIN(List, List.ID.EQ(ListInvitation.ListID))).
+					WHERE(ListInvitation.Email.EQ(String(user.Email)).
+						AND(List.OwnerID.NOT_EQ(Int(user.ID)))),
+			).
+			ON_CONFLICT(ListMember.ListID, ListMember.UserID).
+			DO_NOTHING().
+			RETURNING(ListMember.AllColumns)
+		var accepted []model.ListMember
+		if err := insertStmt.QueryContext(ctx, rtx.dbtx, &accepted); err != nil {
+			return err
+		}
+
+		for _, member := range accepted {
+			err := rtx.recordListEvent(ctx, "list.share.accept", member.ListID,
+				sharingState{Email: user.Email, Permission: member.Permission},
+				sharingState{UserID: user.ID, Email: user.Email, Permission: member.Permission})
+			if err != nil {
+				return err
+			}
+		}
+
+		deleteStmt := ListInvitation.DELETE().WHERE(ListInvitation.Email.EQ(String(user.Email)))
+		_, err := deleteStmt.ExecContext(ctx, rtx.dbtx)
+		return err
+	})
+}
@@ -0,0 +1,35 @@
+package repo
+
+import (
+	"context"
+	. "github.com/go-jet/jet/v2/postgres"
+	. "htmxtodo/gen/htmxtodo_dev/public/table"
+)
+
+// Stats are system-wide counts for the admin dashboard.
+type Stats struct {
+	Users         int64
+	Admins        int64
+	DisabledUsers int64
+	Lists         int64
+	Items         int64
+}
+
+func (r *repository) GetStats(ctx context.Context) (Stats, error) {
+	r = r.reader(ctx)
+
+	stmt := SELECT(
+		IntExp(AppUser.SELECT(COUNT(STAR))).AS("stats.users"),
+		IntExp(AppUser.SELECT(COUNT(STAR)).WHERE(AppUser.Role.EQ(String(RoleAdmin)))).AS("stats.admins"),
+		IntExp(AppUser.SELECT(COUNT(STAR)).WHERE(AppUser.DisabledAt.IS_NOT_NULL())).AS("stats.disabled_users"),
+		IntExp(List.SELECT(COUNT(STAR)).WHERE(List.DeletedAt.IS_NULL())).AS("stats.lists"),
+		IntExp(Item.SELECT(COUNT(STAR)).WHERE(Item.DeletedAt.IS_NULL())).AS("stats.items"),
+	)
+
+	var result Stats
+	if err := stmt.QueryContext(ctx, r.dbtx, &result); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
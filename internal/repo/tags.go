@@ -0,0 +1,337 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	. "github.com/go-jet/jet/v2/postgres"
+	"htmxtodo/gen/htmxtodo_dev/public/model"
+	. "htmxtodo/gen/htmxtodo_dev/public/table"
+	"regexp"
+)
+
+var ErrTagNameTaken = errors.New("the workspace already has a tag with that name")
+
+// tagNameConstraint keeps tag names unique within a workspace.
+const tagNameConstraint = "tag_workspace_id_name_key"
+
+var tagColorPattern = regexp.MustCompile(`^#[0-9a-f]{6}$`)
+
+// IsTagColor reports whether color is a valid tag color, a lowercase #rrggbb.
+func IsTagColor(color string) bool {
+	return tagColorPattern.MatchString(color)
+}
+
+// TagCount is a tag with how many lists it is on.
+type TagCount struct {
+	model.Tag
+	Lists int64
+}
+
+// ListTags are the tags on some lists, by list ID.
+type ListTags map[int64][]model.Tag
+
+// FilterTags returns a workspace's tags by name, with how often each is used outside the
+// trash.
+func (r *repository) FilterTags(ctx context.Context, workspaceId int64) ([]TagCount, error) {
+	r = r.reader(ctx)
+
+	stmt := SELECT(
+		Tag.AllColumns,
+		SELECT(COUNT(STAR)).
+			FROM(ListTag.INNER_JOIN(List, List.ID.EQ(ListTag.ListID))).
+			WHERE(ListTag.TagID.EQ(Tag.ID).AND(List.DeletedAt.IS_NULL())).
+			AS("tag_count.lists"),
+	).FROM(
+		Tag,
+	).WHERE(
+		Tag.WorkspaceID.EQ(Int(workspaceId)),
+	).ORDER_BY(Tag.Name.ASC())
+
+	var results []TagCount
+	if err := stmt.QueryContext(ctx, r.dbtx, &results); err != nil {
+		return nil, err
+	}
+
+	if results == nil {
+		results = make([]TagCount, 0)
+	}
+
+	return results, nil
+}
+
+// GetTag returns a tag in a workspace, or sql.ErrNoRows if the workspace has no such tag.
+func (r *repository) GetTag(ctx context.Context, workspaceId int64, id int64) (model.Tag, error) {
+	r = r.reader(ctx)
+
+	stmt := Tag.SELECT(Tag.AllColumns).
+		WHERE(Tag.ID.EQ(Int(id)).AND(Tag.WorkspaceID.EQ(Int(workspaceId))))
+
+	var result model.Tag
+	err := r.queryRow(ctx, stmt, &result)
+	return result, err
+}
+
+func (r *repository) CreateTag(ctx context.Context, workspaceId int64, name string, color string) (model.Tag, error) {
+	stmt := Tag.INSERT(Tag.WorkspaceID, Tag.Name, Tag.Color).
+		VALUES(workspaceId, name, color).
+		ON_CONFLICT(Tag.WorkspaceID, Tag.Name).
+		DO_NOTHING().
+		RETURNING(Tag.AllColumns)
+
+	var result model.Tag
+	err := r.inTransaction(ctx, func(rtx *repository) error {
+		err := rtx.queryRow(ctx, stmt, &result)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrTagNameTaken
+		}
+		if err != nil {
+			return err
+		}
+		return rtx.recordTagChange(ctx, "tag.create", nil, &result)
+	})
+
+	return result, err
+}
+
+// UpdateTag renames and recolors a tag. Lists refer to tags by ID, so the change
+// shows everywhere at once. It returns ErrTagNameTaken if another tag has the name; merge
+// the tags instead.
+func (r *repository) UpdateTag(ctx context.Context, workspaceId int64, id int64, name string, color string) (model.Tag, error) {
+	var result model.Tag
+
+	err := r.inTransaction(ctx, func(rtx *repository) error {
+		before, err := rtx.GetTag(ctx, workspaceId, id)
+		if err != nil {
+			return err
+		}
+
+		// the constraint rather than a lookup catches a tag renamed to the same name meanwhile
+		updateStmt := Tag.UPDATE(Tag.Name, Tag.Color, Tag.UpdatedAt).
+			SET(name, color, NOW()).
+			WHERE(Tag.ID.EQ(Int(id)).AND(Tag.WorkspaceID.EQ(Int(workspaceId)))).
+			RETURNING(Tag.AllColumns)
+		err = rtx.queryRow(ctx, updateStmt, &result)
+		if isUniqueViolation(err, tagNameConstraint) {
+			return ErrTagNameTaken
+		}
+		if err != nil {
+			return err
+		}
+
+		return rtx.recordTagChange(ctx, "tag.update", &before, &result)
+	})
+
+	return result, err
+}
+
+// MergeTags moves everything tagged with one tag to another, and deletes the first. Both
+// tags must be in the workspace.
+func (r *repository) MergeTags(ctx context.Context, workspaceId int64, sourceId int64, targetId int64) (model.Tag, error) {
+	var target model.Tag
+
+	err := r.inTransaction(ctx, func(rtx *repository) error {
+		// lock both tags so that nothing is tagged with the source while it is merged
+		var tags []model.Tag
+		lockStmt := Tag.SELECT(Tag.AllColumns).
+			WHERE(Tag.ID.IN(Int(sourceId), Int(targetId)).AND(Tag.WorkspaceID.EQ(Int(workspaceId)))).
+			FOR(UPDATE())
+		if err := lockStmt.QueryContext(ctx, rtx.dbtx, &tags); err != nil {
+			return err
+		}
+		if sourceId == targetId || len(tags) != 2 {
+			return sql.ErrNoRows
+		}
+		var source model.Tag
+		for _, tag := range tags {
+			if tag.ID == targetId {
+				target = tag
+			} else {
+				source = tag
+			}
+		}
+
+		listStmt := ListTag.INSERT(ListTag.ListID, ListTag.TagID).
+			QUERY(
+				SELECT(ListTag.ListID, Int64(targetId)).
+					FROM(ListTag).
+					WHERE(ListTag.TagID.EQ(Int(sourceId))),
+			).
+			ON_CONFLICT(ListTag.ListID, ListTag.TagID).
+			DO_NOTHING()
+		if _, err := listStmt.ExecContext(ctx, rtx.dbtx); err != nil {
+			return err
+		}
+
+		err := rtx.recordChange(ctx, change{
+			Action:      "tag.merge",
+			EntityType:  EntityTag,
+			EntityID:    sourceId,
+			WorkspaceID: &workspaceId,
+			Before:      newTagState(source),
+			After:       newTagState(target),
+		})
+		if err != nil {
+			return err
+		}
+
+		// the source tag's own list tags go with it
+		return rtx.DeleteTag(ctx, workspaceId, sourceId)
+	})
+
+	return target, err
+}
+
+func (r *repository) DeleteTag(ctx context.Context, workspaceId int64, id int64) error {
+	stmt := Tag.DELETE().
+		WHERE(Tag.ID.EQ(Int(id)).AND(Tag.WorkspaceID.EQ(Int(workspaceId)))).
+		RETURNING(Tag.AllColumns)
+
+	return r.inTransaction(ctx, func(rtx *repository) error {
+		var deleted []model.Tag
+		if err := stmt.QueryContext(ctx, rtx.dbtx, &deleted); err != nil || len(deleted) == 0 {
+			return err
+		}
+		return rtx.recordTagChange(ctx, "tag.delete", &deleted[0], nil)
+	})
+}
+
+// FilterListTags returns the tags on some lists.
+func (r *repository) FilterListTags(ctx context.Context, listIds []int64) (ListTags, error) {
+	r = r.reader(ctx)
+
+	results := make(ListTags, len(listIds))
+	if len(listIds) == 0 {
+		return results, nil
+	}
+
+	stmt := SELECT(
+		ListTag.AllColumns,
+		Tag.AllColumns,
+	).FROM(
+		ListTag.INNER_JOIN(Tag, Tag.ID.EQ(ListTag.TagID)),
+	).WHERE(
+		ListTag.ListID.IN(idExpressions(listIds)...),
+	).ORDER_BY(Tag.Name.ASC())
+
+	var rows []struct {
+		model.ListTag
+		model.Tag
+	}
+	if err := stmt.QueryContext(ctx, r.dbtx, &rows); err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		results[row.ListID] = append(results[row.ListID], row.Tag)
+	}
+
+	return results, nil
+}
+
+// SetListTags replaces the tags on a list. Tags from other workspaces than the list's are
+// ignored.
+func (r *repository) SetListTags(ctx context.Context, listId int64, tagIds []int64) error {
+	return r.inTransaction(ctx, func(rtx *repository) error {
+		before, err := rtx.listTagIds(ctx, listId)
+		if err != nil {
+			return err
+		}
+
+		ids := idExpressions(tagIds)
+
+		deleteStmt := ListTag.DELETE().
+			WHERE(ListTag.ListID.EQ(Int(listId)).AND(ListTag.TagID.NOT_IN(ids...)))
+		if _, err = deleteStmt.ExecContext(ctx, rtx.dbtx); err != nil {
+			return err
+		}
+
+		insertStmt := ListTag.INSERT(ListTag.ListID, ListTag.TagID).
+			QUERY(
+				SELECT(Int64(listId), Tag.ID).
+					FROM(Tag.INNER_JOIN(List, List.WorkspaceID.EQ(Tag.WorkspaceID))).
+					WHERE(List.ID.EQ(Int(listId)).AND(Tag.ID.IN(ids...))),
+			).
+			ON_CONFLICT(ListTag.ListID, ListTag.TagID).
+			DO_NOTHING()
+		if _, err = insertStmt.ExecContext(ctx, rtx.dbtx); err != nil {
+			return err
+		}
+
+		after, err := rtx.listTagIds(ctx, listId)
+		if err != nil {
+			return err
+		}
+		return rtx.recordListEvent(ctx, "list.tag", listId, before, after)
+	})
+}
+
+// listTagIds returns the IDs of the tags on a list, in order.
+func (r *repository) listTagIds(ctx context.Context, listId int64) ([]int64, error) {
+	stmt := ListTag.SELECT(ListTag.AllColumns).
+		WHERE(ListTag.ListID.EQ(Int(listId))).
+		ORDER_BY(ListTag.TagID.ASC())
+
+	var rows []model.ListTag
+	if err := stmt.QueryContext(ctx, r.dbtx, &rows); err != nil {
+		return nil, err
+	}
+
+	ids := make([]int64, len(rows))
+	for i, row := range rows {
+		ids[i] = row.TagID
+	}
+	return ids, nil
+}
+
+// tagState is what the audit log records of a tag.
+type tagState struct {
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+func newTagState(tag model.Tag) tagState {
+	return tagState{Name: tag.Name, Color: tag.Color}
+}
+
+// recordTagChange records a change to a tag, with its state before and after it.
+func (r *repository) recordTagChange(ctx context.Context, action string, before *model.Tag, after *model.Tag) error {
+	return r.recordChange(ctx, tagChange(action, before, after))
+}
+
+// tagChange is a change to a tag. Either state is nil when the tag was created or deleted.
+func tagChange(action string, before *model.Tag, after *model.Tag) change {
+	tag := after
+	if tag == nil {
+		tag = before
+	}
+
+	c := change{
+		Action:      action,
+		EntityType:  EntityTag,
+		EntityID:    tag.ID,
+		WorkspaceID: &tag.WorkspaceID,
+	}
+	if before != nil {
+		c.Before = newTagState(*before)
+	}
+	if after != nil {
+		c.After = newTagState(*after)
+	}
+
+	return c
+}
+
+// idExpressions returns IDs for IN and NOT IN. With no IDs it returns a single ID nothing
+// has, since IN () is not valid SQL.
+func idExpressions(ids []int64) []Expression {
+	if len(ids) == 0 {
+		return []Expression{Int(0)}
+	}
+
+	expressions := make([]Expression, len(ids))
+	for i, id := range ids {
+		expressions[i] = Int(id)
+	}
+	return expressions
+}
@@ -0,0 +1,208 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	. "github.com/go-jet/jet/v2/postgres"
+	"htmxtodo/gen/htmxtodo_dev/public/model"
+	. "htmxtodo/gen/htmxtodo_dev/public/table"
+	"time"
+)
+
+// DeleteListById moves a list to the trash. Its items stay with it, so that restoring the
+// list brings them back too.
+func (r *repository) DeleteListById(ctx context.Context, id int64) error {
+	return r.inTransaction(ctx, func(rtx *repository) error {
+		before, err := rtx.GetListById(ctx, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		stmt := List.UPDATE(List.DeletedAt, List.Version).
+			SET(NOW(), List.Version.ADD(Int(1))).
+			WHERE(List.ID.EQ(Int(id))).
+			RETURNING(List.AllColumns)
+
+		var after model.List
+		if err = rtx.queryRow(ctx, stmt, &after); err != nil {
+			return err
+		}
+
+		return rtx.recordListChange(ctx, "list.delete", &before, &after)
+	})
+}
+
+// GetDeletedList returns a list in the trash, or sql.ErrNoRows if there is no such list or
+// it is not in the trash.
+func (r *repository) GetDeletedList(ctx context.Context, id int64) (model.List, error) {
+	r = r.reader(ctx)
+
+	stmt := List.SELECT(List.AllColumns).
+		WHERE(List.ID.EQ(Int(id)).AND(List.DeletedAt.IS_NOT_NULL()))
+
+	var result model.List
+	err := r.queryRow(ctx, stmt, &result)
+	return result, err
+}
+
+// FilterDeletedLists returns the lists in a workspace's trash, most recently deleted first.
+func (r *repository) FilterDeletedLists(ctx context.Context, workspaceId int64) ([]model.List, error) {
+	r = r.reader(ctx)
+
+	stmt := List.SELECT(List.AllColumns).
+		WHERE(List.WorkspaceID.EQ(Int(workspaceId)).AND(List.DeletedAt.IS_NOT_NULL())).
+		ORDER_BY(List.DeletedAt.DESC(), List.ID.DESC())
+
+	var results []model.List
+	if err := stmt.QueryContext(ctx, r.dbtx, &results); err != nil {
+		return nil, err
+	}
+
+	if results == nil {
+		results = make([]model.List, 0)
+	}
+
+	return results, nil
+}
+
+// RestoreList takes a list out of the trash, at the end of its workspace's custom order. It
+// returns ErrListNameTaken if another list in the workspace has been given its name since.
+func (r *repository) RestoreList(ctx context.Context, id int64) (model.List, error) {
+	var result model.List
+
+	err := r.inTransaction(ctx, func(rtx *repository) error {
+		lockStmt := List.SELECT(List.AllColumns).
+			WHERE(List.ID.EQ(Int(id)).AND(List.DeletedAt.IS_NOT_NULL())).
+			FOR(UPDATE())
+		if err := rtx.queryRow(ctx, lockStmt, &result); err != nil {
+			return err
+		}
+		before := result
+
+		var existing []model.List
+		nameStmt := List.SELECT(List.ID).
+			WHERE(List.WorkspaceID.EQ(Int(result.WorkspaceID)).
+				AND(List.Name.EQ(String(result.Name))).
+				AND(List.DeletedAt.IS_NULL()))
+		if err := nameStmt.QueryContext(ctx, rtx.dbtx, &existing); err != nil {
+			return err
+		}
+		if len(existing) > 0 {
+			return ErrListNameTaken
+		}
+
+		position := RawInt("SELECT COALESCE(MAX(position), 0) + 1 FROM list WHERE workspace_id = #workspace_id AND deleted_at IS NULL",
+			RawArgs{"#workspace_id": result.WorkspaceID})
+
+		updateStmt := List.UPDATE(List.DeletedAt, List.Position, List.UpdatedAt, List.Version).
+			SET(NULL, position, NOW(), List.Version.ADD(Int(1))).
+			WHERE(List.ID.EQ(Int(id))).
+			RETURNING(List.AllColumns)
+		if err := rtx.queryRow(ctx, updateStmt, &result); err != nil {
+			return err
+		}
+
+		return rtx.recordListChange(ctx, "list.restore", &before, &result)
+	})
+
+	return result, err
+}
+
+// PurgeList permanently deletes a list in the trash, with its items.
+func (r *repository) PurgeList(ctx context.Context, id int64) error {
+	stmt := List.DELETE().
+		WHERE(List.ID.EQ(Int(id)).AND(List.DeletedAt.IS_NOT_NULL())).
+		RETURNING(List.AllColumns)
+
+	return r.inTransaction(ctx, func(rtx *repository) error {
+		var purged model.List
+		if err := rtx.queryRow(ctx, stmt, &purged); err != nil {
+			return err
+		}
+		return rtx.recordListChange(ctx, "list.purge", &purged, nil)
+	})
+}
+
+// DeleteItemById moves an item to the trash.
+func (r *repository) DeleteItemById(ctx context.Context, id int64) error {
+	stmt := Item.UPDATE(Item.DeletedAt, Item.Version).
+		SET(NOW(), Item.Version.ADD(Int(1))).
+		WHERE(Item.ID.EQ(Int(id)).AND(Item.DeletedAt.IS_NULL())).
+		RETURNING(Item.AllColumns)
+
+	return r.inTransaction(ctx, func(rtx *repository) error {
+		var deleted []model.Item
+		if err := stmt.QueryContext(ctx, rtx.dbtx, &deleted); err != nil || len(deleted) == 0 {
+			return err
+		}
+		return rtx.recordItemChange(ctx, "item.delete", id, newItemState(deleted[0]), nil)
+	})
+}
+
+// RestoreItem takes an item out of the trash.
+func (r *repository) RestoreItem(ctx context.Context, id int64) (model.Item, error) {
+	stmt := Item.UPDATE(Item.DeletedAt, Item.UpdatedAt, Item.Version).
+		SET(NULL, NOW(), Item.Version.ADD(Int(1))).
+		WHERE(Item.ID.EQ(Int(id)).AND(Item.DeletedAt.IS_NOT_NULL())).
+		RETURNING(Item.AllColumns)
+
+	var result model.Item
+	err := r.inTransaction(ctx, func(rtx *repository) error {
+		if err := rtx.queryRow(ctx, stmt, &result); err != nil {
+			return err
+		}
+		return rtx.recordItemChange(ctx, "item.restore", id, nil, newItemState(result))
+	})
+	return result, err
+}
+
+// itemState is what the audit log records of an item.
+type itemState struct {
+	Name        string     `json:"name"`
+	Position    int32      `json:"position"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+func newItemState(item model.Item) itemState {
+	return itemState{Name: item.Name, Position: item.Position, CompletedAt: item.CompletedAt}
+}
+
+// PurgeDeleted permanently deletes the lists and items that went into the trash before a
+// time, returning how many were deleted. Items in purged lists are not counted.
+func (r *repository) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	var purged int64
+
+	err := r.inTransaction(ctx, func(rtx *repository) error {
+		itemStmt := Item.DELETE().
+			WHERE(Item.DeletedAt.LT(TimestampzT(before)))
+		items, err := itemStmt.ExecContext(ctx, rtx.dbtx)
+		if err != nil {
+			return err
+		}
+
+		listStmt := List.DELETE().
+			WHERE(List.DeletedAt.LT(TimestampzT(before)))
+		lists, err := listStmt.ExecContext(ctx, rtx.dbtx)
+		if err != nil {
+			return err
+		}
+
+		// the transaction may be a retry
+		purged = 0
+		for _, result := range []sql.Result{items, lists} {
+			n, err := result.RowsAffected()
+			if err != nil {
+				return err
+			}
+			purged += n
+		}
+
+		return nil
+	})
+
+	return purged, err
+}
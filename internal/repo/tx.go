@@ -0,0 +1,106 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// maxTxAttempts is how many times a transaction is run before a serialization failure or
+// deadlock is returned to the caller.
+const maxTxAttempts = 3
+
+// txRetryDelay is how long to wait before the first retry. It doubles for each retry after,
+// with jitter so that the transactions that collided don't collide again.
+const txRetryDelay = 20 * time.Millisecond
+
+// WithTx runs fn as a single unit of work: every call fn makes on the repository it is given
+// commits if fn returns nil and rolls back if it returns an error or panics. The error fn
+// returns is returned as is.
+//
+// If the transaction fails because it conflicted with another one, it is retried from the
+// start, so fn must not have side effects outside the database, such as sending emails;
+// do those after WithTx returns.
+//
+// Calling WithTx on a repository fn was given runs the inner fn in a savepoint of the same
+// transaction. An error from it rolls back only its own work, so the outer fn can recover
+// from it, or return it to roll back everything.
+func (r *repository) WithTx(ctx context.Context, fn func(Repository) error) error {
+	if tx, ok := r.dbtx.(*sql.Tx); ok {
+		return r.withSavepoint(ctx, tx, fn)
+	}
+
+	return r.inTransaction(ctx, func(rtx *repository) error {
+		return fn(rtx)
+	})
+}
+
+// inTransaction runs fn with a repository using a transaction, and commits it if fn
+// succeeds. If r is already using a transaction, fn runs in a savepoint of it, so that a
+// failed statement fn turns into an error such as ErrListNameTaken leaves the outer
+// transaction usable; otherwise the transaction is retried if it fails with a
+// serialization failure or deadlock.
+func (r *repository) inTransaction(ctx context.Context, fn func(rtx *repository) error) error {
+	if tx, ok := r.dbtx.(*sql.Tx); ok {
+		return r.withSavepoint(ctx, tx, func(Repository) error {
+			return fn(r)
+		})
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := r.transact(ctx, fn)
+		if attempt == maxTxAttempts || !isRetryable(err) {
+			return err
+		}
+
+		delay := txRetryDelay << (attempt - 1)
+		delay += time.Duration(rand.Int63n(int64(delay)))
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
+
+// transact runs fn in a new transaction, committing it if fn succeeds.
+func (r *repository) transact(ctx context.Context, fn func(rtx *repository) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = fn(r.withTransaction(tx)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// withSavepoint runs fn inside a savepoint of tx, rolling back to it if fn fails. Postgres
+// keeps shadowed savepoints, so nested savepoints can all use the same name.
+func (r *repository) withSavepoint(ctx context.Context, tx *sql.Tx, fn func(Repository) error) (err error) {
+	if _, err = tx.ExecContext(ctx, "SAVEPOINT with_tx"); err != nil {
+		return err
+	}
+
+	if err = fn(r); err != nil {
+		if _, rollbackErr := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT with_tx"); rollbackErr != nil {
+			return errors.Join(err, rollbackErr)
+		}
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "RELEASE SAVEPOINT with_tx")
+	return err
+}
+
+// isRetryable reports whether a transaction failed only because of concurrent transactions,
+// so that running it again may succeed.
+func isRetryable(err error) bool {
+	code, _ := postgresError(err)
+	return code == "40001" || code == "40P01"
+}
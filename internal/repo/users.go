@@ -0,0 +1,116 @@
+package repo
+
+import (
+	"context"
+	. "github.com/go-jet/jet/v2/postgres"
+	"htmxtodo/gen/htmxtodo_dev/public/model"
+	. "htmxtodo/gen/htmxtodo_dev/public/table"
+	"strings"
+)
+
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// HasRole reports whether user may act with role. Admins have every role.
+func HasRole(user model.AppUser, role string) bool {
+	return user.Role == role || user.Role == RoleAdmin
+}
+
+func (r *repository) GetUserById(ctx context.Context, id int64) (model.AppUser, error) {
+	r = r.reader(ctx)
+
+	stmt := AppUser.SELECT(AppUser.AllColumns).WHERE(AppUser.ID.EQ(Int(id))).LIMIT(1)
+
+	var result model.AppUser
+	err := r.queryRow(ctx, stmt, &result)
+	return result, err
+}
+
+// GetUserByEmail returns the user with the given email, which must be lower case.
+func (r *repository) GetUserByEmail(ctx context.Context, email string) (model.AppUser, error) {
+	r = r.reader(ctx)
+
+	stmt := AppUser.SELECT(AppUser.AllColumns).WHERE(AppUser.Email.EQ(String(email))).LIMIT(1)
+
+	var result model.AppUser
+	err := r.queryRow(ctx, stmt, &result)
+	return result, err
+}
+
+// FindOrCreateUser returns the user with the given email, creating it on first login.
+// Emails are expected to already be normalized to lower case.
+func (r *repository) FindOrCreateUser(ctx context.Context, email string) (model.AppUser, error) {
+	var result model.AppUser
+
+	// the no-op update makes RETURNING produce the existing row on conflict
+	stmt := AppUser.INSERT(AppUser.Email).
+		VALUES(email).
+		ON_CONFLICT(AppUser.Email).
+		DO_UPDATE(SET(AppUser.Email.SET(AppUser.EXCLUDED.Email))).
+		RETURNING(AppUser.AllColumns)
+
+	if err := stmt.QueryContext(ctx, r.dbtx, &result); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// FilterUsers returns users whose email contains query, or all users if it is empty.
+func (r *repository) FilterUsers(ctx context.Context, query string) ([]model.AppUser, error) {
+	r = r.reader(ctx)
+
+	stmt := AppUser.SELECT(AppUser.AllColumns).ORDER_BY(AppUser.Email.ASC())
+
+	if query = strings.TrimSpace(query); query != "" {
+		stmt = stmt.WHERE(LOWER(AppUser.Email).LIKE(String("%" + escapeLike(strings.ToLower(query)) + "%")))
+	}
+
+	var results []model.AppUser
+	if err := stmt.QueryContext(ctx, r.dbtx, &results); err != nil {
+		return nil, err
+	}
+
+	if results == nil {
+		results = make([]model.AppUser, 0)
+	}
+
+	return results, nil
+}
+
+func (r *repository) UpdateUserRole(ctx context.Context, id int64, role string) (model.AppUser, error) {
+	return r.updateUser(ctx, id, AppUser.Role.SET(String(role)))
+}
+
+// UpdateUserDisabled disables or re-enables a user. Disabled users cannot log in, and their
+// existing sessions end on their next request.
+func (r *repository) UpdateUserDisabled(ctx context.Context, id int64, disabled bool) (model.AppUser, error) {
+	if disabled {
+		return r.updateUser(ctx, id, AppUser.DisabledAt.SET(NOW()))
+	}
+	return r.updateUser(ctx, id, AppUser.DisabledAt.SET(TimestampzExp(NULL)))
+}
+
+// RevokeUserSessions ends every session the user started before now.
+func (r *repository) RevokeUserSessions(ctx context.Context, id int64) (model.AppUser, error) {
+	return r.updateUser(ctx, id, AppUser.SessionsRevokedAt.SET(NOW()))
+}
+
+func (r *repository) updateUser(ctx context.Context, id int64, assignment ColumnAssigment) (model.AppUser, error) {
+	var result model.AppUser
+
+	stmt := AppUser.UPDATE().
+		SET(assignment, AppUser.UpdatedAt.SET(NOW())).
+		WHERE(AppUser.ID.EQ(Int(id))).
+		RETURNING(AppUser.AllColumns)
+
+	err := r.queryRow(ctx, stmt, &result)
+	return result, err
+}
+
+// escapeLike escapes the LIKE wildcards in s so that it matches literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,322 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	. "github.com/go-jet/jet/v2/postgres"
+	"htmxtodo/gen/htmxtodo_dev/public/model"
+	. "htmxtodo/gen/htmxtodo_dev/public/table"
+)
+
+// Roles in a workspace. Owners and admins manage members and every list in the workspace;
+// members may edit any list in it.
+const (
+	WorkspaceRoleOwner  = "owner"
+	WorkspaceRoleAdmin  = "admin"
+	WorkspaceRoleMember = "member"
+)
+
+const personalWorkspaceName = "Personal"
+
+var ErrPersonalWorkspace = errors.New("personal workspaces cannot be shared")
+
+// UserWorkspace is a workspace a user belongs to, with their role in it.
+type UserWorkspace struct {
+	model.Workspace
+	Role string
+}
+
+// IsPersonal reports whether this is the user's own workspace, which nobody else can join.
+func (w UserWorkspace) IsPersonal() bool {
+	return w.PersonalUserID != nil
+}
+
+// CanManage reports whether the user may manage the workspace's members.
+func (w UserWorkspace) CanManage() bool {
+	return w.Role == WorkspaceRoleOwner || w.Role == WorkspaceRoleAdmin
+}
+
+// CanManageRole reports whether the user may give someone a role in the workspace, or
+// take it away. Only the owner makes and unmakes admins, so admins cannot act on each other.
+func (w UserWorkspace) CanManageRole(role string) bool {
+	switch w.Role {
+	case WorkspaceRoleOwner:
+		return role != WorkspaceRoleOwner
+	case WorkspaceRoleAdmin:
+		return role == WorkspaceRoleMember
+	default:
+		return false
+	}
+}
+
+// WorkspaceUser is a member of a workspace.
+type WorkspaceUser struct {
+	model.WorkspaceMember
+	Email string
+}
+
+// IsWorkspaceRole reports whether role can be given to someone joining a workspace.
+// Every workspace has exactly one owner, its creator.
+func IsWorkspaceRole(role string) bool {
+	return role == WorkspaceRoleAdmin || role == WorkspaceRoleMember
+}
+
+// WorkspaceListPermission is the permission a user has on a list in a workspace through
+// their role in it, or "" if they are not a member.
+func WorkspaceListPermission(role string, list model.List, userId int64) string {
+	if role == "" {
+		return ""
+	}
+	if list.OwnerID == userId {
+		return PermissionOwner
+	}
+	if role == WorkspaceRoleOwner || role == WorkspaceRoleAdmin {
+		return PermissionOwner
+	}
+	return PermissionEditor
+}
+
+// FilterWorkspaces returns the workspaces a user belongs to, personal workspace first.
+func (r *repository) FilterWorkspaces(ctx context.Context, userId int64) ([]UserWorkspace, error) {
+	r = r.reader(ctx)
+
+	stmt := SELECT(
+		Workspace.AllColumns,
+		WorkspaceMember.Role.AS("user_workspace.role"),
+	).FROM(
+		Workspace.INNER_JOIN(WorkspaceMember, WorkspaceMember.WorkspaceID.EQ(Workspace.ID)),
+	).WHERE(
+		WorkspaceMember.UserID.EQ(Int(userId)),
+	).ORDER_BY(
+		Workspace.PersonalUserID.IS_NULL().ASC(),
+		Workspace.Name.ASC(),
+	)
+
+	var results []UserWorkspace
+	if err := stmt.QueryContext(ctx, r.dbtx, &results); err != nil {
+		return nil, err
+	}
+
+	if results == nil {
+		results = make([]UserWorkspace, 0)
+	}
+
+	return results, nil
+}
+
+// GetWorkspaceRole returns a user's role in a workspace, or "" if they are not a member.
+func (r *repository) GetWorkspaceRole(ctx context.Context, workspaceId int64, userId int64) (string, error) {
+	r = r.reader(ctx)
+
+	stmt := WorkspaceMember.SELECT(WorkspaceMember.AllColumns).
+		WHERE(WorkspaceMember.WorkspaceID.EQ(Int(workspaceId)).AND(WorkspaceMember.UserID.EQ(Int(userId))))
+
+	var member model.WorkspaceMember
+	err := r.queryRow(ctx, stmt, &member)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+
+	return member.Role, err
+}
+
+// GetUserWorkspace returns a workspace with a user's role in it. It returns sql.ErrNoRows if
+// the workspace does not exist or the user is not a member.
+func (r *repository) GetUserWorkspace(ctx context.Context, workspaceId int64, userId int64) (UserWorkspace, error) {
+	r = r.reader(ctx)
+
+	stmt := SELECT(
+		Workspace.AllColumns,
+		WorkspaceMember.Role.AS("user_workspace.role"),
+	).FROM(
+		Workspace.INNER_JOIN(WorkspaceMember, WorkspaceMember.WorkspaceID.EQ(Workspace.ID)),
+	).WHERE(
+		Workspace.ID.EQ(Int(workspaceId)).AND(WorkspaceMember.UserID.EQ(Int(userId))),
+	)
+
+	var result UserWorkspace
+	err := r.queryRow(ctx, stmt, &result)
+	return result, err
+}
+
+// EnsurePersonalWorkspace returns the user's personal workspace, creating it if needed.
+func (r *repository) EnsurePersonalWorkspace(ctx context.Context, user model.AppUser) (model.Workspace, error) {
+	var result model.Workspace
+
+	err := r.inTransaction(ctx, func(rtx *repository) error {
+		var err error
+		result, err = rtx.ensurePersonalWorkspace(ctx, user.ID)
+		return err
+	})
+
+	return result, err
+}
+
+// ensurePersonalWorkspace is EnsurePersonalWorkspace for a repository already in a transaction.
+func (r *repository) ensurePersonalWorkspace(ctx context.Context, userId int64) (model.Workspace, error) {
+	var result model.Workspace
+
+	// the no-op update makes RETURNING produce the existing row on conflict
+	stmt := Workspace.INSERT(Workspace.Name, Workspace.PersonalUserID).
+		VALUES(personalWorkspaceName, userId).
+		ON_CONFLICT(Workspace.PersonalUserID).
+		DO_UPDATE(SET(Workspace.PersonalUserID.SET(Workspace.EXCLUDED.PersonalUserID))).
+		RETURNING(Workspace.AllColumns)
+	if err := r.queryRow(ctx, stmt, &result); err != nil {
+		return result, err
+	}
+
+	err := r.addWorkspaceMember(ctx, result.ID, userId, WorkspaceRoleOwner)
+	return result, err
+}
+
+// CreateWorkspace creates a team workspace owned by a user.
+func (r *repository) CreateWorkspace(ctx context.Context, name string, ownerId int64) (model.Workspace, error) {
+	var result model.Workspace
+
+	err := r.inTransaction(ctx, func(rtx *repository) error {
+		stmt := Workspace.INSERT(Workspace.Name).
+			VALUES(name).
+			RETURNING(Workspace.AllColumns)
+		if err := rtx.queryRow(ctx, stmt, &result); err != nil {
+			return err
+		}
+
+		if err := rtx.addWorkspaceMember(ctx, result.ID, ownerId, WorkspaceRoleOwner); err != nil {
+			return err
+		}
+
+		return rtx.recordWorkspaceChange(ctx, "workspace.create", result.ID, nil, map[string]string{"name": result.Name})
+	})
+
+	return result, err
+}
+
+func (r *repository) FilterWorkspaceMembers(ctx context.Context, workspaceId int64) ([]WorkspaceUser, error) {
+	r = r.reader(ctx)
+
+	stmt := SELECT(
+		WorkspaceMember.AllColumns,
+		AppUser.Email.AS("workspace_user.email"),
+	).FROM(
+		WorkspaceMember.INNER_JOIN(AppUser, AppUser.ID.EQ(WorkspaceMember.UserID)),
+	).WHERE(
+		WorkspaceMember.WorkspaceID.EQ(Int(workspaceId)),
+	).ORDER_BY(AppUser.Email.ASC())
+
+	var results []WorkspaceUser
+	if err := stmt.QueryContext(ctx, r.dbtx, &results); err != nil {
+		return nil, err
+	}
+
+	if results == nil {
+		results = make([]WorkspaceUser, 0)
+	}
+
+	return results, nil
+}
+
+// AddWorkspaceMember adds the registered user with the given email to a team workspace, or
+// changes their role if they are already a member. It returns sql.ErrNoRows if nobody has
+// registered with the email.
+func (r *repository) AddWorkspaceMember(ctx context.Context, workspaceId int64, email string, role string) (model.AppUser, error) {
+	workspace, err := r.getWorkspace(ctx, workspaceId)
+	if err != nil {
+		return model.AppUser{}, err
+	}
+	if workspace.PersonalUserID != nil {
+		return model.AppUser{}, ErrPersonalWorkspace
+	}
+
+	user, err := r.GetUserByEmail(ctx, email)
+	if err != nil {
+		return user, err
+	}
+
+	// the owner's role never changes
+	stmt := WorkspaceMember.INSERT(WorkspaceMember.WorkspaceID, WorkspaceMember.UserID, WorkspaceMember.Role).
+		VALUES(workspaceId, user.ID, role).
+		ON_CONFLICT(WorkspaceMember.WorkspaceID, WorkspaceMember.UserID).
+		DO_UPDATE(
+			SET(WorkspaceMember.Role.SET(WorkspaceMember.EXCLUDED.Role)).
+				WHERE(WorkspaceMember.Role.NOT_EQ(String(WorkspaceRoleOwner))),
+		)
+
+	err = r.inTransaction(ctx, func(rtx *repository) error {
+		previous, err := rtx.GetWorkspaceRole(ctx, workspaceId, user.ID)
+		if err != nil || previous == WorkspaceRoleOwner {
+			return err
+		}
+
+		if _, err = stmt.ExecContext(ctx, rtx.dbtx); err != nil {
+			return err
+		}
+
+		var before any
+		if previous != "" {
+			before = workspaceMemberState{UserID: user.ID, Email: user.Email, Role: previous}
+		}
+		return rtx.recordWorkspaceChange(ctx, "workspace.add_member", workspaceId, before,
+			workspaceMemberState{UserID: user.ID, Email: user.Email, Role: role})
+	})
+	return user, err
+}
+
+// RemoveWorkspaceMember removes a user from a workspace. Owners cannot be removed.
+func (r *repository) RemoveWorkspaceMember(ctx context.Context, workspaceId int64, userId int64) error {
+	stmt := WorkspaceMember.DELETE().
+		WHERE(
+			WorkspaceMember.WorkspaceID.EQ(Int(workspaceId)).
+				AND(WorkspaceMember.UserID.EQ(Int(userId))).
+				AND(WorkspaceMember.Role.NOT_EQ(String(WorkspaceRoleOwner))),
+		).
+		RETURNING(WorkspaceMember.AllColumns)
+
+	return r.inTransaction(ctx, func(rtx *repository) error {
+		var removed []model.WorkspaceMember
+		if err := stmt.QueryContext(ctx, rtx.dbtx, &removed); err != nil || len(removed) == 0 {
+			return err
+		}
+
+		return rtx.recordWorkspaceChange(ctx, "workspace.remove_member", workspaceId,
+			workspaceMemberState{UserID: userId, Role: removed[0].Role}, nil)
+	})
+}
+
+// workspaceMemberState is what the audit log records of a workspace member.
+type workspaceMemberState struct {
+	UserID int64  `json:"user_id"`
+	Email  string `json:"email,omitempty"`
+	Role   string `json:"role"`
+}
+
+// recordWorkspaceChange records a change to a workspace or its members.
+func (r *repository) recordWorkspaceChange(ctx context.Context, action string, workspaceId int64, before any, after any) error {
+	return r.recordChange(ctx, change{
+		Action:      action,
+		EntityType:  EntityWorkspace,
+		EntityID:    workspaceId,
+		WorkspaceID: &workspaceId,
+		Before:      before,
+		After:       after,
+	})
+}
+
+func (r *repository) getWorkspace(ctx context.Context, id int64) (model.Workspace, error) {
+	stmt := Workspace.SELECT(Workspace.AllColumns).WHERE(Workspace.ID.EQ(Int(id))).LIMIT(1)
+
+	var result model.Workspace
+	err := r.queryRow(ctx, stmt, &result)
+	return result, err
+}
+
+func (r *repository) addWorkspaceMember(ctx context.Context, workspaceId int64, userId int64, role string) error {
+	stmt := WorkspaceMember.INSERT(WorkspaceMember.WorkspaceID, WorkspaceMember.UserID, WorkspaceMember.Role).
+		VALUES(workspaceId, userId, role).
+		ON_CONFLICT(WorkspaceMember.WorkspaceID, WorkspaceMember.UserID).
+		DO_NOTHING()
+
+	_, err := stmt.ExecContext(ctx, r.dbtx)
+	return err
+}
@@ -0,0 +1,172 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	smtypes "github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ErrNotFound is returned by providers that do not have a secret.
+var ErrNotFound = errors.New("secret not found")
+
+// Provider looks secrets up by name.
+type Provider interface {
+	Get(ctx context.Context, name string) (string, error)
+}
+
+// Static provides the secrets loaded with the config. Empty values are not found, so
+// later providers can supply them.
+func Static(values Values) Provider {
+	return static{
+		DatabaseUrlName:        values.DatabaseUrl,
+		DatabaseReplicaUrlName: values.DatabaseReplicaUrl,
+		CognitoClientIdName:    values.CognitoClientId,
+		CognitoUserPoolIdName:  values.CognitoUserPoolId,
+		RedisUrlName:           values.RedisUrl,
+		SessionSecretName:      values.SessionSecret,
+		CaptchaSecretName:      values.CaptchaSecret,
+	}
+}
+
+type static map[string]string
+
+func (p static) Get(ctx context.Context, name string) (string, error) {
+	if value := p[name]; value != "" {
+		return value, nil
+	}
+	return "", ErrNotFound
+}
+
+// Dir provides secrets from files named after them, as Docker and Kubernetes mount
+// secrets, e.g. /run/secrets/database_url. A trailing newline is ignored.
+func Dir(path string) Provider {
+	return dir(path)
+}
+
+type dir string
+
+func (p dir) Get(ctx context.Context, name string) (string, error) {
+	b, err := os.ReadFile(filepath.Join(string(p), name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+// SecretsManagerClient is the subset of the Secrets Manager API the provider uses,
+// satisfied by *secretsmanager.Client.
+type SecretsManagerClient interface {
+	GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error)
+}
+
+// SecretsManager provides secrets from AWS Secrets Manager, named with a prefix such as
+// "htmxtodo/production/". A database_url secret can also be a JSON object in the format
+// of RDS managed rotation, with a username, password, host, port and dbname.
+func SecretsManager(client SecretsManagerClient, prefix string) Provider {
+	return secretsManager{client: client, prefix: prefix}
+}
+
+type secretsManager struct {
+	client SecretsManagerClient
+	prefix string
+}
+
+func (p secretsManager) Get(ctx context.Context, name string) (string, error) {
+	output, err := p.client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(p.prefix + name),
+	})
+	var notFound *smtypes.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	value := aws.ToString(output.SecretString)
+	if name == DatabaseUrlName && strings.HasPrefix(strings.TrimSpace(value), "{") {
+		return rdsDatabaseUrl(value)
+	}
+	return value, nil
+}
+
+// rdsDatabaseUrl builds a connection URL from a secret in the format RDS rotates.
+func rdsDatabaseUrl(secret string) (string, error) {
+	var rds struct {
+		Username string          `json:"username"`
+		Password string          `json:"password"`
+		Host     string          `json:"host"`
+		Port     json.RawMessage `json:"port"`
+		Dbname   string          `json:"dbname"`
+	}
+	if err := json.Unmarshal([]byte(secret), &rds); err != nil {
+		return "", fmt.Errorf("invalid database secret: %w", err)
+	}
+	if rds.Host == "" {
+		return "", errors.New("invalid database secret: it has no host")
+	}
+
+	// the port is a number or a string, depending on what wrote the secret
+	host := rds.Host
+	if port := strings.Trim(string(rds.Port), `"`); port != "" {
+		if _, err := strconv.Atoi(port); err != nil {
+			return "", fmt.Errorf("invalid database secret: port %s", rds.Port)
+		}
+		host += ":" + port
+	}
+
+	u := url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(rds.Username, rds.Password),
+		Host:   host,
+		Path:   "/" + rds.Dbname,
+	}
+	return u.String(), nil
+}
+
+// ParameterStoreClient is the subset of the SSM API the provider uses, satisfied by
+// *ssm.Client.
+type ParameterStoreClient interface {
+	GetParameter(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error)
+}
+
+// ParameterStore provides secrets from SSM Parameter Store, named with a prefix such as
+// "/htmxtodo/production/". SecureString parameters are decrypted.
+func ParameterStore(client ParameterStoreClient, prefix string) Provider {
+	return parameterStore{client: client, prefix: prefix}
+}
+
+type parameterStore struct {
+	client ParameterStoreClient
+	prefix string
+}
+
+func (p parameterStore) Get(ctx context.Context, name string) (string, error) {
+	output, err := p.client.GetParameter(ctx, &ssm.GetParameterInput{
+		Name:           aws.String(p.prefix + name),
+		WithDecryption: aws.Bool(true),
+	})
+	var notFound *ssmtypes.ParameterNotFound
+	if errors.As(err, &notFound) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(output.Parameter.Value), nil
+}
@@ -1,30 +1,183 @@
 package secrets
 
 import (
-	"os"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
 )
 
+// The names of the secrets, as providers know them.
+const (
+	DatabaseUrlName        = "database_url"
+	DatabaseReplicaUrlName = "database_replica_url"
+	CognitoClientIdName    = "cognito_client_id"
+	CognitoUserPoolIdName  = "cognito_user_pool_id"
+	RedisUrlName           = "redis_url"
+	SessionSecretName      = "session_secret"
+	CaptchaSecretName      = "captcha_secret"
+)
+
+var names = []string{DatabaseUrlName, DatabaseReplicaUrlName, CognitoClientIdName, CognitoUserPoolIdName, RedisUrlName, SessionSecretName, CaptchaSecretName}
+
 type Secrets interface {
+	// Get returns the named secret.
+	Get(name string) string
 	DatabaseUrl() string
+	DatabaseReplicaUrl() string
 	CognitoClientId() string
+	CognitoUserPoolId() string
+	RedisUrl() string
+	SessionSecret() string
+	CaptchaSecret() string
+	// OnChange registers fn to be called after the named secret changes, such as when a
+	// password is rotated.
+	OnChange(name string, fn func())
+}
+
+// Values are the secrets as they were loaded with the rest of the config.
+type Values struct {
+	DatabaseUrl        string
+	DatabaseReplicaUrl string
+	CognitoClientId    string
+	CognitoUserPoolId  string
+	RedisUrl           string
+	SessionSecret      string
+	CaptchaSecret      string
+}
+
+// New returns secrets that never change.
+func New(values Values) Secrets {
+	store := &Store{providers: []Provider{Static(values)}}
+	if err := store.Refresh(context.Background()); err != nil {
+		panic(err)
+	}
+	return store
+}
+
+// Store caches secrets from a list of providers, and refreshes them on request. A secret
+// comes from the first provider that has it, and is empty if none does.
+type Store struct {
+	providers []Provider
+
+	mu       sync.RWMutex
+	values   map[string]string
+	watchers map[string][]func()
+}
+
+// Load returns a Store with every secret loaded from the providers.
+func Load(ctx context.Context, providers ...Provider) (*Store, error) {
+	store := &Store{providers: providers}
+	if err := store.Refresh(ctx); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// Refresh loads every secret again, then calls the watchers of those that changed. When
+// a provider fails, the secret keeps its cached value, so that a secrets service being
+// briefly unavailable does not take the app down with it.
+func (s *Store) Refresh(ctx context.Context) error {
+	var errs []error
+	var changed []func()
+
+	for _, name := range names {
+		value, err := s.lookup(ctx, name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+
+		s.mu.Lock()
+		if old, ok := s.values[name]; !ok || old != value {
+			if s.values == nil {
+				s.values = map[string]string{}
+			}
+			s.values[name] = value
+			if ok {
+				changed = append(changed, s.watchers[name]...)
+			}
+		}
+		s.mu.Unlock()
+	}
+
+	for _, fn := range changed {
+		fn()
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Store) lookup(ctx context.Context, name string) (string, error) {
+	for _, provider := range s.providers {
+		value, err := provider.Get(ctx, name)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		return value, err
+	}
+	return "", nil
+}
+
+// Run refreshes the secrets every interval until ctx is done.
+func (s *Store) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := s.Refresh(ctx); err != nil {
+			log.Printf("failed to refresh secrets: %v", err)
+		}
+	}
 }
 
-func New() Secrets {
-	return &secrets{
-		databaseUrl:     os.Getenv("DATABASE_URL"),
-		cognitoClientId: os.Getenv("COGNITO_CLIENT_ID"),
+func (s *Store) OnChange(name string, fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.watchers == nil {
+		s.watchers = map[string][]func(){}
 	}
+	s.watchers[name] = append(s.watchers[name], fn)
+}
+
+func (s *Store) Get(name string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.values[name]
+}
+
+func (s *Store) DatabaseUrl() string {
+	return s.Get(DatabaseUrlName)
+}
+
+func (s *Store) DatabaseReplicaUrl() string {
+	return s.Get(DatabaseReplicaUrlName)
+}
+
+func (s *Store) CognitoClientId() string {
+	return s.Get(CognitoClientIdName)
+}
+
+func (s *Store) CognitoUserPoolId() string {
+	return s.Get(CognitoUserPoolIdName)
 }
 
-type secrets struct {
-	databaseUrl     string
-	cognitoClientId string
+func (s *Store) RedisUrl() string {
+	return s.Get(RedisUrlName)
 }
 
-func (s secrets) DatabaseUrl() string {
-	return s.databaseUrl
+func (s *Store) SessionSecret() string {
+	return s.Get(SessionSecretName)
 }
 
-func (s secrets) CognitoClientId() string {
-	return s.cognitoClientId
+func (s *Store) CaptchaSecret() string {
+	return s.Get(CaptchaSecretName)
 }
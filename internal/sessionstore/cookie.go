@@ -0,0 +1,289 @@
+package sessionstore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+	"sync"
+	"time"
+)
+
+// maxCookieSize leaves room for the cookie name and attributes within the 4096 bytes
+// browsers are guaranteed to store.
+const maxCookieSize = 3800
+
+var ErrCookieTooLarge = errors.New("session data is too large to store in a cookie")
+
+// CookieStorage keeps session data in an encrypted cookie next to the session ID cookie, so
+// nothing needs to be stored on the server. fiber.Storage has no access to the request, so
+// Handler loads the cookie into memory for the duration of each request and writes any
+// changes back to the response.
+//
+// The data is encrypted and authenticated with AES-GCM, bound to the session ID so it cannot
+// be replayed under a different ID. Deleting a session only clears the cookie, so unlike the
+// server-side backends a copied cookie stays valid until it expires.
+type CookieStorage struct {
+	aead         cipher.AEAD
+	cookieName   string
+	cookieSecure bool
+
+	mu      sync.Mutex
+	entries map[string]*cookieEntry
+}
+
+type cookieEntry struct {
+	val    []byte
+	exp    time.Time
+	sealed string
+	dirty  bool
+	refs   int
+}
+
+// NewCookieStorage returns a CookieStorage for the session ID cookie named cookieName. The
+// encryption key is derived from secret, which should be long and random.
+func NewCookieStorage(secret string, cookieName string, cookieSecure bool) (*CookieStorage, error) {
+	if secret == "" {
+		return nil, errors.New("a session secret is required for the cookie session backend")
+	}
+
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CookieStorage{
+		aead:         aead,
+		cookieName:   cookieName,
+		cookieSecure: cookieSecure,
+		entries:      make(map[string]*cookieEntry),
+	}, nil
+}
+
+// Handler must be installed before any middleware or handler that uses the session.
+func (s *CookieStorage) Handler(c *fiber.Ctx) error {
+	id := c.Cookies(s.cookieName)
+	s.load(id, c.Cookies(s.dataCookieName()))
+	defer s.release(id)
+
+	err := c.Next()
+
+	s.flush(c, id)
+	return err
+}
+
+func (s *CookieStorage) Get(key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok || entry.val == nil || (!entry.exp.IsZero() && !time.Now().Before(entry.exp)) {
+		return nil, nil
+	}
+
+	return entry.val, nil
+}
+
+func (s *CookieStorage) Set(key string, val []byte, exp time.Duration) error {
+	if len(key) == 0 || len(val) == 0 {
+		return nil
+	}
+
+	var expiresAt time.Time
+	if exp > 0 {
+		expiresAt = time.Now().Add(exp)
+	}
+
+	sealed := s.seal(key, val, expiresAt)
+	if len(sealed) > maxCookieSize {
+		return ErrCookieTooLarge
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.entry(key)
+	entry.val = append([]byte(nil), val...)
+	entry.exp = expiresAt
+	entry.sealed = sealed
+	entry.dirty = true
+
+	return nil
+}
+
+func (s *CookieStorage) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.entries[key]; ok {
+		entry.val = nil
+		entry.sealed = ""
+		entry.dirty = true
+	}
+	return nil
+}
+
+// Reset forgets the sessions of in-flight requests. Sessions held by browsers cannot be
+// reached, so rotate the secret to invalidate them.
+func (s *CookieStorage) Reset() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries = make(map[string]*cookieEntry)
+	return nil
+}
+
+func (s *CookieStorage) Close() error {
+	return nil
+}
+
+func (s *CookieStorage) dataCookieName() string {
+	return s.cookieName + "_data"
+}
+
+// entry returns the entry for id, creating it if needed. s.mu must be held.
+func (s *CookieStorage) entry(id string) *cookieEntry {
+	entry, ok := s.entries[id]
+	if !ok {
+		entry = &cookieEntry{}
+		s.entries[id] = entry
+	}
+	return entry
+}
+
+// load makes the data cookie available to Get for the rest of the request. A cookie that
+// fails to decrypt, for example because it was tampered with, is treated as no data.
+func (s *CookieStorage) load(id string, sealed string) {
+	if id == "" {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.entry(id)
+	entry.refs++
+	if entry.refs == 1 && sealed != "" {
+		if val, exp, err := s.open(id, sealed); err == nil {
+			entry.val = val
+			entry.exp = exp
+			entry.sealed = sealed
+		}
+	}
+}
+
+// flush writes the data for whichever session ID the response ends up with, which differs
+// from the request's after a session is reset or regenerated.
+func (s *CookieStorage) flush(c *fiber.Ctx, requestId string) {
+	id := requestId
+	idCookie := fasthttp.AcquireCookie()
+	defer fasthttp.ReleaseCookie(idCookie)
+	idCookie.SetKey(s.cookieName)
+	if c.Response().Header.Cookie(idCookie) {
+		if idCookie.MaxAge() < 0 {
+			id = ""
+		} else {
+			id = string(idCookie.Value())
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[id]
+	switch {
+	case id == "" && c.Cookies(s.dataCookieName()) != "":
+		s.setDataCookie(c, "", time.Now().Add(-time.Minute))
+	case ok && entry.dirty:
+		if entry.sealed == "" {
+			s.setDataCookie(c, "", time.Now().Add(-time.Minute))
+		} else {
+			s.setDataCookie(c, entry.sealed, entry.exp)
+		}
+	}
+
+	// entries created by Set under a new ID are not referenced by any request
+	if ok && id != requestId && entry.refs == 0 {
+		delete(s.entries, id)
+	}
+}
+
+func (s *CookieStorage) release(id string) {
+	if id == "" {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.entries[id]; ok {
+		entry.refs--
+		if entry.refs <= 0 {
+			delete(s.entries, id)
+		}
+	}
+}
+
+func (s *CookieStorage) setDataCookie(c *fiber.Ctx, value string, expires time.Time) {
+	c.Cookie(&fiber.Cookie{
+		Name:     s.dataCookieName(),
+		Value:    value,
+		Path:     "/",
+		Expires:  expires,
+		Secure:   s.cookieSecure,
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+}
+
+// seal encrypts the expiry time and data, authenticating the session ID alongside them.
+func (s *CookieStorage) seal(id string, val []byte, exp time.Time) string {
+	plaintext := make([]byte, 8, 8+len(val))
+	if !exp.IsZero() {
+		binary.BigEndian.PutUint64(plaintext, uint64(exp.Unix()))
+	}
+	plaintext = append(plaintext, val...)
+
+	nonce := make([]byte, s.aead.NonceSize(), s.aead.NonceSize()+len(plaintext)+s.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		panic(err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(s.aead.Seal(nonce, nonce, plaintext, []byte(id)))
+}
+
+func (s *CookieStorage) open(id string, sealed string) ([]byte, time.Time, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(sealed)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if len(raw) < s.aead.NonceSize() {
+		return nil, time.Time{}, errors.New("session cookie is too short")
+	}
+
+	nonce, ciphertext := raw[:s.aead.NonceSize()], raw[s.aead.NonceSize():]
+	plaintext, err := s.aead.Open(nil, nonce, ciphertext, []byte(id))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if len(plaintext) < 8 {
+		return nil, time.Time{}, errors.New("session cookie is too short")
+	}
+
+	var exp time.Time
+	if unix := binary.BigEndian.Uint64(plaintext[:8]); unix != 0 {
+		exp = time.Unix(int64(unix), 0)
+	}
+
+	return plaintext[8:], exp, nil
+}
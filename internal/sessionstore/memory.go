@@ -0,0 +1,87 @@
+package sessionstore
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryStorage keeps sessions in process memory. Sessions are lost on restart and are not
+// shared between instances, so it is only suitable for tests and local development.
+type MemoryStorage struct {
+	mu      sync.RWMutex
+	entries map[string]memoryEntry
+}
+
+type memoryEntry struct {
+	val []byte
+	exp time.Time
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		entries: make(map[string]memoryEntry),
+	}
+}
+
+func (s *MemoryStorage) Get(key string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entry, ok := s.entries[key]
+	if !ok || entry.expired() {
+		return nil, nil
+	}
+
+	return entry.val, nil
+}
+
+func (s *MemoryStorage) Set(key string, val []byte, exp time.Duration) error {
+	if len(key) == 0 || len(val) == 0 {
+		return nil
+	}
+
+	entry := memoryEntry{
+		// callers may reuse val after Set returns
+		val: append([]byte(nil), val...),
+	}
+	if exp > 0 {
+		entry.exp = time.Now().Add(exp)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// piggyback garbage collection on writes rather than running a goroutine
+	for k, e := range s.entries {
+		if e.expired() {
+			delete(s.entries, k)
+		}
+	}
+	s.entries[key] = entry
+
+	return nil
+}
+
+func (s *MemoryStorage) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
+
+func (s *MemoryStorage) Reset() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries = make(map[string]memoryEntry)
+	return nil
+}
+
+func (s *MemoryStorage) Close() error {
+	return nil
+}
+
+func (e memoryEntry) expired() bool {
+	return !e.exp.IsZero() && !time.Now().Before(e.exp)
+}
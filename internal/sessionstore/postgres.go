@@ -0,0 +1,111 @@
+package sessionstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// postgresGCInterval is how often expired sessions are deleted.
+const postgresGCInterval = time.Minute
+
+// The table is the one gofiber/storage/postgres creates, so that sessions survive the
+// switch from it.
+var postgresSchema = []string{
+	`CREATE TABLE IF NOT EXISTS fiber_storage (
+		k  VARCHAR(64) PRIMARY KEY NOT NULL DEFAULT '',
+		v  BYTEA NOT NULL,
+		e  BIGINT NOT NULL DEFAULT '0'
+	)`,
+	`CREATE INDEX IF NOT EXISTS e ON fiber_storage (e)`,
+}
+
+// PostgresStorage keeps sessions in the fiber_storage table, using the app's connection
+// pool rather than one of its own. Expired sessions are deleted periodically.
+type PostgresStorage struct {
+	db   *sql.DB
+	done chan struct{}
+}
+
+// NewPostgresStorage creates the sessions table if needed. Closing the storage does not
+// close db, which belongs to the caller.
+func NewPostgresStorage(db *sql.DB) (*PostgresStorage, error) {
+	for _, query := range postgresSchema {
+		if _, err := db.Exec(query); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &PostgresStorage{db: db, done: make(chan struct{})}
+	go s.gc()
+	return s, nil
+}
+
+func (s *PostgresStorage) Get(key string) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, nil
+	}
+
+	var val []byte
+	var exp int64
+	err := s.db.QueryRowContext(context.Background(), `SELECT v, e FROM fiber_storage WHERE k = $1`, key).Scan(&val, &exp)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if exp != 0 && exp <= time.Now().Unix() {
+		return nil, nil
+	}
+	return val, nil
+}
+
+func (s *PostgresStorage) Set(key string, val []byte, exp time.Duration) error {
+	if len(key) == 0 || len(val) == 0 {
+		return nil
+	}
+
+	var expUnix int64
+	if exp != 0 {
+		expUnix = time.Now().Add(exp).Unix()
+	}
+	_, err := s.db.ExecContext(context.Background(),
+		`INSERT INTO fiber_storage (k, v, e) VALUES ($1, $2, $3) ON CONFLICT (k) DO UPDATE SET v = $2, e = $3`,
+		key, val, expUnix)
+	return err
+}
+
+func (s *PostgresStorage) Delete(key string) error {
+	if len(key) == 0 {
+		return nil
+	}
+	_, err := s.db.ExecContext(context.Background(), `DELETE FROM fiber_storage WHERE k = $1`, key)
+	return err
+}
+
+func (s *PostgresStorage) Reset() error {
+	_, err := s.db.ExecContext(context.Background(), `TRUNCATE TABLE fiber_storage`)
+	return err
+}
+
+func (s *PostgresStorage) Close() error {
+	close(s.done)
+	return nil
+}
+
+func (s *PostgresStorage) gc() {
+	ticker := time.NewTicker(postgresGCInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case t := <-ticker.C:
+			_, _ = s.db.Exec(`DELETE FROM fiber_storage WHERE e <= $1 AND e != 0`, t.Unix())
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package sessionstore
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"sync"
+	"time"
+)
+
+// ReloadableStorage passes calls on to a storage that can be swapped for another while in
+// use, such as one connected with rotated credentials.
+type ReloadableStorage struct {
+	mu      sync.RWMutex
+	storage fiber.Storage
+}
+
+func NewReloadableStorage(storage fiber.Storage) *ReloadableStorage {
+	return &ReloadableStorage{storage: storage}
+}
+
+// Swap replaces the storage once calls in flight have finished, and closes the one it
+// replaced.
+func (s *ReloadableStorage) Swap(storage fiber.Storage) error {
+	s.mu.Lock()
+	old := s.storage
+	s.storage = storage
+	s.mu.Unlock()
+
+	return old.Close()
+}
+
+func (s *ReloadableStorage) Get(key string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.storage.Get(key)
+}
+
+func (s *ReloadableStorage) Set(key string, val []byte, exp time.Duration) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.storage.Set(key, val, exp)
+}
+
+func (s *ReloadableStorage) Delete(key string) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.storage.Delete(key)
+}
+
+func (s *ReloadableStorage) Reset() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.storage.Reset()
+}
+
+func (s *ReloadableStorage) Close() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.storage.Close()
+}
@@ -0,0 +1,48 @@
+package sessionstore
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	BackendPostgres = "postgres"
+	BackendMemory   = "memory"
+	BackendRedis    = "redis"
+	BackendCookie   = "cookie"
+)
+
+// Config selects and configures the session storage backend. Only the settings for the
+// chosen backend need to be filled in. The postgres backend shares DB with the app.
+type Config struct {
+	Backend  string
+	DB       *sql.DB
+	RedisUrl string
+	// RedisKeyPrefix namespaces the keys in Redis, by default as sessions.
+	RedisKeyPrefix string
+	Secret         string
+	CookieName     string
+	CookieSecure   bool
+}
+
+// New returns the fiber.Storage for the configured backend. The cookie backend also needs
+// its Handler installed before any middleware that uses the session.
+func New(cfg Config) (fiber.Storage, error) {
+	switch cfg.Backend {
+	case BackendPostgres, "":
+		if cfg.DB == nil {
+			return nil, errors.New("the postgres session backend needs a database")
+		}
+		return NewPostgresStorage(cfg.DB)
+	case BackendMemory:
+		return NewMemoryStorage(), nil
+	case BackendRedis:
+		return NewRedisStorage(cfg.RedisUrl, cfg.RedisKeyPrefix)
+	case BackendCookie:
+		return NewCookieStorage(cfg.Secret, cfg.CookieName, cfg.CookieSecure)
+	default:
+		return nil, fmt.Errorf("unknown session backend %q", cfg.Backend)
+	}
+}
@@ -0,0 +1,303 @@
+package sessionstore
+
+import (
+	"bytes"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/session"
+	"htmxtodo/internal/testdb"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testStorage is the conformance suite every session backend must pass. wait must let at
+// least the given duration pass from the backend's point of view.
+func testStorage(t *testing.T, storage fiber.Storage, wait func(time.Duration)) {
+	t.Run("get missing key", func(t *testing.T) {
+		val, err := storage.Get("missing")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if val != nil {
+			t.Fatalf("expected nil, got %q", val)
+		}
+	})
+
+	t.Run("set and get", func(t *testing.T) {
+		if err := storage.Set("set", []byte("value"), time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		assertValue(t, storage, "set", "value")
+	})
+
+	t.Run("set copies value", func(t *testing.T) {
+		val := []byte("value")
+		if err := storage.Set("copy", val, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		copy(val, "XXXXX")
+		assertValue(t, storage, "copy", "value")
+	})
+
+	t.Run("overwrite", func(t *testing.T) {
+		if err := storage.Set("overwrite", []byte("first"), time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		if err := storage.Set("overwrite", []byte("second"), time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		assertValue(t, storage, "overwrite", "second")
+	})
+
+	t.Run("ignores empty key and value", func(t *testing.T) {
+		if err := storage.Set("", []byte("value"), time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		if err := storage.Set("empty", nil, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		assertValue(t, storage, "empty", "")
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		if err := storage.Set("delete", []byte("value"), time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		if err := storage.Delete("delete"); err != nil {
+			t.Fatal(err)
+		}
+		assertValue(t, storage, "delete", "")
+
+		if err := storage.Delete("delete"); err != nil {
+			t.Fatal("deleting a missing key should not fail: ", err)
+		}
+	})
+
+	t.Run("expiry", func(t *testing.T) {
+		if err := storage.Set("expires", []byte("value"), time.Second); err != nil {
+			t.Fatal(err)
+		}
+		if err := storage.Set("forever", []byte("value"), 0); err != nil {
+			t.Fatal(err)
+		}
+		assertValue(t, storage, "expires", "value")
+
+		wait(2 * time.Second)
+
+		assertValue(t, storage, "expires", "")
+		assertValue(t, storage, "forever", "value")
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		if err := storage.Set("reset", []byte("value"), time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		if err := storage.Reset(); err != nil {
+			t.Fatal(err)
+		}
+		assertValue(t, storage, "reset", "")
+	})
+
+	if err := storage.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertValue(t *testing.T, storage fiber.Storage, key string, expected string) {
+	t.Helper()
+
+	val, err := storage.Get(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected == "" && val != nil {
+		t.Fatalf("expected %q to be missing, got %q", key, val)
+	}
+	if string(val) != expected {
+		t.Fatalf("expected %q to be %q, got %q", key, expected, val)
+	}
+}
+
+func TestMemoryStorage(t *testing.T) {
+	t.Parallel()
+	testStorage(t, NewMemoryStorage(), time.Sleep)
+}
+
+func TestRedisStorage(t *testing.T) {
+	t.Parallel()
+	server := miniredis.RunT(t)
+
+	storage, err := NewRedisStorage("redis://"+server.Addr(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testStorage(t, storage, server.FastForward)
+}
+
+func TestReloadableStorage(t *testing.T) {
+	t.Parallel()
+	server := miniredis.RunT(t)
+	server.RequireAuth("old")
+
+	old, err := NewRedisStorage("redis://:old@"+server.Addr(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	storage := NewReloadableStorage(old)
+	if err = storage.Set("rotated", []byte("value"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	// the password is rotated, so only new connections authenticate
+	server.RequireAuth("new")
+	rotated, err := NewRedisStorage("redis://:new@"+server.Addr(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = storage.Swap(rotated); err != nil {
+		t.Fatal(err)
+	}
+
+	assertValue(t, storage, "rotated", "value")
+	if _, err = old.Get("rotated"); err == nil {
+		t.Fatal("expected the old storage to be closed")
+	}
+}
+
+func TestCookieStorage(t *testing.T) {
+	t.Parallel()
+
+	storage, err := NewCookieStorage("secret", "session_id", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testStorage(t, storage, time.Sleep)
+}
+
+func TestPostgresStorage(t *testing.T) {
+	t.Parallel()
+
+	storage, err := New(Config{Backend: BackendPostgres, DB: testdb.New(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testStorage(t, storage, time.Sleep)
+}
+
+func TestCookieStorageRoundTrip(t *testing.T) {
+	storage, err := NewCookieStorage("secret", "session_id", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := session.New(session.Config{
+		KeyLookup: "cookie:session_id",
+		Storage:   storage,
+	})
+
+	app := fiber.New()
+	app.Use(storage.Handler)
+	app.Post("/", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		sess.Set("name", string(c.Body()))
+		return sess.Save()
+	})
+	app.Get("/", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		name, _ := sess.Get("name").(string)
+		return c.SendString(name)
+	})
+	app.Delete("/", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		return sess.Destroy()
+	})
+
+	resp, err := app.Test(httptest.NewRequest("POST", "/", strings.NewReader("Alice")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookies := resp.Cookies()
+	if len(cookies) != 2 {
+		t.Fatalf("expected session ID and data cookies, got %v", cookies)
+	}
+
+	if body := get(t, app, cookies...); body != "Alice" {
+		t.Fatalf("expected session data to round trip, got %q", body)
+	}
+
+	if len(storage.entries) != 0 {
+		t.Fatalf("expected no sessions held in memory between requests, got %d", len(storage.entries))
+	}
+
+	t.Run("tampered cookie", func(t *testing.T) {
+		tampered := *cookieNamed(cookies, "session_id_data")
+		tampered.Value = tampered.Value[:len(tampered.Value)-2] + "AA"
+		if body := get(t, app, cookieNamed(cookies, "session_id"), &tampered); body != "" {
+			t.Fatalf("expected tampered data to be ignored, got %q", body)
+		}
+	})
+
+	t.Run("data moved to another session", func(t *testing.T) {
+		otherId := &http.Cookie{Name: "session_id", Value: "00000000-0000-0000-0000-000000000000"}
+		if body := get(t, app, otherId, cookieNamed(cookies, "session_id_data")); body != "" {
+			t.Fatalf("expected data bound to another session to be ignored, got %q", body)
+		}
+	})
+
+	t.Run("destroy clears cookie", func(t *testing.T) {
+		req := httptest.NewRequest("DELETE", "/", nil)
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := cookieNamed(resp.Cookies(), "session_id_data")
+		if data == nil || data.Value != "" {
+			t.Fatalf("expected data cookie to be cleared, got %v", data)
+		}
+	})
+}
+
+func get(t *testing.T, app *fiber.App, cookies ...*http.Cookie) string {
+	t.Helper()
+
+	req := httptest.NewRequest("GET", "/", nil)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(bytes.TrimSpace(body))
+}
+
+func cookieNamed(cookies []*http.Cookie, name string) *http.Cookie {
+	for _, cookie := range cookies {
+		if cookie.Name == name {
+			return cookie
+		}
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package testdb
+
+import (
+	"context"
+	"gopkg.in/yaml.v3"
+	"htmxtodo/gen/htmxtodo_dev/public/model"
+	"os"
+	"testing"
+)
+
+// Repository is the part of repo.Repository fixtures are loaded through. It is declared
+// here so that the repo package's own tests can load fixtures.
+type Repository interface {
+	FindOrCreateUser(ctx context.Context, email string) (model.AppUser, error)
+	UpdateUserRole(ctx context.Context, id int64, role string) (model.AppUser, error)
+	EnsurePersonalWorkspace(ctx context.Context, user model.AppUser) (model.Workspace, error)
+	CreateWorkspace(ctx context.Context, name string, ownerId int64) (model.Workspace, error)
+	AddWorkspaceMember(ctx context.Context, workspaceId int64, email string, role string) (model.AppUser, error)
+	CreateList(ctx context.Context, workspaceId int64, ownerId int64, name string) (model.List, error)
+	CreateItem(ctx context.Context, listId int64, name string) (model.Item, error)
+	ShareList(ctx context.Context, listId int64, email string, permission string, invitedById int64) (bool, error)
+}
+
+// Fixtures describe users, workspaces, lists and items for a test to start with. They can
+// be written in Go, or in YAML and read with ReadFixtures:
+//
+//	users:
+//	  - email: alice@example.com
+//	    role: admin
+//	  - email: bob@example.com
+//	workspaces:
+//	  - name: Team
+//	    owner: alice@example.com
+//	    members: {bob@example.com: member}
+//	lists:
+//	  - name: Groceries
+//	    owner: bob@example.com
+//	    items: [Milk, Eggs]
+//	    members: {alice@example.com: viewer}
+type Fixtures struct {
+	Users      []UserFixture      `yaml:"users"`
+	Workspaces []WorkspaceFixture `yaml:"workspaces"`
+	Lists      []ListFixture      `yaml:"lists"`
+}
+
+type UserFixture struct {
+	Email string `yaml:"email"`
+	// Role defaults to the role new users get.
+	Role string `yaml:"role"`
+}
+
+type WorkspaceFixture struct {
+	Name  string `yaml:"name"`
+	Owner string `yaml:"owner"`
+	// Members maps the emails of other members to their roles.
+	Members map[string]string `yaml:"members"`
+}
+
+type ListFixture struct {
+	Name  string `yaml:"name"`
+	Owner string `yaml:"owner"`
+	// Workspace names a workspace from the fixtures. Lists without one are in their
+	// owner's personal workspace.
+	Workspace string   `yaml:"workspace"`
+	Items     []string `yaml:"items"`
+	// Members maps the emails of the users the list is shared with to their permissions.
+	Members map[string]string `yaml:"members"`
+}
+
+// Loaded are the rows fixtures created, keyed by email or name.
+type Loaded struct {
+	Users      map[string]model.AppUser
+	Personal   map[string]model.Workspace
+	Workspaces map[string]model.Workspace
+	Lists      map[string]model.List
+	Items      map[string]model.Item
+}
+
+// ReadFixtures reads fixtures from a YAML file.
+func ReadFixtures(t *testing.T, path string) Fixtures {
+	t.Helper()
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fixtures Fixtures
+	if err = yaml.Unmarshal(b, &fixtures); err != nil {
+		t.Fatalf("invalid fixtures in %s: %v", path, err)
+	}
+	return fixtures
+}
+
+// Load creates the fixtures through a repository, so they can be used with any
+// implementation. Users named only as owners or members are created too.
+func (f Fixtures) Load(t *testing.T, r Repository) Loaded {
+	t.Helper()
+	ctx := context.Background()
+
+	loaded := Loaded{
+		Users:      map[string]model.AppUser{},
+		Personal:   map[string]model.Workspace{},
+		Workspaces: map[string]model.Workspace{},
+		Lists:      map[string]model.List{},
+		Items:      map[string]model.Item{},
+	}
+
+	user := func(email string) model.AppUser {
+		t.Helper()
+		if u, ok := loaded.Users[email]; ok {
+			return u
+		}
+
+		u, err := r.FindOrCreateUser(ctx, email)
+		if err != nil {
+			t.Fatal(err)
+		}
+		personal, err := r.EnsurePersonalWorkspace(ctx, u)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		loaded.Users[email] = u
+		loaded.Personal[email] = personal
+		return u
+	}
+
+	for _, fixture := range f.Users {
+		u := user(fixture.Email)
+		if fixture.Role == "" {
+			continue
+		}
+
+		u, err := r.UpdateUserRole(ctx, u.ID, fixture.Role)
+		if err != nil {
+			t.Fatal(err)
+		}
+		loaded.Users[fixture.Email] = u
+	}
+
+	for _, fixture := range f.Workspaces {
+		workspace, err := r.CreateWorkspace(ctx, fixture.Name, user(fixture.Owner).ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for email, role := range fixture.Members {
+			user(email)
+			if _, err = r.AddWorkspaceMember(ctx, workspace.ID, email, role); err != nil {
+				t.Fatal(err)
+			}
+		}
+		loaded.Workspaces[fixture.Name] = workspace
+	}
+
+	for _, fixture := range f.Lists {
+		owner := user(fixture.Owner)
+
+		workspace := loaded.Personal[fixture.Owner]
+		if fixture.Workspace != "" {
+			var ok bool
+			if workspace, ok = loaded.Workspaces[fixture.Workspace]; !ok {
+				t.Fatalf("list %s is in workspace %s, which is not in the fixtures", fixture.Name, fixture.Workspace)
+			}
+		}
+
+		list, err := r.CreateList(ctx, workspace.ID, owner.ID, fixture.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range fixture.Items {
+			item, err := r.CreateItem(ctx, list.ID, name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			loaded.Items[name] = item
+		}
+		for email, permission := range fixture.Members {
+			user(email)
+			if _, err = r.ShareList(ctx, list.ID, email, permission, owner.ID); err != nil {
+				t.Fatal(err)
+			}
+		}
+		loaded.Lists[fixture.Name] = list
+	}
+
+	return loaded
+}
@@ -0,0 +1,181 @@
+// Package testdb gives each test a Postgres schema of its own, so that database tests can
+// run in parallel and leave nothing behind.
+package testdb
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	_ "github.com/lib/pq"
+	"htmxtodo/db"
+	"htmxtodo/gen/htmxtodo_dev/public/table"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// unqualified makes the generated tables leave out their schema, so that queries use
+// whichever schema the connection's search_path names.
+var unqualified sync.Once
+
+// New creates an empty schema with every migration applied, and returns a connection pool
+// that uses it. The schema is dropped when the test finishes. The test is skipped if
+// DATABASE_URL is unset or the database cannot be reached.
+func New(t *testing.T) *sql.DB {
+	t.Helper()
+
+	conn := newSchema(t)
+	if err := migrate(conn, func(string) bool { return true }); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+// NewBefore creates a schema like New, but with only the migrations before the one named
+// applied, so that a test can load the data that migration has to carry over, before
+// applying it and the rest with MigrateFrom.
+func NewBefore(t *testing.T, migration string) *sql.DB {
+	t.Helper()
+
+	conn := newSchema(t)
+	if err := migrate(conn, func(name string) bool { return name < migration }); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+// MigrateFrom applies the named migration and those after it.
+func MigrateFrom(t *testing.T, conn *sql.DB, migration string) {
+	t.Helper()
+
+	if err := migrate(conn, func(name string) bool { return name >= migration }); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newSchema creates an empty schema, and returns a connection pool that uses it.
+func newSchema(t *testing.T) *sql.DB {
+	t.Helper()
+
+	databaseUrl := os.Getenv("DATABASE_URL")
+	if databaseUrl == "" {
+		t.Skip("DATABASE_URL is not set")
+	}
+
+	admin, err := sql.Open("postgres", databaseUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = admin.Close()
+	})
+	if err = admin.Ping(); err != nil {
+		t.Skip("database is not available: ", err)
+	}
+
+	unqualified.Do(func() {
+		table.UseSchema("")
+	})
+
+	schema := newSchemaName(t)
+	if _, err = admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatal(err)
+	}
+	// registered before the pool's Close, so it runs after it
+	t.Cleanup(func() {
+		if _, err := admin.Exec("DROP SCHEMA " + schema + " CASCADE"); err != nil {
+			t.Errorf("failed to drop schema %s: %v", schema, err)
+		}
+	})
+
+	dsn, err := withSearchPath(databaseUrl, schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+
+	return conn
+}
+
+// newSchemaName returns a name for a test's schema that no other test will use.
+func newSchemaName(t *testing.T) string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	return "test_" + hex.EncodeToString(b)
+}
+
+// withSearchPath returns a connection string that puts connections in a schema. lib/pq
+// sends settings it does not know itself to the server, as run-time parameters.
+func withSearchPath(databaseUrl string, schema string) (string, error) {
+	if !strings.HasPrefix(databaseUrl, "postgres://") && !strings.HasPrefix(databaseUrl, "postgresql://") {
+		return databaseUrl + " search_path=" + schema, nil
+	}
+
+	u, err := url.Parse(databaseUrl)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	query.Set("search_path", schema)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// migrate applies the up section of each migration whose file name include accepts, in
+// order.
+func migrate(conn *sql.DB, include func(name string) bool) error {
+	names, err := fs.Glob(db.Migrations, "migrations/*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !include(path.Base(name)) {
+			continue
+		}
+		b, err := db.Migrations.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		if _, err = conn.Exec(upSection(string(b))); err != nil {
+			return fmt.Errorf("migration %s: %w", path.Base(name), err)
+		}
+	}
+	return nil
+}
+
+// upSection returns the statements between a dbmate migration's "-- migrate:up" and
+// "-- migrate:down" markers.
+func upSection(migration string) string {
+	var up strings.Builder
+	inUp := false
+
+	for _, line := range strings.SplitAfter(migration, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "-- migrate:up"):
+			inUp = true
+		case strings.HasPrefix(trimmed, "-- migrate:down"):
+			inUp = false
+		case inUp:
+			up.WriteString(line)
+		}
+	}
+
+	return up.String()
+}
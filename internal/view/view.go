@@ -5,22 +5,59 @@ import (
 	"github.com/a-h/templ"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/session"
+	"htmxtodo/gen/htmxtodo_dev/public/model"
+	"htmxtodo/internal/constants"
+	"htmxtodo/internal/flash"
+	"htmxtodo/internal/repo"
+	"strings"
 )
 
+const DefaultLocale = "en"
+
 type Renderer struct {
 	SessionStore *session.Store
+	Features     map[string]bool
 }
 
-func (r *Renderer) Globals(c *fiber.Ctx) Globals {
-	sess, err := r.SessionStore.Get(c)
-	if err != nil {
-		panic(err)
+// Globals is everything templates may need to know about the current request. It is built
+// once per render by Renderer and read by components through the accessors in this package.
+type Globals struct {
+	CSRFToken    string
+	CurrentUser  *model.AppUser
+	Impersonator *model.AppUser
+	Workspace    *repo.UserWorkspace
+	Workspaces   func() ([]repo.UserWorkspace, error)
+	Flashes      []flash.Message
+	RequestID    string
+	Locale       string
+	Features     map[string]bool
+}
+
+type globalsKey struct{}
+
+// Globals collects the request state that middleware has stored in locals. Flash messages
+// are only taken from the session for full pages; htmx responses get them from flash.Middleware.
+func (r *Renderer) Globals(c *fiber.Ctx) (Globals, error) {
+	g := Globals{
+		Locale:   locale(c),
+		Features: r.Features,
 	}
 
-	return &globals{
-		ctx:  c,
-		sess: sess,
+	g.CSRFToken, _ = c.Locals(constants.CsrfTokenContextKey).(string)
+	g.CurrentUser, _ = c.Locals(constants.CurrentUserContextKey).(*model.AppUser)
+	g.Impersonator, _ = c.Locals(constants.ImpersonatorContextKey).(*model.AppUser)
+	g.Workspace, _ = c.Locals(constants.WorkspaceContextKey).(*repo.UserWorkspace)
+	g.Workspaces, _ = c.Locals(constants.WorkspacesContextKey).(func() ([]repo.UserWorkspace, error))
+	g.RequestID, _ = c.Locals(constants.RequestIdContextKey).(string)
+
+	if flash.IsFullPage(c) {
+		var err error
+		if g.Flashes, err = flash.Pop(c, r.SessionStore); err != nil {
+			return g, err
+		}
 	}
+
+	return g, nil
 }
 
 // Basic render, no globals in context
@@ -31,25 +68,92 @@ func RenderComponent(c *fiber.Ctx, status int, component templ.Component) error
 
 // Adds globals to context
 func (r *Renderer) RenderComponent(c *fiber.Ctx, status int, component templ.Component) error {
+	g, err := r.Globals(c)
+	if err != nil {
+		return err
+	}
+
 	c.Status(status).Set("Content-Type", "text/html; charset=utf-8")
-	ctx := context.WithValue(c.Context(), "globals", r.Globals(c))
+	ctx := WithGlobals(c.Context(), g)
 	return component.Render(ctx, c)
 }
 
-type Globals interface {
-	CSRFToken() string
-	IsLoggedIn() bool
+func WithGlobals(ctx context.Context, g Globals) context.Context {
+	return context.WithValue(ctx, globalsKey{}, g)
+}
+
+// GetGlobals returns the globals for the current render, or the zero value outside of
+// Renderer.RenderComponent.
+func GetGlobals(ctx context.Context) Globals {
+	g, _ := ctx.Value(globalsKey{}).(Globals)
+	return g
+}
+
+func CSRFToken(ctx context.Context) string {
+	return GetGlobals(ctx).CSRFToken
+}
+
+func CurrentUser(ctx context.Context) *model.AppUser {
+	return GetGlobals(ctx).CurrentUser
+}
+
+func IsLoggedIn(ctx context.Context) bool {
+	return CurrentUser(ctx) != nil
+}
+
+// Impersonator is the admin acting as the current user, or nil.
+func Impersonator(ctx context.Context) *model.AppUser {
+	return GetGlobals(ctx).Impersonator
 }
 
-type globals struct {
-	ctx  *fiber.Ctx
-	sess *session.Session
+func IsAdmin(ctx context.Context) bool {
+	user := CurrentUser(ctx)
+	return user != nil && repo.HasRole(*user, repo.RoleAdmin)
 }
 
-func (g *globals) CSRFToken() string {
-	panic("not implemented")
+// Workspace is the workspace the current user is working in, or nil outside of /app.
+func Workspace(ctx context.Context) *repo.UserWorkspace {
+	return GetGlobals(ctx).Workspace
 }
 
-func (g *globals) IsLoggedIn() bool {
-	return g.sess.Get("logged_in") == "true"
+// Workspaces loads all the workspaces the current user can switch to. Only pages showing
+// the switcher need them, so they are not loaded until then.
+func Workspaces(ctx context.Context) ([]repo.UserWorkspace, error) {
+	load := GetGlobals(ctx).Workspaces
+	if load == nil {
+		return nil, nil
+	}
+	return load()
+}
+
+func Flashes(ctx context.Context) []flash.Message {
+	return GetGlobals(ctx).Flashes
+}
+
+func RequestID(ctx context.Context) string {
+	return GetGlobals(ctx).RequestID
+}
+
+func Locale(ctx context.Context) string {
+	if l := GetGlobals(ctx).Locale; l != "" {
+		return l
+	}
+	return DefaultLocale
+}
+
+func FeatureEnabled(ctx context.Context, name string) bool {
+	return GetGlobals(ctx).Features[name]
+}
+
+// locale picks the user's preferred language from Accept-Language. There are no
+// translations yet, so this only affects the lang attribute.
+func locale(c *fiber.Ctx) string {
+	preferred, _, _ := strings.Cut(c.Get(fiber.HeaderAcceptLanguage), ",")
+	preferred, _, _ = strings.Cut(preferred, ";")
+	preferred = strings.TrimSpace(preferred)
+
+	if preferred == "" || preferred == "*" {
+		return DefaultLocale
+	}
+	return preferred
 }
@@ -1,11 +1,20 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"embed"
+	"errors"
+	"flag"
 	"github.com/joho/godotenv"
 	"htmxtodo/internal/app"
+	"htmxtodo/internal/cli"
 	"htmxtodo/internal/config"
+	"htmxtodo/internal/database"
+	"htmxtodo/internal/jobs"
+	"htmxtodo/internal/repo"
+	"htmxtodo/internal/secrets"
+	"io/fs"
 	"log"
 	"os"
 )
@@ -14,14 +23,38 @@ import (
 var staticEmbedFS embed.FS
 
 func main() {
-	err := godotenv.Load()
-	if err != nil {
+	// the .env file is optional, as deployments usually set the environment directly
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
 		log.Fatalf("Error loading .env file: %v", err)
 	}
 
-	db, err := sql.Open("postgres", os.Getenv("DATABASE_URL"))
+	flags := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	printConfig := flags.Bool("print-config", false, "print the effective config, with secrets redacted, and exit")
+	settings, err := config.Load(flags, os.Args[1:], os.Getenv)
+	if err != nil {
+		log.Fatalf("invalid config:\n%v", err)
+	}
+	if *printConfig {
+		if err = settings.Print(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	store, err := config.NewSecrets(ctx, settings)
+	if err != nil {
+		log.Fatalf("failed to load secrets:\n%v", err)
+	}
+	if settings.SecretsRefresh > 0 {
+		go store.Run(ctx, settings.SecretsRefresh)
+	}
+
+	db, err := database.Open(store, secrets.DatabaseUrlName, settings.DatabaseConfig())
 	if err != nil {
-		log.Fatalf("failed to open db: %v", err)
+		log.Fatal(err)
 	}
 	defer func(db *sql.DB) {
 		err := db.Close()
@@ -30,7 +63,24 @@ func main() {
 		}
 	}(db)
 
-	cfg := config.NewConfigFromEnvironment(db, &staticEmbedFS)
+	var replica *sql.DB
+	if store.DatabaseReplicaUrl() != "" {
+		if replica, err = database.Open(store, secrets.DatabaseReplicaUrlName, settings.DatabaseConfig()); err != nil {
+			log.Fatal(err)
+		}
+		defer replica.Close()
+	}
+
+	if args := flags.Args(); len(args) > 0 {
+		if err = cli.Run(ctx, repo.New(db, nil), os.Stdout, args); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	cfg := config.New(settings, store, db, replica, &staticEmbedFS)
+
+	go jobs.PurgeTrash(ctx, cfg.Repo, cfg.TrashRetention, jobs.PurgeInterval)
 
 	a := app.New(cfg)
 
@@ -18,6 +18,29 @@ function getCookie(c_name) {
 	return c_value;
 }
 
+function flashNotification(message) {
+	const notification = document.createElement('div');
+	notification.className = 'notification ' + (message.level === 'error' ? 'is-danger' : 'is-' + message.level);
+
+	const dismiss = document.createElement('button');
+	dismiss.type = 'button';
+	dismiss.className = 'delete';
+	dismiss.setAttribute('aria-label', 'Dismiss');
+	notification.appendChild(dismiss);
+	notification.appendChild(document.createTextNode(message.text));
+
+	if (message.undo) {
+		const undo = document.createElement('button');
+		undo.type = 'button';
+		undo.className = 'button is-small is-light ml-3 undo';
+		undo.setAttribute('hx-post', message.undo);
+		undo.textContent = 'Undo';
+		notification.appendChild(undo);
+	}
+
+	return notification;
+}
+
 document.addEventListener('DOMContentLoaded', function () {
 	// add X-CSRF-Token to all non-GET requests:
 	document.body.addEventListener('htmx:configRequest', function (event) {
@@ -26,10 +49,38 @@ document.addEventListener('DOMContentLoaded', function () {
 		}
 	});
 
-	// treat 204 "no content" same as 200 "success":
+	// show flash messages delivered with htmx responses (see internal/flash):
+	document.body.addEventListener('flash', function (event) {
+		const container = document.getElementById('flashes');
+		for (const message of event.detail.messages) {
+			const notification = flashNotification(message);
+			container.appendChild(notification);
+			htmx.process(notification);
+			// leave time to change your mind about what can be undone
+			setTimeout(() => notification.remove(), message.undo ? 10000 : 5000);
+		}
+	});
+
+	// dismiss flash messages:
+	document.body.addEventListener('click', function (event) {
+		if (event.target.matches('.notification > .delete')) {
+			event.target.parentElement.remove();
+		}
+	});
+
+	// dismiss flash messages once what they report has been undone:
+	document.body.addEventListener('htmx:afterRequest', function (event) {
+		if (event.detail.successful && event.target.matches('.notification > .undo')) {
+			event.target.parentElement.remove();
+		}
+	});
+
+	// treat 204 "no content" same as 200 "success", and show forms returned with validation
+	// errors (422) or edit conflicts (409), and boosted pages saying to slow down (429);
+	// other htmx requests are told to slow down with a flash message:
 	document.body.addEventListener('htmx:beforeSwap', function (event) {
 		const status = event.detail.xhr.status;
-		if (status === 204 || status === 422) {
+		if (status === 204 || status === 409 || status === 422 || (status === 429 && event.detail.boosted)) {
 			event.detail.shouldSwap = true;
 		}
 	});
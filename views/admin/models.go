@@ -0,0 +1,70 @@
+package admin
+
+import (
+	"fmt"
+	"htmxtodo/gen/htmxtodo_dev/public/model"
+	"htmxtodo/internal/repo"
+)
+
+// UsersTableId is the element replaced by the user directory search.
+const UsersTableId = "users-table"
+
+func userRowId(user model.AppUser) string {
+	return fmt.Sprintf("user-%d", user.ID)
+}
+
+func userActionUrl(user model.AppUser, action string) string {
+	return fmt.Sprintf("/admin/users/%d/%s", user.ID, action)
+}
+
+// AuditTableId is the element replaced by the audit log filters.
+const AuditTableId = "audit-events"
+
+// AuditFilters are the audit log's filter form.
+type AuditFilters struct {
+	Actor  string `query:"actor"`
+	Action string `query:"action"`
+	Entity string `query:"entity"`
+	ListID int64  `query:"list"`
+	From   string `query:"from"`
+	To     string `query:"to"`
+}
+
+var entityOptions = []struct {
+	Value string
+	Label string
+}{
+	{"", "Anything"},
+	{repo.EntityList, "Lists"},
+	{repo.EntityItem, "Items"},
+	{repo.EntityTag, "Tags"},
+	{repo.EntityWorkspace, "Workspaces"},
+	{repo.EntityUser, "Users"},
+}
+
+func actorLabel(event repo.AuditEntry) string {
+	if event.ActorID == nil {
+		return "system"
+	}
+	if event.ActorEmail != nil {
+		return *event.ActorEmail
+	}
+	return fmt.Sprintf("user #%d", *event.ActorID)
+}
+
+func entityLabel(event repo.AuditEntry) string {
+	return fmt.Sprintf("%s #%d", event.EntityType, event.EntityID)
+}
+
+// listAuditUrl is the audit log of everything that happened to a list.
+func listAuditUrl(listId int64) string {
+	return fmt.Sprintf("/admin/audit?list=%d", listId)
+}
+
+// optional shows a nullable column, as nothing if it is NULL.
+func optional(column *string) string {
+	if column == nil {
+		return ""
+	}
+	return *column
+}
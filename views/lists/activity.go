@@ -0,0 +1,87 @@
+package lists
+
+import (
+	"encoding/json"
+	"fmt"
+	"htmxtodo/internal/repo"
+)
+
+// activityLabels describe the actions recorded on a list, as done by whoever did them.
+var activityLabels = map[string]string{
+	"list.create":       "created the list",
+	"list.update":       "renamed the list",
+	"list.delete":       "moved the list to the trash",
+	"list.restore":      "restored the list",
+	"list.transfer":     "transferred the list",
+	"list.share":        "shared the list",
+	"list.share.accept": "accepted an invitation",
+	"list.unshare":      "stopped sharing the list",
+	"list.invite":       "invited someone to the list",
+	"list.uninvite":     "withdrew an invitation",
+	"list.link_create":  "created a public link",
+	"list.link_revoke":  "revoked a public link",
+	"list.tag":          "changed the tags",
+	"item.create":       "added an item",
+	"item.update":       "renamed an item",
+	"item.delete":       "deleted an item",
+	"item.restore":      "restored an item",
+}
+
+func (c CardProps) ActivityUrl() string {
+	return fmt.Sprintf("/app/lists/%d/activity", c.List.ID)
+}
+
+func (c CardProps) ActivityId() string {
+	return fmt.Sprintf("activity-%d", c.List.ID)
+}
+
+func activityActor(entry repo.AuditEntry) string {
+	if entry.ActorEmail == nil {
+		return "Someone"
+	}
+	return *entry.ActorEmail
+}
+
+func activityLabel(entry repo.AuditEntry) string {
+	if label, ok := activityLabels[entry.Action]; ok {
+		return label
+	}
+	return entry.Action
+}
+
+// activityDetail picks out what changed for people to read, such as a new name or who a
+// list was shared with, from an entry's before and after states. Who a list is shared with
+// is only shown to its owners, as on the sharing page.
+func activityDetail(entry repo.AuditEntry, showMembers bool) string {
+	before, after := auditState(entry.Before), auditState(entry.After)
+
+	if after["name"] != nil && before["name"] != nil && after["name"] != before["name"] {
+		return fmt.Sprintf("“%v” → “%v”", before["name"], after["name"])
+	}
+	for _, state := range []map[string]any{after, before} {
+		if email, ok := state["email"].(string); ok {
+			if !showMembers {
+				return ""
+			}
+			if permission, ok := state["permission"].(string); ok {
+				return fmt.Sprintf("%s (%s)", email, permission)
+			}
+			return email
+		}
+		if name, ok := state["name"].(string); ok {
+			return name
+		}
+	}
+
+	return ""
+}
+
+// auditState decodes a state recorded in the audit log. States that are not objects, such
+// as lists of tag IDs, decode as empty.
+func auditState(column *string) map[string]any {
+	state := make(map[string]any)
+	if column != nil {
+		_ = json.Unmarshal([]byte(*column), &state)
+	}
+	return state
+}
@@ -3,11 +3,32 @@ package lists
 import (
 	"fmt"
 	"htmxtodo/gen/htmxtodo_dev/public/model"
+	"htmxtodo/internal/repo"
 )
 
+// ListsId is the element holding the workspace's list cards.
+const ListsId = "lists"
+
 type CardProps struct {
 	model.List
 	EditingName bool
+	// ConflictName is the name the user tried to save when someone else had renamed the list
+	// since they started editing it. The list holds the other person's name.
+	ConflictName string
+	// Snippet is the part of the list that matched the current search, if any.
+	Snippet string
+	// Permission is the current user's permission on the list.
+	Permission string
+	// SharedBy is the owner's email, for lists shared with the current user.
+	SharedBy string
+	// ShareLink is the list's public link, if it has one and the current user owns it.
+	ShareLink    *model.ShareLink
+	ShareLinkUrl string
+	// ReadOnly cards are shown through public links, and have no actions at all.
+	ReadOnly bool
+	Tags     []model.Tag
+	// TagOptions are the tags in the list's workspace, which editors may put on it.
+	TagOptions []model.Tag
 }
 
 func (c CardProps) ListUrl() string {
@@ -25,3 +46,45 @@ func (c CardProps) Id() string {
 func (c CardProps) Selector() string {
 	return fmt.Sprintf("#card-%d", c.List.ID)
 }
+
+func (c CardProps) SharingUrl() string {
+	return fmt.Sprintf("/app/lists/%d/sharing", c.List.ID)
+}
+
+func (c CardProps) ShareLinkActionUrl() string {
+	return fmt.Sprintf("/app/lists/%d/share-link", c.List.ID)
+}
+
+func (c CardProps) TagsUrl() string {
+	return fmt.Sprintf("/app/lists/%d/tags", c.List.ID)
+}
+
+func (c CardProps) HasTag(tag model.Tag) bool {
+	for _, t := range c.Tags {
+		if t.ID == tag.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// EditName is the name the edit form starts with.
+func (c CardProps) EditName() string {
+	if c.ConflictName != "" {
+		return c.ConflictName
+	}
+	return c.List.Name
+}
+
+func (c CardProps) CanEdit() bool {
+	return !c.ReadOnly && repo.PermissionAllows(c.Permission, repo.PermissionEditor)
+}
+
+func (c CardProps) IsOwner() bool {
+	return !c.ReadOnly && c.Permission == repo.PermissionOwner
+}
+
+// SharedListUrl is the path of the public page for a share link token.
+func SharedListUrl(token string) string {
+	return "/s/" + token
+}
@@ -0,0 +1,79 @@
+package lists
+
+import (
+	"htmxtodo/internal/repo"
+	"net/url"
+	"strconv"
+)
+
+// MoreListsId is the element that loads the next page of lists when it scrolls into view.
+const MoreListsId = "more-lists"
+
+// Filters are the search, filter and sort options on the lists page, as query parameters.
+// Dates are YYYY-MM-DD, and the "to" dates are inclusive.
+type Filters struct {
+	Search      string `query:"q"`
+	Name        string `query:"name"`
+	CreatedFrom string `query:"created_from"`
+	CreatedTo   string `query:"created_to"`
+	UpdatedFrom string `query:"updated_from"`
+	UpdatedTo   string `query:"updated_to"`
+	Incomplete  bool   `query:"incomplete"`
+	Tag         int64  `query:"tag"`
+	Sort        string `query:"sort"`
+	After       string `query:"after"`
+}
+
+// PageUrl is the URL of the page of lists after a cursor, with the same filters.
+func (f Filters) PageUrl(after string) string {
+	values := url.Values{}
+	set := func(key string, value string) {
+		if value != "" {
+			values.Set(key, value)
+		}
+	}
+
+	set("q", f.Search)
+	set("name", f.Name)
+	set("created_from", f.CreatedFrom)
+	set("created_to", f.CreatedTo)
+	set("updated_from", f.UpdatedFrom)
+	set("updated_to", f.UpdatedTo)
+	if f.Incomplete {
+		values.Set("incomplete", "true")
+	}
+	if f.Tag != 0 {
+		values.Set("tag", strconv.FormatInt(f.Tag, 10))
+	}
+	set("sort", f.Sort)
+	set("after", after)
+
+	return "/app/lists?" + values.Encode()
+}
+
+// IsFiltered reports whether any lists may be hidden by the filters.
+func (f Filters) IsFiltered() bool {
+	return f.Search != "" || f.Name != "" || f.CreatedFrom != "" || f.CreatedTo != "" ||
+		f.UpdatedFrom != "" || f.UpdatedTo != "" || f.Incomplete || f.Tag != 0
+}
+
+// tagFilterUrl is the URL of the lists with a tag, or of all lists if tagId is 0.
+func tagFilterUrl(tagId int64) string {
+	if tagId == 0 {
+		return "/app/lists"
+	}
+	return "/app/lists?tag=" + strconv.FormatInt(tagId, 10)
+}
+
+type sortOption struct {
+	Value string
+	Label string
+}
+
+var sortOptions = []sortOption{
+	{"", "Best match, or name"},
+	{repo.ListSortName, "Name"},
+	{repo.ListSortCreated, "Newest"},
+	{repo.ListSortUpdated, "Recently updated"},
+	{repo.ListSortCustom, "Custom order"},
+}
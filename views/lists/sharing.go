@@ -0,0 +1,36 @@
+package lists
+
+import (
+	"fmt"
+	"htmxtodo/gen/htmxtodo_dev/public/model"
+	"htmxtodo/internal/repo"
+)
+
+type ShareForm struct {
+	Email      string `form:"email"`
+	Permission string `form:"permission"`
+}
+
+type SharingProps struct {
+	List        model.List
+	Members     []repo.Member
+	Invitations []model.ListInvitation
+	Form        ShareForm
+	Error       string
+}
+
+func (p SharingProps) MembersUrl() string {
+	return fmt.Sprintf("/app/lists/%d/members", p.List.ID)
+}
+
+func (p SharingProps) MemberUrl(member repo.Member) string {
+	return fmt.Sprintf("/app/lists/%d/members/%d", p.List.ID, member.UserID)
+}
+
+func (p SharingProps) InvitationUrl(invitation model.ListInvitation) string {
+	return fmt.Sprintf("/app/lists/%d/invitations/%d", p.List.ID, invitation.ID)
+}
+
+func (p SharingProps) OwnerUrl() string {
+	return fmt.Sprintf("/app/lists/%d/owner", p.List.ID)
+}
@@ -7,6 +7,7 @@ type RegistrationForm struct {
 }
 
 type LoginForm struct {
-	Email    string `form:"email"`
-	Password string `form:"password"`
+	Email      string `form:"email"`
+	Password   string `form:"password"`
+	RememberMe bool   `form:"remember_me"`
 }
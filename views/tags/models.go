@@ -0,0 +1,41 @@
+package tags
+
+import (
+	"fmt"
+	"htmxtodo/gen/htmxtodo_dev/public/model"
+	"htmxtodo/internal/repo"
+)
+
+// DefaultColor is the color the new tag form starts with.
+const DefaultColor = "#3273dc"
+
+type TagForm struct {
+	Name  string `form:"name"`
+	Color string `form:"color"`
+}
+
+type MergeForm struct {
+	IntoID int64 `form:"into_id"`
+}
+
+type IndexProps struct {
+	Tags  []repo.TagCount
+	Form  TagForm
+	Error string
+}
+
+func tagUrl(tag model.Tag) string {
+	return fmt.Sprintf("/app/tags/%d", tag.ID)
+}
+
+func mergeUrl(tag model.Tag) string {
+	return fmt.Sprintf("/app/tags/%d/merge", tag.ID)
+}
+
+func rowId(tag model.Tag) string {
+	return fmt.Sprintf("tag-%d", tag.ID)
+}
+
+func listsUrl(tag model.Tag) string {
+	return fmt.Sprintf("/app/lists?tag=%d", tag.ID)
+}
@@ -0,0 +1,36 @@
+package trash
+
+import (
+	"fmt"
+	"htmxtodo/gen/htmxtodo_dev/public/model"
+	"strings"
+)
+
+// rowIdPrefix starts the IDs of the rows on the trash page.
+const rowIdPrefix = "trash-list-"
+
+type IndexProps struct {
+	Lists []model.List
+	// RetentionDays is how long lists stay in the trash before they are purged.
+	RetentionDays int
+}
+
+// IsRow reports whether an htmx target is a row on the trash page, rather than somewhere
+// the list can be restored to.
+func IsRow(target string) bool {
+	return strings.HasPrefix(target, rowIdPrefix)
+}
+
+// RestoreUrl is where lists in the trash are restored from, both on the trash page and by
+// the Undo button after deleting them.
+func RestoreUrl(list model.List) string {
+	return fmt.Sprintf("/app/lists/%d/restore", list.ID)
+}
+
+func purgeUrl(list model.List) string {
+	return fmt.Sprintf("/app/trash/%d", list.ID)
+}
+
+func rowId(list model.List) string {
+	return fmt.Sprintf("%s%d", rowIdPrefix, list.ID)
+}
@@ -0,0 +1,35 @@
+package workspaces
+
+import (
+	"fmt"
+	"htmxtodo/internal/repo"
+)
+
+type WorkspaceForm struct {
+	Name string `form:"name"`
+}
+
+type MemberForm struct {
+	Email string `form:"email"`
+	Role  string `form:"role"`
+}
+
+type SettingsProps struct {
+	Workspace repo.UserWorkspace
+	Members   []repo.WorkspaceUser
+	Form      MemberForm
+	Error     string
+}
+
+func (p SettingsProps) MemberUrl(member repo.WorkspaceUser) string {
+	return fmt.Sprintf("/app/workspace/members/%d", member.UserID)
+}
+
+// CanRemove reports whether the current user may remove a member: the owner may remove
+// anyone else, admins may remove members, and anyone but the owner may leave.
+func (p SettingsProps) CanRemove(member repo.WorkspaceUser, currentUserId int64) bool {
+	if p.Workspace.IsPersonal() || member.Role == repo.WorkspaceRoleOwner {
+		return false
+	}
+	return p.Workspace.CanManageRole(member.Role) || member.UserID == currentUserId
+}